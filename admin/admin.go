@@ -0,0 +1,213 @@
+// Package admin implements the operator actions on service instances which are
+// shared by the broker's admin API and brokerctl.
+package admin
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/catalog"
+	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/helpers/response"
+	"github.com/18F/aws-broker/services"
+	"github.com/18F/aws-broker/taskqueue"
+	"github.com/jinzhu/gorm"
+)
+
+// InstanceSummary is the operator-facing summary of a service instance.
+type InstanceSummary struct {
+	InstanceGUID     string    `json:"instance_guid"`
+	Service          string    `json:"service"`
+	ServiceID        string    `json:"service_id"`
+	Plan             string    `json:"plan"`
+	PlanID           string    `json:"plan_id"`
+	OrganizationGUID string    `json:"organization_guid"`
+	SpaceGUID        string    `json:"space_guid"`
+	State            string    `json:"state"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// InstanceDetail merges the summary of an instance with the service specific
+// record and the state of its AWS resource.
+type InstanceDetail struct {
+	InstanceSummary
+	base.InstanceDetail
+}
+
+// NewInstanceSummary summarizes the instance, naming its service and plan.
+func NewInstanceSummary(c *catalog.Catalog, i base.Instance) InstanceSummary {
+	serviceName, planName := c.LookupNames(i.ServiceID, i.PlanID)
+	return InstanceSummary{
+		InstanceGUID:     i.Uuid,
+		Service:          serviceName,
+		ServiceID:        i.ServiceID,
+		Plan:             planName,
+		PlanID:           i.PlanID,
+		OrganizationGUID: i.OrganizationGUID,
+		SpaceGUID:        i.SpaceGUID,
+		State:            i.State.String(),
+		CreatedAt:        i.CreatedAt,
+		UpdatedAt:        i.UpdatedAt,
+	}
+}
+
+// ListInstances returns the instances of every service that match the filter,
+// oldest first.
+func ListInstances(c *catalog.Catalog, brokerDb *gorm.DB, filter base.InstanceFilter) ([]InstanceSummary, error) {
+	var instances []base.Instance
+	for _, model := range services.InstanceModels {
+		found, err := base.FindInstances(brokerDb, brokerDb.NewScope(model).TableName(), filter)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, found...)
+	}
+	sort.SliceStable(instances, func(i, j int) bool {
+		return instances[i].CreatedAt.Before(instances[j].CreatedAt)
+	})
+
+	summaries := make([]InstanceSummary, 0, len(instances))
+	for _, instance := range instances {
+		summaries = append(summaries, NewInstanceSummary(c, instance))
+	}
+	return summaries, nil
+}
+
+// DescribeInstance returns the broker's record of the instance, without
+// credentials, along with the current state of its AWS resource.
+func DescribeInstance(ctx context.Context, c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager, logger lager.Logger) (InstanceDetail, response.Response) {
+	instance, broker, resp := findInstanceBroker(c, brokerDb, id, settings, taskqueue, logger)
+	if resp != nil {
+		return InstanceDetail{}, resp
+	}
+
+	detail, resp := broker.DescribeInstance(ctx, c, id, instance)
+	if resp != nil {
+		return InstanceDetail{}, resp
+	}
+	return InstanceDetail{NewInstanceSummary(c, findServiceInstance(brokerDb, instance)), detail}, nil
+}
+
+// SetInstanceState forces the state of the instance, e.g. to recover an
+// instance that is stuck in progress.
+func SetInstanceState(ctx context.Context, c *catalog.Catalog, brokerDb *gorm.DB, id string, state base.InstanceState, settings *config.Settings, taskqueue *taskqueue.QueueManager, logger lager.Logger) (InstanceSummary, response.Response) {
+	instance, broker, resp := findInstanceBroker(c, brokerDb, id, settings, taskqueue, logger)
+	if resp != nil {
+		return InstanceSummary{}, resp
+	}
+
+	previousState := findServiceInstance(brokerDb, instance).State
+	if resp := broker.SetInstanceState(ctx, c, id, instance, state); resp != nil {
+		return InstanceSummary{}, resp
+	}
+	if err := brokerDb.Model(&instance).Update("state", state).Error; err != nil {
+		logger.Error("save-base-instance", err)
+		return InstanceSummary{}, response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+
+	logger.Info("set-instance-state", lager.Data{
+		"previous-state": previousState.String(),
+		"state":          state.String(),
+	})
+	return NewInstanceSummary(c, instance), nil
+}
+
+// InstanceCredentials decrypts the credentials of the instance. A reason is
+// required, and is logged along with the access so that it can be audited.
+func InstanceCredentials(ctx context.Context, c *catalog.Catalog, brokerDb *gorm.DB, id string, reason string, settings *config.Settings, taskqueue *taskqueue.QueueManager, logger lager.Logger) (map[string]string, response.Response) {
+	if reason == "" {
+		return nil, response.NewErrorResponse(http.StatusBadRequest, "A reason is required to access the credentials of an instance.")
+	}
+	instance, broker, resp := findInstanceBroker(c, brokerDb, id, settings, taskqueue, logger)
+	if resp != nil {
+		return nil, resp
+	}
+
+	logger.Info("decrypt-credentials", lager.Data{"reason": reason})
+	return broker.InstanceCredentials(ctx, c, id, instance)
+}
+
+// CreateSnapshot takes a manual snapshot of the instance with the given name.
+func CreateSnapshot(ctx context.Context, c *catalog.Catalog, brokerDb *gorm.DB, id string, name string, settings *config.Settings, taskqueue *taskqueue.QueueManager, logger lager.Logger) (base.Snapshot, response.Response) {
+	instance, broker, resp := findInstanceBroker(c, brokerDb, id, settings, taskqueue, logger)
+	if resp != nil {
+		return base.Snapshot{}, resp
+	}
+
+	snapshot, resp := broker.CreateSnapshot(ctx, c, id, instance, name)
+	if resp != nil {
+		return base.Snapshot{}, resp
+	}
+	logger.Info("create-snapshot", lager.Data{"snapshot": snapshot.Name})
+	return snapshot, nil
+}
+
+// ListSnapshots lists the snapshots of the instance.
+func ListSnapshots(ctx context.Context, c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager, logger lager.Logger) ([]base.Snapshot, response.Response) {
+	instance, broker, resp := findInstanceBroker(c, brokerDb, id, settings, taskqueue, logger)
+	if resp != nil {
+		return nil, resp
+	}
+	return broker.ListSnapshots(ctx, c, id, instance)
+}
+
+// PurgeInstance removes the broker's records of an instance whose AWS resource
+// no longer exists. Instances whose resource still exists are left alone.
+func PurgeInstance(ctx context.Context, c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager, logger lager.Logger) response.Response {
+	instance, broker, resp := findInstanceBroker(c, brokerDb, id, settings, taskqueue, logger)
+	if resp != nil {
+		return resp
+	}
+
+	detail, resp := broker.DescribeInstance(ctx, c, id, instance)
+	if resp != nil {
+		return resp
+	}
+	if !detail.ResourceGone {
+		return response.NewErrorResponse(http.StatusConflict, "The AWS resource of the instance still exists.")
+	}
+
+	for _, model := range services.InstanceModels {
+		if err := brokerDb.Where("uuid = ?", id).Delete(model).Error; err != nil {
+			logger.Error("purge-service-instance", err)
+			return response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+		}
+	}
+	if err := brokerDb.Unscoped().Delete(&instance).Error; err != nil {
+		logger.Error("purge-base-instance", err)
+		return response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+
+	logger.Info("purge-instance", lager.Data{"aws-error": detail.AWSError})
+	return nil
+}
+
+func findInstanceBroker(c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager, logger lager.Logger) (base.Instance, base.Broker, response.Response) {
+	instance, resp := base.FindBaseInstance(brokerDb, id)
+	if resp != nil {
+		return instance, nil, resp
+	}
+	broker, resp := services.FindBroker(instance.ServiceID, c, brokerDb, settings, taskqueue, logger)
+	if resp != nil {
+		return instance, nil, resp
+	}
+	return instance, broker, nil
+}
+
+// findServiceInstance returns the instance as recorded by its service, falling
+// back to the base instance if no service has a record of it.
+func findServiceInstance(brokerDb *gorm.DB, instance base.Instance) base.Instance {
+	for _, model := range services.InstanceModels {
+		var serviceInstance base.Instance
+		table := brokerDb.NewScope(model).TableName()
+		if brokerDb.Table(table).Where("uuid = ?", instance.Uuid).First(&serviceInstance).Error == nil {
+			return serviceInstance
+		}
+	}
+	return instance
+}
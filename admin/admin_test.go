@@ -0,0 +1,121 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/catalog"
+	"github.com/18F/aws-broker/common"
+	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/db"
+	"github.com/18F/aws-broker/helpers/request"
+	"github.com/18F/aws-broker/services"
+	"github.com/18F/aws-broker/taskqueue"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+var rdsRequest = request.Request{
+	ServiceID:        "db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+	PlanID:           "da91e15c-98c9-46a9-b114-02b8d28062c6",
+	OrganizationGUID: "an-org",
+	SpaceGUID:        "a-space",
+}
+
+func setup(t *testing.T) (*catalog.Catalog, *gorm.DB, *config.Settings) {
+	brokerDb, err := db.InternalDBInit(&common.DBConfig{DbType: "sqlite3", DbName: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrate(brokerDb, lagertest.NewTestLogger("admin-test")); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := &config.Settings{
+		EncryptionKey:       "12345678901234567890123456789012",
+		Environment:         "test",
+		MaxAllocatedStorage: 1024,
+	}
+	return catalog.InitCatalog(filepath.Join(wd, ".."), lagertest.NewTestLogger("admin-test")), brokerDb, settings
+}
+
+func createRDSInstance(t *testing.T, c *catalog.Catalog, brokerDb *gorm.DB, settings *config.Settings) string {
+	id := uuid.NewString()
+	broker, resp := services.FindBroker(rdsRequest.ServiceID, c, brokerDb, settings, taskqueue.NewQueueManager(), lagertest.NewTestLogger("admin-test"))
+	if resp != nil {
+		t.Fatal("unable to find broker", resp.GetStatusCode())
+	}
+	if resp := broker.CreateInstance(context.Background(), c, id, rdsRequest); resp.GetStatusCode() != http.StatusAccepted {
+		t.Fatal("unable to create instance", resp.GetStatusCode())
+	}
+	if err := brokerDb.Create(&base.Instance{Uuid: id, Request: rdsRequest}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestInstanceCredentials(t *testing.T) {
+	c, brokerDb, settings := setup(t)
+	id := createRDSInstance(t, c, brokerDb, settings)
+	logger := lagertest.NewTestLogger("admin-test")
+
+	_, resp := InstanceCredentials(context.Background(), c, brokerDb, id, "", settings, taskqueue.NewQueueManager(), logger)
+	if resp == nil || resp.GetStatusCode() != http.StatusBadRequest {
+		t.Fatal("expected credentials to require a reason")
+	}
+
+	credentials, resp := InstanceCredentials(context.Background(), c, brokerDb, id, "TICKET-1", settings, taskqueue.NewQueueManager(), logger)
+	if resp != nil {
+		t.Fatal("unable to get credentials", resp.GetStatusCode())
+	}
+	if credentials["password"] == "" || credentials["uri"] == "" {
+		t.Errorf("expected decrypted credentials, got %v", credentials)
+	}
+	if len(logger.LogMessages()) == 0 || logger.Logs()[len(logger.Logs())-1].Data["reason"] != "TICKET-1" {
+		t.Error("expected the reason to be logged")
+	}
+}
+
+func TestSetInstanceState(t *testing.T) {
+	c, brokerDb, settings := setup(t)
+	id := createRDSInstance(t, c, brokerDb, settings)
+	logger := lagertest.NewTestLogger("admin-test")
+
+	summary, resp := SetInstanceState(context.Background(), c, brokerDb, id, base.InstanceNotCreated, settings, taskqueue.NewQueueManager(), logger)
+	if resp != nil {
+		t.Fatal("unable to set state", resp.GetStatusCode())
+	}
+	if summary.State != base.InstanceNotCreated.String() {
+		t.Errorf("unexpected state %s", summary.State)
+	}
+
+	notCreated := base.InstanceNotCreated
+	instances, err := ListInstances(c, brokerDb, base.InstanceFilter{State: &notCreated})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 1 || instances[0].InstanceGUID != id || instances[0].Service != "rds" {
+		t.Errorf("expected the instance to be listed in its new state, got %v", instances)
+	}
+}
+
+func TestPurgeInstanceWithResource(t *testing.T) {
+	c, brokerDb, settings := setup(t)
+	id := createRDSInstance(t, c, brokerDb, settings)
+
+	resp := PurgeInstance(context.Background(), c, brokerDb, id, settings, taskqueue.NewQueueManager(), lagertest.NewTestLogger("admin-test"))
+	if resp == nil || resp.GetStatusCode() != http.StatusConflict {
+		t.Fatal("expected an instance with an AWS resource not to be purged")
+	}
+	if _, resp := base.FindBaseInstance(brokerDb, id); resp != nil {
+		t.Error("expected the instance to be kept")
+	}
+}
@@ -0,0 +1,221 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/catalog"
+	"github.com/18F/aws-broker/common"
+	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/helpers/logging"
+	"github.com/18F/aws-broker/helpers/response"
+	"github.com/18F/aws-broker/taskqueue"
+	"github.com/jinzhu/gorm"
+)
+
+// delayedDeletionSchedule is how often instances whose deletion delay has
+// passed are looked for.
+const delayedDeletionSchedule = "*/15 * * * *"
+
+// delayedDeletionTaskID is the tag of the scheduled deletion of pending
+// instances in the task queue.
+const delayedDeletionTaskID = "delayed-deletion"
+
+// PendingDeletionSummary is the operator-facing summary of an instance that is
+// pending deletion.
+type PendingDeletionSummary struct {
+	InstanceSummary
+	DeleteAt        time.Time `json:"delete_at"`
+	DeletionStarted bool      `json:"deletion_started"`
+}
+
+// ListPendingDeletions returns the instances that are pending deletion, the
+// soonest to be deleted first.
+func ListPendingDeletions(c *catalog.Catalog, brokerDb *gorm.DB) ([]PendingDeletionSummary, error) {
+	var deletions []base.PendingDeletion
+	if err := brokerDb.Order("delete_at").Find(&deletions).Error; err != nil {
+		return nil, err
+	}
+
+	summaries := make([]PendingDeletionSummary, 0, len(deletions))
+	for _, deletion := range deletions {
+		summary := InstanceSummary{InstanceGUID: deletion.InstanceGUID}
+		if instance, resp := base.FindBaseInstance(brokerDb, deletion.InstanceGUID); resp == nil {
+			summary = NewInstanceSummary(c, findServiceInstance(brokerDb, instance))
+		}
+		summaries = append(summaries, PendingDeletionSummary{
+			InstanceSummary: summary,
+			DeleteAt:        deletion.DeleteAt,
+			DeletionStarted: deletion.DeletionStarted,
+		})
+	}
+	return summaries, nil
+}
+
+// RestoreInstance cancels the pending deletion of an instance and starts it
+// again. The instance is no longer known to Cloud Foundry, so it has to be
+// registered again, e.g. by cloning it with clone_from, and is only deleted
+// once an operator calls DeleteInstance.
+func RestoreInstance(ctx context.Context, c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager, logger lager.Logger) response.Response {
+	deletion, err := base.FindPendingDeletion(brokerDb, id)
+	if err != nil {
+		logger.Error("find-pending-deletion", err)
+		return response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+	if deletion == nil {
+		return response.NewErrorResponse(http.StatusNotFound, "The instance is not pending deletion.")
+	}
+	if deletion.DeletionStarted {
+		return response.NewErrorResponse(http.StatusConflict, "The deletion of the instance has already started.")
+	}
+	instance, broker, resp := findInstanceBroker(c, brokerDb, id, settings, taskqueue, logger)
+	if resp != nil {
+		return resp
+	}
+
+	if resp := broker.ResumeInstance(ctx, c, id, instance); resp != nil {
+		return resp
+	}
+	if err := brokerDb.Delete(deletion).Error; err != nil {
+		logger.Error("delete-pending-deletion", err)
+		return response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+	logger.Info("restore-instance")
+	return nil
+}
+
+// DeleteInstance marks an instance, such as one that was restored, as pending
+// deletion with no delay left, so that the next scheduled run deletes it along
+// with its AWS resource.
+func DeleteInstance(c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager, now time.Time, logger lager.Logger) response.Response {
+	if _, _, resp := findInstanceBroker(c, brokerDb, id, settings, taskqueue, logger); resp != nil {
+		return resp
+	}
+	deletion, err := base.FindPendingDeletion(brokerDb, id)
+	if err != nil {
+		logger.Error("find-pending-deletion", err)
+		return response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+	if deletion != nil {
+		return response.NewErrorResponse(http.StatusConflict, "The instance is already pending deletion.")
+	}
+
+	deletion = &base.PendingDeletion{InstanceGUID: id, DeleteAt: now}
+	if err := brokerDb.Create(deletion).Error; err != nil {
+		logger.Error("create-pending-deletion", err)
+		return response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+	logger.Info("delete-instance")
+	return nil
+}
+
+// ScheduleDelayedDeletion schedules the deletion of the instances whose
+// deletion delay has passed. A run is skipped while another broker is still
+// deleting them.
+func ScheduleDelayedDeletion(q *taskqueue.QueueManager, c *catalog.Catalog, brokerDb *gorm.DB, settings *config.Settings, logger lager.Logger) error {
+	if q.IsTaskScheduled(delayedDeletionTaskID) {
+		return nil
+	}
+	logger = logger.Session("delayed-deletion")
+	_, err := q.ScheduleTask(delayedDeletionSchedule, delayedDeletionTaskID, func() {
+		ran, err := common.RunExclusive(brokerDb, delayedDeletionTaskID, func() {
+			DeletePendingInstances(context.Background(), c, brokerDb, settings, q, time.Now(), logger)
+		})
+		if err != nil {
+			logger.Error("lock-task", err)
+		} else if !ran {
+			logger.Info("task-running-elsewhere")
+		}
+	})
+	return err
+}
+
+// suspendPendingInstances suspends the instances whose deletion delay has not
+// passed yet again, since RDS starts instances that have been stopped for 7
+// days.
+func suspendPendingInstances(ctx context.Context, c *catalog.Catalog, brokerDb *gorm.DB, settings *config.Settings, taskqueue *taskqueue.QueueManager, now time.Time, logger lager.Logger) {
+	var deletions []base.PendingDeletion
+	if err := brokerDb.Where("delete_at > ? AND deletion_started = ?", now, false).Find(&deletions).Error; err != nil {
+		logger.Error("find-pending-deletions", err)
+		return
+	}
+
+	for _, deletion := range deletions {
+		instance, broker, resp := findInstanceBroker(c, brokerDb, deletion.InstanceGUID, settings, taskqueue, logger)
+		if resp == nil {
+			resp = broker.SuspendInstance(ctx, c, deletion.InstanceGUID, instance)
+		}
+		if resp != nil {
+			logger.Info("suspend-instance-response", lager.Data{
+				logging.InstanceGUIDKey: deletion.InstanceGUID,
+				"status-code":           resp.GetStatusCode(),
+			})
+		}
+	}
+}
+
+// DeletePendingInstances deletes the instances whose deletion delay has passed
+// and returns the number of instances deleted. Asynchronous deletions are
+// followed up on the next runs, and an instance that fails to delete is
+// retried. Instances that are not due yet are stopped again if they were
+// restarted.
+func DeletePendingInstances(ctx context.Context, c *catalog.Catalog, brokerDb *gorm.DB, settings *config.Settings, taskqueue *taskqueue.QueueManager, now time.Time, logger lager.Logger) int {
+	suspendPendingInstances(ctx, c, brokerDb, settings, taskqueue, now, logger)
+
+	var deletions []base.PendingDeletion
+	if err := brokerDb.Where("delete_at <= ?", now).Find(&deletions).Error; err != nil {
+		logger.Error("find-pending-deletions", err)
+		return 0
+	}
+
+	deleted := 0
+	for n := range deletions {
+		deletion := &deletions[n]
+		data := lager.Data{logging.InstanceGUIDKey: deletion.InstanceGUID}
+		instance, broker, resp := findInstanceBroker(c, brokerDb, deletion.InstanceGUID, settings, taskqueue, logger)
+		if resp != nil {
+			if resp.GetStatusCode() != http.StatusNotFound {
+				logger.Info("find-instance-response", data)
+				continue
+			}
+		} else if !deletion.DeletionStarted {
+			resp = broker.DeleteInstance(ctx, c, deletion.InstanceGUID, instance)
+			switch resp.GetResponseType() {
+			case response.SuccessDeleteResponseType:
+				if err := brokerDb.Unscoped().Delete(&instance).Error; err != nil {
+					logger.Error("delete-base-instance", err, data)
+					continue
+				}
+			case response.SuccessAcceptedResponseType:
+				if err := brokerDb.Model(deletion).Update("deletion_started", true).Error; err != nil {
+					logger.Error("save-pending-deletion", err, data)
+				}
+				continue
+			default:
+				logger.Info("delete-instance-response", lager.Data{
+					logging.InstanceGUIDKey: deletion.InstanceGUID,
+					"status-code":           resp.GetStatusCode(),
+				})
+				continue
+			}
+		} else {
+			// The service removes its records once the asynchronous deletion
+			// has completed.
+			broker.LastOperation(ctx, c, deletion.InstanceGUID, instance, base.DeleteOp.String())
+			if _, resp := base.FindBaseInstance(brokerDb, deletion.InstanceGUID); resp == nil || resp.GetStatusCode() != http.StatusNotFound {
+				continue
+			}
+		}
+
+		if err := brokerDb.Delete(deletion).Error; err != nil {
+			logger.Error("delete-pending-deletion", err, data)
+			continue
+		}
+		logger.Info("instance-deleted", data)
+		deleted++
+	}
+	return deleted
+}
@@ -0,0 +1,152 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/taskqueue"
+)
+
+func TestRestoreInstance(t *testing.T) {
+	c, brokerDb, settings := setup(t)
+	id := createRDSInstance(t, c, brokerDb, settings)
+	logger := lagertest.NewTestLogger("admin-test")
+
+	resp := RestoreInstance(context.Background(), c, brokerDb, id, settings, taskqueue.NewQueueManager(), logger)
+	if resp == nil || resp.GetStatusCode() != http.StatusNotFound {
+		t.Fatal("expected an instance that is not pending deletion not to be restored")
+	}
+
+	if err := brokerDb.Create(&base.PendingDeletion{InstanceGUID: id, DeleteAt: time.Now().AddDate(0, 0, 7)}).Error; err != nil {
+		t.Fatal(err)
+	}
+	deletions, err := ListPendingDeletions(c, brokerDb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deletions) != 1 || deletions[0].InstanceGUID != id || deletions[0].Service != "rds" {
+		t.Fatalf("expected the instance to be pending deletion, got %v", deletions)
+	}
+
+	if resp := RestoreInstance(context.Background(), c, brokerDb, id, settings, taskqueue.NewQueueManager(), logger); resp != nil {
+		t.Fatal("unable to restore instance", resp.GetStatusCode())
+	}
+	if deletion, _ := base.FindPendingDeletion(brokerDb, id); deletion != nil {
+		t.Error("expected the pending deletion to be cancelled")
+	}
+	if _, resp := base.FindBaseInstance(brokerDb, id); resp != nil {
+		t.Error("expected the instance to be kept")
+	}
+}
+
+func TestRestoreInstanceDeletionStarted(t *testing.T) {
+	c, brokerDb, settings := setup(t)
+	id := createRDSInstance(t, c, brokerDb, settings)
+	if err := brokerDb.Create(&base.PendingDeletion{InstanceGUID: id, DeletionStarted: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	resp := RestoreInstance(context.Background(), c, brokerDb, id, settings, taskqueue.NewQueueManager(), lagertest.NewTestLogger("admin-test"))
+	if resp == nil || resp.GetStatusCode() != http.StatusConflict {
+		t.Fatal("expected an instance being deleted not to be restored")
+	}
+}
+
+func TestDeletePendingInstances(t *testing.T) {
+	c, brokerDb, settings := setup(t)
+	now := time.Now()
+	due := createRDSInstance(t, c, brokerDb, settings)
+	later := createRDSInstance(t, c, brokerDb, settings)
+	for id, deleteAt := range map[string]time.Time{
+		due:       now.Add(-time.Minute),
+		later:     now.AddDate(0, 0, 1),
+		"missing": now.Add(-time.Minute),
+	} {
+		if err := brokerDb.Create(&base.PendingDeletion{InstanceGUID: id, DeleteAt: deleteAt}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	logger := lagertest.NewTestLogger("admin-test")
+
+	// The instance is deleted with a final snapshot, which completes on a
+	// later run.
+	if deleted := DeletePendingInstances(context.Background(), c, brokerDb, settings, taskqueue.NewQueueManager(), now, logger); deleted != 1 {
+		t.Fatalf("expected only the missing instance to be cleaned up, got %d", deleted)
+	}
+	if deletion, _ := base.FindPendingDeletion(brokerDb, due); deletion == nil || !deletion.DeletionStarted {
+		t.Fatal("expected the deletion of the instance to have started")
+	}
+
+	if deleted := DeletePendingInstances(context.Background(), c, brokerDb, settings, taskqueue.NewQueueManager(), now, logger); deleted != 1 {
+		t.Fatalf("expected the instance to be deleted, got %d", deleted)
+	}
+	if _, resp := base.FindBaseInstance(brokerDb, due); resp == nil {
+		t.Error("expected the instance to be deleted")
+	}
+	if deletion, _ := base.FindPendingDeletion(brokerDb, due); deletion != nil {
+		t.Error("expected the pending deletion to be removed")
+	}
+	if deletion, _ := base.FindPendingDeletion(brokerDb, later); deletion == nil {
+		t.Error("expected the instance that is not due to be kept")
+	}
+}
+
+func TestDeletePendingInstancesSuspendsLaterInstances(t *testing.T) {
+	c, brokerDb, settings := setup(t)
+	now := time.Now()
+	later := createRDSInstance(t, c, brokerDb, settings)
+	for _, id := range []string{later, "missing"} {
+		if err := brokerDb.Create(&base.PendingDeletion{InstanceGUID: id, DeleteAt: now.AddDate(0, 0, 1)}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	logger := lagertest.NewTestLogger("admin-test")
+
+	if deleted := DeletePendingInstances(context.Background(), c, brokerDb, settings, taskqueue.NewQueueManager(), now, logger); deleted != 0 {
+		t.Fatalf("expected no instance to be deleted, got %d", deleted)
+	}
+	// Only the instance that cannot be found fails to be suspended again.
+	suspendFailures := 0
+	for _, message := range logger.LogMessages() {
+		if message == "admin-test.suspend-instance-response" {
+			suspendFailures++
+		}
+	}
+	if suspendFailures != 1 {
+		t.Errorf("expected 1 instance not to be suspended, got %d", suspendFailures)
+	}
+	for _, id := range []string{later, "missing"} {
+		if deletion, _ := base.FindPendingDeletion(brokerDb, id); deletion == nil || deletion.DeletionStarted {
+			t.Errorf("expected %s to stay pending deletion", id)
+		}
+	}
+}
+
+func TestDeleteRestoredInstance(t *testing.T) {
+	c, brokerDb, settings := setup(t)
+	id := createRDSInstance(t, c, brokerDb, settings)
+	now := time.Now()
+	logger := lagertest.NewTestLogger("admin-test")
+
+	if resp := DeleteInstance(c, brokerDb, id, settings, taskqueue.NewQueueManager(), now, logger); resp != nil {
+		t.Fatal("unable to delete instance", resp.GetStatusCode())
+	}
+	resp := DeleteInstance(c, brokerDb, id, settings, taskqueue.NewQueueManager(), now, logger)
+	if resp == nil || resp.GetStatusCode() != http.StatusConflict {
+		t.Error("expected an instance pending deletion not to be scheduled again")
+	}
+
+	// The deletion starts on the next run and completes on the one after.
+	later := now.Add(time.Minute)
+	DeletePendingInstances(context.Background(), c, brokerDb, settings, taskqueue.NewQueueManager(), later, logger)
+	if deleted := DeletePendingInstances(context.Background(), c, brokerDb, settings, taskqueue.NewQueueManager(), later, logger); deleted != 1 {
+		t.Fatalf("expected the instance to be deleted, got %d", deleted)
+	}
+	if _, resp := base.FindBaseInstance(brokerDb, id); resp == nil {
+		t.Error("expected the instance to be deleted")
+	}
+}
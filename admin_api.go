@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/18F/aws-broker/admin"
+	"github.com/18F/aws-broker/catalog"
+	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/helpers/logging"
+	"github.com/18F/aws-broker/helpers/tracing"
+	"github.com/18F/aws-broker/taskqueue"
+	"github.com/go-martini/martini"
+	"github.com/jinzhu/gorm"
+	"github.com/martini-contrib/render"
+)
+
+// AdminListInstances lists the instances of every service, optionally filtered
+// by the "service", "plan", "org", "space" and "state" query parameters.
+// URL: /admin/instances
+func AdminListInstances(req *http.Request, r render.Render, brokerDb *gorm.DB, c *catalog.Catalog, logger lager.Logger) {
+	logger = logger.Session("admin-list-instances")
+	_, span := tracing.Start(req.Context(), "admin.list-instances")
+	defer span.End()
+	instances, resp := adminListInstances(req, c, brokerDb, logger)
+	if resp != nil {
+		r.JSON(resp.GetStatusCode(), resp)
+		return
+	}
+	r.JSON(http.StatusOK, map[string]interface{}{
+		"instances": instances,
+	})
+}
+
+// AdminShowInstance shows the broker record of an instance merged with the
+// current state of its AWS resource.
+// URL: /admin/instances/:instance_id
+func AdminShowInstance(p martini.Params, req *http.Request, r render.Render, brokerDb *gorm.DB, s *config.Settings, c *catalog.Catalog, q *taskqueue.QueueManager, logger lager.Logger) {
+	logger = logger.Session("admin-show-instance", lager.Data{
+		logging.InstanceGUIDKey: p["instance_id"],
+	})
+	ctx, span := tracing.Start(req.Context(), "admin.show-instance", tracing.InstanceGUIDKey.String(p["instance_id"]))
+	defer span.End()
+	detail, resp := admin.DescribeInstance(ctx, c, brokerDb, p["instance_id"], s, q, logger)
+	if resp != nil {
+		r.JSON(resp.GetStatusCode(), resp)
+		return
+	}
+	r.JSON(http.StatusOK, detail)
+}
+
+// AdminSetInstanceState forces the state of an instance, e.g. to recover an
+// instance stuck in progress.
+// URL: /admin/instances/:instance_id/state
+// Request: {"state": "InstanceReady"}
+func AdminSetInstanceState(p martini.Params, req *http.Request, r render.Render, brokerDb *gorm.DB, s *config.Settings, c *catalog.Catalog, q *taskqueue.QueueManager, logger lager.Logger) {
+	logger = logger.Session("admin-set-instance-state", lager.Data{
+		logging.InstanceGUIDKey: p["instance_id"],
+	})
+	ctx, span := tracing.Start(req.Context(), "admin.set-instance-state", tracing.InstanceGUIDKey.String(p["instance_id"]))
+	defer span.End()
+	instance, resp := adminSetInstanceState(ctx, req, c, brokerDb, p["instance_id"], s, q, logger)
+	if resp != nil {
+		r.JSON(resp.GetStatusCode(), resp)
+		return
+	}
+	r.JSON(http.StatusOK, instance)
+}
+
+// AdminReconcileInstance re-runs the last operation reconciliation of an
+// instance against AWS, as the platform would when polling.
+// URL: /admin/instances/:instance_id/reconcile?operation=create
+func AdminReconcileInstance(p martini.Params, req *http.Request, r render.Render, brokerDb *gorm.DB, s *config.Settings, c *catalog.Catalog, q *taskqueue.QueueManager, logger lager.Logger) {
+	logger = logger.Session("admin-reconcile-instance", lager.Data{
+		logging.OperationKey:    req.URL.Query().Get("operation"),
+		logging.InstanceGUIDKey: p["instance_id"],
+	})
+	ctx, span := tracing.Start(req.Context(), "admin.reconcile-instance", tracing.InstanceGUIDKey.String(p["instance_id"]))
+	defer span.End()
+	resp := adminReconcileInstance(ctx, req, c, brokerDb, p["instance_id"], s, q, logger)
+	r.JSON(resp.GetStatusCode(), resp)
+}
+
+// AdminListSnapshots lists the automated and manual snapshots of an instance.
+// URL: /admin/instances/:instance_id/snapshots
+func AdminListSnapshots(p martini.Params, req *http.Request, r render.Render, brokerDb *gorm.DB, s *config.Settings, c *catalog.Catalog, q *taskqueue.QueueManager, logger lager.Logger) {
+	logger = logger.Session("admin-list-snapshots", lager.Data{
+		logging.InstanceGUIDKey: p["instance_id"],
+	})
+	ctx, span := tracing.Start(req.Context(), "admin.list-snapshots", tracing.InstanceGUIDKey.String(p["instance_id"]))
+	defer span.End()
+	snapshots, resp := admin.ListSnapshots(ctx, c, brokerDb, p["instance_id"], s, q, logger)
+	if resp != nil {
+		r.JSON(resp.GetStatusCode(), resp)
+		return
+	}
+	r.JSON(http.StatusOK, map[string]interface{}{
+		"snapshots": snapshots,
+	})
+}
+
+// AdminCreateSnapshot takes a manual snapshot of an instance.
+// URL: /admin/instances/:instance_id/snapshots
+// Request: {"name": "pre-migration"}
+func AdminCreateSnapshot(p martini.Params, req *http.Request, r render.Render, brokerDb *gorm.DB, s *config.Settings, c *catalog.Catalog, q *taskqueue.QueueManager, logger lager.Logger) {
+	logger = logger.Session("admin-create-snapshot", lager.Data{
+		logging.InstanceGUIDKey: p["instance_id"],
+	})
+	ctx, span := tracing.Start(req.Context(), "admin.create-snapshot", tracing.InstanceGUIDKey.String(p["instance_id"]))
+	defer span.End()
+	snapshot, resp := adminCreateSnapshot(ctx, req, c, brokerDb, p["instance_id"], s, q, logger)
+	if resp != nil {
+		r.JSON(resp.GetStatusCode(), resp)
+		return
+	}
+	r.JSON(http.StatusAccepted, snapshot)
+}
+
+// AdminRestoreInstance cancels the pending deletion of an instance.
+// URL: /admin/instances/:instance_id/restore
+func AdminRestoreInstance(p martini.Params, req *http.Request, r render.Render, brokerDb *gorm.DB, s *config.Settings, c *catalog.Catalog, q *taskqueue.QueueManager, logger lager.Logger) {
+	logger = logger.Session("admin-restore-instance", lager.Data{
+		logging.InstanceGUIDKey: p["instance_id"],
+	})
+	ctx, span := tracing.Start(req.Context(), "admin.restore-instance", tracing.InstanceGUIDKey.String(p["instance_id"]))
+	defer span.End()
+	if resp := admin.RestoreInstance(ctx, c, brokerDb, p["instance_id"], s, q, logger); resp != nil {
+		r.JSON(resp.GetStatusCode(), resp)
+		return
+	}
+	r.JSON(http.StatusOK, map[string]interface{}{})
+}
+
+// AdminDeleteInstance schedules the deletion of an instance that is no longer
+// known to Cloud Foundry, such as a restored instance.
+// URL: /admin/instances/:instance_id/delete
+func AdminDeleteInstance(p martini.Params, req *http.Request, r render.Render, brokerDb *gorm.DB, s *config.Settings, c *catalog.Catalog, q *taskqueue.QueueManager, logger lager.Logger) {
+	logger = logger.Session("admin-delete-instance", lager.Data{
+		logging.InstanceGUIDKey: p["instance_id"],
+	})
+	_, span := tracing.Start(req.Context(), "admin.delete-instance", tracing.InstanceGUIDKey.String(p["instance_id"]))
+	defer span.End()
+	if resp := admin.DeleteInstance(c, brokerDb, p["instance_id"], s, q, time.Now(), logger); resp != nil {
+		r.JSON(resp.GetStatusCode(), resp)
+		return
+	}
+	r.JSON(http.StatusAccepted, map[string]interface{}{})
+}
+
+// AdminListPendingDeletions lists the instances that are pending deletion.
+// URL: /admin/deletions
+func AdminListPendingDeletions(req *http.Request, r render.Render, brokerDb *gorm.DB, c *catalog.Catalog, logger lager.Logger) {
+	logger = logger.Session("admin-list-pending-deletions")
+	_, span := tracing.Start(req.Context(), "admin.list-pending-deletions")
+	defer span.End()
+	deletions, resp := adminListPendingDeletions(c, brokerDb, logger)
+	if resp != nil {
+		r.JSON(resp.GetStatusCode(), resp)
+		return
+	}
+	r.JSON(http.StatusOK, map[string]interface{}{
+		"deletions": deletions,
+	})
+}
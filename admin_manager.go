@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/18F/aws-broker/admin"
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/catalog"
+	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/helpers/response"
+	"github.com/18F/aws-broker/taskqueue"
+	"github.com/jinzhu/gorm"
+)
+
+// adminStateRequest is the body of a request to force the state of an instance.
+type adminStateRequest struct {
+	State string `json:"state"`
+}
+
+// adminSnapshotRequest is the body of a request to take a manual snapshot of
+// an instance.
+type adminSnapshotRequest struct {
+	Name string `json:"name"`
+}
+
+// instanceFilterFromQuery builds an instance filter from the "service", "plan",
+// "org", "space" and "state" query parameters. The service may be given by
+// name or ID, and the state by name or number.
+func instanceFilterFromQuery(req *http.Request, c *catalog.Catalog) (base.InstanceFilter, response.Response) {
+	query := req.URL.Query()
+	filter := base.InstanceFilter{
+		PlanID:           query.Get("plan"),
+		OrganizationGUID: query.Get("org"),
+		SpaceGUID:        query.Get("space"),
+	}
+	if service := query.Get("service"); service != "" {
+		serviceID, ok := c.FindServiceID(service)
+		if !ok {
+			return filter, response.NewErrorResponse(http.StatusBadRequest, catalog.ErrNoServiceFound.Error())
+		}
+		filter.ServiceID = serviceID
+	}
+	if state := query.Get("state"); state != "" {
+		instanceState, err := base.ParseInstanceState(state)
+		if err != nil {
+			return filter, response.NewErrorResponse(http.StatusBadRequest, err.Error())
+		}
+		filter.State = &instanceState
+	}
+	return filter, nil
+}
+
+func adminListInstances(req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, logger lager.Logger) ([]admin.InstanceSummary, response.Response) {
+	filter, resp := instanceFilterFromQuery(req, c)
+	if resp != nil {
+		return nil, resp
+	}
+
+	instances, err := admin.ListInstances(c, brokerDb, filter)
+	if err != nil {
+		logger.Error("list-instances", err)
+		return nil, response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+	return instances, nil
+}
+
+func adminListPendingDeletions(c *catalog.Catalog, brokerDb *gorm.DB, logger lager.Logger) ([]admin.PendingDeletionSummary, response.Response) {
+	deletions, err := admin.ListPendingDeletions(c, brokerDb)
+	if err != nil {
+		logger.Error("list-pending-deletions", err)
+		return nil, response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+	return deletions, nil
+}
+
+func adminSetInstanceState(ctx context.Context, req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager, logger lager.Logger) (admin.InstanceSummary, response.Response) {
+	if req.Body == nil {
+		return admin.InstanceSummary{}, response.ErrNoRequestBodyResponse
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return admin.InstanceSummary{}, response.NewErrorResponse(http.StatusBadRequest, err.Error())
+	}
+	var stateRequest adminStateRequest
+	if err := json.Unmarshal(body, &stateRequest); err != nil {
+		return admin.InstanceSummary{}, response.NewErrorResponse(http.StatusBadRequest, "Invalid request. Error: "+err.Error())
+	}
+	state, err := base.ParseInstanceState(stateRequest.State)
+	if err != nil {
+		return admin.InstanceSummary{}, response.NewErrorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	return admin.SetInstanceState(ctx, c, brokerDb, id, state, settings, taskqueue, logger)
+}
+
+func adminCreateSnapshot(ctx context.Context, req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager, logger lager.Logger) (base.Snapshot, response.Response) {
+	if req.Body == nil {
+		return base.Snapshot{}, response.ErrNoRequestBodyResponse
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return base.Snapshot{}, response.NewErrorResponse(http.StatusBadRequest, err.Error())
+	}
+	var snapshotRequest adminSnapshotRequest
+	if err := json.Unmarshal(body, &snapshotRequest); err != nil {
+		return base.Snapshot{}, response.NewErrorResponse(http.StatusBadRequest, "Invalid request. Error: "+err.Error())
+	}
+
+	return admin.CreateSnapshot(ctx, c, brokerDb, id, snapshotRequest.Name, settings, taskqueue, logger)
+}
+
+func adminReconcileInstance(ctx context.Context, req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager, logger lager.Logger) response.Response {
+	resp := lastOperation(ctx, req, c, brokerDb, id, settings, taskqueue, logger)
+	logger.Info("reconcile-instance-response", responseData(resp))
+	return resp
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"code.cloudfoundry.org/lager"
+	"github.com/18F/aws-broker/base"
 	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/helpers/logging"
+	"github.com/18F/aws-broker/helpers/tracing"
 	"github.com/18F/aws-broker/taskqueue"
 	"github.com/go-martini/martini"
 	"github.com/jinzhu/gorm"
@@ -34,8 +38,14 @@ type CreateResponse struct {
 //   "organization_guid": "org-guid-here",
 //   "space_guid":        "space-guid-here"
 // }
-func CreateInstance(p martini.Params, req *http.Request, r render.Render, brokerDb *gorm.DB, s *config.Settings, c *catalog.Catalog, q *taskqueue.QueueManager) {
-	resp := createInstance(req, c, brokerDb, p["id"], s, q)
+func CreateInstance(p martini.Params, req *http.Request, r render.Render, brokerDb *gorm.DB, s *config.Settings, c *catalog.Catalog, q *taskqueue.QueueManager, logger lager.Logger) {
+	logger = logger.Session("create", lager.Data{
+		logging.OperationKey:    base.CreateOp.String(),
+		logging.InstanceGUIDKey: p["id"],
+	})
+	ctx, span := tracing.Start(req.Context(), "osb.create", tracing.InstanceGUIDKey.String(p["id"]))
+	defer span.End()
+	resp := createInstance(ctx, req, c, brokerDb, p["id"], s, q, logger)
 	r.JSON(resp.GetStatusCode(), resp)
 }
 
@@ -48,28 +58,56 @@ func CreateInstance(p martini.Params, req *http.Request, r render.Render, broker
 //   "organization_guid": "org-guid-here",
 //   "space_guid":        "space-guid-here"
 // }
-func ModifyInstance(p martini.Params, req *http.Request, r render.Render, brokerDb *gorm.DB, s *config.Settings, c *catalog.Catalog, q *taskqueue.QueueManager) {
-	resp := modifyInstance(req, c, brokerDb, p["id"], s, q)
+func ModifyInstance(p martini.Params, req *http.Request, r render.Render, brokerDb *gorm.DB, s *config.Settings, c *catalog.Catalog, q *taskqueue.QueueManager, logger lager.Logger) {
+	logger = logger.Session("modify", lager.Data{
+		logging.OperationKey:    base.ModifyOp.String(),
+		logging.InstanceGUIDKey: p["id"],
+	})
+	ctx, span := tracing.Start(req.Context(), "osb.modify", tracing.InstanceGUIDKey.String(p["id"]))
+	defer span.End()
+	resp := modifyInstance(ctx, req, c, brokerDb, p["id"], s, q, logger)
 	r.JSON(resp.GetStatusCode(), resp)
 }
 
 // LastOperation processes all requests for binding a service instance to an application.
 // URL: /v2/service_instances/:instance_id/last_operation
-func LastOperation(p martini.Params, req *http.Request, r render.Render, brokerDb *gorm.DB, s *config.Settings, c *catalog.Catalog, q *taskqueue.QueueManager) {
-	resp := lastOperation(req, c, brokerDb, p["instance_id"], s, q)
+func LastOperation(p martini.Params, req *http.Request, r render.Render, brokerDb *gorm.DB, s *config.Settings, c *catalog.Catalog, q *taskqueue.QueueManager, logger lager.Logger) {
+	logger = logger.Session("last-operation", lager.Data{
+		logging.OperationKey:    req.URL.Query().Get("operation"),
+		logging.InstanceGUIDKey: p["instance_id"],
+	})
+	ctx, span := tracing.Start(req.Context(), "osb.last-operation", tracing.InstanceGUIDKey.String(p["instance_id"]))
+	defer span.End()
+	resp := lastOperation(ctx, req, c, brokerDb, p["instance_id"], s, q, logger)
 	r.JSON(resp.GetStatusCode(), resp)
 }
 
 // BindInstance processes all requests for binding a service instance to an application.
 // URL: /v2/service_instances/:instance_id/service_bindings/:binding_id
-func BindInstance(p martini.Params, req *http.Request, r render.Render, brokerDb *gorm.DB, s *config.Settings, c *catalog.Catalog, q *taskqueue.QueueManager) {
-	resp := bindInstance(req, c, brokerDb, p["instance_id"], s, q)
+func BindInstance(p martini.Params, req *http.Request, r render.Render, brokerDb *gorm.DB, s *config.Settings, c *catalog.Catalog, q *taskqueue.QueueManager, logger lager.Logger) {
+	logger = logger.Session("bind", lager.Data{
+		logging.OperationKey:    base.BindOp.String(),
+		logging.InstanceGUIDKey: p["instance_id"],
+		logging.BindingGUIDKey:  p["id"],
+	})
+	ctx, span := tracing.Start(req.Context(), "osb.bind",
+		tracing.InstanceGUIDKey.String(p["instance_id"]),
+		tracing.BindingGUIDKey.String(p["id"]),
+	)
+	defer span.End()
+	resp := bindInstance(ctx, req, c, brokerDb, p["instance_id"], s, q, logger)
 	r.JSON(resp.GetStatusCode(), resp)
 }
 
 // DeleteInstance processes all requests for deleting an existing service instance.
 // URL: /v2/service_instances/:instance_id
-func DeleteInstance(p martini.Params, req *http.Request, r render.Render, brokerDb *gorm.DB, s *config.Settings, c *catalog.Catalog, q *taskqueue.QueueManager) {
-	resp := deleteInstance(req, c, brokerDb, p["instance_id"], s, q)
+func DeleteInstance(p martini.Params, req *http.Request, r render.Render, brokerDb *gorm.DB, s *config.Settings, c *catalog.Catalog, q *taskqueue.QueueManager, logger lager.Logger) {
+	logger = logger.Session("delete", lager.Data{
+		logging.OperationKey:    base.DeleteOp.String(),
+		logging.InstanceGUIDKey: p["instance_id"],
+	})
+	ctx, span := tracing.Start(req.Context(), "osb.delete", tracing.InstanceGUIDKey.String(p["instance_id"]))
+	defer span.End()
+	resp := deleteInstance(ctx, req, c, brokerDb, p["instance_id"], s, q, logger)
 	r.JSON(resp.GetStatusCode(), resp)
 }
@@ -13,9 +13,10 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/awsutil"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+
+	"github.com/18F/aws-broker/helpers/logging"
 )
 
 type PolicyDocument struct {
@@ -75,28 +76,13 @@ func (pd *PolicyDocument) AddNewStatements(newStatements []PolicyStatementEntry)
 	return modified
 }
 
-func NewIAMPolicyClient(region string, logger lager.Logger) *IAMPolicyClient {
+func NewIAMPolicyClient(iamsvc iamiface.IAMAPI, logger lager.Logger) *IAMPolicyClient {
 	return &IAMPolicyClient{
-		iam:    iam.New(session.Must(session.NewSession()), aws.NewConfig().WithRegion(region)),
+		iam:    iamsvc,
 		logger: logger.Session("iam-policy"),
 	}
 }
 
-func logAWSError(err error) {
-	if awsErr, ok := err.(awserr.Error); ok {
-		// Generic AWS error with Code, Message, and original error (if any)
-		fmt.Println(awsErr.Code(), awsErr.Message(), awsErr.OrigErr())
-		if reqErr, ok := err.(awserr.RequestFailure); ok {
-			// A service error occurred
-			fmt.Println(reqErr.Code(), reqErr.Message(), reqErr.StatusCode(), reqErr.RequestID())
-		}
-	} else {
-		// This case should never be hit, the SDK should always return an
-		// error which satisfies the awserr.Error interface.
-		fmt.Println(err.Error())
-	}
-}
-
 // create new assumable role with the trust policy
 func (ip *IAMPolicyClient) CreateAssumeRole(
 	policy string,
@@ -113,19 +99,18 @@ func (ip *IAMPolicyClient) CreateAssumeRole(
 	if err != nil {
 		if awsErr, ok := err.(awserr.Error); ok {
 			if awsErr.Code() == iam.ErrCodeEntityAlreadyExistsException {
-				fmt.Println(iam.ErrCodeEntityAlreadyExistsException, awsErr.Error())
-				fmt.Printf("role %s already exists, continuing\n", rolename)
+				ip.logger.Info("role-already-exists", lager.Data{"role": rolename})
 				resp, innerErr := ip.iam.GetRole(&iam.GetRoleInput{
 					RoleName: aws.String(rolename),
 				})
 				if innerErr != nil {
-					logAWSError(err)
+					logging.LogAWSError(ip.logger, "get-role", innerErr)
 					return nil, innerErr
 				}
 				return resp.Role, nil
 			}
 		}
-		logAWSError(err)
+		logging.LogAWSError(ip.logger, "create-role", err)
 		return role, err
 	}
 
@@ -208,30 +193,29 @@ func (ip *IAMPolicyClient) CreateUserPolicy(
 	if err != nil {
 		if awsErr, ok := err.(awserr.Error); ok {
 			if awsErr.Code() == iam.ErrCodeEntityAlreadyExistsException {
-				fmt.Println(iam.ErrCodeEntityAlreadyExistsException, awsErr.Error())
-				fmt.Printf("policy name %s already exists, attempting to get policy ARN\n", policyname)
+				ip.logger.Info("policy-already-exists", lager.Data{"policy": policyname})
 				resp, innerErr := ip.iam.ListAttachedUserPolicies(&iam.ListAttachedUserPoliciesInput{
 					UserName: aws.String(username),
 				})
 				if innerErr != nil {
-					logAWSError(err)
+					logging.LogAWSError(ip.logger, "list-attached-user-policies", innerErr)
 					return "", innerErr
 				}
 				for _, policy := range resp.AttachedPolicies {
 					if *policy.PolicyName == policyname {
-						fmt.Printf("found policy ARN %s for policy %s\n", *policy.PolicyArn, policyname)
+						ip.logger.Info("found-policy", lager.Data{"policy": policyname, "policy-arn": *policy.PolicyArn})
 						return *policy.PolicyArn, nil
 					}
 				}
 				return "", err
 			}
 		}
-		logAWSError(err)
+		logging.LogAWSError(ip.logger, "create-policy", err)
 		// return if error
 		return IamRolePolicyARN, err
 	}
 
-	fmt.Println(awsutil.Prettify(respPolicy))
+	ip.logger.Debug("create-policy", lager.Data{"output": awsutil.Prettify(respPolicy)})
 
 	if respPolicy.Policy.Arn != nil {
 		IamRolePolicyARN = *(respPolicy.Policy.Arn)
@@ -241,7 +225,7 @@ func (ip *IAMPolicyClient) CreateUserPolicy(
 		}
 		_, err := ip.iam.AttachUserPolicy(userAttachPolicyInput)
 		if err != nil {
-			logAWSError(err)
+			logging.LogAWSError(ip.logger, "attach-user-policy", err)
 			return IamRolePolicyARN, err
 		}
 	}
@@ -266,25 +250,24 @@ func (ip *IAMPolicyClient) CreatePolicyAttachRole(
 	if err != nil {
 		if awsErr, ok := err.(awserr.Error); ok {
 			if awsErr.Code() == iam.ErrCodeEntityAlreadyExistsException {
-				fmt.Println(iam.ErrCodeEntityAlreadyExistsException, awsErr.Error())
-				fmt.Printf("policy name %s already exists, attempting to get policy ARN\n", policyname)
+				ip.logger.Info("policy-already-exists", lager.Data{"policy": policyname})
 				resp, innerErr := ip.iam.ListAttachedRolePolicies(&iam.ListAttachedRolePoliciesInput{
 					RoleName: role.RoleName,
 				})
 				if innerErr != nil {
-					logAWSError(err)
+					logging.LogAWSError(ip.logger, "list-attached-role-policies", innerErr)
 					return "", innerErr
 				}
 				for _, policy := range resp.AttachedPolicies {
 					if *policy.PolicyName == policyname {
-						fmt.Printf("found policy ARN %s for role %s\n", *policy.PolicyArn, *role.RoleName)
+						ip.logger.Info("found-policy", lager.Data{"role": *role.RoleName, "policy-arn": *policy.PolicyArn})
 						return *policy.PolicyArn, nil
 					}
 				}
 				return "", err
 			}
 		}
-		logAWSError(err)
+		logging.LogAWSError(ip.logger, "create-policy", err)
 		return policyarn, err
 	}
 	if respPolicy.Policy.Arn != nil && role.RoleName != nil {
@@ -296,10 +279,10 @@ func (ip *IAMPolicyClient) CreatePolicyAttachRole(
 
 		respAttachPolicy, err := ip.iam.AttachRolePolicy(roleAttachPolicyInput)
 		if err != nil {
-			logAWSError(err)
+			logging.LogAWSError(ip.logger, "attach-role-policy", err)
 			return policyarn, err
 		}
-		fmt.Println(awsutil.Prettify(respAttachPolicy))
+		ip.logger.Debug("attach-role-policy", lager.Data{"output": awsutil.Prettify(respAttachPolicy)})
 	}
 	return policyarn, nil
 }
@@ -314,8 +297,7 @@ func (ip IAMPolicyClient) UpdateExistingPolicy(policyARN string, policyStatement
 		PolicyArn: aws.String(policyARN),
 	})
 	if err != nil {
-		logAWSError(err)
-		fmt.Printf("UpdateExistingPolicy.GetPolicy with arn: %s failed\n", policyARN)
+		logging.LogAWSError(ip.logger, "get-policy", err, lager.Data{"policy-arn": policyARN})
 		return respPolVer, err
 	}
 	// get existing policy's current version number
@@ -326,8 +308,10 @@ func (ip IAMPolicyClient) UpdateExistingPolicy(policyARN string, policyStatement
 		}
 		resPolicyVersion, err := ip.iam.GetPolicyVersion(policyVersionInput)
 		if err != nil {
-			logAWSError(err)
-			fmt.Printf("UpdateExistingPolicy.GetPolicyVersion Failed with: %s failed\n", *(resPolicy.Policy.DefaultVersionId))
+			logging.LogAWSError(ip.logger, "get-policy-version", err, lager.Data{
+				"policy-arn": policyARN,
+				"version-id": *(resPolicy.Policy.DefaultVersionId),
+			})
 			return respPolVer, err
 		}
 
@@ -335,7 +319,7 @@ func (ip IAMPolicyClient) UpdateExistingPolicy(policyARN string, policyStatement
 		if resPolicyVersion.PolicyVersion.Document != nil {
 			err = policyDoc.FromString(*resPolicyVersion.PolicyVersion.Document)
 			if err != nil {
-				fmt.Printf("UpdateExistingPolicy.ConvertToPolicyDoc Failed with: %s failed\n", (*resPolicyVersion.PolicyVersion.Document))
+				ip.logger.Error("parse-policy-document", err, lager.Data{"document": *resPolicyVersion.PolicyVersion.Document})
 				return respPolVer, err
 			}
 		}
@@ -363,14 +347,13 @@ func (ip IAMPolicyClient) UpdateExistingPolicy(policyARN string, policyStatement
 
 		resp, err := ip.iam.CreatePolicyVersion(policyUpdatedVersion)
 		if err != nil {
-			logAWSError(err)
-			fmt.Printf("UpdateExistingPolicy.CreatePolicyVersion Failed with: %v\n", policyUpdatedVersion)
+			logging.LogAWSError(ip.logger, "create-policy-version", err, lager.Data{"input": policyUpdatedVersion})
 			return respPolVer, err
 		}
 		if resp.PolicyVersion != nil {
 			respPolVer = resp.PolicyVersion
 		}
-		fmt.Printf("UpdateExistingPolicy Success with: %v\n", respPolVer)
+		ip.logger.Info("update-policy", lager.Data{"policy-arn": policyARN, "version": respPolVer})
 	}
 
 	return respPolVer, nil
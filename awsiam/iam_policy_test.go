@@ -169,7 +169,8 @@ func TestCreateAssumeRole(t *testing.T) {
 	policy := `{"Version": "2012-10-17","Statement": [{"Sid": "","Effect": "Allow","Principal": {"Service": "es.amazonaws.com"},"Action": "sts:AssumeRole"}]}`
 	rolename := "test-role"
 	ip := &IAMPolicyClient{
-		iam: &mockIamClient{},
+		logger: logger,
+		iam:    &mockIamClient{},
 	}
 	iamTags := []*iam.Tag{
 		{
@@ -197,6 +198,7 @@ func TestCreateAssumeRoleAlreadyExists(t *testing.T) {
 	rolename := "test-role"
 	createRoleErr := awserr.New(iam.ErrCodeEntityAlreadyExistsException, "already exists", errors.New("fail"))
 	ip := &IAMPolicyClient{
+		logger: logger,
 		iam: &mockIamClient{
 			createRoleErr: createRoleErr,
 		},
@@ -217,7 +219,8 @@ func TestCreateAssumeRoleAlreadyExists(t *testing.T) {
 
 func TestCreateUserPolicy(t *testing.T) {
 	ip := &IAMPolicyClient{
-		iam: &mockIamClient{},
+		logger: logger,
+		iam:    &mockIamClient{},
 	}
 	Domain := "Test"
 	ARN := "arn:aws:iam::123456789012:elasticsearch/" + Domain
@@ -250,6 +253,7 @@ func TestCreateUserPolicyAlreadyExists(t *testing.T) {
 	createPolicyErr := awserr.New(iam.ErrCodeEntityAlreadyExistsException, "policy already exists", errors.New("fail"))
 
 	ip := &IAMPolicyClient{
+		logger: logger,
 		iam: &mockIamClient{
 			createPolicyErr: createPolicyErr,
 			attachedUserPolicies: []*iam.AttachedPolicy{
@@ -274,7 +278,8 @@ func TestCreateUserPolicyAlreadyExists(t *testing.T) {
 
 func TestCreatePolicyAttachRole(t *testing.T) {
 	ip := &IAMPolicyClient{
-		iam: &mockIamClient{},
+		logger: logger,
+		iam:    &mockIamClient{},
 	}
 	role := iam.Role{
 		RoleName: aws.String("test-role"),
@@ -300,6 +305,7 @@ func TestCreatePolicyAttachRoleAlreadyExists(t *testing.T) {
 	createPolicyErr := awserr.New(iam.ErrCodeEntityAlreadyExistsException, "policy already exists", errors.New("fail"))
 
 	ip := &IAMPolicyClient{
+		logger: logger,
 		iam: &mockIamClient{
 			createPolicyErr: createPolicyErr,
 			attachedRolePolicies: []*iam.AttachedPolicy{
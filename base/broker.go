@@ -1,6 +1,8 @@
 package base
 
 import (
+	"context"
+
 	"github.com/18F/aws-broker/catalog"
 	"github.com/18F/aws-broker/helpers/request"
 	"github.com/18F/aws-broker/helpers/response"
@@ -38,15 +40,39 @@ func (o Operation) String() string {
 // Broker is the interface that every type of broker should implement.
 type Broker interface {
 	// CreateInstance uses the catalog and parsed request to create an instance for the particular type of service.
-	CreateInstance(*catalog.Catalog, string, request.Request) response.Response
+	CreateInstance(context.Context, *catalog.Catalog, string, request.Request) response.Response
 	// ModifyInstance uses the catalog and parsed request to modify an existing instance for the particular type of service.
-	ModifyInstance(*catalog.Catalog, string, request.Request, Instance) response.Response
+	ModifyInstance(context.Context, *catalog.Catalog, string, request.Request, Instance) response.Response
 	// LastOperation uses the catalog and parsed request to get an instance status for the particular type of service.
-	LastOperation(*catalog.Catalog, string, Instance, string) response.Response
+	LastOperation(context.Context, *catalog.Catalog, string, Instance, string) response.Response
 	// BindInstance takes the existing instance and binds it to an app.
-	BindInstance(*catalog.Catalog, string, request.Request, Instance) response.Response
+	BindInstance(context.Context, *catalog.Catalog, string, request.Request, Instance) response.Response
 	// DeleteInstance deletes the existing instance.
-	DeleteInstance(*catalog.Catalog, string, Instance) response.Response
+	DeleteInstance(context.Context, *catalog.Catalog, string, Instance) response.Response
 	// Supports Async operation
 	AsyncOperationRequired(*catalog.Catalog, Instance, Operation) bool
+	// DescribeInstance returns the broker's record of the instance along with the current state of its AWS resource.
+	DescribeInstance(context.Context, *catalog.Catalog, string, Instance) (InstanceDetail, response.Response)
+	// SetInstanceState overwrites the state the broker has recorded for the instance.
+	SetInstanceState(context.Context, *catalog.Catalog, string, Instance, InstanceState) response.Response
+	// InstanceCredentials returns the decrypted credentials the broker has stored for the instance.
+	InstanceCredentials(context.Context, *catalog.Catalog, string, Instance) (map[string]string, response.Response)
+	// CreateSnapshot takes a manual snapshot of the instance with the given name, deleting the oldest manual snapshots beyond the retention count.
+	CreateSnapshot(context.Context, *catalog.Catalog, string, Instance, string) (Snapshot, response.Response)
+	// ListSnapshots lists the snapshots of the instance.
+	ListSnapshots(context.Context, *catalog.Catalog, string, Instance) ([]Snapshot, response.Response)
+	// SuspendInstance prepares the instance for its delayed deletion, stopping its AWS resource where the service supports it.
+	SuspendInstance(context.Context, *catalog.Catalog, string, Instance) response.Response
+	// ResumeInstance starts the AWS resource of an instance whose delayed deletion was cancelled.
+	ResumeInstance(context.Context, *catalog.Catalog, string, Instance) response.Response
+}
+
+// InstanceDetail is the operator-facing view of an instance. Secrets are
+// removed from the broker's record before it is returned. ResourceGone is set
+// when AWS reports that the resource of the instance no longer exists.
+type InstanceDetail struct {
+	Instance     interface{} `json:"instance"`
+	AWS          interface{} `json:"aws,omitempty"`
+	AWSError     string      `json:"aws_error,omitempty"`
+	ResourceGone bool        `json:"resource_gone"`
 }
@@ -0,0 +1,38 @@
+package base
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// PendingDeletion records an instance that was deprovisioned while deletions
+// are delayed. The instance is deleted once DeleteAt has passed, unless an
+// operator restores it first. DeletionStarted is set once the deletion of its
+// AWS resource was requested.
+type PendingDeletion struct {
+	InstanceGUID string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	DeleteAt        time.Time
+	DeletionStarted bool `sql:"size(255)"`
+
+	CreatedAt time.Time
+}
+
+// TableName keeps the pending deletions apart from the instances.
+func (PendingDeletion) TableName() string {
+	return "pending_deletions"
+}
+
+// FindPendingDeletion returns the pending deletion of the instance, if any.
+func FindPendingDeletion(brokerDb *gorm.DB, id string) (*PendingDeletion, error) {
+	deletion := PendingDeletion{}
+	result := brokerDb.Where("instance_guid = ?", id).First(&deletion)
+	if result.RecordNotFound() {
+		return nil, nil
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &deletion, nil
+}
@@ -1,8 +1,10 @@
 package base
 
 import (
-	"log"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/18F/aws-broker/helpers/request"
@@ -47,6 +49,20 @@ func (i InstanceState) String() string {
 	}
 }
 
+// ParseInstanceState parses either the name of an InstanceState, as returned
+// by String, or its numeric value.
+func ParseInstanceState(s string) (InstanceState, error) {
+	if n, err := strconv.ParseUint(s, 10, 8); err == nil && InstanceState(n) <= InstanceNotModified {
+		return InstanceState(n), nil
+	}
+	for state := InstanceNotCreated; state <= InstanceNotModified; state++ {
+		if strings.EqualFold(s, state.String()) {
+			return state, nil
+		}
+	}
+	return InstanceNotCreated, fmt.Errorf("unknown instance state %q", s)
+}
+
 type Instance struct {
 	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
 
@@ -64,7 +80,6 @@ type Instance struct {
 // FindBaseInstance is a helper function to find the base instance of the
 func FindBaseInstance(brokerDb *gorm.DB, id string) (Instance, response.Response) {
 	instance := Instance{}
-	log.Println("Looking for instance with id " + id)
 	result := brokerDb.Where("uuid = ?", id).First(&instance)
 	if result.Error == nil {
 		return instance, nil
@@ -74,3 +89,58 @@ func FindBaseInstance(brokerDb *gorm.DB, id string) (Instance, response.Response
 		return instance, response.NewErrorResponse(http.StatusInternalServerError, result.Error.Error())
 	}
 }
+
+// FindCloneSource finds the base instance that a new instance is cloned from.
+// The source must be an instance of the same service owned by the same
+// organization as the new instance.
+func FindCloneSource(brokerDb *gorm.DB, id string, serviceID string, organizationGUID string) (Instance, response.Response) {
+	instance, resp := FindBaseInstance(brokerDb, id)
+	if resp != nil {
+		if resp.GetStatusCode() == http.StatusNotFound {
+			return instance, response.NewErrorResponse(http.StatusNotFound, "The instance to clone from does not exist")
+		}
+		return instance, resp
+	}
+	if instance.OrganizationGUID != organizationGUID {
+		return instance, response.NewErrorResponse(http.StatusForbidden, "The instance to clone from does not belong to this organization")
+	}
+	if instance.ServiceID != serviceID {
+		return instance, response.NewErrorResponse(http.StatusBadRequest, "The instance to clone from is not an instance of this service")
+	}
+	return instance, nil
+}
+
+// InstanceFilter restricts the instances returned by FindInstances. Empty
+// fields match every instance.
+type InstanceFilter struct {
+	ServiceID        string
+	PlanID           string
+	OrganizationGUID string
+	SpaceGUID        string
+	State            *InstanceState
+}
+
+// FindInstances returns the instances in the given table that match the
+// filter. Every service instance embeds Instance, so this can be used with the
+// table of any service, which unlike the base instances tracks the state.
+func FindInstances(brokerDb *gorm.DB, table string, filter InstanceFilter) ([]Instance, error) {
+	query := brokerDb.Table(table)
+	if filter.ServiceID != "" {
+		query = query.Where("service_id = ?", filter.ServiceID)
+	}
+	if filter.PlanID != "" {
+		query = query.Where("plan_id = ?", filter.PlanID)
+	}
+	if filter.OrganizationGUID != "" {
+		query = query.Where("organization_guid = ?", filter.OrganizationGUID)
+	}
+	if filter.SpaceGUID != "" {
+		query = query.Where("space_guid = ?", filter.SpaceGUID)
+	}
+	if filter.State != nil {
+		query = query.Where("state = ?", *filter.State)
+	}
+	var instances []Instance
+	err := query.Order("created_at").Find(&instances).Error
+	return instances, err
+}
@@ -0,0 +1,68 @@
+package base
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// maxSnapshotNameLength keeps the AWS identifiers of manual snapshots, which
+// are prefixed with the identifier of their instance, within the AWS limits.
+const maxSnapshotNameLength = 40
+
+// snapshotNamePattern matches names made of letters, digits and single
+// hyphens, starting with a letter, as AWS requires of snapshot identifiers.
+var snapshotNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9]*(-[a-zA-Z0-9]+)*$`)
+
+// Snapshot is the operator-facing view of a snapshot of the AWS resource of
+// an instance. Manual is set for the snapshots the broker took on request,
+// which are subject to the manual snapshot retention count.
+type Snapshot struct {
+	Name      string     `json:"name"`
+	Type      string     `json:"type"`
+	Status    string     `json:"status"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	Manual    bool       `json:"manual"`
+}
+
+// ManualSnapshotPrefix is the prefix of the AWS identifiers of the manual
+// snapshots of the AWS resource with the given identifier.
+func ManualSnapshotPrefix(resourceID string) string {
+	return resourceID + "-manual-"
+}
+
+// ValidateSnapshotName checks the name of a manual snapshot.
+func ValidateSnapshotName(name string) error {
+	if len(name) > maxSnapshotNameLength {
+		return errors.New("snapshot names cannot be longer than 40 characters")
+	}
+	if !snapshotNamePattern.MatchString(name) {
+		return errors.New("snapshot names must start with a letter and contain only letters, digits and single hyphens")
+	}
+	return nil
+}
+
+// ExpiredSnapshots returns the manual snapshots beyond the retention count,
+// oldest first. Snapshots are kept if the retention count is not positive.
+func ExpiredSnapshots(snapshots []Snapshot, retention int64) []Snapshot {
+	manual := []Snapshot{}
+	for _, snapshot := range snapshots {
+		if snapshot.Manual {
+			manual = append(manual, snapshot)
+		}
+	}
+	if retention <= 0 || int64(len(manual)) <= retention {
+		return nil
+	}
+
+	// Snapshots that are still being created have no creation time yet, and
+	// are the newest.
+	sort.SliceStable(manual, func(i, j int) bool {
+		if manual[j].CreatedAt == nil {
+			return manual[i].CreatedAt != nil
+		}
+		return manual[i].CreatedAt != nil && manual[i].CreatedAt.Before(*manual[j].CreatedAt)
+	})
+	return manual[:int64(len(manual))-retention]
+}
@@ -0,0 +1,49 @@
+package base
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestValidateSnapshotName(t *testing.T) {
+	for _, name := range []string{"pre-migration", "v2", "Release-2026-10-18"} {
+		if err := ValidateSnapshotName(name); err != nil {
+			t.Errorf("expected %q to be valid, got %s", name, err)
+		}
+	}
+	for _, name := range []string{"", "2026", "pre--migration", "pre-", "pre_migration", "pre migration", "a-very-long-snapshot-name-that-exceeds-the-limit"} {
+		if err := ValidateSnapshotName(name); err == nil {
+			t.Errorf("expected %q to be invalid", name)
+		}
+	}
+}
+
+func TestExpiredSnapshots(t *testing.T) {
+	day := func(d int) *time.Time {
+		created := time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC)
+		return &created
+	}
+	snapshots := []Snapshot{
+		{Name: "db-manual-c", CreatedAt: day(3), Manual: true},
+		{Name: "automated", CreatedAt: day(1)},
+		{Name: "db-manual-new", Manual: true},
+		{Name: "db-manual-a", CreatedAt: day(1), Manual: true},
+		{Name: "db-manual-b", CreatedAt: day(2), Manual: true},
+	}
+
+	expected := []Snapshot{
+		{Name: "db-manual-a", CreatedAt: day(1), Manual: true},
+		{Name: "db-manual-b", CreatedAt: day(2), Manual: true},
+	}
+	if diff := deep.Equal(ExpiredSnapshots(snapshots, 2), expected); diff != nil {
+		t.Error(diff)
+	}
+	if expired := ExpiredSnapshots(snapshots, 4); len(expired) != 0 {
+		t.Errorf("expected no expired snapshots, got %v", expired)
+	}
+	if expired := ExpiredSnapshots(snapshots, 0); len(expired) != 0 {
+		t.Errorf("expected snapshots to be kept without a retention count, got %v", expired)
+	}
+}
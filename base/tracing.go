@@ -0,0 +1,137 @@
+package base
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/18F/aws-broker/catalog"
+	"github.com/18F/aws-broker/helpers/request"
+	"github.com/18F/aws-broker/helpers/response"
+	"github.com/18F/aws-broker/helpers/tracing"
+)
+
+// tracedBroker wraps a Broker so that each of its operations is recorded as a span.
+type tracedBroker struct {
+	name   string
+	broker Broker
+}
+
+// NewTracedBroker returns a Broker that records a span named after the service
+// for each operation of the given broker.
+func NewTracedBroker(name string, broker Broker) Broker {
+	return &tracedBroker{name: name, broker: broker}
+}
+
+func (t *tracedBroker) start(ctx context.Context, op Operation, id string, req request.Request) (context.Context, trace.Span) {
+	return tracing.Start(ctx, t.name+"."+op.String(),
+		tracing.InstanceGUIDKey.String(id),
+		tracing.ServiceKey.String(req.ServiceID),
+		tracing.PlanKey.String(req.PlanID),
+		tracing.OrganizationKey.String(req.OrganizationGUID),
+		tracing.SpaceKey.String(req.SpaceGUID),
+		tracing.OperationKey.String(op.String()),
+	)
+}
+
+func end(span trace.Span, resp response.Response) {
+	span.SetAttributes(attribute.Int("broker.status_code", resp.GetStatusCode()))
+	if resp.GetResponseType() == response.ErrorResponseType {
+		span.SetStatus(codes.Error, string(resp.GetResponseType()))
+	}
+	span.End()
+}
+
+func (t *tracedBroker) CreateInstance(ctx context.Context, c *catalog.Catalog, id string, req request.Request) response.Response {
+	ctx, span := t.start(ctx, CreateOp, id, req)
+	resp := t.broker.CreateInstance(ctx, c, id, req)
+	end(span, resp)
+	return resp
+}
+
+func (t *tracedBroker) ModifyInstance(ctx context.Context, c *catalog.Catalog, id string, req request.Request, i Instance) response.Response {
+	ctx, span := t.start(ctx, ModifyOp, id, req)
+	resp := t.broker.ModifyInstance(ctx, c, id, req, i)
+	end(span, resp)
+	return resp
+}
+
+func (t *tracedBroker) LastOperation(ctx context.Context, c *catalog.Catalog, id string, i Instance, operation string) response.Response {
+	ctx, span := tracing.Start(ctx, t.name+".last-operation",
+		tracing.InstanceGUIDKey.String(id),
+		tracing.ServiceKey.String(i.ServiceID),
+		tracing.PlanKey.String(i.PlanID),
+		tracing.OperationKey.String(operation),
+	)
+	resp := t.broker.LastOperation(ctx, c, id, i, operation)
+	end(span, resp)
+	return resp
+}
+
+func (t *tracedBroker) BindInstance(ctx context.Context, c *catalog.Catalog, id string, req request.Request, i Instance) response.Response {
+	ctx, span := t.start(ctx, BindOp, id, i.Request)
+	resp := t.broker.BindInstance(ctx, c, id, req, i)
+	end(span, resp)
+	return resp
+}
+
+func (t *tracedBroker) DeleteInstance(ctx context.Context, c *catalog.Catalog, id string, i Instance) response.Response {
+	ctx, span := t.start(ctx, DeleteOp, id, i.Request)
+	resp := t.broker.DeleteInstance(ctx, c, id, i)
+	end(span, resp)
+	return resp
+}
+
+func (t *tracedBroker) DescribeInstance(ctx context.Context, c *catalog.Catalog, id string, i Instance) (InstanceDetail, response.Response) {
+	ctx, span := tracing.Start(ctx, t.name+".describe", tracing.InstanceGUIDKey.String(id))
+	defer span.End()
+	return t.broker.DescribeInstance(ctx, c, id, i)
+}
+
+func (t *tracedBroker) SetInstanceState(ctx context.Context, c *catalog.Catalog, id string, i Instance, state InstanceState) response.Response {
+	ctx, span := tracing.Start(ctx, t.name+".set-state",
+		tracing.InstanceGUIDKey.String(id),
+		attribute.String("broker.state", state.String()),
+	)
+	defer span.End()
+	return t.broker.SetInstanceState(ctx, c, id, i, state)
+}
+
+func (t *tracedBroker) InstanceCredentials(ctx context.Context, c *catalog.Catalog, id string, i Instance) (map[string]string, response.Response) {
+	ctx, span := tracing.Start(ctx, t.name+".credentials", tracing.InstanceGUIDKey.String(id))
+	defer span.End()
+	return t.broker.InstanceCredentials(ctx, c, id, i)
+}
+
+func (t *tracedBroker) CreateSnapshot(ctx context.Context, c *catalog.Catalog, id string, i Instance, name string) (Snapshot, response.Response) {
+	ctx, span := tracing.Start(ctx, t.name+".create-snapshot",
+		tracing.InstanceGUIDKey.String(id),
+		attribute.String("broker.snapshot", name),
+	)
+	defer span.End()
+	return t.broker.CreateSnapshot(ctx, c, id, i, name)
+}
+
+func (t *tracedBroker) ListSnapshots(ctx context.Context, c *catalog.Catalog, id string, i Instance) ([]Snapshot, response.Response) {
+	ctx, span := tracing.Start(ctx, t.name+".list-snapshots", tracing.InstanceGUIDKey.String(id))
+	defer span.End()
+	return t.broker.ListSnapshots(ctx, c, id, i)
+}
+
+func (t *tracedBroker) SuspendInstance(ctx context.Context, c *catalog.Catalog, id string, i Instance) response.Response {
+	ctx, span := tracing.Start(ctx, t.name+".suspend", tracing.InstanceGUIDKey.String(id))
+	defer span.End()
+	return t.broker.SuspendInstance(ctx, c, id, i)
+}
+
+func (t *tracedBroker) ResumeInstance(ctx context.Context, c *catalog.Catalog, id string, i Instance) response.Response {
+	ctx, span := tracing.Start(ctx, t.name+".resume", tracing.InstanceGUIDKey.String(id))
+	defer span.End()
+	return t.broker.ResumeInstance(ctx, c, id, i)
+}
+
+func (t *tracedBroker) AsyncOperationRequired(c *catalog.Catalog, i Instance, o Operation) bool {
+	return t.broker.AsyncOperationRequired(c, i, o)
+}
@@ -2,13 +2,15 @@ package catalog
 
 import (
 	"io/ioutil"
-	"log"
+	"os"
 	"path/filepath"
 
 	"errors"
+	"fmt"
 	"net/http"
 	"reflect"
 
+	"code.cloudfoundry.org/lager"
 	"github.com/18F/aws-broker/helpers/response"
 	"gopkg.in/go-playground/validator.v8"
 	"gopkg.in/yaml.v2"
@@ -63,6 +65,50 @@ var (
 type RDSService struct {
 	Service `yaml:",inline" validate:"required"`
 	Plans   []RDSPlan `yaml:"plans" json:"plans" validate:"required,dive,required"`
+	// AllowedDBParameters lists, per database engine, the parameters that
+	// tenants can set with the "db_parameters" parameter.
+	AllowedDBParameters map[string][]string `yaml:"allowedDbParameters" json:"-"`
+	// AllowedExtensions lists, per PostgreSQL major version, the extensions
+	// that tenants can create with the "extensions" parameter.
+	AllowedExtensions map[string][]string `yaml:"allowedExtensions" json:"-"`
+}
+
+// validatePlans checks the settings of the plans that depend on each other.
+// Encrypted snapshots can only be copied to a DR region with a key of that
+// region.
+func (s RDSService) validatePlans() error {
+	for _, plan := range s.Plans {
+		// Only the DB snapshots of dedicated instances are copied.
+		if plan.DRRegion != "" && plan.Adapter != "dedicated" {
+			return fmt.Errorf("plan %s has a dr_region, which is only supported for dedicated plans", plan.ID)
+		}
+		if plan.Encrypted && plan.DRRegion != "" && plan.DRKMSKeyID == "" {
+			return fmt.Errorf("plan %s is encrypted and has a dr_region, but no dr_kms_key_id", plan.ID)
+		}
+	}
+	return nil
+}
+
+// CheckExtension verifies that a PostgreSQL extension can be created by
+// tenants on instances of the given major version.
+func (s RDSService) CheckExtension(majorVersion string, extension string) bool {
+	for _, allowedExtension := range s.AllowedExtensions[majorVersion] {
+		if extension == allowedExtension {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckDBParameter verifies that a database parameter can be set by tenants
+// on instances of the given database engine.
+func (s RDSService) CheckDBParameter(dbType string, parameterName string) bool {
+	for _, allowedParameter := range s.AllowedDBParameters[dbType] {
+		if parameterName == allowedParameter {
+			return true
+		}
+	}
+	return false
 }
 
 // FetchPlan will look for a specific RDS Plan based on the plan ID.
@@ -94,6 +140,37 @@ type RDSPlan struct {
 	SubnetGroup           string            `yaml:"subnetGroup" json:"-" validate:"required"`
 	SecurityGroup         string            `yaml:"securityGroup" json:"-" validate:"required"`
 	ApprovedMajorVersions []string          `yaml:"approvedMajorVersions" json:"-"`
+	SkipFinalSnapshot     bool              `yaml:"skip_final_snapshot" json:"-"`
+	// ReaderInstances is the number of reader instances of the clusters of
+	// plans using the aurora adapter.
+	ReaderInstances int64 `yaml:"readerInstances" json:"-"`
+	// ServerlessMinCapacity and ServerlessMaxCapacity are the Aurora Serverless
+	// v2 capacity range, in ACUs, of plans with the db.serverless instance class.
+	ServerlessMinCapacity float64 `yaml:"serverlessMinCapacity" json:"-"`
+	ServerlessMaxCapacity float64 `yaml:"serverlessMaxCapacity" json:"-"`
+	// IAMDatabaseAuthentication enables IAM database authentication on the
+	// instances of the plan, which are bound with AWS keys instead of a
+	// database password.
+	IAMDatabaseAuthentication bool `yaml:"iamDatabaseAuthentication" json:"-"`
+	// PreferredMaintenanceWindow ("ddd:hh24:mi-ddd:hh24:mi") and
+	// PreferredBackupWindow ("hh24:mi-hh24:mi") are the default windows, in
+	// UTC, of the instances of the plan.
+	PreferredMaintenanceWindow string `yaml:"preferredMaintenanceWindow" json:"-"`
+	PreferredBackupWindow      string `yaml:"preferredBackupWindow" json:"-"`
+	// CredentialRotationDays is the number of days after which the master
+	// credentials of the instances of the plan are rotated, or 0 to only
+	// rotate them on request.
+	CredentialRotationDays int64 `yaml:"credentialRotationDays" json:"-"`
+	// ManageMasterUserPassword has RDS manage the master passwords of the
+	// dedicated instances of the plan in AWS Secrets Manager, encrypted with
+	// MasterUserSecretKMSKeyID or the default key of Secrets Manager.
+	ManageMasterUserPassword bool   `yaml:"manageMasterUserPassword" json:"-"`
+	MasterUserSecretKMSKeyID string `yaml:"masterUserSecretKmsKeyId" json:"-"`
+	// DRRegion is the region the snapshots of the dedicated instances of the
+	// plan are copied to for disaster recovery, encrypted with DRKMSKeyID.
+	// Encrypted snapshots can only be copied with a key of the DR region.
+	DRRegion   string `yaml:"dr_region" json:"-"`
+	DRKMSKeyID string `yaml:"dr_kms_key_id" json:"-"`
 }
 
 // CheckVersion verifies that a specific version chosen by the user for a new
@@ -268,6 +345,45 @@ func (c *Catalog) GetServices() []interface{} {
 	return services
 }
 
+// FindServiceID returns the ID of the service with the given name or ID.
+func (c *Catalog) FindServiceID(nameOrID string) (string, bool) {
+	for _, service := range []Service{c.RdsService.Service, c.RedisService.Service, c.ElasticsearchService.Service} {
+		if nameOrID == service.ID || nameOrID == service.Name {
+			return service.ID, true
+		}
+	}
+	return "", false
+}
+
+// LookupNames returns the names of the service and plan with the given IDs.
+// Names that are not in the catalog are returned as empty strings.
+func (c *Catalog) LookupNames(serviceID, planID string) (serviceName string, planName string) {
+	var plans []Plan
+	switch serviceID {
+	case c.RdsService.ID:
+		serviceName = c.RdsService.Name
+		for _, plan := range c.RdsService.Plans {
+			plans = append(plans, plan.Plan)
+		}
+	case c.RedisService.ID:
+		serviceName = c.RedisService.Name
+		for _, plan := range c.RedisService.Plans {
+			plans = append(plans, plan.Plan)
+		}
+	case c.ElasticsearchService.ID:
+		serviceName = c.ElasticsearchService.Name
+		for _, plan := range c.ElasticsearchService.Plans {
+			plans = append(plans, plan.Plan)
+		}
+	}
+	for _, plan := range plans {
+		if plan.ID == planID {
+			planName = plan.Name
+		}
+	}
+	return serviceName, planName
+}
+
 // GetResources returns the resources wrapper for all the resources generated from the secrets.
 func (c *Catalog) GetResources() Resources {
 	return c.resources
@@ -275,38 +391,44 @@ func (c *Catalog) GetResources() Resources {
 
 // InitCatalog initializes a Catalog struct that contains services and plans
 // defined in the catalog.yaml configuration file and returns a pointer to that catalog
-func InitCatalog(path string) *Catalog {
+func InitCatalog(path string, logger lager.Logger) *Catalog {
 	var catalog Catalog
 	catalogFile := filepath.Join(path, "catalog.yml")
 	data, err := ioutil.ReadFile(catalogFile)
 	if err != nil {
-		log.Fatalf("error: %v", err)
+		logger.Error("read-catalog", err, lager.Data{"file": catalogFile})
+		os.Exit(1)
 	}
 	err = yaml.Unmarshal(data, &catalog)
 	if err != nil {
-		log.Fatalf("error: %v", err)
+		logger.Error("parse-catalog", err, lager.Data{"file": catalogFile})
+		os.Exit(1)
 	}
 
 	config := &validator.Config{TagName: "validate"}
 
 	validate := validator.New(config)
 	validateErr := validate.Struct(catalog)
+	if validateErr == nil {
+		validateErr = catalog.RdsService.validatePlans()
+	}
 	if validateErr != nil {
-		log.Println(validateErr)
+		logger.Error("validate-catalog", validateErr)
 		return nil
 	}
 
-	err = catalog.loadServicesResources(path)
+	err = catalog.loadServicesResources(path, logger)
 	if err != nil {
-		log.Fatalf("error: %v", err)
+		logger.Error("load-service-resources", err)
+		os.Exit(1)
 	}
 
 	return &catalog
 }
 
-func (c *Catalog) loadServicesResources(path string) error {
+func (c *Catalog) loadServicesResources(path string, logger lager.Logger) error {
 	// Load secrets
-	secrets := InitSecrets(path)
+	secrets := InitSecrets(path, logger)
 	if secrets == nil {
 		return errors.New("Unable to load secrets.")
 	}
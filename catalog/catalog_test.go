@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"code.cloudfoundry.org/lager/lagertest"
 )
 
 var rdsPGTestPlanID = "da91e15c-98c9-46a9-b114-02b8d28062c6"
@@ -26,7 +28,7 @@ func checkedGetwd(t *testing.T) string {
 func TestInitCatalog(t *testing.T) {
 	wd := checkedGetwd(t)
 	path := filepath.Join(wd, "..")
-	catalog := InitCatalog(path)
+	catalog := InitCatalog(path, lagertest.NewTestLogger("catalog-test"))
 	if catalog == nil {
 		t.Error("Did not read catalog")
 	}
@@ -35,7 +37,7 @@ func TestInitCatalog(t *testing.T) {
 func TestFetchPlan(t *testing.T) {
 	wd := checkedGetwd(t)
 	path := filepath.Join(wd, "..")
-	catalog := InitCatalog(path)
+	catalog := InitCatalog(path, lagertest.NewTestLogger("catalog-test"))
 
 	_, err := catalog.RdsService.FetchPlan(rdsPGTestPlanID)
 
@@ -47,7 +49,7 @@ func TestFetchPlan(t *testing.T) {
 func TestRDSPGCheckVersion(t *testing.T) {
 	wd := checkedGetwd(t)
 	path := filepath.Join(wd, "..")
-	catalog := InitCatalog(path)
+	catalog := InitCatalog(path, lagertest.NewTestLogger("catalog-test"))
 
 	plan, err := catalog.RdsService.FetchPlan(rdsPGTestPlanID)
 
@@ -73,7 +75,7 @@ func TestRDSPGCheckVersion(t *testing.T) {
 func TestRDSMySQLCheckVersion(t *testing.T) {
 	wd := checkedGetwd(t)
 	path := filepath.Join(wd, "..")
-	catalog := InitCatalog(path)
+	catalog := InitCatalog(path, lagertest.NewTestLogger("catalog-test"))
 
 	plan, err := catalog.RdsService.FetchPlan(rdsMySQLTestPlanID)
 
@@ -99,7 +101,7 @@ func TestRDSMySQLCheckVersion(t *testing.T) {
 func TestRDSCheckVersionEmpty(t *testing.T) {
 	wd := checkedGetwd(t)
 	path := filepath.Join(wd, "..")
-	catalog := InitCatalog(path)
+	catalog := InitCatalog(path, lagertest.NewTestLogger("catalog-test"))
 
 	plan, err := catalog.RdsService.FetchPlan(rdsPGTestPlanID)
 
@@ -115,3 +117,87 @@ func TestRDSCheckVersionEmpty(t *testing.T) {
 		t.Error("Empty RDS version check failed.")
 	}
 }
+
+func TestFindServiceID(t *testing.T) {
+	wd := checkedGetwd(t)
+	path := filepath.Join(wd, "..")
+	catalog := InitCatalog(path, lagertest.NewTestLogger("catalog-test"))
+
+	byName, ok := catalog.FindServiceID("rds")
+	if !ok || byName != catalog.RdsService.ID {
+		t.Errorf("expected rds to resolve to %s, got %s", catalog.RdsService.ID, byName)
+	}
+	byID, ok := catalog.FindServiceID(catalog.RedisService.ID)
+	if !ok || byID != catalog.RedisService.ID {
+		t.Errorf("expected %s to resolve to itself, got %s", catalog.RedisService.ID, byID)
+	}
+	if _, ok := catalog.FindServiceID("unknown"); ok {
+		t.Error("expected unknown service not to be found")
+	}
+}
+
+func TestLookupNames(t *testing.T) {
+	wd := checkedGetwd(t)
+	path := filepath.Join(wd, "..")
+	catalog := InitCatalog(path, lagertest.NewTestLogger("catalog-test"))
+
+	serviceName, planName := catalog.LookupNames(catalog.RdsService.ID, rdsPGTestPlanID)
+	if serviceName != "rds" || planName != "micro-psql" {
+		t.Errorf("unexpected names %q and %q", serviceName, planName)
+	}
+	serviceName, planName = catalog.LookupNames("unknown", rdsPGTestPlanID)
+	if serviceName != "" || planName != "" {
+		t.Errorf("expected no names, got %q and %q", serviceName, planName)
+	}
+}
+
+func TestRDSCheckDBParameter(t *testing.T) {
+	wd := checkedGetwd(t)
+	path := filepath.Join(wd, "..")
+	catalog := InitCatalog(path, lagertest.NewTestLogger("catalog-test"))
+
+	if !catalog.RdsService.CheckDBParameter("postgres", "work_mem") {
+		t.Error("expected work_mem to be allowed for postgres")
+	}
+	if catalog.RdsService.CheckDBParameter("mysql", "work_mem") {
+		t.Error("expected work_mem not to be allowed for mysql")
+	}
+	if catalog.RdsService.CheckDBParameter("postgres", "shared_buffers") {
+		t.Error("expected shared_buffers not to be allowed for postgres")
+	}
+}
+
+func TestRDSCheckExtension(t *testing.T) {
+	wd := checkedGetwd(t)
+	path := filepath.Join(wd, "..")
+	catalog := InitCatalog(path, lagertest.NewTestLogger("catalog-test"))
+
+	if !catalog.RdsService.CheckExtension("15", "postgis") {
+		t.Error("expected postgis to be allowed for PostgreSQL 15")
+	}
+	if catalog.RdsService.CheckExtension("12", "postgis") {
+		t.Error("expected postgis not to be allowed for PostgreSQL 12")
+	}
+	if catalog.RdsService.CheckExtension("15", "plperl") {
+		t.Error("expected plperl not to be allowed for PostgreSQL 15")
+	}
+}
+
+func TestRDSValidatePlans(t *testing.T) {
+	plan := RDSPlan{Adapter: "dedicated", Encrypted: true, DRRegion: "us-west-2"}
+	plan.ID = "plan"
+	s := RDSService{Plans: []RDSPlan{plan}}
+	if err := s.validatePlans(); err == nil {
+		t.Error("expected an encrypted plan with a DR region but no DR key to be rejected")
+	}
+
+	s.Plans[0].DRKMSKeyID = "dr-key"
+	if err := s.validatePlans(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	s.Plans[0].Adapter = "aurora"
+	if err := s.validatePlans(); err == nil {
+		t.Error("expected an Aurora plan with a DR region to be rejected")
+	}
+}
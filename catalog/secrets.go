@@ -2,9 +2,10 @@ package catalog
 
 import (
 	"io/ioutil"
-	"log"
+	"os"
 	"path/filepath"
 
+	"code.cloudfoundry.org/lager"
 	"github.com/18F/aws-broker/common"
 	"gopkg.in/go-playground/validator.v8"
 	"gopkg.in/yaml.v2"
@@ -56,16 +57,18 @@ type ElasticsearchDBSecret struct {
 }
 
 // InitSecrets initializes the secrets struct based on the yaml file.
-func InitSecrets(path string) *Secrets {
+func InitSecrets(path string, logger lager.Logger) *Secrets {
 	var secrets Secrets
 	secretsFile := filepath.Join(path, "secrets.yml")
 	data, err := ioutil.ReadFile(secretsFile)
 	if err != nil {
-		log.Fatalf("error: %v", err)
+		logger.Error("read-secrets", err, lager.Data{"file": secretsFile})
+		os.Exit(1)
 	}
 	err = yaml.Unmarshal(data, &secrets)
 	if err != nil {
-		log.Fatalf("error: %v", err)
+		logger.Error("parse-secrets", err, lager.Data{"file": secretsFile})
+		os.Exit(1)
 	}
 
 	config := &validator.Config{TagName: "validate"}
@@ -73,7 +76,7 @@ func InitSecrets(path string) *Secrets {
 	validate := validator.New(config)
 	validateErr := validate.Struct(secrets)
 	if validateErr != nil {
-		log.Println(validateErr)
+		logger.Error("validate-secrets", validateErr)
 		return nil
 	}
 	return &secrets
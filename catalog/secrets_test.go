@@ -4,12 +4,14 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"code.cloudfoundry.org/lager/lagertest"
 )
 
 func TestInitSecrets(t *testing.T) {
 	wd, _ := os.Getwd()
 	path := filepath.Join(wd, "..")
-	secrets := InitSecrets(path)
+	secrets := InitSecrets(path, lagertest.NewTestLogger("catalog-test"))
 	if secrets == nil {
 		t.Error("Did not read catalog")
 	}
@@ -28,6 +28,10 @@ meta:
     mysql_security_group: `${TERRAFORM} output -raw -state=stack.tfstate rds_mysql_security_group`
     oracle_security_group: `${TERRAFORM} output -raw -state=stack.tfstate rds_oracle_security_group`
     mssql_security_group: `${TERRAFORM} output -raw -state=stack.tfstate rds_mssql_security_group`
+    # The DR region and key of the DR plans are optional; without them no
+    # snapshots are copied.
+    dr_region: "`${TERRAFORM} output -raw -state=stack.tfstate rds_dr_region 2>/dev/null || true`"
+    dr_kms_key_id: "`${TERRAFORM} output -raw -state=stack.tfstate rds_dr_kms_key_id 2>/dev/null || true`"
   redis:
     subnet_group: `${TERRAFORM} output -raw -state stack.tfstate elasticache_subnet_group`
     security_group: `${TERRAFORM} output -raw -state stack.tfstate elasticache_redis_security_group`
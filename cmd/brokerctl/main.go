@@ -0,0 +1,447 @@
+// Command brokerctl lets operators inspect and repair the broker's service
+// instances without editing the broker database by hand.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/jinzhu/gorm"
+
+	"github.com/18F/aws-broker/admin"
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/catalog"
+	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/db"
+	"github.com/18F/aws-broker/helpers/logging"
+	"github.com/18F/aws-broker/helpers/response"
+	"github.com/18F/aws-broker/helpers/tracing"
+	"github.com/18F/aws-broker/taskqueue"
+)
+
+const usage = `Usage: brokerctl <command> [flags]
+
+Commands:
+  list                                   List instances, optionally filtered
+  show <instance-guid>                   Show the record and AWS status of an instance
+  credentials -reason <reason> <guid>    Decrypt the credentials of an instance
+  mark -state <failed|ready> <guid>      Force the state of an instance
+  purge [-confirm]                       Remove instances whose AWS resource is gone
+  snapshots <instance-guid>              List the snapshots of an instance
+  snapshot -name <name> <guid>           Take a manual snapshot of an instance
+  deletions                              List the instances pending deletion
+  restore <instance-guid>                Cancel the pending deletion of an instance
+  delete <instance-guid>                 Delete a restored instance on the next deletion run
+  migrate <up|down|status> [-steps n]    Apply, revert or list database migrations
+
+The list and purge commands accept the filters -service, -plan, -org, -space and -state.
+`
+
+// cli holds the dependencies shared by every command.
+type cli struct {
+	ctx       context.Context
+	catalog   *catalog.Catalog
+	db        *gorm.DB
+	settings  *config.Settings
+	taskqueue *taskqueue.QueueManager
+	logger    lager.Logger
+}
+
+// instanceFilterFlags registers the instance filter flags on the flag set.
+type instanceFilterFlags struct {
+	service, plan, org, space, state *string
+}
+
+func newInstanceFilterFlags(fs *flag.FlagSet) instanceFilterFlags {
+	return instanceFilterFlags{
+		service: fs.String("service", "", "Only include instances of this service, by name or ID"),
+		plan:    fs.String("plan", "", "Only include instances of this plan ID"),
+		org:     fs.String("org", "", "Only include instances in this organization GUID"),
+		space:   fs.String("space", "", "Only include instances in this space GUID"),
+		state:   fs.String("state", "", "Only include instances in this state, e.g. InstanceInProgress"),
+	}
+}
+
+func (f instanceFilterFlags) filter(c *catalog.Catalog) (base.InstanceFilter, error) {
+	filter := base.InstanceFilter{
+		PlanID:           *f.plan,
+		OrganizationGUID: *f.org,
+		SpaceGUID:        *f.space,
+	}
+	if *f.service != "" {
+		serviceID, ok := c.FindServiceID(*f.service)
+		if !ok {
+			return filter, fmt.Errorf("unknown service %q", *f.service)
+		}
+		filter.ServiceID = serviceID
+	}
+	if *f.state != "" {
+		state, err := base.ParseInstanceState(*f.state)
+		if err != nil {
+			return filter, err
+		}
+		filter.State = &state
+	}
+	return filter, nil
+}
+
+// parseMarkState parses the state given to the mark command. "failed" and
+// "ready" are accepted along with the names of every instance state.
+func parseMarkState(s string) (base.InstanceState, error) {
+	switch strings.ToLower(s) {
+	case "failed":
+		return base.InstanceNotCreated, nil
+	case "ready":
+		return base.InstanceReady, nil
+	}
+	return base.ParseInstanceState(s)
+}
+
+// responseError converts an error response of the broker to an error.
+func responseError(resp response.Response) error {
+	body, _ := json.Marshal(resp)
+	return fmt.Errorf("status %d: %s", resp.GetStatusCode(), body)
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// instanceArg returns the single instance GUID given to a command.
+func instanceArg(fs *flag.FlagSet) (string, error) {
+	if fs.NArg() != 1 {
+		return "", fmt.Errorf("%s requires exactly one instance GUID", fs.Name())
+	}
+	return fs.Arg(0), nil
+}
+
+func (c *cli) list(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	filterFlags := newInstanceFilterFlags(fs)
+	fs.Parse(args)
+
+	filter, err := filterFlags.filter(c.catalog)
+	if err != nil {
+		return err
+	}
+	instances, err := admin.ListInstances(c.catalog, c.db, filter)
+	if err != nil {
+		return err
+	}
+	return printJSON(instances)
+}
+
+func (c *cli) show(args []string) error {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	fs.Parse(args)
+	id, err := instanceArg(fs)
+	if err != nil {
+		return err
+	}
+
+	logger := c.logger.Session("show", lager.Data{logging.InstanceGUIDKey: id})
+	detail, resp := admin.DescribeInstance(c.ctx, c.catalog, c.db, id, c.settings, c.taskqueue, logger)
+	if resp != nil {
+		return responseError(resp)
+	}
+	return printJSON(detail)
+}
+
+func (c *cli) credentials(args []string) error {
+	fs := flag.NewFlagSet("credentials", flag.ExitOnError)
+	reason := fs.String("reason", "", "Why the credentials are needed, e.g. a ticket reference (required)")
+	fs.Parse(args)
+	id, err := instanceArg(fs)
+	if err != nil {
+		return err
+	}
+	if *reason == "" {
+		return errors.New("credentials requires -reason")
+	}
+
+	logger := c.logger.Session("credentials", lager.Data{logging.InstanceGUIDKey: id})
+	credentials, resp := admin.InstanceCredentials(c.ctx, c.catalog, c.db, id, *reason, c.settings, c.taskqueue, logger)
+	if resp != nil {
+		return responseError(resp)
+	}
+	return printJSON(credentials)
+}
+
+func (c *cli) mark(args []string) error {
+	fs := flag.NewFlagSet("mark", flag.ExitOnError)
+	stateName := fs.String("state", "", "The state to set: failed, ready or the name of an instance state (required)")
+	fs.Parse(args)
+	id, err := instanceArg(fs)
+	if err != nil {
+		return err
+	}
+	if *stateName == "" {
+		return errors.New("mark requires -state")
+	}
+	state, err := parseMarkState(*stateName)
+	if err != nil {
+		return err
+	}
+
+	logger := c.logger.Session("mark", lager.Data{logging.InstanceGUIDKey: id})
+	instance, resp := admin.SetInstanceState(c.ctx, c.catalog, c.db, id, state, c.settings, c.taskqueue, logger)
+	if resp != nil {
+		return responseError(resp)
+	}
+	return printJSON(instance)
+}
+
+func (c *cli) purge(args []string) error {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	filterFlags := newInstanceFilterFlags(fs)
+	confirm := fs.Bool("confirm", false, "Remove the instances rather than only listing them")
+	fs.Parse(args)
+
+	filter, err := filterFlags.filter(c.catalog)
+	if err != nil {
+		return err
+	}
+	instances, err := admin.ListInstances(c.catalog, c.db, filter)
+	if err != nil {
+		return err
+	}
+
+	gone := []admin.InstanceSummary{}
+	for _, instance := range instances {
+		logger := c.logger.Session("purge", lager.Data{logging.InstanceGUIDKey: instance.InstanceGUID})
+		detail, resp := admin.DescribeInstance(c.ctx, c.catalog, c.db, instance.InstanceGUID, c.settings, c.taskqueue, logger)
+		if resp != nil {
+			logger.Error("describe-instance", responseError(resp))
+			continue
+		}
+		if !detail.ResourceGone {
+			continue
+		}
+		if *confirm {
+			if resp := admin.PurgeInstance(c.ctx, c.catalog, c.db, instance.InstanceGUID, c.settings, c.taskqueue, logger); resp != nil {
+				logger.Error("purge-instance", responseError(resp))
+				continue
+			}
+		}
+		gone = append(gone, instance)
+	}
+
+	if !*confirm {
+		fmt.Fprintf(os.Stderr, "%d instances have no AWS resource. Run again with -confirm to remove them.\n", len(gone))
+	}
+	return printJSON(gone)
+}
+
+func (c *cli) snapshots(args []string) error {
+	fs := flag.NewFlagSet("snapshots", flag.ExitOnError)
+	fs.Parse(args)
+	id, err := instanceArg(fs)
+	if err != nil {
+		return err
+	}
+
+	logger := c.logger.Session("snapshots", lager.Data{logging.InstanceGUIDKey: id})
+	snapshots, resp := admin.ListSnapshots(c.ctx, c.catalog, c.db, id, c.settings, c.taskqueue, logger)
+	if resp != nil {
+		return responseError(resp)
+	}
+	return printJSON(snapshots)
+}
+
+func (c *cli) snapshot(args []string) error {
+	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	name := fs.String("name", "", "The name of the snapshot, e.g. pre-migration (required)")
+	fs.Parse(args)
+	id, err := instanceArg(fs)
+	if err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("snapshot requires -name")
+	}
+
+	logger := c.logger.Session("snapshot", lager.Data{logging.InstanceGUIDKey: id})
+	snapshot, resp := admin.CreateSnapshot(c.ctx, c.catalog, c.db, id, *name, c.settings, c.taskqueue, logger)
+	if resp != nil {
+		return responseError(resp)
+	}
+	return printJSON(snapshot)
+}
+
+func (c *cli) deletions(args []string) error {
+	fs := flag.NewFlagSet("deletions", flag.ExitOnError)
+	fs.Parse(args)
+
+	deletions, err := admin.ListPendingDeletions(c.catalog, c.db)
+	if err != nil {
+		return err
+	}
+	return printJSON(deletions)
+}
+
+func (c *cli) restore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	fs.Parse(args)
+	id, err := instanceArg(fs)
+	if err != nil {
+		return err
+	}
+
+	logger := c.logger.Session("restore", lager.Data{logging.InstanceGUIDKey: id})
+	if resp := admin.RestoreInstance(c.ctx, c.catalog, c.db, id, c.settings, c.taskqueue, logger); resp != nil {
+		return responseError(resp)
+	}
+	fmt.Fprintln(os.Stderr, "The instance was restored.")
+	return nil
+}
+
+func (c *cli) delete(args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	fs.Parse(args)
+	id, err := instanceArg(fs)
+	if err != nil {
+		return err
+	}
+
+	logger := c.logger.Session("delete", lager.Data{logging.InstanceGUIDKey: id})
+	if resp := admin.DeleteInstance(c.catalog, c.db, id, c.settings, c.taskqueue, time.Now(), logger); resp != nil {
+		return responseError(resp)
+	}
+	fmt.Fprintln(os.Stderr, "The instance will be deleted on the next deletion run.")
+	return nil
+}
+
+func (c *cli) migrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	steps := fs.Int("steps", 1, "The number of migrations to revert with down")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("migrate requires one of up, down or status")
+	}
+
+	switch fs.Arg(0) {
+	case "up":
+		if err := db.Migrate(c.db, c.logger); err != nil {
+			return err
+		}
+	case "down":
+		if *steps < 1 {
+			return errors.New("-steps must be at least 1")
+		}
+		c.logger.Info("rollback-migrations", lager.Data{"steps": *steps})
+		if err := db.Rollback(c.db, *steps, c.logger); err != nil {
+			return err
+		}
+	case "status":
+	default:
+		return fmt.Errorf("unknown migrate command %q", fs.Arg(0))
+	}
+
+	statuses, err := db.Status(c.db)
+	if err != nil {
+		return err
+	}
+	return printJSON(statuses)
+}
+
+// operator returns the name of the local user running brokerctl, which is
+// recorded with every action for auditing.
+func operator() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
+
+func run() error {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return errors.New("a command is required")
+	}
+	command, args := flag.Arg(0), flag.Args()[1:]
+
+	var settings config.Settings
+
+	// Load settings from environment
+	if err := settings.LoadFromEnv(); err != nil {
+		return fmt.Errorf("there was an error loading settings: %w", err)
+	}
+
+	brokerDb, err := db.InternalDBInit(settings.DbConfig)
+	if err != nil {
+		return fmt.Errorf("there was an error with the DB. Error: %s", err.Error())
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), "brokerctl", settings.TracingEndpoint)
+	if err != nil {
+		return fmt.Errorf("could not initialize tracing: %s", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	ctx, span := tracing.Start(context.Background(), "brokerctl."+command)
+	defer span.End()
+
+	path, _ := os.Getwd()
+	logger := logging.NewLogger("brokerctl", os.Stderr, lager.INFO).WithData(lager.Data{
+		"operator": operator(),
+	})
+	brokerCatalog := catalog.InitCatalog(path, logger)
+	if brokerCatalog == nil {
+		return errors.New("there was an error loading the catalog")
+	}
+	c := &cli{
+		ctx:       ctx,
+		catalog:   brokerCatalog,
+		db:        brokerDb,
+		settings:  &settings,
+		taskqueue: taskqueue.NewQueueManager(),
+		logger:    logger,
+	}
+
+	switch command {
+	case "list":
+		return c.list(args)
+	case "show":
+		return c.show(args)
+	case "credentials":
+		return c.credentials(args)
+	case "mark":
+		return c.mark(args)
+	case "purge":
+		return c.purge(args)
+	case "snapshots":
+		return c.snapshots(args)
+	case "snapshot":
+		return c.snapshot(args)
+	case "deletions":
+		return c.deletions(args)
+	case "restore":
+		return c.restore(args)
+	case "delete":
+		return c.delete(args)
+	case "migrate":
+		return c.migrate(args)
+	}
+	flag.Usage()
+	return fmt.Errorf("unknown command %q", command)
+}
+
+func main() {
+	err := run()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+}
@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 
+	"code.cloudfoundry.org/lager"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
@@ -23,7 +25,10 @@ import (
 	"github.com/18F/aws-broker/catalog"
 	"github.com/18F/aws-broker/config"
 	"github.com/18F/aws-broker/db"
+	"github.com/18F/aws-broker/helpers/logging"
+	"github.com/18F/aws-broker/helpers/tracing"
 
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/exp/slices"
 )
 
@@ -67,12 +72,24 @@ func run() error {
 		return fmt.Errorf("there was an error with the DB. Error: %s", err.Error())
 	}
 
+	shutdownTracing, err := tracing.Init(context.Background(), "aws-broker-tasks", settings.TracingEndpoint)
+	if err != nil {
+		return fmt.Errorf("could not initialize tracing: %s", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	ctx, span := tracing.Start(context.Background(), "tasks."+*actionPtr,
+		attribute.StringSlice("tasks.services", servicesToTag),
+	)
+	defer span.End()
+
 	sess, err := session.NewSession(&aws.Config{
 		Region: aws.String(settings.Region),
 	})
 	if err != nil {
 		return fmt.Errorf("could not initialize session: %s", err)
 	}
+	tracing.InstrumentSession(ctx, sess)
 
 	if *actionPtr == "reconcile-tags" {
 		tagManager, err := brokertags.NewCFTagManager(
@@ -87,7 +104,8 @@ func run() error {
 		}
 
 		path, _ := os.Getwd()
-		c := catalog.InitCatalog(path)
+		logger := logging.NewLogger("aws-broker-tasks", os.Stderr, lager.INFO)
+		c := catalog.InitCatalog(path, logger)
 
 		logsClient := cloudwatchlogs.New(sess)
 
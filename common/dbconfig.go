@@ -8,10 +8,9 @@ import (
 	// This is to init the sqlite driver
 	_ "github.com/mattn/go-sqlite3"
 
-	"errors"
 	"fmt"
+
 	"github.com/jinzhu/gorm"
-	"log"
 )
 
 // DBConfig holds configuration information to connect to a database.
@@ -45,13 +44,6 @@ type DBConfig struct {
 func DBInit(dbConfig *DBConfig) (*gorm.DB, error) {
 	var DB *gorm.DB
 	var err error
-	/*
-		log.Printf("Attempting to login as %s with password length %d and url %s to db name %s\n",
-			dbConfig.Username,
-			len(dbConfig.Password),
-			dbConfig.URL,
-			dbConfig.DbName)
-	*/
 	switch dbConfig.DbType {
 	case "postgres":
 		conn := "dbname=%s user=%s password=%s host=%s sslmode=%s port=%d"
@@ -76,18 +68,15 @@ func DBInit(dbConfig *DBConfig) (*gorm.DB, error) {
 	case "sqlite3":
 		DB, err = gorm.Open("sqlite3", dbConfig.DbName)
 	default:
-		errorString := "Cannot connect. Unsupported DB type: (" + dbConfig.DbType + ")"
-		log.Println(errorString)
-		return nil, errors.New(errorString)
+		return nil, fmt.Errorf("cannot connect. Unsupported DB type: (%s)", dbConfig.DbType)
 	}
 	if err != nil {
-		log.Println("Error!" + err.Error())
 		return nil, err
 	}
 
+	// Errors are returned for the caller to log with its structured logger.
 	if err = DB.DB().Ping(); err != nil {
-		log.Println("Unable to verify connection to database")
-		return nil, err
+		return nil, fmt.Errorf("unable to verify connection to database: %w", err)
 	}
 	return DB, nil
 }
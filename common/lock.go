@@ -0,0 +1,36 @@
+package common
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// taskLockKey namespaces the advisory locks taken by scheduled tasks.
+const taskLockKey = 0x7461736b
+
+// RunExclusive runs fn unless another broker sharing the database is already
+// running the task, and reports whether fn ran. On postgres, a transaction
+// holds an advisory lock on the task for as long as fn runs, so that the
+// scheduled tasks of brokers running side by side do not overlap. fn uses
+// the database outside of that transaction.
+func RunExclusive(db *gorm.DB, taskID string, fn func()) (bool, error) {
+	if db.Dialect().GetName() != "postgres" {
+		fn()
+		return true, nil
+	}
+	tx := db.Begin()
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	// The transaction writes nothing; ending it releases the lock.
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?, hashtext(?))", taskLockKey, taskID).Row().Scan(&locked); err != nil {
+		return false, err
+	}
+	if !locked {
+		return false, nil
+	}
+	fn()
+	return true, nil
+}
@@ -0,0 +1,23 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestRunExclusive(t *testing.T) {
+	db, err := DBInit(&DBConfig{DbType: "sqlite3", DbName: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	runs := 0
+	ran, err := RunExclusive(db, "task", func() { runs++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only postgres is locked; other databases always run the task.
+	if !ran || runs != 1 {
+		t.Errorf("expected the task to run once, got ran %t and %d runs", ran, runs)
+	}
+}
@@ -3,7 +3,6 @@ package config
 import (
 	"errors"
 	"fmt"
-	"log"
 	"os"
 	"strconv"
 
@@ -12,29 +11,33 @@ import (
 
 // Settings stores settings used to run the application
 type Settings struct {
-	EncryptionKey             string
-	DbNamePrefix              string
-	DbShorthandPrefix         string
-	MaxAllocatedStorage       int64
-	DbConfig                  *common.DBConfig
-	Environment               string
-	Region                    string
-	PubliclyAccessibleFeature bool
-	EnableFunctionsFeature    bool
-	SnapshotsBucketName       string
-	SnapshotsRepoName         string
-	LastSnapshotName          string
-	CfApiUrl                  string
-	CfApiClientId             string
-	CfApiClientSecret         string
-	MaxBackupRetention        int64
-	MinBackupRetention        int64
+	EncryptionKey              string
+	DbNamePrefix               string
+	DbShorthandPrefix          string
+	MaxAllocatedStorage        int64
+	DbConfig                   *common.DBConfig
+	Environment                string
+	Region                     string
+	PubliclyAccessibleFeature  bool
+	EnableFunctionsFeature     bool
+	SnapshotsBucketName        string
+	SnapshotsRepoName          string
+	LastSnapshotName           string
+	CfApiUrl                   string
+	CfApiClientId              string
+	CfApiClientSecret          string
+	MaxBackupRetention         int64
+	MinBackupRetention         int64
+	ManualSnapshotRetention    int64
+	CredentialRotationSchedule string
+	DeletionDelayDays          int64
+	DRSnapshotCopySchedule     string
+	TracingEndpoint            string
+	SkipMigrations             bool
 }
 
 // LoadFromEnv loads settings from environment variables
 func (s *Settings) LoadFromEnv() error {
-	log.Println("Loading settings")
-
 	// Load DB Settings
 	dbConfig := common.DBConfig{}
 	dbConfig.DbType = os.Getenv("DB_TYPE")
@@ -135,6 +138,40 @@ func (s *Settings) LoadFromEnv() error {
 		s.MinBackupRetention = 14
 	}
 
+	// Number of manual snapshots kept per instance; the oldest are deleted
+	// when a new one is taken.
+	s.ManualSnapshotRetention, _ = strconv.ParseInt(os.Getenv("MANUAL_SNAPSHOT_RETENTION"), 10, 64)
+	if s.ManualSnapshotRetention == 0 {
+		s.ManualSnapshotRetention = 10
+	}
+
+	// Cron expression of when the master credentials of RDS instances are
+	// checked against the rotation period of their plan, daily by default.
+	if s.CredentialRotationSchedule = os.Getenv("CREDENTIAL_ROTATION_SCHEDULE"); s.CredentialRotationSchedule == "" {
+		s.CredentialRotationSchedule = "0 5 * * *"
+	}
+
+	// Number of days that deprovisioned instances are kept, stopped where
+	// possible, before they are deleted. Instances are deleted right away if
+	// unset.
+	s.DeletionDelayDays, _ = strconv.ParseInt(os.Getenv("DELETION_DELAY_DAYS"), 10, 64)
+
+	// Cron expression of when the snapshots of RDS instances are copied to the
+	// DR region of their plan, hourly by default.
+	if s.DRSnapshotCopySchedule = os.Getenv("DR_SNAPSHOT_COPY_SCHEDULE"); s.DRSnapshotCopySchedule == "" {
+		s.DRSnapshotCopySchedule = "0 * * * *"
+	}
+
+	// Skip applying database migrations at startup, e.g. to apply them
+	// separately with brokerctl.
+	if _, ok := os.LookupEnv("DB_SKIP_MIGRATIONS"); ok {
+		s.SkipMigrations = true
+	}
+
+	// OTLP/HTTP endpoint to export traces to, e.g. http://localhost:4318.
+	// Tracing is disabled if unset.
+	s.TracingEndpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+
 	if cfApiUrl, ok := os.LookupEnv("CF_API_URL"); ok {
 		s.CfApiUrl = cfApiUrl
 	} else {
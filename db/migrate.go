@@ -0,0 +1,154 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/jinzhu/gorm"
+)
+
+// Migration is a numbered, reversible change to the schema of the broker's
+// database. Up applies the change and Down reverts it. Both are run in a
+// transaction along with the record of the migration.
+type Migration struct {
+	ID   int
+	Name string
+	Up   func(tx *gorm.DB) error
+	Down func(tx *gorm.DB) error
+}
+
+// SchemaMigration records a migration that has been applied to the database.
+type SchemaMigration struct {
+	ID        int    `gorm:"primary_key;auto_increment:false"`
+	Name      string `sql:"size(255)"`
+	AppliedAt time.Time
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// Migrate applies every migration that has not been applied yet, in order.
+func Migrate(db *gorm.DB, logger lager.Logger) error {
+	return migrate(db, Migrations, logger)
+}
+
+// Rollback reverts the given number of the most recently applied migrations.
+func Rollback(db *gorm.DB, steps int, logger lager.Logger) error {
+	return rollback(db, Migrations, steps, logger)
+}
+
+// Status lists every migration along with when it was applied, if it has been.
+func Status(db *gorm.DB) ([]MigrationStatus, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []MigrationStatus
+	for _, migration := range Migrations {
+		status := MigrationStatus{ID: migration.ID, Name: migration.Name}
+		if record, ok := applied[migration.ID]; ok {
+			status.AppliedAt = &record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func migrate(db *gorm.DB, migrations []Migration, logger lager.Logger) error {
+	if err := db.AutoMigrate(&SchemaMigration{}).Error; err != nil {
+		return fmt.Errorf("could not create the schema migrations table: %w", err)
+	}
+	for _, migration := range migrations {
+		err := inTransaction(db, migration.ID, func(tx *gorm.DB, applied bool) error {
+			if applied {
+				return nil
+			}
+			logger.Info("applying-migration", lager.Data{"id": migration.ID, "name": migration.Name})
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{ID: migration.ID, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d %s failed: %w", migration.ID, migration.Name, err)
+		}
+	}
+	return nil
+}
+
+func rollback(db *gorm.DB, migrations []Migration, steps int, logger lager.Logger) error {
+	if err := db.AutoMigrate(&SchemaMigration{}).Error; err != nil {
+		return fmt.Errorf("could not create the schema migrations table: %w", err)
+	}
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		migration := migrations[i]
+		reverted := false
+		err := inTransaction(db, migration.ID, func(tx *gorm.DB, applied bool) error {
+			if !applied {
+				return nil
+			}
+			logger.Info("reverting-migration", lager.Data{"id": migration.ID, "name": migration.Name})
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			reverted = true
+			return tx.Delete(&SchemaMigration{ID: migration.ID}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("reverting migration %d %s failed: %w", migration.ID, migration.Name, err)
+		}
+		if reverted {
+			steps--
+		}
+	}
+	return nil
+}
+
+// inTransaction runs fn in a transaction, telling it whether the migration has
+// been applied. On postgres, the transaction holds an advisory lock on the
+// migration so that brokers starting at the same time do not both run it.
+func inTransaction(db *gorm.DB, id int, fn func(tx *gorm.DB, applied bool) error) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if db.Dialect().GetName() == "postgres" {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", migrationLockKey, id).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	var count int
+	if err := tx.Model(&SchemaMigration{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := fn(tx, count > 0); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// migrationLockKey namespaces the advisory locks taken while migrating.
+const migrationLockKey = 0x62726b72
+
+func appliedMigrations(db *gorm.DB) (map[int]SchemaMigration, error) {
+	applied := map[int]SchemaMigration{}
+	if !db.HasTable(&SchemaMigration{}) {
+		return applied, nil
+	}
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		applied[record.ID] = record
+	}
+	return applied, nil
+}
@@ -0,0 +1,320 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/common"
+	"github.com/18F/aws-broker/services/elasticsearch"
+	"github.com/18F/aws-broker/services/rds"
+	"github.com/18F/aws-broker/services/redis"
+	"github.com/jinzhu/gorm"
+)
+
+// initTestDb connects to an empty database of the type in DB_TYPE, which is
+// sqlite3 by default. On postgres the migrations run in a database of their
+// own, since they drop tables that the tests of other packages use.
+func initTestDb(t *testing.T) *gorm.DB {
+	dbConfig := &common.DBConfig{DbType: "sqlite3", DbName: ":memory:"}
+	if os.Getenv("DB_TYPE") == "postgres" {
+		dbConfig = &common.DBConfig{
+			DbType:   "postgres",
+			DbName:   os.Getenv("POSTGRES_USER"),
+			Password: os.Getenv("POSTGRES_PASSWORD"),
+			Sslmode:  "disable",
+			Port:     5432,
+			Username: os.Getenv("POSTGRES_USER"),
+			URL:      "localhost",
+		}
+		db, err := InternalDBInit(dbConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Exec("DROP DATABASE IF EXISTS migrations_test")
+		if err := db.Exec("CREATE DATABASE migrations_test").Error; err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+		dbConfig.DbName = "migrations_test"
+	}
+
+	db, err := InternalDBInit(dbConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dbConfig.DbType == "sqlite3" {
+		// Every connection to an in-memory sqlite database has its own database.
+		db.DB().SetMaxOpenConns(1)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+var migratedTables = []interface{}{
+	&rds.RDSInstance{},
+	&rds.FinalSnapshot{},
+	&rds.ReadReplica{},
+	&rds.DRSnapshotCopy{},
+	&redis.RedisInstance{},
+	&elasticsearch.ElasticsearchInstance{},
+	&base.Instance{},
+	&base.PendingDeletion{},
+}
+
+func TestMigrate(t *testing.T) {
+	db := initTestDb(t)
+
+	if err := Migrate(db, lagertest.NewTestLogger("migrate-test")); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range migratedTables {
+		if !db.HasTable(table) {
+			t.Errorf("expected table for %T to be created", table)
+		}
+	}
+
+	statuses, err := Status(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != len(Migrations) {
+		t.Fatalf("expected a status for each migration, got %d", len(statuses))
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("expected migration %d to be applied", status.ID)
+		}
+	}
+
+	// Applying the migrations again does nothing.
+	if err := Migrate(db, lagertest.NewTestLogger("migrate-test")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateModels(t *testing.T) {
+	db := initTestDb(t)
+	if err := Migrate(db, lagertest.NewTestLogger("migrate-test")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The migrated schema must hold every column of the current models.
+	skipFinalSnapshot := true
+	rotatedAt := time.Now()
+	instance := rds.RDSInstance{
+		Database:                         "db",
+		EnabledCloudwatchLogGroupExports: []string{"postgresql"},
+		SkipFinalSnapshot:                &skipFinalSnapshot,
+		SnapshotIdentifier:               "snapshot",
+		RestoreModifyPending:             true,
+		DbName:                           "name",
+		ReadReplicas:                     1,
+		BlueGreenDeploymentIdentifier:    "bgd-1",
+		ReaderHost:                       "reader-host",
+		IamUserName:                      "db",
+		IamPolicyARN:                     "policy-arn",
+		IamAccessKeyID:                   "access-key",
+		IamSecretAccessKey:               "secret-key",
+		IamSecretAccessKeySalt:           "secret-key-salt",
+		MaxAllocatedStorage:              100,
+		Iops:                             12000,
+		StorageThroughput:                500,
+		EnablePerformanceInsights:        true,
+		PerformanceInsightsRetention:     7,
+		PerformanceInsightsKMSKeyID:      "key-id",
+		MonitoringInterval:               60,
+		MonitoringRoleARN:                "role-arn",
+		PreferredMaintenanceWindow:       "sun:06:00-sun:07:00",
+		PreferredBackupWindow:            "03:00-04:00",
+		DBParameters:                     []string{"work_mem=4096"},
+		Extensions:                       []string{"postgis"},
+		ExtensionsPending:                true,
+		ExtensionsRebootPending:          true,
+		CredentialsRotatedAt:             &rotatedAt,
+		ScheduledCredentialRotation:      true,
+		PendingSalt:                      "pending-salt",
+		PendingPassword:                  "pending-password",
+		ManageMasterUserPassword:         true,
+		MasterUserSecretARN:              "secret-arn",
+		DeletionProtection:               true,
+	}
+	instance.Uuid = "rds-instance"
+	if err := db.Create(&instance).Error; err != nil {
+		t.Fatal(err)
+	}
+	finalSnapshot := rds.FinalSnapshot{SnapshotIdentifier: "db-final-rds-instance", InstanceGUID: "rds-instance"}
+	if err := db.Create(&finalSnapshot).Error; err != nil {
+		t.Fatal(err)
+	}
+	readReplica := rds.ReadReplica{Identifier: "db-replica-1", InstanceGUID: "rds-instance", Host: "host", Port: 5432}
+	if err := db.Create(&readReplica).Error; err != nil {
+		t.Fatal(err)
+	}
+	drSnapshotCopy := rds.DRSnapshotCopy{
+		SourceSnapshotIdentifier: "rds:db-2026-10-18-05-10",
+		InstanceGUID:             "rds-instance",
+		PlanID:                   "plan",
+		Database:                 "db",
+		SnapshotType:             "automated",
+		Region:                   "us-west-2",
+		SnapshotIdentifier:       "db-2026-10-18-05-10",
+		SnapshotCreateTime:       time.Now(),
+	}
+	if err := db.Create(&drSnapshotCopy).Error; err != nil {
+		t.Fatal(err)
+	}
+	redisInstance := redis.RedisInstance{ClusterID: "cluster", ClonedFrom: "source", CloneSnapshotName: "cluster-clone", ClonePending: true}
+	redisInstance.Uuid = "redis-instance"
+	if err := db.Create(&redisInstance).Error; err != nil {
+		t.Fatal(err)
+	}
+	esInstance := elasticsearch.ElasticsearchInstance{Domain: "domain", ClonedFrom: "source", CloneSnapshotName: "clone-elasticsearch-instance", ClonePending: true, CloneRestoreStarted: true, CloneReadPolicyARN: "policy-arn"}
+	esInstance.Uuid = "elasticsearch-instance"
+	if err := db.Create(&esInstance).Error; err != nil {
+		t.Fatal(err)
+	}
+	pendingDeletion := base.PendingDeletion{InstanceGUID: "rds-instance", DeleteAt: time.Now(), DeletionStarted: true}
+	if err := db.Create(&pendingDeletion).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateExistingDatabase(t *testing.T) {
+	db := initTestDb(t)
+
+	// A database set up by AutoMigrate before migrations were introduced.
+	if err := db.AutoMigrate(migratedTables...).Error; err != nil {
+		t.Fatal(err)
+	}
+	existing := base.Instance{Uuid: "existing"}
+	if err := db.Create(&existing).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Migrate(db, lagertest.NewTestLogger("migrate-test")); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	db.Model(&base.Instance{}).Where("uuid = ?", "existing").Count(&count)
+	if count != 1 {
+		t.Error("expected existing records to be kept")
+	}
+}
+
+func TestRollback(t *testing.T) {
+	db := initTestDb(t)
+	if err := Migrate(db, lagertest.NewTestLogger("migrate-test")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Rollback(db, len(Migrations), lagertest.NewTestLogger("migrate-test")); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range migratedTables {
+		if db.HasTable(table) {
+			t.Errorf("expected table for %T to be dropped", table)
+		}
+	}
+	statuses, err := Status(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			t.Errorf("expected migration %d to be reverted", status.ID)
+		}
+	}
+
+	// The migrations can be applied again after they are reverted.
+	if err := Migrate(db, lagertest.NewTestLogger("migrate-test")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateIAMSecretAccessKeySalt(t *testing.T) {
+	db := initTestDb(t)
+	if err := migrate(db, Migrations[:19], lagertest.NewTestLogger("migrate-test")); err != nil {
+		t.Fatal(err)
+	}
+	for uuid, secretAccessKey := range map[string]string{"iam": "secret-key", "password": ""} {
+		if err := db.Exec("INSERT INTO rds_instances (uuid, salt, iam_secret_access_key) VALUES (?, ?, ?)", uuid, "salt", secretAccessKey).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Migrate(db, lagertest.NewTestLogger("migrate-test")); err != nil {
+		t.Fatal(err)
+	}
+	for uuid, expectedSalt := range map[string]string{"iam": "salt", "password": ""} {
+		instance := rds.RDSInstance{}
+		if err := db.Where("uuid = ?", uuid).First(&instance).Error; err != nil {
+			t.Fatal(err)
+		}
+		if instance.IamSecretAccessKeySalt != expectedSalt {
+			t.Errorf("expected salt %q for %s, got %q", expectedSalt, uuid, instance.IamSecretAccessKeySalt)
+		}
+	}
+}
+
+type migrationTestRecord struct {
+	ID int
+}
+
+func TestMigrateFailure(t *testing.T) {
+	db := initTestDb(t)
+	migrations := []Migration{
+		{
+			ID:   1,
+			Name: "create-table",
+			Up: func(tx *gorm.DB) error {
+				return tx.CreateTable(&migrationTestRecord{}).Error
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.DropTable(&migrationTestRecord{}).Error
+			},
+		},
+		{
+			ID:   2,
+			Name: "fail",
+			Up: func(tx *gorm.DB) error {
+				if err := tx.Create(&migrationTestRecord{ID: 1}).Error; err != nil {
+					return err
+				}
+				return errors.New("failed")
+			},
+			Down: func(tx *gorm.DB) error {
+				return nil
+			},
+		},
+	}
+
+	if err := migrate(db, migrations, lagertest.NewTestLogger("migrate-test")); err == nil {
+		t.Fatal("expected the failing migration to return an error")
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := applied[1]; !ok {
+		t.Error("expected the first migration to be recorded")
+	}
+	if _, ok := applied[2]; ok {
+		t.Error("expected the failing migration not to be recorded")
+	}
+	var count int
+	db.Model(&migrationTestRecord{}).Count(&count)
+	if count != 0 {
+		t.Error("expected the changes of the failing migration to be rolled back")
+	}
+
+	if err := rollback(db, migrations, 1, lagertest.NewTestLogger("migrate-test")); err != nil {
+		t.Fatal(err)
+	}
+	if db.HasTable(&migrationTestRecord{}) {
+		t.Error("expected the first migration to be reverted")
+	}
+}
@@ -0,0 +1,755 @@
+package db
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// Migrations are the changes to the broker's schema, applied in order of ID.
+// A migration must not be changed once it has been released: add a new one
+// instead. Migrations use their own copies of the models as they were at the
+// time, so that later changes to the models do not change past migrations.
+var Migrations = []Migration{
+	{
+		ID:   1,
+		Name: "initial-schema",
+		Up: func(tx *gorm.DB) error {
+			// The schema that AutoMigrate created before migrations were introduced.
+			// AutoMigrate leaves existing tables and columns alone, so this adopts
+			// existing databases as well as creating new ones.
+			return tx.AutoMigrate(&rdsInstanceV1{}, &redisInstanceV1{}, &elasticsearchInstanceV1{}, &instanceV1{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.DropTableIfExists(&rdsInstanceV1{}, &redisInstanceV1{}, &elasticsearchInstanceV1{}, &instanceV1{}).Error
+		},
+	},
+	{
+		ID:   2,
+		Name: "rds-final-snapshots",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rdsInstanceV2{}, &rdsFinalSnapshotV2{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.DropTableIfExists(&rdsFinalSnapshotV2{}).Error; err != nil {
+				return err
+			}
+			return tx.Model(&rdsInstanceV2{}).DropColumn("skip_final_snapshot").Error
+		},
+	},
+	{
+		ID:   3,
+		Name: "rds-restore-from-snapshot",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rdsInstanceV3{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{"snapshot_identifier", "restore_modify_pending", "db_name"} {
+				if err := tx.Model(&rdsInstanceV3{}).DropColumn(column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID:   4,
+		Name: "clone-instances",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&redisInstanceV4{}, &elasticsearchInstanceV4{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, model := range []interface{}{&redisInstanceV4{}, &elasticsearchInstanceV4{}} {
+				for _, column := range []string{"cloned_from", "clone_snapshot_name", "clone_pending"} {
+					if err := tx.Model(model).DropColumn(column).Error; err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID:   5,
+		Name: "rds-read-replicas",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rdsInstanceV5{}, &rdsReadReplicaV5{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.DropTableIfExists(&rdsReadReplicaV5{}).Error; err != nil {
+				return err
+			}
+			return tx.Model(&rdsInstanceV5{}).DropColumn("read_replicas").Error
+		},
+	},
+	{
+		ID:   6,
+		Name: "rds-blue-green-deployments",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rdsInstanceV6{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Model(&rdsInstanceV6{}).DropColumn("blue_green_deployment_identifier").Error
+		},
+	},
+	{
+		ID:   7,
+		Name: "rds-aurora-reader-host",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rdsInstanceV7{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Model(&rdsInstanceV7{}).DropColumn("reader_host").Error
+		},
+	},
+	{
+		ID:   8,
+		Name: "rds-iam-database-authentication",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rdsInstanceV8{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{"iam_user_name", "iam_policy_arn", "iam_access_key_id", "iam_secret_access_key"} {
+				if err := tx.Model(&rdsInstanceV8{}).DropColumn(column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID:   9,
+		Name: "rds-storage-performance",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rdsInstanceV9{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{"max_allocated_storage", "iops", "storage_throughput"} {
+				if err := tx.Model(&rdsInstanceV9{}).DropColumn(column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID:   10,
+		Name: "rds-monitoring",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rdsInstanceV10{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{
+				"enable_performance_insights",
+				"performance_insights_retention",
+				"performance_insights_kms_key_id",
+				"monitoring_interval",
+				"monitoring_role_arn",
+			} {
+				if err := tx.Model(&rdsInstanceV10{}).DropColumn(column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID:   11,
+		Name: "rds-maintenance-backup-windows",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rdsInstanceV11{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{"preferred_maintenance_window", "preferred_backup_window"} {
+				if err := tx.Model(&rdsInstanceV11{}).DropColumn(column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID:   12,
+		Name: "rds-db-parameters",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rdsInstanceV12{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Model(&rdsInstanceV12{}).DropColumn("db_parameters").Error
+		},
+	},
+	{
+		ID:   13,
+		Name: "rds-extensions",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rdsInstanceV13{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{"extensions", "extensions_pending"} {
+				if err := tx.Model(&rdsInstanceV13{}).DropColumn(column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID:   14,
+		Name: "rds-credential-rotation",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rdsInstanceV14{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Model(&rdsInstanceV14{}).DropColumn("credentials_rotated_at").Error
+		},
+	},
+	{
+		ID:   15,
+		Name: "rds-managed-master-password",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rdsInstanceV15{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{"manage_master_user_password", "master_user_secret_arn"} {
+				if err := tx.Model(&rdsInstanceV15{}).DropColumn(column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID:   16,
+		Name: "rds-deletion-protection",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rdsInstanceV16{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Model(&rdsInstanceV16{}).DropColumn("deletion_protection").Error
+		},
+	},
+	{
+		ID:   17,
+		Name: "pending-deletions",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&pendingDeletionV17{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.DropTableIfExists(&pendingDeletionV17{}).Error
+		},
+	},
+	{
+		ID:   18,
+		Name: "rds-dr-snapshot-copies",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rdsDRSnapshotCopyV18{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.DropTableIfExists(&rdsDRSnapshotCopyV18{}).Error
+		},
+	},
+	{
+		ID:   19,
+		Name: "elasticsearch-clone-restore",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&elasticsearchInstanceV19{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Model(&elasticsearchInstanceV19{}).DropColumn("clone_restore_started").Error
+		},
+	},
+	{
+		ID:   20,
+		Name: "rds-iam-secret-access-key-salt",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&rdsInstanceV20{}).Error; err != nil {
+				return err
+			}
+			// The existing secret access keys were encrypted with the salt
+			// of the instance.
+			return tx.Model(&rdsInstanceV20{}).
+				Where("iam_secret_access_key <> ''").
+				UpdateColumn("iam_secret_access_key_salt", gorm.Expr("salt")).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Model(&rdsInstanceV20{}).DropColumn("iam_secret_access_key_salt").Error
+		},
+	},
+	{
+		ID:   21,
+		Name: "rds-extensions-reboot-pending",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rdsInstanceV21{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Model(&rdsInstanceV21{}).DropColumn("extensions_reboot_pending").Error
+		},
+	},
+	{
+		ID:   22,
+		Name: "rds-scheduled-credential-rotation",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rdsInstanceV22{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{"scheduled_credential_rotation", "pending_salt", "pending_password"} {
+				if err := tx.Model(&rdsInstanceV22{}).DropColumn(column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID:   23,
+		Name: "elasticsearch-clone-read-policy",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&elasticsearchInstanceV23{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Model(&elasticsearchInstanceV23{}).DropColumn("clone_read_policy_arn").Error
+		},
+	},
+}
+
+// instanceV1 is base.Instance as of migration 1.
+type instanceV1 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	ServiceID        string `sql:"size(255)"`
+	PlanID           string `sql:"size(255)"`
+	OrganizationGUID string `sql:"size(255)"`
+	SpaceGUID        string `sql:"size(255)"`
+
+	Host string `sql:"size(255)"`
+	Port int64
+
+	State int
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (instanceV1) TableName() string { return "instances" }
+
+// The service instances embed base.Instance, whose columns are repeated in
+// each of the following as gorm does not map embedded unexported structs.
+
+// rdsInstanceV1 is rds.RDSInstance as of migration 1.
+type rdsInstanceV1 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	ServiceID        string `sql:"size(255)"`
+	PlanID           string `sql:"size(255)"`
+	OrganizationGUID string `sql:"size(255)"`
+	SpaceGUID        string `sql:"size(255)"`
+
+	Host string `sql:"size(255)"`
+	Port int64
+
+	State int
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Database string `sql:"size(255)"`
+	Username string `sql:"size(255)"`
+	Password string `sql:"size(255)"`
+	Salt     string `sql:"size(255)"`
+
+	BackupRetentionPeriod int64 `sql:"size(255)"`
+	AllocatedStorage      int64 `sql:"size(255)"`
+
+	Adapter string `sql:"size(255)"`
+
+	DbType       string `sql:"size(255)"`
+	DbVersion    string `sql:"size(255)"`
+	LicenseModel string `sql:"size(255)"`
+
+	BinaryLogFormat    string `sql:"size(255)"`
+	EnablePgCron       *bool  `sql:"size(255)"`
+	ParameterGroupName string `sql:"size(255)"`
+
+	EnabledCloudwatchLogGroupExports pq.StringArray `sql:"type:text[]"`
+
+	StorageType string `sql:"size(255)"`
+}
+
+func (rdsInstanceV1) TableName() string { return "rds_instances" }
+
+// redisInstanceV1 is redis.RedisInstance as of migration 1.
+type redisInstanceV1 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	ServiceID        string `sql:"size(255)"`
+	PlanID           string `sql:"size(255)"`
+	OrganizationGUID string `sql:"size(255)"`
+	SpaceGUID        string `sql:"size(255)"`
+
+	Host string `sql:"size(255)"`
+	Port int64
+
+	State int
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Description string `sql:"size(255)"`
+
+	Password string `sql:"size(255)"`
+	Salt     string `sql:"size(255)"`
+
+	EngineVersion              string `sql:"size(255)"`
+	ClusterID                  string `sql:"size(255)"`
+	CacheNodeType              string `sql:"size(255)"`
+	NumCacheClusters           int    `sql:"size(255)"`
+	ParameterGroup             string `sql:"size(255)"`
+	PreferredMaintenanceWindow string `sql:"size(255)"`
+	SnapshotWindow             string `sql:"size(255)"`
+	SnapshotRetentionLimit     int    `sql:"size(255)"`
+	AutomaticFailoverEnabled   bool   `sql:"size(255)"`
+
+	ParameterGroupName string `sql:"size(255)"`
+
+	EngineLogsGroupName string `sql:"size(512)"`
+	SlowLogsGroupName   string `sql:"size(512)"`
+}
+
+func (redisInstanceV1) TableName() string { return "redis_instances" }
+
+// elasticsearchInstanceV1 is elasticsearch.ElasticsearchInstance as of migration 1.
+type elasticsearchInstanceV1 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	ServiceID        string `sql:"size(255)"`
+	PlanID           string `sql:"size(255)"`
+	OrganizationGUID string `sql:"size(255)"`
+	SpaceGUID        string `sql:"size(255)"`
+
+	Host string `sql:"size(255)"`
+	Port int64
+
+	State int
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Description string `sql:"size(255)"`
+
+	Password                       string `sql:"size(255)"`
+	Salt                           string `sql:"size(255)"`
+	AccessKey                      string `sql:"size(255)"`
+	SecretKey                      string `sql:"size(255)"`
+	IamPolicy                      string `sql:"size(255)"`
+	IamPolicyARN                   string `sql:"size(255)"`
+	AccessControlPolicy            string `sql:"size(255)"`
+	ElasticsearchVersion           string `sql:"size(255)"`
+	CurrentESVersion               string `sql:"size(255)"`
+	MasterCount                    int    `sql:"size(255)"`
+	DataCount                      int    `sql:"size(255)"`
+	InstanceType                   string `sql:"size(255)"`
+	MasterInstanceType             string `sql:"size(255)"`
+	VolumeSize                     int    `sql:"size(255)"`
+	VolumeType                     string `sql:"size(255)"`
+	MasterEnabled                  bool   `sql:"size(255)"`
+	NodeToNodeEncryption           bool   `sql:"size(255)"`
+	EncryptAtRest                  bool   `sql:"size(255)"`
+	AutomatedSnapshotStartHour     int    `sql:"size(255)"`
+	Bucket                         string `sql:"size(255)"`
+	BrokerSnapshotsEnabled         bool   `sql:"size(255)"`
+	SnapshotARN                    string `sql:"size(255)"`
+	SnapshotPolicyARN              string `sql:"size(255)"`
+	SnapshotPath                   string `sql:"size(255)"`
+	IamPassRolePolicyARN           string `sql:"size(255)"`
+	IndicesFieldDataCacheSize      string `sql:"size(255)"`
+	IndicesQueryBoolMaxClauseCount string `sql:"size(255)"`
+
+	Domain string `sql:"size(255)"`
+	ARN    string `sql:"size(255)"`
+
+	SearchSlowLogsGroupARN string `sql:"size(2048)"`
+	IndexSlowLogsGroupARN  string `sql:"size(2048)"`
+	ErrorLogsGroupARN      string `sql:"size(2048)"`
+	AuditLogsGroupARN      string `sql:"size(2048)"`
+}
+
+func (elasticsearchInstanceV1) TableName() string { return "elasticsearch_instances" }
+
+// rdsInstanceV2 holds the columns added to rds.RDSInstance in migration 2.
+type rdsInstanceV2 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	SkipFinalSnapshot *bool `sql:"size(255)"`
+}
+
+func (rdsInstanceV2) TableName() string { return "rds_instances" }
+
+// rdsFinalSnapshotV2 is rds.FinalSnapshot as of migration 2.
+type rdsFinalSnapshotV2 struct {
+	SnapshotIdentifier string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	InstanceGUID     string `sql:"size(255)"`
+	ServiceID        string `sql:"size(255)"`
+	PlanID           string `sql:"size(255)"`
+	OrganizationGUID string `sql:"size(255)"`
+	SpaceGUID        string `sql:"size(255)"`
+
+	Database  string `sql:"size(255)"`
+	DbType    string `sql:"size(255)"`
+	DbVersion string `sql:"size(255)"`
+
+	CreatedAt time.Time
+}
+
+func (rdsFinalSnapshotV2) TableName() string { return "rds_final_snapshots" }
+
+// rdsInstanceV3 holds the columns added to rds.RDSInstance in migration 3.
+type rdsInstanceV3 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	SnapshotIdentifier   string `sql:"size(255)"`
+	RestoreModifyPending bool   `sql:"size(255)"`
+	DbName               string `sql:"size(255)"`
+}
+
+func (rdsInstanceV3) TableName() string { return "rds_instances" }
+
+// redisInstanceV4 holds the columns added to redis.RedisInstance in migration 4.
+type redisInstanceV4 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	ClonedFrom        string `sql:"size(255)"`
+	CloneSnapshotName string `sql:"size(255)"`
+	ClonePending      bool   `sql:"size(255)"`
+}
+
+func (redisInstanceV4) TableName() string { return "redis_instances" }
+
+// elasticsearchInstanceV4 holds the columns added to
+// elasticsearch.ElasticsearchInstance in migration 4.
+type elasticsearchInstanceV4 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	ClonedFrom        string `sql:"size(255)"`
+	CloneSnapshotName string `sql:"size(255)"`
+	ClonePending      bool   `sql:"size(255)"`
+}
+
+func (elasticsearchInstanceV4) TableName() string { return "elasticsearch_instances" }
+
+// rdsInstanceV5 holds the columns added to rds.RDSInstance in migration 5.
+type rdsInstanceV5 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	ReadReplicas int64 `sql:"size(255)"`
+}
+
+func (rdsInstanceV5) TableName() string { return "rds_instances" }
+
+// rdsReadReplicaV5 is rds.ReadReplica as of migration 5.
+type rdsReadReplicaV5 struct {
+	Identifier string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	InstanceGUID string `sql:"size(255)"`
+
+	Host string `sql:"size(255)"`
+	Port int64
+
+	State int
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (rdsReadReplicaV5) TableName() string { return "rds_read_replicas" }
+
+// rdsInstanceV6 holds the columns added to rds.RDSInstance in migration 6.
+type rdsInstanceV6 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	BlueGreenDeploymentIdentifier string `sql:"size(255)"`
+}
+
+func (rdsInstanceV6) TableName() string { return "rds_instances" }
+
+// rdsInstanceV7 holds the columns added to rds.RDSInstance in migration 7.
+type rdsInstanceV7 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	ReaderHost string `sql:"size(255)"`
+}
+
+func (rdsInstanceV7) TableName() string { return "rds_instances" }
+
+// rdsInstanceV8 holds the columns added to rds.RDSInstance in migration 8.
+type rdsInstanceV8 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	IamUserName        string `sql:"size(255)"`
+	IamPolicyARN       string `sql:"size(255)"`
+	IamAccessKeyID     string `sql:"size(255)"`
+	IamSecretAccessKey string `sql:"size(255)"`
+}
+
+func (rdsInstanceV8) TableName() string { return "rds_instances" }
+
+// rdsInstanceV9 holds the columns added to rds.RDSInstance in migration 9.
+type rdsInstanceV9 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	MaxAllocatedStorage int64 `sql:"size(255)"`
+	Iops                int64 `sql:"size(255)"`
+	StorageThroughput   int64 `sql:"size(255)"`
+}
+
+func (rdsInstanceV9) TableName() string { return "rds_instances" }
+
+// rdsInstanceV10 holds the columns added to rds.RDSInstance in migration 10.
+type rdsInstanceV10 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	EnablePerformanceInsights    bool   `sql:"size(255)"`
+	PerformanceInsightsRetention int64  `sql:"size(255)"`
+	PerformanceInsightsKMSKeyID  string `sql:"size(255)"`
+	MonitoringInterval           int64  `sql:"size(255)"`
+	MonitoringRoleARN            string `sql:"size(255)"`
+}
+
+func (rdsInstanceV10) TableName() string { return "rds_instances" }
+
+// rdsInstanceV11 holds the columns added to rds.RDSInstance in migration 11.
+type rdsInstanceV11 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	PreferredMaintenanceWindow string `sql:"size(255)"`
+	PreferredBackupWindow      string `sql:"size(255)"`
+}
+
+func (rdsInstanceV11) TableName() string { return "rds_instances" }
+
+// rdsInstanceV12 holds the columns added to rds.RDSInstance in migration 12.
+type rdsInstanceV12 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	DBParameters pq.StringArray `sql:"type:text[]"`
+}
+
+func (rdsInstanceV12) TableName() string { return "rds_instances" }
+
+// rdsInstanceV13 holds the columns added to rds.RDSInstance in migration 13.
+type rdsInstanceV13 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	Extensions        pq.StringArray `sql:"type:text[]"`
+	ExtensionsPending bool           `sql:"size(255)"`
+}
+
+func (rdsInstanceV13) TableName() string { return "rds_instances" }
+
+// rdsInstanceV14 holds the columns added to rds.RDSInstance in migration 14.
+type rdsInstanceV14 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	CredentialsRotatedAt *time.Time
+}
+
+func (rdsInstanceV14) TableName() string { return "rds_instances" }
+
+// rdsInstanceV15 holds the columns added to rds.RDSInstance in migration 15.
+type rdsInstanceV15 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	ManageMasterUserPassword bool   `sql:"size(255)"`
+	MasterUserSecretARN      string `sql:"size(255)"`
+}
+
+func (rdsInstanceV15) TableName() string { return "rds_instances" }
+
+// rdsInstanceV16 holds the columns added to rds.RDSInstance in migration 16.
+type rdsInstanceV16 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	DeletionProtection bool `sql:"size(255)"`
+}
+
+func (rdsInstanceV16) TableName() string { return "rds_instances" }
+
+// pendingDeletionV17 is base.PendingDeletion as of migration 17.
+type pendingDeletionV17 struct {
+	InstanceGUID string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	DeleteAt        time.Time
+	DeletionStarted bool `sql:"size(255)"`
+
+	CreatedAt time.Time
+}
+
+func (pendingDeletionV17) TableName() string { return "pending_deletions" }
+
+// rdsDRSnapshotCopyV18 is rds.DRSnapshotCopy as of migration 18.
+type rdsDRSnapshotCopyV18 struct {
+	SourceSnapshotIdentifier string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	InstanceGUID string `sql:"size(255)"`
+	PlanID       string `sql:"size(255)"`
+	Database     string `sql:"size(255)"`
+	SnapshotType string `sql:"size(255)"`
+
+	Region             string `sql:"size(255)"`
+	SnapshotIdentifier string `sql:"size(255)"`
+	SnapshotCreateTime time.Time
+
+	CreatedAt time.Time
+}
+
+func (rdsDRSnapshotCopyV18) TableName() string { return "rds_dr_snapshot_copies" }
+
+// elasticsearchInstanceV19 holds the columns added to
+// elasticsearch.ElasticsearchInstance in migration 19.
+type elasticsearchInstanceV19 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	CloneRestoreStarted bool `sql:"size(255)"`
+}
+
+func (elasticsearchInstanceV19) TableName() string { return "elasticsearch_instances" }
+
+// rdsInstanceV20 holds the columns added to rds.RDSInstance in migration 20.
+type rdsInstanceV20 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	IamSecretAccessKeySalt string `sql:"size(255)"`
+}
+
+func (rdsInstanceV20) TableName() string { return "rds_instances" }
+
+// rdsInstanceV21 holds the columns added to rds.RDSInstance in migration 21.
+type rdsInstanceV21 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	ExtensionsRebootPending bool `sql:"size(255)"`
+}
+
+func (rdsInstanceV21) TableName() string { return "rds_instances" }
+
+// rdsInstanceV22 holds the columns added to rds.RDSInstance in migration 22.
+type rdsInstanceV22 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	ScheduledCredentialRotation bool   `sql:"size(255)"`
+	PendingSalt                 string `sql:"size(255)"`
+	PendingPassword             string `sql:"size(255)"`
+}
+
+func (rdsInstanceV22) TableName() string { return "rds_instances" }
+
+// elasticsearchInstanceV23 holds the columns added to
+// elasticsearch.ElasticsearchInstance in migration 23.
+type elasticsearchInstanceV23 struct {
+	Uuid string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	CloneReadPolicyARN string `sql:"size(255)"`
+}
+
+func (elasticsearchInstanceV23) TableName() string { return "elasticsearch_instances" }
@@ -52,6 +52,8 @@ var (
 	SuccessBindResponseType Type = "success_bind"
 	// SuccessDeleteResponseType represents a response for a successful instance deletion.
 	SuccessDeleteResponseType Type = "success_delete"
+	// SuccessModifyResponseType represents a response for a successful synchronous instance update.
+	SuccessModifyResponseType Type = "success_modify"
 	// ErrorResponseType represents a response for an error.
 	ErrorResponseType Type = "error"
 )
@@ -98,6 +100,11 @@ var (
 	SuccessDeleteResponse = newSuccessResponse(http.StatusOK, SuccessDeleteResponseType, "The instance was deleted")
 )
 
+// NewSuccessModifyResponse is the constructor for the response of an update that completed synchronously.
+func NewSuccessModifyResponse(description string) Response {
+	return newSuccessResponse(http.StatusOK, SuccessModifyResponseType, description)
+}
+
 // If a broker has an async operation ( create, modify, delete, bind) and wants to return an "operation" they should use this
 // Otherwise they can return SuccessAcceptedResponse
 func NewAsyncOperationResponse(operation string) Response {
@@ -19,6 +19,7 @@ var responseTests = []responseTest{
 	{SuccessDeleteResponse, "{\"description\":\"The instance was deleted\"}", http.StatusOK, SuccessDeleteResponseType},
 	{NewErrorResponse(http.StatusNotFound, "oops"), "{\"description\":\"oops\"}", http.StatusNotFound, ErrorResponseType},
 	{NewSuccessBindResponse(map[string]string{"username": "myuser"}), "{\"credentials\":{\"username\":\"myuser\"}}", http.StatusCreated, SuccessBindResponseType},
+	{NewSuccessModifyResponse("The snapshot was requested"), "{\"description\":\"The snapshot was requested\"}", http.StatusOK, SuccessModifyResponseType},
 }
 
 func TestGenericSuccessResponse(t *testing.T) {
@@ -0,0 +1,123 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/18F/aws-broker"
+
+// These are the attribute keys set on broker spans so that the spans of one
+// instance can be found regardless of which request created them.
+const (
+	InstanceGUIDKey = attribute.Key("broker.instance_guid")
+	BindingGUIDKey  = attribute.Key("broker.binding_guid")
+	ServiceKey      = attribute.Key("broker.service_id")
+	PlanKey         = attribute.Key("broker.plan_id")
+	OrganizationKey = attribute.Key("broker.organization_guid")
+	SpaceKey        = attribute.Key("broker.space_guid")
+	OperationKey    = attribute.Key("broker.operation")
+)
+
+// Init configures the global tracer provider to export spans over OTLP/HTTP
+// to the given endpoint, e.g. http://localhost:4318 for a local collector.
+// If the endpoint is empty tracing is left disabled. The returned function
+// flushes any pending spans and must be called before the process exits.
+func Init(ctx context.Context, serviceName string, endpoint string) (func(context.Context) error, error) {
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return provider.Shutdown, nil
+}
+
+// Start creates a span as a child of any span in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// RecordError marks the span as failed if err is not nil.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// InstrumentSession adds handlers to an AWS session so that every request made
+// by a client built from it is recorded as a span. The AWS SDK only carries a
+// context on requests made with the *WithContext methods, so spans for other
+// requests are parented to ctx instead.
+func InstrumentSession(ctx context.Context, sess *session.Session) *session.Session {
+	sess.Handlers.Validate.PushFrontNamed(request.NamedHandler{
+		Name: "tracing.StartSpan",
+		Fn: func(r *request.Request) {
+			parent := r.Context()
+			if !trace.SpanContextFromContext(parent).IsValid() {
+				parent = ctx
+			}
+			spanCtx, _ := otel.Tracer(tracerName).Start(
+				parent,
+				r.ClientInfo.ServiceName+"."+r.Operation.Name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.RPCSystemKey.String("aws-api"),
+					semconv.RPCService(r.ClientInfo.ServiceName),
+					semconv.RPCMethod(r.Operation.Name),
+					semconv.CloudRegion(r.ClientInfo.SigningRegion),
+				),
+			)
+			r.SetContext(spanCtx)
+		},
+	})
+	sess.Handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: "tracing.EndSpan",
+		Fn: func(r *request.Request) {
+			span := trace.SpanFromContext(r.Context())
+			span.SetAttributes(
+				semconv.AWSRequestID(r.RequestID),
+				attribute.Int("aws.retry_count", r.RetryCount),
+			)
+			if r.HTTPResponse != nil {
+				span.SetAttributes(semconv.HTTPResponseStatusCode(r.HTTPResponse.StatusCode))
+			}
+			RecordError(span, r.Error)
+			span.End()
+		},
+	})
+	return sess
+}
@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInstrumentSession(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amzn-Requestid", "aws-request-id")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`<ErrorResponse><Error><Code>DBInstanceNotFound</Code><Message>not found</Message></Error></ErrorResponse>`))
+	}))
+	defer server.Close()
+
+	ctx, parent := Start(context.Background(), "parent")
+	sess := InstrumentSession(ctx, session.Must(session.NewSession(&aws.Config{
+		Endpoint:    aws.String(server.URL),
+		Region:      aws.String("us-gov-west-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(0),
+	})))
+
+	_, err := rds.New(sess).DescribeDBInstances(&rds.DescribeDBInstancesInput{
+		DBInstanceIdentifier: aws.String("db"),
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	awsSpan := spans[0]
+	if awsSpan.Name() != "rds.DescribeDBInstances" {
+		t.Errorf("unexpected span name: %s", awsSpan.Name())
+	}
+	if awsSpan.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("expected AWS span to be a child of the context span")
+	}
+	if awsSpan.Status().Code != codes.Error {
+		t.Errorf("expected error status, got %v", awsSpan.Status())
+	}
+	found := false
+	for _, attr := range awsSpan.Attributes() {
+		if attr.Key == "aws.request_id" && attr.Value.AsString() == "aws-request-id" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected aws.request_id attribute, got %v", awsSpan.Attributes())
+	}
+}
@@ -1,33 +1,58 @@
 package main
 
 import (
+	"context"
+	"net/http"
+	"os"
+
+	"code.cloudfoundry.org/lager"
 	"github.com/18F/aws-broker/config"
 	"github.com/go-martini/martini"
 	"github.com/jinzhu/gorm"
 	"github.com/martini-contrib/auth"
 	"github.com/martini-contrib/render"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/trace"
 
-	"log"
-	"os"
-
+	"github.com/18F/aws-broker/admin"
 	"github.com/18F/aws-broker/catalog"
 	"github.com/18F/aws-broker/db"
+	"github.com/18F/aws-broker/helpers/logging"
+	"github.com/18F/aws-broker/helpers/tracing"
+	"github.com/18F/aws-broker/services/rds"
 	"github.com/18F/aws-broker/taskqueue"
 )
 
 func main() {
+	logger := logging.NewLogger("aws-broker", os.Stdout, lager.INFO)
+	// Send any remaining standard library logging through the same logger.
+	logging.RedirectStdLog(logger)
+
 	var settings config.Settings
 
 	// Load settings from environment
+	logger.Info("load-settings")
 	if err := settings.LoadFromEnv(); err != nil {
-		log.Println("There was an error loading settings")
-		log.Println(err)
+		logger.Error("load-settings", err)
 		return
 	}
 
+	shutdownTracing, err := tracing.Init(context.Background(), "aws-broker", settings.TracingEndpoint)
+	if err != nil {
+		logger.Error("init-tracing", err)
+		return
+	}
+	defer shutdownTracing(context.Background())
+
 	DB, err := db.InternalDBInit(settings.DbConfig)
 	if err != nil {
-		log.Println("There was an error with the DB. Error: " + err.Error())
+		logger.Error("init-db", err)
+		return
+	}
+	if settings.SkipMigrations {
+		logger.Info("skip-migrations")
+	} else if err := db.Migrate(DB, logger); err != nil {
+		logger.Error("migrate-db", err)
 		return
 	}
 
@@ -35,62 +60,126 @@ func main() {
 	Queue.Init()
 
 	// Try to connect and create the app.
-	if m := App(&settings, DB, Queue); m != nil {
-		log.Println("Starting app...")
-		m.Run()
+	if m := App(&settings, DB, Queue, logger); m != nil {
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = "3000"
+		}
+		addr := os.Getenv("HOST") + ":" + port
+		logger.Info("starting-app", lager.Data{"addr": addr})
+		// Serve through otelhttp so that each request starts a trace, continuing
+		// any trace propagated by the caller.
+		err := http.ListenAndServe(addr, otelhttp.NewHandler(m, "osb-api"))
+		logger.Error("serve", err)
 	} else {
-		log.Println("Unable to setup application. Exiting...")
+		logger.Info("unable-to-setup-application")
 	}
 }
 
 // App gathers all necessary dependencies (databases, settings), injects them into the router, and starts the app.
-func App(settings *config.Settings, DB *gorm.DB, TaskQueue *taskqueue.QueueManager) *martini.ClassicMartini {
+func App(settings *config.Settings, DB *gorm.DB, TaskQueue *taskqueue.QueueManager, logger lager.Logger) *martini.ClassicMartini {
 
 	m := martini.Classic()
 
 	username := os.Getenv("AUTH_USER")
 	password := os.Getenv("AUTH_PASS")
 
-	m.Use(auth.Basic(username, password))
 	m.Use(render.Renderer())
+	m.Use(requestLogger(logger))
 
 	m.Map(DB)
 	m.Map(settings)
 	m.Map(TaskQueue)
 
 	path, _ := os.Getwd()
-	m.Map(catalog.InitCatalog(path))
+	c := catalog.InitCatalog(path, logger)
+	m.Map(c)
 
-	log.Println("Loading Routes")
+	if err := rds.ScheduleCredentialRotation(TaskQueue, DB, settings, c, logger); err != nil {
+		logger.Error("schedule-credential-rotation", err)
+	}
+	if err := rds.ScheduleDRSnapshotCopy(TaskQueue, DB, settings, c, logger); err != nil {
+		logger.Error("schedule-dr-snapshot-copy", err)
+	}
+	if err := admin.ScheduleDelayedDeletion(TaskQueue, c, DB, settings, logger); err != nil {
+		logger.Error("schedule-delayed-deletion", err)
+	}
 
-	// Serve the catalog with services and plans
-	m.Get("/v2/catalog", func(r render.Render, c *catalog.Catalog) {
-		r.JSON(200, map[string]interface{}{
-			"services": c.GetServices(),
+	logger.Info("loading-routes")
+
+	m.Group("/v2", func(r martini.Router) {
+		// Serve the catalog with services and plans
+		r.Get("/catalog", func(r render.Render, c *catalog.Catalog) {
+			r.JSON(200, map[string]interface{}{
+				"services": c.GetServices(),
+			})
 		})
-	})
 
-	// Create the service instance (cf create-service-instance)
-	// This is a PUT per https://github.com/openservicebrokerapi/servicebroker/blob/v2.16/spec.md#provisioning
-	m.Put("/v2/service_instances/:id", CreateInstance)
+		// Create the service instance (cf create-service-instance)
+		// This is a PUT per https://github.com/openservicebrokerapi/servicebroker/blob/v2.16/spec.md#provisioning
+		r.Put("/service_instances/:id", CreateInstance)
 
-	// Update the service instance
-	m.Patch("/v2/service_instances/:id", ModifyInstance)
+		// Update the service instance
+		r.Patch("/service_instances/:id", ModifyInstance)
 
-	// Poll service endpoint to get status of rds or elasticache
-	m.Get("/v2/service_instances/:instance_id/last_operation", LastOperation)
+		// Poll service endpoint to get status of rds or elasticache
+		r.Get("/service_instances/:instance_id/last_operation", LastOperation)
 
-	// Bind the service to app (cf bind-service)
-	m.Put("/v2/service_instances/:instance_id/service_bindings/:id", BindInstance)
+		// Bind the service to app (cf bind-service)
+		r.Put("/service_instances/:instance_id/service_bindings/:id", BindInstance)
 
-	// Unbind the service from app
-	m.Delete("/v2/service_instances/:instance_id/service_bindings/:id", func(p martini.Params, r render.Render) {
-		var emptyJSON struct{}
-		r.JSON(200, emptyJSON)
-	})
+		// Unbind the service from app
+		r.Delete("/service_instances/:instance_id/service_bindings/:id", func(p martini.Params, r render.Render, logger lager.Logger) {
+			logger.Info("unbind-instance", lager.Data{
+				logging.InstanceGUIDKey: p["instance_id"],
+				logging.BindingGUIDKey:  p["id"],
+			})
+			var emptyJSON struct{}
+			r.JSON(200, emptyJSON)
+		})
 
-	// Delete service instance
-	m.Delete("/v2/service_instances/:instance_id", DeleteInstance)
+		// Delete service instance
+		r.Delete("/service_instances/:instance_id", DeleteInstance)
+	}, auth.Basic(username, password))
+
+	// The admin API is only served when it has its own credentials, so that
+	// the platform's broker credentials never grant operator access.
+	adminUsername := os.Getenv("ADMIN_AUTH_USER")
+	adminPassword := os.Getenv("ADMIN_AUTH_PASS")
+	if adminUsername != "" && adminPassword != "" {
+		m.Group("/admin", func(r martini.Router) {
+			r.Get("/instances", AdminListInstances)
+			r.Get("/instances/:instance_id", AdminShowInstance)
+			r.Put("/instances/:instance_id/state", AdminSetInstanceState)
+			r.Post("/instances/:instance_id/reconcile", AdminReconcileInstance)
+			r.Get("/instances/:instance_id/snapshots", AdminListSnapshots)
+			r.Post("/instances/:instance_id/snapshots", AdminCreateSnapshot)
+			r.Post("/instances/:instance_id/restore", AdminRestoreInstance)
+			r.Post("/instances/:instance_id/delete", AdminDeleteInstance)
+			r.Get("/deletions", AdminListPendingDeletions)
+		}, auth.Basic(adminUsername, adminPassword))
+	} else {
+		logger.Info("admin-api-disabled")
+	}
 
 	return m
 }
+
+// requestLogger maps a logger for each request which carries the request ID,
+// so that every log line written while handling the request can be correlated.
+func requestLogger(logger lager.Logger) martini.Handler {
+	return func(c martini.Context, req *http.Request, w http.ResponseWriter) {
+		requestID := logging.RequestID(req)
+		w.Header().Set("X-Request-Id", requestID)
+		data := lager.Data{
+			logging.RequestIDKey: requestID,
+			"method":             req.Method,
+			"path":               req.URL.Path,
+		}
+		if spanContext := trace.SpanContextFromContext(req.Context()); spanContext.HasTraceID() {
+			data[logging.TraceIDKey] = spanContext.TraceID().String()
+		}
+		requestLogger := logger.Session("request", data)
+		c.MapTo(requestLogger, (*lager.Logger)(nil))
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"slices"
 	"strings"
 
+	"code.cloudfoundry.org/lager/lagertest"
 	"github.com/go-martini/martini"
 	"github.com/google/uuid"
 	"github.com/jinzhu/gorm"
@@ -17,7 +18,10 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/18F/aws-broker/admin"
+	"github.com/18F/aws-broker/base"
 	"github.com/18F/aws-broker/common"
 	"github.com/18F/aws-broker/config"
 	"github.com/18F/aws-broker/db"
@@ -155,6 +159,92 @@ var modifyRDSInstanceReq = []byte(
 	}
 }`)
 
+// medium-psql plan
+var modifyRDSInstanceSkipFinalSnapshotReq = []byte(
+	`{
+	"service_id":"db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+	"plan_id":"1070028c-b5fb-4de8-989b-4e00d07ef5e8",
+	"organization_guid":"an-org",
+	"space_guid":"a-space",
+	"parameters": {
+		"skip_final_snapshot": true
+	},
+	"previous_values": {
+		"plan_id": "da91e15c-98c9-46a9-b114-02b8d28062c6"
+	}
+}`)
+
+var createRDSInstanceWithReadReplicasReq = []byte(
+	`{
+	"service_id":"db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+	"plan_id":"da91e15c-98c9-46a9-b114-02b8d28062c6",
+	"organization_guid":"an-org",
+	"space_guid":"a-space",
+	"parameters": {
+		"read_replicas": 2
+	}
+}`)
+
+// medium-psql plan
+var modifyRDSInstanceReadReplicasReq = []byte(
+	`{
+	"service_id":"db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+	"plan_id":"1070028c-b5fb-4de8-989b-4e00d07ef5e8",
+	"organization_guid":"an-org",
+	"space_guid":"a-space",
+	"parameters": {
+		"read_replicas": 1
+	},
+	"previous_values": {
+		"plan_id": "da91e15c-98c9-46a9-b114-02b8d28062c6"
+	}
+}`)
+
+var createRDSInstanceDeletionProtectionReq = []byte(
+	`{
+	"service_id":"db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+	"plan_id":"da91e15c-98c9-46a9-b114-02b8d28062c6",
+	"organization_guid":"an-org",
+	"space_guid":"a-space",
+	"parameters": {
+		"deletion_protection": true
+	}
+}`)
+
+var modifyRDSInstanceDeletionProtectionOffReq = []byte(
+	`{
+	"service_id":"db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+	"plan_id":"da91e15c-98c9-46a9-b114-02b8d28062c6",
+	"organization_guid":"an-org",
+	"space_guid":"a-space",
+	"parameters": {
+		"deletion_protection": false
+	}
+}`)
+
+var modifyRDSInstanceCreateSnapshotReq = []byte(
+	`{
+	"service_id":"db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+	"plan_id":"da91e15c-98c9-46a9-b114-02b8d28062c6",
+	"parameters": {
+		"create_snapshot": "pre-migration"
+	},
+	"organization_guid":"an-org",
+	"space_guid":"a-space"
+}`)
+
+var modifyRDSInstanceCreateSnapshotWithStorageReq = []byte(
+	`{
+	"service_id":"db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+	"plan_id":"da91e15c-98c9-46a9-b114-02b8d28062c6",
+	"parameters": {
+		"create_snapshot": "pre-migration",
+		"storage": 25
+	},
+	"organization_guid":"an-org",
+	"space_guid":"a-space"
+}`)
+
 // medium-psql-redundant plan
 var modifyRDSInstanceNotAllowedReq = []byte(
 	`{
@@ -186,6 +276,17 @@ var modifyRedisInstanceReq = []byte(
 	}
 }`)
 
+var modifyRedisInstanceCreateSnapshotReq = []byte(
+	`{
+	"service_id":"cda65825-e357-4a93-a24b-9ab138d97815",
+	"plan_id":"475e36bf-387f-44c1-9b81-575fec2ee443",
+	"organization_guid":"an-org",
+	"space_guid":"a-space",
+	"parameters": {
+		"create_snapshot": "pre-migration"
+	}
+}`)
+
 var createElasticsearchInstanceAdvancedOptionsReq = []byte(
 	`{
 	"service_id":"90413816-9c77-418b-9fc7-b9739e7c1254",
@@ -263,12 +364,22 @@ func initTestDb(dbConfig *common.DBConfig) (*gorm.DB, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := db.Migrate(brokerDB, lagertest.NewTestLogger("main-test")); err != nil {
+		return nil, err
+	}
 	return brokerDB, nil
 }
 
 func setup() *martini.ClassicMartini {
+	return setupWithSettings(func(*config.Settings) {})
+}
+
+// setupWithSettings sets up the broker with the settings changed by configure.
+func setupWithSettings(configure func(*config.Settings)) *martini.ClassicMartini {
 	os.Setenv("AUTH_USER", "default")
 	os.Setenv("AUTH_PASS", "default")
+	os.Setenv("ADMIN_AUTH_USER", "admin")
+	os.Setenv("ADMIN_AUTH_PASS", "admin")
 	var s config.Settings
 
 	dbConfig, err := initTestDbConfig()
@@ -282,6 +393,7 @@ func setup() *martini.ClassicMartini {
 	s.CfApiUrl = "fake-api-url"
 	s.CfApiClientId = "fake-client-id"
 	s.CfApiClientSecret = "fake-client-secret"
+	configure(&s)
 
 	brokerDB, err = initTestDb(dbConfig)
 	if err != nil {
@@ -290,7 +402,7 @@ func setup() *martini.ClassicMartini {
 	tq := taskqueue.NewQueueManager()
 	tq.Init()
 
-	m := App(&s, brokerDB, tq)
+	m := App(&s, brokerDB, tq, lagertest.NewTestLogger("main-test"))
 
 	return m
 }
@@ -315,6 +427,20 @@ func doRequest(m *martini.ClassicMartini, url string, method string, auth bool,
 	return res, m
 }
 
+func doAdminRequest(m *martini.ClassicMartini, url string, method string, body io.Reader) (*httptest.ResponseRecorder, *martini.ClassicMartini) {
+	if m == nil {
+		m = setup()
+	}
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, url, body)
+	req.SetBasicAuth("admin", "admin")
+
+	m.ServeHTTP(res, req)
+
+	return res, m
+}
+
 /*
 	End Mock Objects
 */
@@ -699,6 +825,187 @@ func TestModifyRDSInstanceNotAllowed(t *testing.T) {
 	}
 }
 
+func TestAuroraInstance(t *testing.T) {
+	instanceUUID := uuid.NewString()
+	url := fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", instanceUUID)
+	res, m := doRequest(nil, url, "PUT", true, bytes.NewBufferString(`{
+	"service_id":"db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+	"plan_id":"5c0b6bd3-6f3a-4a4e-9d1d-5c4fb6d2f3a8",
+	"organization_guid":"an-org",
+	"space_guid":"a-space"
+}`))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Fatal(url, "with auth should return 202 and it returned", res.Code)
+	}
+	i := rds.RDSInstance{}
+	brokerDB.Where("uuid = ?", instanceUUID).First(&i)
+	if i.Adapter != "aurora" {
+		t.Errorf("expected an aurora instance, got %s", i.Adapter)
+	}
+
+	// Aurora instances cannot move to a dedicated plan.
+	res, _ = doRequest(m, url, "PATCH", true, bytes.NewBufferString(`{
+	"service_id":"db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+	"plan_id":"1070028c-b5fb-4de8-989b-4e00d07ef5e8",
+	"organization_guid":"an-org",
+	"space_guid":"a-space",
+	"previous_values": {
+		"plan_id": "5c0b6bd3-6f3a-4a4e-9d1d-5c4fb6d2f3a8"
+	}
+}`))
+	if res.Code != http.StatusBadRequest {
+		t.Logf("Body is: " + res.Body.String())
+		t.Error(url, "switching to a dedicated plan should return 400 and it returned", res.Code)
+	}
+
+	// The readers of Aurora instances are set by their plan.
+	res, _ = doRequest(m, url, "PATCH", true, bytes.NewBufferString(`{
+	"service_id":"db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+	"plan_id":"5c0b6bd3-6f3a-4a4e-9d1d-5c4fb6d2f3a8",
+	"organization_guid":"an-org",
+	"space_guid":"a-space",
+	"parameters": {
+		"read_replicas": 1
+	},
+	"previous_values": {
+		"plan_id": "5c0b6bd3-6f3a-4a4e-9d1d-5c4fb6d2f3a8"
+	}
+}`))
+	if res.Code != http.StatusBadRequest {
+		t.Logf("Body is: " + res.Body.String())
+		t.Error(url, "with read_replicas should return 400 and it returned", res.Code)
+	}
+}
+
+func TestSharedRDSInstance(t *testing.T) {
+	instanceUUID := uuid.NewString()
+	url := fmt.Sprintf("/v2/service_instances/%s", instanceUUID)
+	res, m := doRequest(nil, url+"?accepts_incomplete=true", "PUT", true, bytes.NewBufferString(`{
+	"service_id":"db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+	"plan_id":"44d24fc7-f7a4-4ac1-b7a0-de82836e89a4",
+	"organization_guid":"an-org",
+	"space_guid":"a-space"
+}`))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Fatal(url, "with auth should return 202 and it returned", res.Code)
+	}
+	i := rds.RDSInstance{}
+	brokerDB.Where("uuid = ?", instanceUUID).First(&i)
+	if i.Adapter != "shared" {
+		t.Errorf("expected a shared instance, got %s", i.Adapter)
+	}
+
+	// Shared databases are dropped without a final snapshot.
+	res, _ = doRequest(m, url+"?accepts_incomplete=true", "DELETE", true, nil)
+	if res.Code != http.StatusOK {
+		t.Logf("Unable to delete instance. Body is: " + res.Body.String())
+		t.Error(url, "with auth should return 200 and it returned", res.Code)
+	}
+
+	snapshot := rds.FinalSnapshot{}
+	brokerDB.Where("instance_guid = ?", instanceUUID).First(&snapshot)
+	if snapshot.SnapshotIdentifier != "" {
+		t.Error("No final snapshot should be recorded, got", snapshot.SnapshotIdentifier)
+	}
+}
+
+func TestModifyRDSInstanceBlueGreen(t *testing.T) {
+	instanceUUID := uuid.NewString()
+	url := fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", instanceUUID)
+
+	// Blue/green deployments are only used for updates.
+	res, _ := doRequest(nil, url, "PUT", true, bytes.NewBufferString(`{
+	"service_id":"db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+	"plan_id":"da91e15c-98c9-46a9-b114-02b8d28062c6",
+	"organization_guid":"an-org",
+	"space_guid":"a-space",
+	"parameters": {
+		"use_blue_green": true
+	}
+}`))
+	if res.Code != http.StatusBadRequest {
+		t.Logf("Body is: " + res.Body.String())
+		t.Error(url, "with use_blue_green should return 400 and it returned", res.Code)
+	}
+
+	res, m := doRequest(nil, url, "PUT", true, bytes.NewBuffer(createRDSPGWithVersionInstanceReq))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Fatal(url, "with auth should return 202 and it returned", res.Code)
+	}
+
+	res, _ = doRequest(m, url, "PATCH", true, bytes.NewBufferString(`{
+	"service_id":"db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+	"plan_id":"da91e15c-98c9-46a9-b114-02b8d28062c6",
+	"organization_guid":"an-org",
+	"space_guid":"a-space",
+	"parameters": {
+		"use_blue_green": true,
+		"binary_log_format": "ROW"
+	},
+	"previous_values": {
+		"plan_id": "da91e15c-98c9-46a9-b114-02b8d28062c6"
+	}
+}`))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to modify instance. Body is: " + res.Body.String())
+		t.Fatal(url, "with use_blue_green should return 202 and it returned", res.Code)
+	}
+}
+
+func TestModifyRDSInstanceMajorVersion(t *testing.T) {
+	instanceUUID := uuid.NewString()
+	url := fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", instanceUUID)
+	res, m := doRequest(nil, url, "PUT", true, bytes.NewBufferString(`{
+	"service_id":"db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+	"plan_id":"da91e15c-98c9-46a9-b114-02b8d28062c6",
+	"organization_guid":"an-org",
+	"space_guid":"a-space",
+	"parameters": {
+		"version": "12"
+	}
+}`))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Fatal(url, "with auth should return 202 and it returned", res.Code)
+	}
+
+	upgradeReq := func(version string) *bytes.Buffer {
+		return bytes.NewBufferString(fmt.Sprintf(`{
+	"service_id":"db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+	"plan_id":"da91e15c-98c9-46a9-b114-02b8d28062c6",
+	"organization_guid":"an-org",
+	"space_guid":"a-space",
+	"parameters": {
+		"version": "%s"
+	},
+	"previous_values": {
+		"plan_id": "da91e15c-98c9-46a9-b114-02b8d28062c6"
+	}
+}`, version))
+	}
+
+	// A version that the plan does not approve
+	res, _ = doRequest(m, url, "PATCH", true, upgradeReq("9.6"))
+	if res.Code != http.StatusBadRequest {
+		t.Logf("Body is: " + res.Body.String())
+		t.Error(url, "with an unapproved version should return 400 and it returned", res.Code)
+	}
+
+	res, _ = doRequest(m, url, "PATCH", true, upgradeReq("15"))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to modify instance. Body is: " + res.Body.String())
+		t.Fatal(url, "with auth should return 202 and it returned", res.Code)
+	}
+	i := rds.RDSInstance{}
+	brokerDB.Where("uuid = ?", instanceUUID).First(&i)
+	if i.DbVersion != "15" {
+		t.Errorf("expected the instance to be upgraded to version 15, got %s", i.DbVersion)
+	}
+}
+
 func TestModifyRDSInstanceSizeIncrease(t *testing.T) {
 	instanceUUID := uuid.NewString()
 	// We need to create an instance first before we can try to modify it.
@@ -1051,12 +1358,33 @@ func TestRDSDeleteInstance(t *testing.T) {
 		t.Error("The instance should be in the DB")
 	}
 
-	res, _ = doRequest(m, url, "DELETE", true, nil)
+	// Deleting takes a final snapshot, so it is asynchronous
+	res, m = doRequest(m, url, "DELETE", true, nil)
+	if res.Code != http.StatusUnprocessableEntity {
+		t.Error(url, "without accepts_incomplete should return 422 and it returned", res.Code)
+	}
+
+	res, m = doRequest(m, url+"?accepts_incomplete=true", "DELETE", true, nil)
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to delete instance. Body is: " + res.Body.String())
+		t.Error(url, "with auth should return 202 and it returned", res.Code)
+	}
+
+	// Is the final snapshot recorded?
+	snapshot := rds.FinalSnapshot{}
+	brokerDB.Where("instance_guid = ?", instanceUUID).First(&snapshot)
+	if !strings.Contains(snapshot.SnapshotIdentifier, instanceUUID) {
+		t.Error("The final snapshot should be in the DB, got", snapshot.SnapshotIdentifier)
+	}
 
+	res, _ = doRequest(m, fmt.Sprintf("/v2/service_instances/%s/last_operation?operation=delete", instanceUUID), "GET", true, nil)
 	if res.Code != http.StatusOK {
-		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Logf("Unable to check last operation. Body is: " + res.Body.String())
 		t.Error(url, "with auth should return 200 and it returned", res.Code)
 	}
+	if !strings.Contains(res.Body.String(), "succeeded") {
+		t.Error("The deletion should have succeeded, got", res.Body.String())
+	}
 
 	// Is it actually gone from the DB?
 	i = rds.RDSInstance{}
@@ -1064,6 +1392,327 @@ func TestRDSDeleteInstance(t *testing.T) {
 	if len(i.Uuid) > 0 {
 		t.Error("The instance shouldn't be in the DB")
 	}
+	baseInstance := base.Instance{}
+	brokerDB.Where("uuid = ?", instanceUUID).First(&baseInstance)
+	if len(baseInstance.Uuid) > 0 {
+		t.Error("The base instance shouldn't be in the DB")
+	}
+}
+
+func TestRDSCreateInstanceFromSourceInstance(t *testing.T) {
+	sourceUUID := uuid.NewString()
+	res, m := doRequest(nil, fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", sourceUUID), "PUT", true, bytes.NewBuffer(createRDSInstanceReq))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Fatal("with auth should return 202 and it returned", res.Code)
+	}
+
+	restoreReq := func(orgGUID string, sourceInstanceGUID string) *bytes.Buffer {
+		return bytes.NewBufferString(fmt.Sprintf(`{
+	"service_id":"db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+	"plan_id":"da91e15c-98c9-46a9-b114-02b8d28062c6",
+	"organization_guid":"%s",
+	"space_guid":"a-space",
+	"parameters": {
+		"source_instance_guid": "%s"
+	}
+}`, orgGUID, sourceInstanceGUID))
+	}
+
+	testCases := map[string]struct {
+		orgGUID            string
+		sourceInstanceGUID string
+		expectedCode       int
+	}{
+		"same organization": {
+			orgGUID:            "an-org",
+			sourceInstanceGUID: sourceUUID,
+			expectedCode:       http.StatusAccepted,
+		},
+		"another organization": {
+			orgGUID:            "another-org",
+			sourceInstanceGUID: sourceUUID,
+			expectedCode:       http.StatusForbidden,
+		},
+		"unknown source instance": {
+			orgGUID:            "an-org",
+			sourceInstanceGUID: uuid.NewString(),
+			expectedCode:       http.StatusNotFound,
+		},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			url := fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", uuid.NewString())
+			res, _ := doRequest(m, url, "PUT", true, restoreReq(test.orgGUID, test.sourceInstanceGUID))
+			if res.Code != test.expectedCode {
+				t.Logf("Body is: " + res.Body.String())
+				t.Error(url, "should return", test.expectedCode, "and it returned", res.Code)
+			}
+		})
+	}
+}
+
+func TestRDSCreateInstanceFromPointInTime(t *testing.T) {
+	sourceUUID := uuid.NewString()
+	res, m := doRequest(nil, fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", sourceUUID), "PUT", true, bytes.NewBuffer(createRDSInstanceReq))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Fatal("with auth should return 202 and it returned", res.Code)
+	}
+
+	restoreReq := func(orgGUID string, sourceInstanceGUID string, restoreTime string) *bytes.Buffer {
+		return bytes.NewBufferString(fmt.Sprintf(`{
+	"service_id":"db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+	"plan_id":"da91e15c-98c9-46a9-b114-02b8d28062c6",
+	"organization_guid":"%s",
+	"space_guid":"a-space",
+	"parameters": {
+		"restore_from_instance": "%s",
+		"restore_time": "%s"
+	}
+}`, orgGUID, sourceInstanceGUID, restoreTime))
+	}
+
+	testCases := map[string]struct {
+		orgGUID            string
+		sourceInstanceGUID string
+		restoreTime        string
+		expectedCode       int
+	}{
+		"latest restorable time": {
+			orgGUID:            "an-org",
+			sourceInstanceGUID: sourceUUID,
+			restoreTime:        "latest",
+			expectedCode:       http.StatusAccepted,
+		},
+		"within retention period": {
+			orgGUID:            "an-org",
+			sourceInstanceGUID: sourceUUID,
+			restoreTime:        time.Now().Add(-time.Hour).Format(time.RFC3339),
+			expectedCode:       http.StatusAccepted,
+		},
+		"invalid restore time": {
+			orgGUID:            "an-org",
+			sourceInstanceGUID: sourceUUID,
+			restoreTime:        "yesterday",
+			expectedCode:       http.StatusBadRequest,
+		},
+		"another organization": {
+			orgGUID:            "another-org",
+			sourceInstanceGUID: sourceUUID,
+			restoreTime:        "latest",
+			expectedCode:       http.StatusForbidden,
+		},
+		"unknown source instance": {
+			orgGUID:            "an-org",
+			sourceInstanceGUID: uuid.NewString(),
+			restoreTime:        "latest",
+			expectedCode:       http.StatusNotFound,
+		},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			url := fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", uuid.NewString())
+			res, _ := doRequest(m, url, "PUT", true, restoreReq(test.orgGUID, test.sourceInstanceGUID, test.restoreTime))
+			if res.Code != test.expectedCode {
+				t.Logf("Body is: " + res.Body.String())
+				t.Error(url, "should return", test.expectedCode, "and it returned", res.Code)
+			}
+		})
+	}
+}
+
+func TestRDSDeleteInstanceSkipFinalSnapshot(t *testing.T) {
+	instanceUUID := uuid.NewString()
+	url := fmt.Sprintf("/v2/service_instances/%s", instanceUUID)
+	res, m := doRequest(nil, url+"?accepts_incomplete=true", "PUT", true, bytes.NewBuffer(createRDSInstanceReq))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Error(url, "with auth should return 202 and it returned", res.Code)
+	}
+
+	// Opt out of the final snapshot before deleting
+	res, m = doRequest(m, url+"?accepts_incomplete=true", "PATCH", true, bytes.NewBuffer(modifyRDSInstanceSkipFinalSnapshotReq))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to modify instance. Body is: " + res.Body.String())
+		t.Error(url, "with auth should return 202 and it returned", res.Code)
+	}
+
+	res, _ = doRequest(m, url+"?accepts_incomplete=true", "DELETE", true, nil)
+	if res.Code != http.StatusOK {
+		t.Logf("Unable to delete instance. Body is: " + res.Body.String())
+		t.Error(url, "with auth should return 200 and it returned", res.Code)
+	}
+
+	snapshot := rds.FinalSnapshot{}
+	brokerDB.Where("instance_guid = ?", instanceUUID).First(&snapshot)
+	if snapshot.SnapshotIdentifier != "" {
+		t.Error("No final snapshot should be recorded, got", snapshot.SnapshotIdentifier)
+	}
+
+	i := rds.RDSInstance{}
+	brokerDB.Where("uuid = ?", instanceUUID).First(&i)
+	if len(i.Uuid) > 0 {
+		t.Error("The instance shouldn't be in the DB")
+	}
+}
+
+func TestRDSDeleteInstanceDeletionProtection(t *testing.T) {
+	instanceUUID := uuid.NewString()
+	url := fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", instanceUUID)
+	res, m := doRequest(nil, url, "PUT", true, bytes.NewBuffer(createRDSInstanceDeletionProtectionReq))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Fatal(url, "with auth should return 202 and it returned", res.Code)
+	}
+	i := rds.RDSInstance{}
+	brokerDB.Where("uuid = ?", instanceUUID).First(&i)
+	if !i.DeletionProtection {
+		t.Error("The instance should have deletion protection")
+	}
+
+	res, m = doRequest(m, url, "DELETE", true, nil)
+	if res.Code != http.StatusUnprocessableEntity {
+		t.Error(url, "with deletion protection should return 422 and it returned", res.Code)
+	}
+	if !strings.Contains(res.Body.String(), "deletion protection") {
+		t.Error("The error should explain the deletion protection, got", res.Body.String())
+	}
+
+	// Turn the protection off before deleting
+	res, m = doRequest(m, url, "PATCH", true, bytes.NewBuffer(modifyRDSInstanceDeletionProtectionOffReq))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to modify instance. Body is: " + res.Body.String())
+		t.Error(url, "with auth should return 202 and it returned", res.Code)
+	}
+	res, _ = doRequest(m, url, "DELETE", true, nil)
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to delete instance. Body is: " + res.Body.String())
+		t.Error(url, "with auth should return 202 and it returned", res.Code)
+	}
+}
+
+func TestRDSDeleteInstanceDelayed(t *testing.T) {
+	m := setupWithSettings(func(s *config.Settings) {
+		s.DeletionDelayDays = 7
+	})
+	instanceUUID := uuid.NewString()
+	url := fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", instanceUUID)
+	res, m := doRequest(m, url, "PUT", true, bytes.NewBuffer(createRDSInstanceReq))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Fatal(url, "with auth should return 202 and it returned", res.Code)
+	}
+
+	// The instance is only marked for deletion
+	for range 2 {
+		res, m = doRequest(m, url, "DELETE", true, nil)
+		if res.Code != http.StatusOK {
+			t.Logf("Unable to delete instance. Body is: " + res.Body.String())
+			t.Error(url, "with auth should return 200 and it returned", res.Code)
+		}
+	}
+	deletion := base.PendingDeletion{}
+	brokerDB.Where("instance_guid = ?", instanceUUID).First(&deletion)
+	if time.Until(deletion.DeleteAt) < 6*24*time.Hour {
+		t.Error("The instance should be deleted in 7 days, got", deletion.DeleteAt)
+	}
+	i := rds.RDSInstance{}
+	brokerDB.Where("uuid = ?", instanceUUID).First(&i)
+	if i.Uuid != instanceUUID {
+		t.Error("The instance should still be in the DB")
+	}
+
+	res, _ = doAdminRequest(m, "/admin/deletions", "GET", nil)
+	if res.Code != http.StatusOK || !strings.Contains(res.Body.String(), instanceUUID) {
+		t.Error("The instance should be listed as pending deletion, got", res.Code, res.Body.String())
+	}
+	res, _ = doAdminRequest(m, fmt.Sprintf("/admin/instances/%s/restore", instanceUUID), "POST", nil)
+	if res.Code != http.StatusOK {
+		t.Logf("Unable to restore instance. Body is: " + res.Body.String())
+		t.Error("restoring the instance should return 200 and it returned", res.Code)
+	}
+	var count int64
+	brokerDB.Model(&base.PendingDeletion{}).Where("instance_guid = ?", instanceUUID).Count(&count)
+	if count != 0 {
+		t.Error("The pending deletion should be cancelled")
+	}
+}
+
+func TestRDSReadReplicas(t *testing.T) {
+	instanceUUID := uuid.NewString()
+	url := fmt.Sprintf("/v2/service_instances/%s", instanceUUID)
+	res, m := doRequest(nil, url+"?accepts_incomplete=true", "PUT", true, bytes.NewBuffer(createRDSInstanceWithReadReplicasReq))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Fatal(url, "with auth should return 202 and it returned", res.Code)
+	}
+
+	// The replicas are created once the instance is available.
+	res, _ = doRequest(m, url+"/last_operation", "GET", true, nil)
+	if !strings.Contains(res.Body.String(), "succeeded") {
+		t.Logf("Body is: " + res.Body.String())
+		t.Error(url, "should report the instance as succeeded")
+	}
+	var replicas []rds.ReadReplica
+	brokerDB.Where("instance_guid = ?", instanceUUID).Find(&replicas)
+	if len(replicas) != 2 {
+		t.Fatalf("expected 2 read replicas, got %d", len(replicas))
+	}
+
+	res, _ = doRequest(m, url+"/service_bindings/the_binding", "PUT", true, bytes.NewBuffer(createRDSInstanceReq))
+	if res.Code != http.StatusCreated {
+		t.Logf("Unable to bind instance. Body is: " + res.Body.String())
+		t.Fatal(url, "with auth should return 201 and it returned", res.Code)
+	}
+	var r struct {
+		Credentials map[string]string
+	}
+	json.Unmarshal(res.Body.Bytes(), &r)
+	replicaURIs := strings.Split(r.Credentials["replica_uris"], ",")
+	if len(replicaURIs) != 2 || !strings.Contains(replicaURIs[0], replicas[0].Host) {
+		t.Errorf("expected the URIs of the read replicas, got %q", r.Credentials["replica_uris"])
+	}
+
+	// Reduce the number of replicas
+	res, _ = doRequest(m, url+"?accepts_incomplete=true", "PATCH", true, bytes.NewBuffer(modifyRDSInstanceReadReplicasReq))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to modify instance. Body is: " + res.Body.String())
+		t.Fatal(url, "with auth should return 202 and it returned", res.Code)
+	}
+	doRequest(m, url+"/last_operation", "GET", true, nil)
+	replicas = nil
+	brokerDB.Where("instance_guid = ?", instanceUUID).Find(&replicas)
+	if len(replicas) != 1 {
+		t.Fatalf("expected 1 read replica, got %d", len(replicas))
+	}
+
+	// The replicas are deleted before the instance
+	res, _ = doRequest(m, url+"?accepts_incomplete=true", "DELETE", true, nil)
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to delete instance. Body is: " + res.Body.String())
+		t.Error(url, "with auth should return 202 and it returned", res.Code)
+	}
+	replicas = nil
+	brokerDB.Where("instance_guid = ?", instanceUUID).Find(&replicas)
+	if len(replicas) != 1 {
+		t.Fatalf("expected the read replica to be kept until it is gone, got %d", len(replicas))
+	}
+	res, _ = doRequest(m, url+"/last_operation?operation=delete", "GET", true, nil)
+	if !strings.Contains(res.Body.String(), "in progress") {
+		t.Logf("Body is: " + res.Body.String())
+		t.Error(url, "should report the deletion as in progress until the instance is gone")
+	}
+	replicas = nil
+	brokerDB.Where("instance_guid = ?", instanceUUID).Find(&replicas)
+	if len(replicas) != 0 {
+		t.Errorf("expected the read replicas to be deleted, got %d", len(replicas))
+	}
+	res, _ = doRequest(m, url+"/last_operation?operation=delete", "GET", true, nil)
+	if !strings.Contains(res.Body.String(), "succeeded") {
+		t.Logf("Body is: " + res.Body.String())
+		t.Error(url, "should report the deletion as succeeded once the instance is gone")
+	}
 }
 
 /*
@@ -1168,6 +1817,25 @@ func TestModifyRedisInstance(t *testing.T) {
 	}
 }
 
+func TestModifyRedisInstanceCreateSnapshot(t *testing.T) {
+	instanceUUID := uuid.NewString()
+	createURL := fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", instanceUUID)
+	res, m := doRequest(nil, createURL, "PUT", true, bytes.NewBuffer(createRedisInstanceReq))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Fatal(createURL, "with auth should return 202 and it returned", res.Code)
+	}
+
+	resp, _ := doRequest(m, createURL, "PATCH", true, bytes.NewBuffer(modifyRedisInstanceCreateSnapshotReq))
+	if resp.Code != http.StatusOK {
+		t.Logf("Unable to take snapshot. Body is: " + resp.Body.String())
+		t.Fatal(createURL, "with create_snapshot should return 200 and it returned", resp.Code)
+	}
+	if !strings.Contains(resp.Body.String(), "-manual-pre-migration") {
+		t.Error(createURL, "should return the name of the snapshot, got", resp.Body.String())
+	}
+}
+
 func TestRedisLastOperation(t *testing.T) {
 	instanceUUID := uuid.NewString()
 	url := fmt.Sprintf("/v2/service_instances/%s/last_operation", instanceUUID)
@@ -1646,3 +2314,346 @@ func TestElasticsearchDeleteInstance(t *testing.T) {
 		t.Error("The instance shouldn't be in the DB")
 	}
 }
+
+func TestCreateInstanceCloneFrom(t *testing.T) {
+	services := map[string]struct {
+		createReq []byte
+		serviceID string
+		planID    string
+	}{
+		"rds": {
+			createReq: createRDSInstanceReq,
+			serviceID: "db80ca29-2d1b-4fbc-aad3-d03c0bfa7593",
+			planID:    "da91e15c-98c9-46a9-b114-02b8d28062c6",
+		},
+		"redis": {
+			createReq: createRedisInstanceReq,
+			serviceID: "cda65825-e357-4a93-a24b-9ab138d97815",
+			planID:    "475e36bf-387f-44c1-9b81-575fec2ee443",
+		},
+		"elasticsearch": {
+			createReq: createElasticsearchInstanceReq,
+			serviceID: "90413816-9c77-418b-9fc7-b9739e7c1254",
+			planID:    "55b529cf-639e-4673-94fd-ad0a5dafe0ad",
+		},
+	}
+
+	var m *martini.ClassicMartini
+	sourceUUIDs := map[string]string{}
+	for name, service := range services {
+		sourceUUID := uuid.NewString()
+		var res *httptest.ResponseRecorder
+		res, m = doRequest(m, fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", sourceUUID), "PUT", true, bytes.NewBuffer(service.createReq))
+		if res.Code != http.StatusAccepted {
+			t.Logf("Unable to create instance. Body is: " + res.Body.String())
+			t.Fatal("with auth should return 202 and it returned", res.Code)
+		}
+		sourceUUIDs[name] = sourceUUID
+	}
+
+	cloneReq := func(serviceID string, planID string, orgGUID string, cloneFrom string) *bytes.Buffer {
+		return bytes.NewBufferString(fmt.Sprintf(`{
+	"service_id":"%s",
+	"plan_id":"%s",
+	"organization_guid":"%s",
+	"space_guid":"a-space",
+	"parameters": {
+		"clone_from": "%s"
+	}
+}`, serviceID, planID, orgGUID, cloneFrom))
+	}
+
+	for name, service := range services {
+		otherService := "rds"
+		if name == "rds" {
+			otherService = "redis"
+		}
+		testCases := map[string]struct {
+			orgGUID      string
+			cloneFrom    string
+			expectedCode int
+		}{
+			"same organization": {
+				orgGUID:      "an-org",
+				cloneFrom:    sourceUUIDs[name],
+				expectedCode: http.StatusAccepted,
+			},
+			"another organization": {
+				orgGUID:      "another-org",
+				cloneFrom:    sourceUUIDs[name],
+				expectedCode: http.StatusForbidden,
+			},
+			"unknown source instance": {
+				orgGUID:      "an-org",
+				cloneFrom:    uuid.NewString(),
+				expectedCode: http.StatusNotFound,
+			},
+			"source instance of another service": {
+				orgGUID:      "an-org",
+				cloneFrom:    sourceUUIDs[otherService],
+				expectedCode: http.StatusBadRequest,
+			},
+		}
+		for caseName, test := range testCases {
+			t.Run(name+" "+caseName, func(t *testing.T) {
+				url := fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", uuid.NewString())
+				res, _ := doRequest(m, url, "PUT", true, cloneReq(service.serviceID, service.planID, test.orgGUID, test.cloneFrom))
+				if res.Code != test.expectedCode {
+					t.Logf("Body is: " + res.Body.String())
+					t.Error(url, "should return", test.expectedCode, "and it returned", res.Code)
+				}
+			})
+		}
+	}
+
+	// The clone of an Elasticsearch instance is restored once the domain is ready.
+	instanceUUID := uuid.NewString()
+	es := services["elasticsearch"]
+	res, _ := doRequest(m, fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", instanceUUID), "PUT", true, cloneReq(es.serviceID, es.planID, "an-org", sourceUUIDs["elasticsearch"]))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Fatal("with auth should return 202 and it returned", res.Code)
+	}
+	i := elasticsearch.ElasticsearchInstance{}
+	brokerDB.Where("uuid = ?", instanceUUID).First(&i)
+	if !i.ClonePending || i.ClonedFrom != sourceUUIDs["elasticsearch"] {
+		t.Errorf("expected the instance to be pending a clone of %s, got %+v", sourceUUIDs["elasticsearch"], i)
+	}
+	url := fmt.Sprintf("/v2/service_instances/%s/last_operation", instanceUUID)
+	res, _ = doRequest(m, url, "GET", true, nil)
+	if !strings.Contains(res.Body.String(), "succeeded") {
+		t.Logf("Body is: " + res.Body.String())
+		t.Error(url, "should report the clone as succeeded")
+	}
+	i = elasticsearch.ElasticsearchInstance{}
+	brokerDB.Where("uuid = ?", instanceUUID).First(&i)
+	if i.ClonePending {
+		t.Error("expected the clone to be restored")
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	url := "/admin/instances"
+	res, m := doRequest(nil, url, "GET", false, nil)
+	if res.Code != http.StatusUnauthorized {
+		t.Error(url, "without auth should return 401 and it returned", res.Code)
+	}
+
+	// The broker credentials must not grant access to the admin API.
+	res, m = doRequest(m, url, "GET", true, nil)
+	if res.Code != http.StatusUnauthorized {
+		t.Error(url, "with broker auth should return 401 and it returned", res.Code)
+	}
+
+	// Nor the admin credentials to the broker API.
+	res, m = doAdminRequest(m, "/v2/catalog", "GET", nil)
+	if res.Code != http.StatusUnauthorized {
+		t.Error("/v2/catalog with admin auth should return 401 and it returned", res.Code)
+	}
+
+	res, _ = doAdminRequest(m, url, "GET", nil)
+	if res.Code != http.StatusOK {
+		t.Error(url, "with admin auth should return 200 and it returned", res.Code)
+	}
+}
+
+func TestAdminListInstances(t *testing.T) {
+	instanceUUID := uuid.NewString()
+	res, m := doRequest(nil, fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", instanceUUID), "PUT", true, bytes.NewBuffer(createRedisInstanceReq))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Fatal("create with auth should return 202 and it returned", res.Code)
+	}
+
+	url := "/admin/instances?service=redis&org=an-org&state=InstanceReady"
+	res, m = doAdminRequest(m, url, "GET", nil)
+	if res.Code != http.StatusOK {
+		t.Fatal(url, "should return 200 and it returned", res.Code)
+	}
+	var list struct {
+		Instances []admin.InstanceSummary `json:"instances"`
+	}
+	if err := json.Unmarshal(res.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, instance := range list.Instances {
+		if instance.Service != "redis" || instance.OrganizationGUID != "an-org" || instance.State != "InstanceReady" {
+			t.Errorf("instance %+v does not match the filter", instance)
+		}
+		if instance.InstanceGUID == instanceUUID {
+			found = true
+		}
+	}
+	if !found {
+		t.Error(url, "should list the created instance")
+	}
+
+	url = "/admin/instances?service=redis&state=InstanceInProgress"
+	res, m = doAdminRequest(m, url, "GET", nil)
+	if strings.Contains(res.Body.String(), instanceUUID) {
+		t.Error(url, "should not list the created instance")
+	}
+
+	url = "/admin/instances?state=unknown"
+	res, m = doAdminRequest(m, url, "GET", nil)
+	if res.Code != http.StatusBadRequest {
+		t.Error(url, "should return 400 and it returned", res.Code)
+	}
+
+	url = "/admin/instances?service=unknown"
+	res, _ = doAdminRequest(m, url, "GET", nil)
+	if res.Code != http.StatusBadRequest {
+		t.Error(url, "should return 400 and it returned", res.Code)
+	}
+}
+
+func TestAdminShowInstance(t *testing.T) {
+	instanceUUID := uuid.NewString()
+	url := fmt.Sprintf("/admin/instances/%s", instanceUUID)
+	res, m := doAdminRequest(nil, url, "GET", nil)
+	if res.Code != http.StatusNotFound {
+		t.Error(url, "without the instance should return 404 and it returned", res.Code)
+	}
+
+	res, m = doRequest(m, fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", instanceUUID), "PUT", true, bytes.NewBuffer(createRDSInstanceReq))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Fatal("create with auth should return 202 and it returned", res.Code)
+	}
+
+	res, _ = doAdminRequest(m, url, "GET", nil)
+	if res.Code != http.StatusOK {
+		t.Fatal(url, "should return 200 and it returned", res.Code)
+	}
+	var detail map[string]interface{}
+	if err := json.Unmarshal(res.Body.Bytes(), &detail); err != nil {
+		t.Fatal(err)
+	}
+	if detail["instance_guid"] != instanceUUID || detail["service"] != "rds" {
+		t.Error(url, "should return the instance summary, got", res.Body.String())
+	}
+	if _, ok := detail["instance"]; !ok {
+		t.Error(url, "should return the service instance record")
+	}
+	if strings.Contains(res.Body.String(), `"password"`) || strings.Contains(res.Body.String(), `"salt"`) {
+		t.Error(url, "should not return credentials")
+	}
+}
+
+func TestAdminSetInstanceState(t *testing.T) {
+	instanceUUID := uuid.NewString()
+	res, m := doRequest(nil, fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", instanceUUID), "PUT", true, bytes.NewBuffer(createRDSInstanceReq))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Fatal("create with auth should return 202 and it returned", res.Code)
+	}
+
+	url := fmt.Sprintf("/admin/instances/%s/state", instanceUUID)
+	res, m = doAdminRequest(m, url, "PUT", strings.NewReader(`{"state": "bogus"}`))
+	if res.Code != http.StatusBadRequest {
+		t.Error(url, "with an invalid state should return 400 and it returned", res.Code)
+	}
+
+	res, _ = doAdminRequest(m, url, "PUT", strings.NewReader(`{"state": "InstanceInProgress"}`))
+	if res.Code != http.StatusOK {
+		t.Logf("Unable to set state. Body is: " + res.Body.String())
+		t.Fatal(url, "should return 200 and it returned", res.Code)
+	}
+
+	i := rds.RDSInstance{}
+	brokerDB.Where("uuid = ?", instanceUUID).First(&i)
+	if i.State != base.InstanceInProgress {
+		t.Error("The RDS instance state should be updated, got", i.State)
+	}
+	instance := base.Instance{}
+	brokerDB.Where("uuid = ?", instanceUUID).First(&instance)
+	if instance.State != base.InstanceInProgress {
+		t.Error("The base instance state should be updated, got", instance.State)
+	}
+}
+
+func TestAdminReconcileInstance(t *testing.T) {
+	instanceUUID := uuid.NewString()
+	url := fmt.Sprintf("/admin/instances/%s/reconcile?operation=create", instanceUUID)
+	res, m := doAdminRequest(nil, url, "POST", nil)
+	if res.Code != http.StatusNotFound {
+		t.Error(url, "without the instance should return 404 and it returned", res.Code)
+	}
+
+	res, m = doRequest(m, fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", instanceUUID), "PUT", true, bytes.NewBuffer(createRDSInstanceReq))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Fatal("create with auth should return 202 and it returned", res.Code)
+	}
+
+	res, _ = doAdminRequest(m, url, "POST", nil)
+	if res.Code != http.StatusOK {
+		t.Logf("Unable to reconcile. Body is: " + res.Body.String())
+		t.Error(url, "should return 200 and it returned", res.Code)
+	}
+}
+
+func TestModifyRDSInstanceCreateSnapshot(t *testing.T) {
+	instanceUUID := uuid.NewString()
+	createURL := fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", instanceUUID)
+	res, m := doRequest(nil, createURL, "PUT", true, bytes.NewBuffer(createRDSInstanceReq))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Fatal(createURL, "with auth should return 202 and it returned", res.Code)
+	}
+
+	// Snapshots are not combined with other changes.
+	resp, m := doRequest(m, createURL, "PATCH", true, bytes.NewBuffer(modifyRDSInstanceCreateSnapshotWithStorageReq))
+	if resp.Code != http.StatusBadRequest {
+		t.Error(createURL, "with create_snapshot and storage should return 400 and it returned", resp.Code)
+	}
+
+	resp, _ = doRequest(m, createURL, "PATCH", true, bytes.NewBuffer(modifyRDSInstanceCreateSnapshotReq))
+	if resp.Code != http.StatusOK {
+		t.Logf("Unable to take snapshot. Body is: " + resp.Body.String())
+		t.Fatal(createURL, "with create_snapshot should return 200 and it returned", resp.Code)
+	}
+	if !strings.Contains(resp.Body.String(), "-manual-pre-migration") {
+		t.Error(createURL, "should return the name of the snapshot, got", resp.Body.String())
+	}
+}
+
+func TestAdminSnapshots(t *testing.T) {
+	instanceUUID := uuid.NewString()
+	url := fmt.Sprintf("/admin/instances/%s/snapshots", instanceUUID)
+	res, m := doAdminRequest(nil, url, "GET", nil)
+	if res.Code != http.StatusNotFound {
+		t.Error(url, "without the instance should return 404 and it returned", res.Code)
+	}
+
+	res, m = doRequest(m, fmt.Sprintf("/v2/service_instances/%s?accepts_incomplete=true", instanceUUID), "PUT", true, bytes.NewBuffer(createRDSInstanceReq))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to create instance. Body is: " + res.Body.String())
+		t.Fatal("create with auth should return 202 and it returned", res.Code)
+	}
+
+	res, m = doAdminRequest(m, url, "POST", strings.NewReader(`{"name": "not a name"}`))
+	if res.Code != http.StatusBadRequest {
+		t.Error(url, "with an invalid name should return 400 and it returned", res.Code)
+	}
+
+	res, m = doAdminRequest(m, url, "POST", strings.NewReader(`{"name": "pre-migration"}`))
+	if res.Code != http.StatusAccepted {
+		t.Logf("Unable to take snapshot. Body is: " + res.Body.String())
+		t.Fatal(url, "should return 202 and it returned", res.Code)
+	}
+	var snapshot base.Snapshot
+	if err := json.Unmarshal(res.Body.Bytes(), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(snapshot.Name, "-manual-pre-migration") || !snapshot.Manual {
+		t.Error(url, "should return the snapshot, got", res.Body.String())
+	}
+
+	res, _ = doAdminRequest(m, url, "GET", nil)
+	if res.Code != http.StatusOK {
+		t.Fatal(url, "should return 200 and it returned", res.Code)
+	}
+	validJSON(res.Body.Bytes(), url, t)
+}
@@ -1,76 +1,29 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"time"
 
+	"code.cloudfoundry.org/lager"
 	"github.com/18F/aws-broker/base"
 	"github.com/18F/aws-broker/catalog"
 	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/helpers/logging"
 	"github.com/18F/aws-broker/helpers/request"
 	"github.com/18F/aws-broker/helpers/response"
-	"github.com/18F/aws-broker/services/elasticsearch"
-	"github.com/18F/aws-broker/services/rds"
-	"github.com/18F/aws-broker/services/redis"
+	"github.com/18F/aws-broker/services"
 	"github.com/18F/aws-broker/taskqueue"
-	brokertags "github.com/cloud-gov/go-broker-tags"
 	"github.com/jinzhu/gorm"
 )
 
-type mockTagGenerator struct {
-	tags map[string]string
-}
-
-func (mt *mockTagGenerator) GenerateTags(
-	action brokertags.Action,
-	serviceName string,
-	servicePlanName string,
-	resourceGUIDs brokertags.ResourceGUIDs,
-	getMissingResources bool,
-) (map[string]string, error) {
-	return mt.tags, nil
-}
-
-func findBroker(serviceID string, c *catalog.Catalog, brokerDb *gorm.DB, settings *config.Settings, taskqueue *taskqueue.QueueManager) (base.Broker, response.Response) {
-	var tagManager brokertags.TagManager
-	if settings.Environment == "test" {
-		tagManager = &mockTagGenerator{}
-	} else {
-		var err error
-		tagManager, err = brokertags.NewCFTagManager(
-			"AWS broker",
-			settings.Environment,
-			settings.CfApiUrl,
-			settings.CfApiClientId,
-			settings.CfApiClientSecret,
-		)
-		if err != nil {
-			return nil, response.NewErrorResponse(http.StatusInternalServerError, err.Error())
-		}
-	}
-
-	switch serviceID {
-	// RDS Service
-	case c.RdsService.ID:
-		return rds.InitRDSBroker(brokerDb, settings, tagManager), nil
-	case c.RedisService.ID:
-		return redis.InitRedisBroker(brokerDb, settings, tagManager), nil
-	case c.ElasticsearchService.ID:
-		broker, err := elasticsearch.InitElasticsearchBroker(brokerDb, settings, taskqueue, tagManager)
-		if err != nil {
-			return nil, response.NewErrorResponse(http.StatusInternalServerError, err.Error())
-		}
-		return broker, nil
-	}
-
-	return nil, response.NewErrorResponse(http.StatusNotFound, catalog.ErrNoServiceFound.Error())
-}
-
-func createInstance(req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager) response.Response {
+func createInstance(ctx context.Context, req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager, logger lager.Logger) response.Response {
 	createRequest, err := request.ExtractRequest(req)
 	if err != nil {
 		return err
 	}
-	broker, err := findBroker(createRequest.ServiceID, c, brokerDb, settings, taskqueue)
+	logger = logger.WithData(requestData(createRequest))
+	broker, err := services.FindBroker(createRequest.ServiceID, c, brokerDb, settings, taskqueue, logger)
 	if err != nil {
 		return err
 	}
@@ -81,7 +34,7 @@ func createInstance(req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id
 	}
 
 	// Create instance
-	resp := broker.CreateInstance(c, id, createRequest)
+	resp := broker.CreateInstance(ctx, c, id, createRequest)
 
 	if resp.GetResponseType() != response.ErrorResponseType {
 		instance := base.Instance{Uuid: id, Request: createRequest}
@@ -90,14 +43,16 @@ func createInstance(req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id
 		err := brokerDb.Create(&instance).Error
 
 		if err != nil {
+			logger.Error("save-base-instance", err)
 			return response.NewErrorResponse(http.StatusBadRequest, err.Error())
 		}
 	}
 
+	logger.Info("create-instance-response", responseData(resp))
 	return resp
 }
 
-func modifyInstance(req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager) response.Response {
+func modifyInstance(ctx context.Context, req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager, logger lager.Logger) response.Response {
 	// Extract the request information.
 	modifyRequest, err := request.ExtractRequest(req)
 	if err != nil {
@@ -111,7 +66,8 @@ func modifyInstance(req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id
 	}
 
 	// Retrieve the correct broker.
-	broker, err := findBroker(instance.ServiceID, c, brokerDb, settings, taskqueue)
+	logger = logger.WithData(requestData(modifyRequest))
+	broker, err := services.FindBroker(instance.ServiceID, c, brokerDb, settings, taskqueue, logger)
 	if err != nil {
 		return err
 	}
@@ -123,34 +79,39 @@ func modifyInstance(req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id
 	}
 
 	// Attempt to modify the database instance.
-	resp := broker.ModifyInstance(c, id, modifyRequest, instance)
+	resp := broker.ModifyInstance(ctx, c, id, modifyRequest, instance)
 
 	if resp.GetResponseType() != response.ErrorResponseType {
 		err := brokerDb.Save(&instance).Error
 
 		if err != nil {
+			logger.Error("save-base-instance", err)
 			return response.NewErrorResponse(http.StatusBadRequest, err.Error())
 		}
 	}
 
+	logger.Info("modify-instance-response", responseData(resp))
 	return resp
 }
 
-func lastOperation(req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager) response.Response {
+func lastOperation(ctx context.Context, req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager, logger lager.Logger) response.Response {
 	instance, resp := base.FindBaseInstance(brokerDb, id)
 	if resp != nil {
 		return resp
 	}
-	broker, resp := findBroker(instance.ServiceID, c, brokerDb, settings, taskqueue)
+	logger = logger.WithData(requestData(instance.Request))
+	broker, resp := services.FindBroker(instance.ServiceID, c, brokerDb, settings, taskqueue, logger)
 	if resp != nil {
 		return resp
 	}
 	// pass in the operation parameter from request
 	operation := req.URL.Query().Get("operation")
-	return broker.LastOperation(c, id, instance, operation)
+	resp = broker.LastOperation(ctx, c, id, instance, operation)
+	logger.Debug("last-operation-response", responseData(resp))
+	return resp
 }
 
-func bindInstance(req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager) response.Response {
+func bindInstance(ctx context.Context, req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager, logger lager.Logger) response.Response {
 	// Extract the request information.
 	bindRequest, err := request.ExtractRequest(req)
 	if err != nil {
@@ -161,23 +122,30 @@ func bindInstance(req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id s
 	if resp != nil {
 		return resp
 	}
-	broker, resp := findBroker(instance.ServiceID, c, brokerDb, settings, taskqueue)
+	logger = logger.WithData(requestData(instance.Request))
+	broker, resp := services.FindBroker(instance.ServiceID, c, brokerDb, settings, taskqueue, logger)
 	if resp != nil {
 		return resp
 	}
 
-	return broker.BindInstance(c, id, bindRequest, instance)
+	resp = broker.BindInstance(ctx, c, id, bindRequest, instance)
+	logger.Info("bind-instance-response", responseData(resp))
+	return resp
 }
 
-func deleteInstance(req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager) response.Response {
+func deleteInstance(ctx context.Context, req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id string, settings *config.Settings, taskqueue *taskqueue.QueueManager, logger lager.Logger) response.Response {
 	instance, resp := base.FindBaseInstance(brokerDb, id)
 	if resp != nil {
 		return resp
 	}
-	broker, resp := findBroker(instance.ServiceID, c, brokerDb, settings, taskqueue)
+	logger = logger.WithData(requestData(instance.Request))
+	broker, resp := services.FindBroker(instance.ServiceID, c, brokerDb, settings, taskqueue, logger)
 	if resp != nil {
 		return resp
 	}
+	if settings.DeletionDelayDays > 0 {
+		return scheduleDeletion(ctx, c, brokerDb, id, instance, broker, settings, logger)
+	}
 	if broker.AsyncOperationRequired(c, instance, base.DeleteOp) {
 		// Check if async calls are allowed.
 		asyncAllowed := req.FormValue("accepts_incomplete") == "true"
@@ -185,11 +153,57 @@ func deleteInstance(req *http.Request, c *catalog.Catalog, brokerDb *gorm.DB, id
 			return response.ErrUnprocessableEntityResponse
 		}
 	}
-	resp = broker.DeleteInstance(c, id, instance)
+	resp = broker.DeleteInstance(ctx, c, id, instance)
 	//only delete from DB if it was a sync delete and succeeded
 	if resp.GetResponseType() == response.SuccessDeleteResponseType {
 		brokerDb.Unscoped().Delete(&instance)
 		// TODO check delete error
 	}
+	logger.Info("delete-instance-response", responseData(resp))
 	return resp
 }
+
+// scheduleDeletion stops the instance where its service allows it and records
+// it as pending deletion instead of deleting it. The instance is deleted once
+// the delay has passed, unless an operator restores it first.
+func scheduleDeletion(ctx context.Context, c *catalog.Catalog, brokerDb *gorm.DB, id string, instance base.Instance, broker base.Broker, settings *config.Settings, logger lager.Logger) response.Response {
+	pending, err := base.FindPendingDeletion(brokerDb, id)
+	if err != nil {
+		logger.Error("find-pending-deletion", err)
+		return response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+	if pending != nil {
+		return response.SuccessDeleteResponse
+	}
+
+	if resp := broker.SuspendInstance(ctx, c, id, instance); resp != nil {
+		logger.Info("suspend-instance-response", responseData(resp))
+		return resp
+	}
+	deletion := base.PendingDeletion{
+		InstanceGUID: id,
+		DeleteAt:     time.Now().AddDate(0, 0, int(settings.DeletionDelayDays)),
+	}
+	if err := brokerDb.Create(&deletion).Error; err != nil {
+		logger.Error("create-pending-deletion", err)
+		return response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+	logger.Info("deletion-scheduled", lager.Data{"delete-at": deletion.DeleteAt})
+	return response.SuccessDeleteResponse
+}
+
+// requestData returns the service and plan of a request as log data.
+func requestData(req request.Request) lager.Data {
+	return lager.Data{
+		logging.ServiceKey: req.ServiceID,
+		logging.PlanKey:    req.PlanID,
+	}
+}
+
+// responseData returns the status of a broker response as log data.
+func responseData(resp response.Response) lager.Data {
+	return lager.Data{
+		"status-code":   resp.GetStatusCode(),
+		"response-type": resp.GetResponseType(),
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/catalog"
+	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/helpers/response"
+	"github.com/18F/aws-broker/services/elasticsearch"
+	"github.com/18F/aws-broker/services/rds"
+	"github.com/18F/aws-broker/services/redis"
+	"github.com/18F/aws-broker/taskqueue"
+	brokertags "github.com/cloud-gov/go-broker-tags"
+	"github.com/jinzhu/gorm"
+)
+
+// InstanceModels are the instance records of each service. Unlike the base
+// instances, these track the current state of each instance.
+var InstanceModels = []interface{}{
+	&rds.RDSInstance{},
+	&redis.RedisInstance{},
+	&elasticsearch.ElasticsearchInstance{},
+}
+
+type mockTagGenerator struct {
+	tags map[string]string
+}
+
+func (mt *mockTagGenerator) GenerateTags(
+	action brokertags.Action,
+	serviceName string,
+	servicePlanName string,
+	resourceGUIDs brokertags.ResourceGUIDs,
+	getMissingResources bool,
+) (map[string]string, error) {
+	return mt.tags, nil
+}
+
+// FindBroker returns the broker for the service with the given ID.
+func FindBroker(serviceID string, c *catalog.Catalog, brokerDb *gorm.DB, settings *config.Settings, taskqueue *taskqueue.QueueManager, logger lager.Logger) (base.Broker, response.Response) {
+	var tagManager brokertags.TagManager
+	if settings.Environment == "test" {
+		tagManager = &mockTagGenerator{}
+	} else {
+		var err error
+		tagManager, err = brokertags.NewCFTagManager(
+			"AWS broker",
+			settings.Environment,
+			settings.CfApiUrl,
+			settings.CfApiClientId,
+			settings.CfApiClientSecret,
+		)
+		if err != nil {
+			logger.Error("new-tag-manager", err)
+			return nil, response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	switch serviceID {
+	// RDS Service
+	case c.RdsService.ID:
+		return base.NewTracedBroker("rds", rds.InitRDSBroker(brokerDb, settings, tagManager, logger)), nil
+	case c.RedisService.ID:
+		return base.NewTracedBroker("redis", redis.InitRedisBroker(brokerDb, settings, tagManager, logger)), nil
+	case c.ElasticsearchService.ID:
+		broker, err := elasticsearch.InitElasticsearchBroker(brokerDb, settings, taskqueue, tagManager, logger)
+		if err != nil {
+			logger.Error("init-elasticsearch-broker", err)
+			return nil, response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+		}
+		return base.NewTracedBroker("elasticsearch", broker), nil
+	}
+
+	return nil, response.NewErrorResponse(http.StatusNotFound, catalog.ErrNoServiceFound.Error())
+}
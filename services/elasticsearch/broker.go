@@ -1,16 +1,17 @@
 package elasticsearch
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"os"
 
 	"code.cloudfoundry.org/lager"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/opensearchservice"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/jinzhu/gorm"
 
@@ -19,6 +20,7 @@ import (
 	"github.com/18F/aws-broker/config"
 	"github.com/18F/aws-broker/helpers/request"
 	"github.com/18F/aws-broker/helpers/response"
+	"github.com/18F/aws-broker/helpers/tracing"
 	"github.com/18F/aws-broker/taskqueue"
 
 	brokertags "github.com/cloud-gov/go-broker-tags"
@@ -34,6 +36,7 @@ type ElasticsearchOptions struct {
 	Bucket               string                       `json:"bucket"`
 	AdvancedOptions      ElasticsearchAdvancedOptions `json:"advanced_options,omitempty"`
 	VolumeType           string                       `json:"volume_type"`
+	CloneFrom            string                       `json:"clone_from"`
 }
 
 func (o ElasticsearchOptions) Validate(settings *config.Settings) error {
@@ -57,21 +60,19 @@ func InitElasticsearchBroker(
 	settings *config.Settings,
 	taskqueue *taskqueue.QueueManager,
 	tagManager brokertags.TagManager,
+	logger lager.Logger,
 ) (base.Broker, error) {
-	logger := lager.NewLogger("aws-es-broker")
-	logger.RegisterSink(lager.NewWriterSink(os.Stdout, lager.INFO))
-
 	return &elasticsearchBroker{
 		brokerDB,
 		settings,
 		taskqueue,
-		logger,
+		logger.Session("elasticsearch"),
 		tagManager,
 	}, nil
 }
 
 // initializeAdapter is the main function to create database instances
-func initializeAdapter(plan catalog.ElasticsearchPlan, s *config.Settings, logger lager.Logger) (ElasticsearchAdapter, response.Response) {
+func initializeAdapter(ctx context.Context, plan catalog.ElasticsearchPlan, s *config.Settings, logger lager.Logger) (ElasticsearchAdapter, response.Response) {
 	var elasticsearchAdapter ElasticsearchAdapter
 
 	if s.Environment == "test" {
@@ -79,13 +80,15 @@ func initializeAdapter(plan catalog.ElasticsearchPlan, s *config.Settings, logge
 		return elasticsearchAdapter, nil
 	}
 
+	sess := tracing.InstrumentSession(ctx, session.Must(session.NewSession()))
 	elasticsearchAdapter = &dedicatedElasticsearchAdapter{
 		Plan:       plan,
 		settings:   *s,
 		logger:     logger,
-		opensearch: opensearchservice.New(session.Must(session.NewSession()), aws.NewConfig().WithRegion(s.Region)),
-		iam:        iam.New(session.Must(session.NewSession()), aws.NewConfig().WithRegion(s.Region)),
-		sts:        sts.New(session.Must(session.NewSession()), aws.NewConfig().WithRegion(s.Region)),
+		opensearch: opensearchservice.New(sess, aws.NewConfig().WithRegion(s.Region)),
+		iam:        iam.New(sess, aws.NewConfig().WithRegion(s.Region)),
+		sts:        sts.New(sess, aws.NewConfig().WithRegion(s.Region)),
+		s3:         s3.New(sess, aws.NewConfig().WithRegion(s.Region)),
 	}
 
 	return elasticsearchAdapter, nil
@@ -107,7 +110,7 @@ func (broker *elasticsearchBroker) AsyncOperationRequired(c *catalog.Catalog, i
 	}
 }
 
-func (broker *elasticsearchBroker) CreateInstance(c *catalog.Catalog, id string, createRequest request.Request) response.Response {
+func (broker *elasticsearchBroker) CreateInstance(ctx context.Context, c *catalog.Catalog, id string, createRequest request.Request) response.Response {
 	newInstance := ElasticsearchInstance{}
 
 	options := ElasticsearchOptions{}
@@ -155,6 +158,7 @@ func (broker *elasticsearchBroker) CreateInstance(c *catalog.Catalog, id string,
 		false,
 	)
 	if err != nil {
+		broker.logger.Error("generate-tags", err)
 		return response.NewErrorResponse(http.StatusInternalServerError, "There was an error generating the tags. Error: "+err.Error())
 	}
 
@@ -173,15 +177,23 @@ func (broker *elasticsearchBroker) CreateInstance(c *catalog.Catalog, id string,
 		return response.NewErrorResponse(http.StatusBadRequest, "There was an error initializing the instance. Error: "+err.Error())
 	}
 
-	adapter, adapterErr := initializeAdapter(plan, broker.settings, broker.logger)
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, broker.logger)
 	if adapterErr != nil {
 		return adapterErr
 	}
+
+	if options.CloneFrom != "" {
+		if resp := broker.snapshotCloneSource(adapter, &newInstance, options); resp != nil {
+			return resp
+		}
+	}
+
 	// Create the elasticsearch instance.
 	status, err := adapter.createElasticsearch(&newInstance, newInstance.ClearPassword)
 	if status == base.InstanceNotCreated {
 		desc := "There was an error creating the instance."
 		if err != nil {
+			broker.logger.Error("create-elasticsearch", err)
 			desc = desc + " Error: " + err.Error()
 		}
 		return response.NewErrorResponse(http.StatusBadRequest, desc)
@@ -191,12 +203,14 @@ func (broker *elasticsearchBroker) CreateInstance(c *catalog.Catalog, id string,
 	broker.brokerDB.NewRecord(newInstance)
 	err = broker.brokerDB.Create(&newInstance).Error
 	if err != nil {
+		broker.logger.Error("save-instance", err)
 		return response.NewErrorResponse(http.StatusBadRequest, err.Error())
 	}
+	broker.logger.Info("create-elasticsearch-requested", lager.Data{"domain": newInstance.Domain})
 	return response.NewAsyncOperationResponse(base.CreateOp.String())
 }
 
-func (broker *elasticsearchBroker) ModifyInstance(c *catalog.Catalog, id string, updateRequest request.Request, baseInstance base.Instance) response.Response {
+func (broker *elasticsearchBroker) ModifyInstance(ctx context.Context, c *catalog.Catalog, id string, updateRequest request.Request, baseInstance base.Instance) response.Response {
 	esInstance := ElasticsearchInstance{}
 	options := ElasticsearchOptions{}
 	if len(updateRequest.RawParameters) > 0 {
@@ -214,7 +228,7 @@ func (broker *elasticsearchBroker) ModifyInstance(c *catalog.Catalog, id string,
 	if planErr != nil {
 		return planErr
 	}
-	adapter, adapterErr := initializeAdapter(plan, broker.settings, broker.logger)
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, broker.logger)
 	if adapterErr != nil {
 		return adapterErr
 	}
@@ -229,24 +243,24 @@ func (broker *elasticsearchBroker) ModifyInstance(c *catalog.Catalog, id string,
 	}
 	err := esInstance.update(options)
 	if err != nil {
-		broker.logger.Error("Updating instance failed", err)
+		broker.logger.Error("update-instance", err)
 		return response.NewErrorResponse(http.StatusBadRequest, "Error updating Elasticsearch service instance")
 	}
 	_, err = adapter.modifyElasticsearch(&esInstance)
 	if err != nil {
-		broker.logger.Error("AWS call updating instance failed", err)
+		broker.logger.Error("modify-elasticsearch", err)
 		return response.NewErrorResponse(http.StatusBadRequest, "Error modifying Elasticsearch service instance")
 	}
 	err = broker.brokerDB.Save(&esInstance).Error
 	if err != nil {
-		broker.logger.Error("Saving instance failed", err)
+		broker.logger.Error("save-instance", err)
 		return response.NewErrorResponse(http.StatusBadRequest, "Error saving updated Elasticsearch service instance")
 	}
 
 	return response.NewAsyncOperationResponse(base.ModifyOp.String())
 }
 
-func (broker *elasticsearchBroker) LastOperation(c *catalog.Catalog, id string, baseInstance base.Instance, operation string) response.Response {
+func (broker *elasticsearchBroker) LastOperation(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance, operation string) response.Response {
 	existingInstance := ElasticsearchInstance{}
 
 	var count int64
@@ -260,7 +274,7 @@ func (broker *elasticsearchBroker) LastOperation(c *catalog.Catalog, id string,
 		return planErr
 	}
 
-	adapter, adapterErr := initializeAdapter(plan, broker.settings, broker.logger)
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, broker.logger)
 	if adapterErr != nil {
 		return adapterErr
 	}
@@ -275,10 +289,16 @@ func (broker *elasticsearchBroker) LastOperation(c *catalog.Catalog, id string,
 			jobstate.State = base.InstanceNotGone //indicate a failure
 		}
 		status = jobstate.State
-		broker.logger.Debug(fmt.Sprintf("Deletion Job state: %s\n Message: %s\n", jobstate.State.String(), jobstate.Message))
+		broker.logger.Debug("delete-job-state", lager.Data{
+			"state":   jobstate.State.String(),
+			"message": jobstate.Message,
+		})
 
 	default: //all other ops use synchronous checking of aws api
 		status, _ = adapter.checkElasticsearchStatus(&existingInstance)
+		if status == base.InstanceReady && existingInstance.ClonePending {
+			status = broker.restoreClone(adapter, &existingInstance)
+		}
 		broker.brokerDB.Save(&existingInstance)
 
 	}
@@ -300,11 +320,78 @@ func (broker *elasticsearchBroker) LastOperation(c *catalog.Catalog, id string,
 		state = "in progress"
 	}
 
-	broker.logger.Debug(fmt.Sprintf("LastOperation - Final\n\tstate: %s\n", state))
+	broker.logger.Debug("last-operation-state", lager.Data{"state": state})
 	return response.NewSuccessLastOperation(state, "The service instance status is "+state)
 }
 
-func (broker *elasticsearchBroker) BindInstance(c *catalog.Catalog, id string, bindRequest request.Request, baseInstance base.Instance) response.Response {
+// snapshotCloneSource takes the snapshot of the instance to clone from that the
+// new instance is restored from once it is created.
+func (broker *elasticsearchBroker) snapshotCloneSource(adapter ElasticsearchAdapter, i *ElasticsearchInstance, options ElasticsearchOptions) response.Response {
+	if _, resp := base.FindCloneSource(broker.brokerDB, options.CloneFrom, i.ServiceID, i.OrganizationGUID); resp != nil {
+		return resp
+	}
+	var count int64
+	sourceInstance := ElasticsearchInstance{}
+	broker.brokerDB.Where("uuid = ?", options.CloneFrom).First(&sourceInstance).Count(&count)
+	if count == 0 {
+		return response.NewErrorResponse(http.StatusNotFound, "The instance to clone from does not exist")
+	}
+	sourcePassword, err := sourceInstance.getPassword(broker.settings.EncryptionKey)
+	if err != nil {
+		broker.logger.Error("get-password", err)
+		return response.NewErrorResponse(http.StatusInternalServerError, "Unable to get the password of the instance to clone from.")
+	}
+
+	i.ClonedFrom = sourceInstance.Uuid
+	i.CloneSnapshotName = "clone-" + i.Uuid
+	i.ClonePending = true
+	// A snapshot can only be restored to the same or a later version.
+	if options.ElasticsearchVersion == "" {
+		i.ElasticsearchVersion = sourceInstance.ElasticsearchVersion
+	}
+
+	err = adapter.snapshotElasticsearch(&sourceInstance, sourcePassword, i.CloneSnapshotName)
+	// Taking the snapshot may set up the snapshot repository of the source.
+	broker.brokerDB.Save(&sourceInstance)
+	if err != nil {
+		broker.logger.Error("snapshot-elasticsearch", err)
+		return response.NewErrorResponse(http.StatusBadRequest, "There was an error taking a snapshot of the instance to clone from. Error: "+err.Error())
+	}
+	return nil
+}
+
+// restoreClone restores the snapshot of the instance to clone from into the
+// instance.
+func (broker *elasticsearchBroker) restoreClone(adapter ElasticsearchAdapter, i *ElasticsearchInstance) base.InstanceState {
+	var count int64
+	sourceInstance := ElasticsearchInstance{}
+	broker.brokerDB.Where("uuid = ?", i.ClonedFrom).First(&sourceInstance).Count(&count)
+	if count == 0 {
+		broker.logger.Info("clone-source-not-found", lager.Data{"source": i.ClonedFrom})
+		return base.InstanceNotCreated
+	}
+	password, err := i.getPassword(broker.settings.EncryptionKey)
+	if err != nil {
+		broker.logger.Error("get-password", err)
+		return base.InstanceNotCreated
+	}
+	sourcePassword, err := sourceInstance.getPassword(broker.settings.EncryptionKey)
+	if err != nil {
+		broker.logger.Error("get-password", err)
+		return base.InstanceNotCreated
+	}
+
+	status, err := adapter.restoreElasticsearch(i, password, &sourceInstance, sourcePassword)
+	if err != nil {
+		broker.logger.Error("restore-elasticsearch", err)
+	}
+	if status == base.InstanceReady {
+		i.ClonePending = false
+	}
+	return status
+}
+
+func (broker *elasticsearchBroker) BindInstance(ctx context.Context, c *catalog.Catalog, id string, bindRequest request.Request, baseInstance base.Instance) response.Response {
 	existingInstance := ElasticsearchInstance{}
 
 	options := ElasticsearchOptions{}
@@ -336,7 +423,7 @@ func (broker *elasticsearchBroker) BindInstance(c *catalog.Catalog, id string, b
 	}
 
 	// Get the correct database logic depending on the type of plan
-	adapter, adapterErr := initializeAdapter(plan, broker.settings, broker.logger)
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, broker.logger)
 	if adapterErr != nil {
 		return adapterErr
 	}
@@ -347,6 +434,7 @@ func (broker *elasticsearchBroker) BindInstance(c *catalog.Catalog, id string, b
 	if credentials, err = adapter.bindElasticsearchToApp(&existingInstance, password); err != nil {
 		desc := "There was an error binding the database instance to the application."
 		if err != nil {
+			broker.logger.Error("bind-elasticsearch-to-app", err)
 			desc = desc + " Error: " + err.Error()
 		}
 		return response.NewErrorResponse(http.StatusBadRequest, desc)
@@ -356,7 +444,7 @@ func (broker *elasticsearchBroker) BindInstance(c *catalog.Catalog, id string, b
 	return response.NewSuccessBindResponse(credentials)
 }
 
-func (broker *elasticsearchBroker) DeleteInstance(c *catalog.Catalog, id string, baseInstance base.Instance) response.Response {
+func (broker *elasticsearchBroker) DeleteInstance(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance) response.Response {
 	existingInstance := ElasticsearchInstance{}
 	var count int64
 	broker.brokerDB.Where("uuid = ?", id).First(&existingInstance).Count(&count)
@@ -373,7 +461,7 @@ func (broker *elasticsearchBroker) DeleteInstance(c *catalog.Catalog, id string,
 		return response.NewErrorResponse(http.StatusInternalServerError, "Unable to get instance password.")
 	}
 
-	adapter, adapterErr := initializeAdapter(plan, broker.settings, broker.logger)
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, broker.logger)
 	if adapterErr != nil {
 		return adapterErr
 	}
@@ -392,6 +480,7 @@ func (broker *elasticsearchBroker) DeleteInstance(c *catalog.Catalog, id string,
 	default:
 		desc := "There was an error deleting the instance."
 		if err != nil {
+			broker.logger.Error("delete-elasticsearch", err)
 			desc = desc + " Error: " + err.Error()
 		}
 		broker.brokerDB.Save(&existingInstance)
@@ -399,3 +488,99 @@ func (broker *elasticsearchBroker) DeleteInstance(c *catalog.Catalog, id string,
 	}
 
 }
+
+func (broker *elasticsearchBroker) DescribeInstance(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance) (base.InstanceDetail, response.Response) {
+	existingInstance := ElasticsearchInstance{}
+	var count int64
+	broker.brokerDB.Where("uuid = ?", id).First(&existingInstance).Count(&count)
+	if count == 0 {
+		return base.InstanceDetail{}, response.NewErrorResponse(http.StatusNotFound, "Instance not found")
+	}
+
+	plan, planErr := c.ElasticsearchService.FetchPlan(baseInstance.PlanID)
+	if planErr != nil {
+		return base.InstanceDetail{}, planErr
+	}
+
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, broker.logger)
+	if adapterErr != nil {
+		return base.InstanceDetail{}, adapterErr
+	}
+
+	detail := base.InstanceDetail{}
+	if domainStatus, err := adapter.describeElasticsearch(&existingInstance); err != nil {
+		detail.AWSError = err.Error()
+		if awsErr, ok := err.(awserr.Error); ok {
+			detail.ResourceGone = awsErr.Code() == opensearchservice.ErrCodeResourceNotFoundException
+		}
+	} else {
+		detail.AWS = domainStatus
+	}
+
+	existingInstance.Password = ""
+	existingInstance.Salt = ""
+	existingInstance.SecretKey = ""
+	detail.Instance = existingInstance
+	return detail, nil
+}
+
+func (broker *elasticsearchBroker) SetInstanceState(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance, state base.InstanceState) response.Response {
+	existingInstance := ElasticsearchInstance{}
+	var count int64
+	broker.brokerDB.Where("uuid = ?", id).First(&existingInstance).Count(&count)
+	if count == 0 {
+		return response.NewErrorResponse(http.StatusNotFound, "Instance not found")
+	}
+
+	err := broker.brokerDB.Model(&existingInstance).Update("state", state).Error
+	if err != nil {
+		broker.logger.Error("save-instance", err)
+		return response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+	return nil
+}
+
+// InstanceCredentials returns the credentials of the instance as recorded by
+// the broker, without contacting AWS or changing the instance.
+func (broker *elasticsearchBroker) InstanceCredentials(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance) (map[string]string, response.Response) {
+	existingInstance := ElasticsearchInstance{}
+	var count int64
+	broker.brokerDB.Where("uuid = ?", id).First(&existingInstance).Count(&count)
+	if count == 0 {
+		return nil, response.NewErrorResponse(http.StatusNotFound, "Instance not found")
+	}
+
+	password, err := existingInstance.getPassword(broker.settings.EncryptionKey)
+	if err != nil {
+		broker.logger.Error("get-password", err)
+		return nil, response.NewErrorResponse(http.StatusInternalServerError, "Unable to get instance password.")
+	}
+
+	credentials, err := existingInstance.getCredentials(password)
+	if err != nil {
+		broker.logger.Error("get-credentials", err)
+		return nil, response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+	return credentials, nil
+}
+
+// CreateSnapshot is not supported for Elasticsearch instances, whose
+// snapshots are taken in the broker snapshot repository.
+func (broker *elasticsearchBroker) CreateSnapshot(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance, name string) (base.Snapshot, response.Response) {
+	return base.Snapshot{}, response.NewErrorResponse(http.StatusBadRequest, "Manual snapshots are not supported for Elasticsearch instances.")
+}
+
+// ListSnapshots is not supported for Elasticsearch instances.
+func (broker *elasticsearchBroker) ListSnapshots(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance) ([]base.Snapshot, response.Response) {
+	return nil, response.NewErrorResponse(http.StatusBadRequest, "Manual snapshots are not supported for Elasticsearch instances.")
+}
+
+// SuspendInstance leaves the domain running, since OpenSearch domains cannot
+// be stopped.
+func (broker *elasticsearchBroker) SuspendInstance(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance) response.Response {
+	return nil
+}
+
+func (broker *elasticsearchBroker) ResumeInstance(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance) response.Response {
+	return nil
+}
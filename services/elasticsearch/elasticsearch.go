@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
-	"log"
 	"time"
 
 	"code.cloudfoundry.org/lager"
@@ -14,17 +13,18 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/awsutil"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/aws/aws-sdk-go/service/opensearchservice"
 	"github.com/aws/aws-sdk-go/service/opensearchservice/opensearchserviceiface"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/aws/aws-sdk-go/service/sts/stsiface"
 
 	"github.com/18F/aws-broker/catalog"
 	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/helpers/logging"
 
 	"fmt"
 )
@@ -35,6 +35,9 @@ type ElasticsearchAdapter interface {
 	checkElasticsearchStatus(i *ElasticsearchInstance) (base.InstanceState, error)
 	bindElasticsearchToApp(i *ElasticsearchInstance, password string) (map[string]string, error)
 	deleteElasticsearch(i *ElasticsearchInstance, passoword string, queue *taskqueue.QueueManager) (base.InstanceState, error)
+	describeElasticsearch(i *ElasticsearchInstance) (*opensearchservice.DomainStatus, error)
+	snapshotElasticsearch(i *ElasticsearchInstance, password string, snapshotName string) error
+	restoreElasticsearch(i *ElasticsearchInstance, password string, source *ElasticsearchInstance, sourcePassword string) (base.InstanceState, error)
 }
 
 type mockElasticsearchAdapter struct {
@@ -65,6 +68,19 @@ func (d *mockElasticsearchAdapter) deleteElasticsearch(i *ElasticsearchInstance,
 	return base.InstanceGone, nil
 }
 
+func (d *mockElasticsearchAdapter) describeElasticsearch(i *ElasticsearchInstance) (*opensearchservice.DomainStatus, error) {
+	// TODO
+	return &opensearchservice.DomainStatus{DomainName: aws.String(i.Domain)}, nil
+}
+
+func (d *mockElasticsearchAdapter) snapshotElasticsearch(i *ElasticsearchInstance, password string, snapshotName string) error {
+	return nil
+}
+
+func (d *mockElasticsearchAdapter) restoreElasticsearch(i *ElasticsearchInstance, password string, source *ElasticsearchInstance, sourcePassword string) (base.InstanceState, error) {
+	return base.InstanceReady, nil
+}
+
 type dedicatedElasticsearchAdapter struct {
 	Plan       catalog.ElasticsearchPlan
 	settings   config.Settings
@@ -72,6 +88,7 @@ type dedicatedElasticsearchAdapter struct {
 	iam        iamiface.IAMAPI
 	sts        stsiface.STSAPI
 	opensearch opensearchserviceiface.OpenSearchServiceAPI
+	s3         s3iface.S3API
 }
 
 // This is the prefix for all pgroups created by the broker.
@@ -79,13 +96,13 @@ const PgroupPrefix = "cg-elasticsearch-broker-"
 
 func (d *dedicatedElasticsearchAdapter) createElasticsearch(i *ElasticsearchInstance, password string) (base.InstanceState, error) {
 	user := awsiam.NewIAMUserClient(d.iam, d.logger)
-	ip := awsiam.NewIAMPolicyClient(d.settings.Region, d.logger)
+	ip := awsiam.NewIAMPolicyClient(d.iam, d.logger)
 
 	// IAM User and policy before domain starts creating so it can be used to create access control policy
 	iamTags := awsiam.ConvertTagsMapToIAMTags(i.Tags)
 	_, err := user.Create(i.Domain, "", iamTags)
 	if err != nil {
-		fmt.Println(err.Error())
+		d.logger.Error("create-iam-user", err)
 		return base.InstanceNotCreated, err
 	}
 	accessKeyID, secretAccessKey, err := user.CreateAccessKey(i.Domain)
@@ -103,16 +120,7 @@ func (d *dedicatedElasticsearchAdapter) createElasticsearch(i *ElasticsearchInst
 	stsInput := &sts.GetCallerIdentityInput{}
 	result, err := d.sts.GetCallerIdentity(stsInput)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			default:
-				fmt.Println(aerr.Error())
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and
-			// Message from an error.
-			fmt.Println(err.Error())
-		}
+		logging.LogAWSError(d.logger, "get-caller-identity", err)
 		return base.InstanceNotCreated, nil
 	}
 
@@ -131,7 +139,7 @@ func (d *dedicatedElasticsearchAdapter) createElasticsearch(i *ElasticsearchInst
 		// we wait for 5 seconds to retry the domain creation to hopefully allow IAM to become consistent.
 		//
 		// see https://docs.aws.amazon.com/IAM/latest/UserGuide/troubleshoot_general.html#troubleshoot_general_eventual-consistency
-		log.Println("Retrying domain creation because of possible IAM eventual consistency issue")
+		d.logger.Info("retry-create-domain", lager.Data{"reason": "possible IAM eventual consistency issue"})
 		time.Sleep(5 * time.Second)
 		resp, err = d.opensearch.CreateDomain(params)
 	}
@@ -184,24 +192,16 @@ func (d *dedicatedElasticsearchAdapter) bindElasticsearchToApp(i *ElasticsearchI
 
 		resp, err := d.opensearch.DescribeDomain(params)
 		if err != nil {
-			if awsErr, ok := err.(awserr.Error); ok {
-				// Generic AWS error with Code, Message, and original error (if any)
-				fmt.Println(awsErr.Code(), awsErr.Message(), awsErr.OrigErr())
-				if reqErr, ok := err.(awserr.RequestFailure); ok {
-					// A service error occurred
-					fmt.Println(reqErr.Code(), reqErr.Message(), reqErr.StatusCode(), reqErr.RequestID())
-				}
-			} else {
-				// This case should never be hit, the SDK should always return an
-				// error which satisfies the awserr.Error interface.
-				fmt.Println(err.Error())
-			}
+			logging.LogAWSError(d.logger, "describe-domain", err)
 			return nil, err
 		}
 
 		if resp.DomainStatus.Created != nil && *(resp.DomainStatus.Created) {
 			if resp.DomainStatus.Endpoints != nil && resp.DomainStatus.ARN != nil {
-				fmt.Printf("endpoint: %s ARN: %s \n", *(resp.DomainStatus.Endpoints["vpc"]), *(resp.DomainStatus.ARN))
+				d.logger.Debug("domain-endpoint", lager.Data{
+					"endpoint": *(resp.DomainStatus.Endpoints["vpc"]),
+					"arn":      *(resp.DomainStatus.ARN),
+				})
 				i.Host = *(resp.DomainStatus.Endpoints["vpc"])
 				i.ARN = *(resp.DomainStatus.ARN)
 				i.State = base.InstanceReady
@@ -253,13 +253,8 @@ func (d *dedicatedElasticsearchAdapter) deleteElasticsearch(i *ElasticsearchInst
 	}
 	_, err := d.opensearch.DescribeDomain(params)
 	if err != nil {
+		logging.LogAWSError(d.logger, "describe-domain", err)
 		if awsErr, ok := err.(awserr.Error); ok {
-			// Generic AWS error with Code, Message, and original error (if any)
-			fmt.Println(awsErr.Code(), awsErr.Message(), awsErr.OrigErr())
-			if reqErr, ok := err.(awserr.RequestFailure); ok {
-				// A service error occurred
-				fmt.Println(reqErr.Code(), reqErr.Message(), reqErr.StatusCode(), reqErr.RequestID())
-			}
 			// Instance no longer exists, force a removal from brokerdb
 			if awsErr.Code() == opensearchservice.ErrCodeResourceNotFoundException {
 				return base.InstanceGone, err
@@ -286,18 +281,7 @@ func (d *dedicatedElasticsearchAdapter) checkElasticsearchStatus(i *Elasticsearc
 
 		resp, err := d.opensearch.DescribeDomain(params)
 		if err != nil {
-			if awsErr, ok := err.(awserr.Error); ok {
-				// Generic AWS error with Code, Message, and original error (if any)
-				fmt.Println(awsErr.Code(), awsErr.Message(), awsErr.OrigErr())
-				if reqErr, ok := err.(awserr.RequestFailure); ok {
-					// A service error occurred
-					fmt.Println(reqErr.Code(), reqErr.Message(), reqErr.StatusCode(), reqErr.RequestID())
-				}
-			} else {
-				// This case should never be hit, the SDK should always return an
-				// error which satisfies the awserr.Error interface.
-				fmt.Println(err.Error())
-			}
+			logging.LogAWSError(d.logger, "describe-domain", err)
 			return base.InstanceNotCreated, err
 		}
 
@@ -319,21 +303,21 @@ func (d *dedicatedElasticsearchAdapter) checkElasticsearchStatus(i *Elasticsearc
 	return base.InstanceNotCreated, nil
 }
 
+func (d *dedicatedElasticsearchAdapter) describeElasticsearch(i *ElasticsearchInstance) (*opensearchservice.DomainStatus, error) {
+	resp, err := d.opensearch.DescribeDomain(&opensearchservice.DescribeDomainInput{
+		DomainName: aws.String(i.Domain),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "describe-domain", err)
+		return nil, err
+	}
+	return resp.DomainStatus, nil
+}
+
 func (d *dedicatedElasticsearchAdapter) didAwsCallSucceed(err error) bool {
 	// TODO Eventually return a formatted error object.
 	if err != nil {
-		if awsErr, ok := err.(awserr.Error); ok {
-			// Generic AWS Error with Code, Message, and original error (if any)
-			fmt.Println(awsErr.Code(), awsErr.Message(), awsErr.OrigErr())
-			if reqErr, ok := err.(awserr.RequestFailure); ok {
-				// A service error occurred
-				fmt.Println(reqErr.Code(), reqErr.Message(), reqErr.StatusCode(), reqErr.RequestID())
-			}
-		} else {
-			// This case should never be hit, The SDK should alwsy return an
-			// error which satisfies the awserr.Error interface.
-			fmt.Println(err.Error())
-		}
+		logging.LogAWSError(d.logger, "aws-call-failed", err)
 		return false
 	}
 	return true
@@ -347,7 +331,7 @@ func (d *dedicatedElasticsearchAdapter) createUpdateBucketRolesAndPolicies(
 	path string,
 	iamTags []*iam.Tag,
 ) error {
-	ip := awsiam.NewIAMPolicyClient(d.settings.Region, d.logger)
+	ip := awsiam.NewIAMPolicyClient(d.iam, d.logger)
 	var snapshotRole *iam.Role
 
 	// create snapshotrole if not done yet
@@ -447,7 +431,7 @@ func (d *dedicatedElasticsearchAdapter) asyncDeleteElasticSearchDomain(i *Elasti
 	err := d.takeLastSnapshot(i, password)
 	if err != nil {
 		desc := fmt.Sprintf("asyncDelete - \n\t takeLastSnapshot returned error: %v\n", err)
-		fmt.Println(desc)
+		d.logger.Error("async-delete-take-last-snapshot", err, lager.Data{logging.InstanceGUIDKey: i.Uuid})
 		msg.JobState.State = base.InstanceNotGone
 		msg.JobState.Message = desc
 		jobstate <- msg
@@ -457,7 +441,7 @@ func (d *dedicatedElasticsearchAdapter) asyncDeleteElasticSearchDomain(i *Elasti
 	err = d.writeManifestToS3(i, password)
 	if err != nil {
 		desc := fmt.Sprintf("asyncDelete - \n\t writeManifestToS3 returned error: %v\n", err)
-		fmt.Println(desc)
+		d.logger.Error("async-delete-write-manifest-to-s3", err, lager.Data{logging.InstanceGUIDKey: i.Uuid})
 		msg.JobState.State = base.InstanceNotGone
 		msg.JobState.Message = desc
 		jobstate <- msg
@@ -467,7 +451,7 @@ func (d *dedicatedElasticsearchAdapter) asyncDeleteElasticSearchDomain(i *Elasti
 	err = d.cleanupRolesAndPolicies(i)
 	if err != nil {
 		desc := fmt.Sprintf("asyncDelete - \n\t cleanupRolesAndPolicies returned error: %v\n", err)
-		fmt.Println(desc)
+		d.logger.Error("async-delete-cleanup-roles-and-policies", err, lager.Data{logging.InstanceGUIDKey: i.Uuid})
 		msg.JobState.State = base.InstanceNotGone
 		msg.JobState.Message = desc
 		jobstate <- msg
@@ -477,7 +461,7 @@ func (d *dedicatedElasticsearchAdapter) asyncDeleteElasticSearchDomain(i *Elasti
 	err = d.cleanupElasticSearchDomain(i)
 	if err != nil {
 		desc := fmt.Sprintf("asyncDelete - \n\t cleanupElasticSearchDomain returned error: %v\n", err)
-		fmt.Println(desc)
+		d.logger.Error("async-delete-cleanup-elastic-search-domain", err, lager.Data{logging.InstanceGUIDKey: i.Uuid})
 		msg.JobState.State = base.InstanceNotGone
 		msg.JobState.Message = desc
 		jobstate <- msg
@@ -489,11 +473,9 @@ func (d *dedicatedElasticsearchAdapter) asyncDeleteElasticSearchDomain(i *Elasti
 	jobstate <- msg
 }
 
-// in which we make the ES API call to take a snapshot
-// then poll for snapshot completetion, may block for a considerable time
-func (d *dedicatedElasticsearchAdapter) takeLastSnapshot(i *ElasticsearchInstance, password string) error {
-
-	var sleep = 10 * time.Second
+// brokerSnapshotsApi returns a client for the ES API of the instance, with the
+// broker snapshot repository of the instance registered.
+func (d *dedicatedElasticsearchAdapter) brokerSnapshotsApi(i *ElasticsearchInstance, password string) (*EsApiHandler, error) {
 	var creds map[string]string
 	var err error
 
@@ -501,14 +483,14 @@ func (d *dedicatedElasticsearchAdapter) takeLastSnapshot(i *ElasticsearchInstanc
 	if i.Host == "" {
 		creds, err = d.bindElasticsearchToApp(i, password)
 		if err != nil {
-			fmt.Println(err)
-			return err
+			d.logger.Error("bind-for-snapshot", err)
+			return nil, err
 		}
 	} else {
 		creds, err = i.getCredentials(password)
 		if err != nil {
-			fmt.Println(err)
-			return err
+			d.logger.Error("get-credentials", err)
+			return nil, err
 		}
 	}
 
@@ -521,7 +503,7 @@ func (d *dedicatedElasticsearchAdapter) takeLastSnapshot(i *ElasticsearchInstanc
 		err := d.createUpdateBucketRolesAndPolicies(i, d.settings.SnapshotsBucketName, i.SnapshotPath, iamTags)
 		if err != nil {
 			d.logger.Error("bindElasticsearchToApp - Error in createUpdateRolesAndPolicies", err)
-			return err
+			return nil, err
 		}
 		i.BrokerSnapshotsEnabled = true
 	}
@@ -540,6 +522,20 @@ func (d *dedicatedElasticsearchAdapter) takeLastSnapshot(i *ElasticsearchInstanc
 	)
 	if err != nil {
 		d.logger.Error("createsnapshotrepo returns error", err)
+		return nil, err
+	}
+
+	return esApi, nil
+}
+
+// in which we make the ES API call to take a snapshot
+// then poll for snapshot completetion, may block for a considerable time
+func (d *dedicatedElasticsearchAdapter) takeLastSnapshot(i *ElasticsearchInstance, password string) error {
+
+	var sleep = 10 * time.Second
+
+	esApi, err := d.brokerSnapshotsApi(i, password)
+	if err != nil {
 		return err
 	}
 
@@ -565,38 +561,224 @@ func (d *dedicatedElasticsearchAdapter) takeLastSnapshot(i *ElasticsearchInstanc
 	return nil
 }
 
+// snapshotElasticsearch starts a snapshot of the instance in its broker
+// snapshot repository without waiting for it to complete.
+func (d *dedicatedElasticsearchAdapter) snapshotElasticsearch(i *ElasticsearchInstance, password string, snapshotName string) error {
+	esApi, err := d.brokerSnapshotsApi(i, password)
+	if err != nil {
+		return err
+	}
+
+	_, err = esApi.CreateSnapshot(d.settings.SnapshotsRepoName, snapshotName)
+	if err != nil {
+		d.logger.Error("create-snapshot", err)
+		return err
+	}
+	return nil
+}
+
+// restoreElasticsearch restores the clone snapshot of the source instance into
+// the instance once the snapshot is complete. The snapshots of the source are
+// registered as a read-only repository, which the snapshot role of the
+// instance can only read. The instance is ready once no shards are being
+// recovered anymore, at which point that access is revoked and the clone
+// snapshot is deleted from the snapshots of the source.
+func (d *dedicatedElasticsearchAdapter) restoreElasticsearch(
+	i *ElasticsearchInstance,
+	password string,
+	source *ElasticsearchInstance,
+	sourcePassword string,
+) (base.InstanceState, error) {
+	sourceCreds, err := source.getCredentials(sourcePassword)
+	if err != nil {
+		d.logger.Error("get-credentials", err)
+		return base.InstanceNotCreated, err
+	}
+	sourceApi := &EsApiHandler{}
+	sourceApi.Init(sourceCreds, d.settings.Region)
+
+	esApi, err := d.brokerSnapshotsApi(i, password)
+	if err != nil {
+		return base.InstanceNotCreated, err
+	}
+
+	if i.CloneRestoreStarted {
+		shards, err := esApi.GetActiveRecoveries()
+		if err != nil {
+			d.logger.Error("get-active-recoveries", err)
+			return base.InstanceInProgress, err
+		}
+		d.logger.Info("elasticsearch-clone-restore-status", lager.Data{
+			"snapshot":          i.CloneSnapshotName,
+			"recovering-shards": shards,
+		})
+		if shards > 0 {
+			return base.InstanceInProgress, nil
+		}
+
+		_, err = esApi.DeleteSnapshotRepo(d.settings.SnapshotsRepoName + "-clone")
+		if err != nil {
+			d.logger.Error("delete-snapshot-repo", err)
+			return base.InstanceInProgress, err
+		}
+		if err := d.revokeCloneReadPolicy(i); err != nil {
+			return base.InstanceInProgress, err
+		}
+		_, err = sourceApi.DeleteSnapshot(d.settings.SnapshotsRepoName, i.CloneSnapshotName)
+		if err != nil {
+			d.logger.Error("delete-snapshot", err)
+			return base.InstanceInProgress, err
+		}
+		return base.InstanceReady, nil
+	}
+
+	status, err := sourceApi.GetSnapshotStatus(d.settings.SnapshotsRepoName, i.CloneSnapshotName)
+	if err != nil {
+		d.logger.Error("get-snapshot-status", err)
+		return base.InstanceNotCreated, err
+	}
+	d.logger.Info("elasticsearch-clone-snapshot-status", lager.Data{
+		"snapshot": i.CloneSnapshotName,
+		"status":   status,
+	})
+	switch status {
+	case "SUCCESS":
+	case "IN_PROGRESS", "STARTED":
+		return base.InstanceInProgress, nil
+	default:
+		return base.InstanceNotCreated, fmt.Errorf("snapshot %s of instance %s is %s", i.CloneSnapshotName, source.Uuid, status)
+	}
+
+	// The snapshot role of the instance needs to read the snapshots of the source.
+	if err := d.grantCloneReadPolicy(i, source); err != nil {
+		return base.InstanceNotCreated, err
+	}
+
+	cloneRepoName := d.settings.SnapshotsRepoName + "-clone"
+	_, err = esApi.CreateReadonlySnapshotRepo(
+		cloneRepoName,
+		d.settings.SnapshotsBucketName,
+		source.SnapshotPath,
+		d.settings.Region,
+		i.SnapshotARN,
+	)
+	if err != nil {
+		d.logger.Error("create-snapshot-repo", err)
+		return base.InstanceNotCreated, err
+	}
+
+	_, err = esApi.RestoreSnapshot(cloneRepoName, i.CloneSnapshotName)
+	if err != nil {
+		d.logger.Error("restore-snapshot", err)
+		return base.InstanceNotCreated, err
+	}
+	i.CloneRestoreStarted = true
+	return base.InstanceInProgress, nil
+}
+
+// snapshotRoleName is the name of the role the domain of the instance assumes
+// to access its snapshots in S3.
+func snapshotRoleName(i *ElasticsearchInstance) string {
+	return i.Domain + "-to-s3-SnapshotRole"
+}
+
+// grantCloneReadPolicy allows the snapshot role of the instance to list the
+// snapshots bucket and read the snapshots of the source, but not to change
+// them.
+func (d *dedicatedElasticsearchAdapter) grantCloneReadPolicy(i *ElasticsearchInstance, source *ElasticsearchInstance) error {
+	if i.CloneReadPolicyARN != "" {
+		return nil
+	}
+	bucketArn := "arn:aws-us-gov:s3:::" + d.settings.SnapshotsBucketName
+	policyDoc := awsiam.PolicyDocument{
+		Version: "2012-10-17",
+		Statement: []awsiam.PolicyStatementEntry{
+			{
+				Action:   []string{"s3:ListBucket"},
+				Effect:   "Allow",
+				Resource: []string{bucketArn},
+			},
+			{
+				Action:   []string{"s3:GetObject"},
+				Effect:   "Allow",
+				Resource: []string{bucketArn + source.SnapshotPath + "/*"},
+			},
+		},
+	}
+	policy, err := policyDoc.ToString()
+	if err != nil {
+		return err
+	}
+	ip := awsiam.NewIAMPolicyClient(d.iam, d.logger)
+	role := iam.Role{RoleName: aws.String(snapshotRoleName(i))}
+	policyARN, err := ip.CreatePolicyAttachRole(i.Domain+"-clone-S3-ReadPolicy", policy, role, awsiam.ConvertTagsMapToIAMTags(i.Tags))
+	if err != nil {
+		d.logger.Error("create-clone-read-policy", err)
+		return err
+	}
+	i.CloneReadPolicyARN = policyARN
+	return nil
+}
+
+// revokeCloneReadPolicy removes the access of the snapshot role of the
+// instance to the snapshots of its source.
+func (d *dedicatedElasticsearchAdapter) revokeCloneReadPolicy(i *ElasticsearchInstance) error {
+	if i.CloneReadPolicyARN == "" {
+		return nil
+	}
+	_, err := d.iam.DetachRolePolicy(&iam.DetachRolePolicyInput{
+		PolicyArn: aws.String(i.CloneReadPolicyARN),
+		RoleName:  aws.String(snapshotRoleName(i)),
+	})
+	if awsErr, ok := err.(awserr.Error); err != nil && !(ok && awsErr.Code() == iam.ErrCodeNoSuchEntityException) {
+		d.logger.Error("detach-clone-read-policy", err)
+		return err
+	}
+	ip := awsiam.NewIAMPolicyClient(d.iam, d.logger)
+	if err := ip.DeletePolicy(i.CloneReadPolicyARN); err != nil {
+		d.logger.Error("delete-clone-read-policy", err)
+		return err
+	}
+	i.CloneReadPolicyARN = ""
+	return nil
+}
+
 // in which we clean up all the roles and policies for the ES domain
 func (d *dedicatedElasticsearchAdapter) cleanupRolesAndPolicies(i *ElasticsearchInstance) error {
 	user := awsiam.NewIAMUserClient(d.iam, d.logger)
-	policyHandler := awsiam.NewIAMPolicyClient(d.settings.Region, d.logger)
+	policyHandler := awsiam.NewIAMPolicyClient(d.iam, d.logger)
 
 	if err := user.DetachUserPolicy(i.Domain, i.IamPolicyARN); err != nil {
-		fmt.Println(err.Error())
+		d.logger.Error("detach-user-policy", err)
 		return err
 	}
 
 	if err := user.DeleteAccessKey(i.Domain, i.AccessKey); err != nil {
-		fmt.Println(err.Error())
+		d.logger.Error("delete-access-key", err)
 		return err
 	}
 
 	if err := user.DetachUserPolicy(i.Domain, i.IamPassRolePolicyARN); err != nil {
-		fmt.Println(err.Error())
+		d.logger.Error("detach-pass-role-policy", err)
+		return err
+	}
+
+	if err := d.revokeCloneReadPolicy(i); err != nil {
 		return err
 	}
 
 	roleDetachPolicyInput := &iam.DetachRolePolicyInput{
 		PolicyArn: aws.String(i.SnapshotPolicyARN),
-		RoleName:  aws.String(i.Domain + "-to-s3-SnapshotRole"),
+		RoleName:  aws.String(snapshotRoleName(i)),
 	}
 
 	if _, err := d.iam.DetachRolePolicy(roleDetachPolicyInput); err != nil {
-		fmt.Println(err.Error())
+		d.logger.Error("detach-role-policy", err)
 		return err
 	}
 
 	if err := policyHandler.DeletePolicy(i.SnapshotPolicyARN); err != nil {
-		fmt.Println(err.Error())
+		d.logger.Error("delete-snapshot-policy", err)
 		return err
 	}
 
@@ -605,22 +787,22 @@ func (d *dedicatedElasticsearchAdapter) cleanupRolesAndPolicies(i *Elasticsearch
 	}
 
 	if _, err := d.iam.DeleteRole(rolePolicyDeleteInput); err != nil {
-		fmt.Println(err.Error())
+		d.logger.Error("delete-role", err)
 		return err
 	}
 
 	if err := policyHandler.DeletePolicy(i.IamPassRolePolicyARN); err != nil {
-		fmt.Println(err.Error())
+		d.logger.Error("delete-pass-role-policy", err)
 		return err
 	}
 
 	if err := user.Delete(i.Domain); err != nil {
-		fmt.Println(err.Error())
+		d.logger.Error("delete-iam-user", err)
 		return err
 	}
 
 	if err := policyHandler.DeletePolicy(i.IamPolicyARN); err != nil {
-		fmt.Println(err.Error())
+		d.logger.Error("delete-iam-policy", err)
 		return err
 	}
 	return nil
@@ -633,8 +815,7 @@ func (d *dedicatedElasticsearchAdapter) cleanupElasticSearchDomain(i *Elasticsea
 	}
 	resp, err := d.opensearch.DeleteDomain(params)
 
-	// Pretty-print the response data.
-	d.logger.Info(fmt.Sprintf("aws.DeleteElasticSearchDomain: \n\t%s\n", awsutil.StringValue(resp)))
+	d.logger.Debug("delete-domain", lager.Data{"response": awsutil.StringValue(resp)})
 
 	// Decide if AWS service call was successful
 	if success := d.didAwsCallSucceed(err); !success {
@@ -653,17 +834,11 @@ func (d *dedicatedElasticsearchAdapter) cleanupElasticSearchDomain(i *Elasticsea
 			if awsErr, ok := err.(awserr.Error); ok {
 				// Instance no longer exists, this is success
 				if awsErr.Code() == opensearchservice.ErrCodeResourceNotFoundException {
-					d.logger.Info(fmt.Sprintf("%s domain has been deleted", i.Domain))
+					d.logger.Info("domain-deleted", lager.Data{"domain": i.Domain})
 					return nil
 				}
-				// Generic AWS error with Code, Message, and original error (if any)
-				d.logger.Error("CleanUpESDomain - svc.DescribeElasticSearchDomain Failed", awsErr)
-
-				if reqErr, ok := err.(awserr.RequestFailure); ok {
-					// A service error occurred
-					fmt.Println(reqErr.Code(), reqErr.Message(), reqErr.StatusCode(), reqErr.RequestID())
-				}
 			}
+			logging.LogAWSError(d.logger, "describe-domain", err)
 			return err
 		}
 	}
@@ -679,16 +854,7 @@ func (d *dedicatedElasticsearchAdapter) writeManifestToS3(i *ElasticsearchInstan
 	}
 	body := bytes.NewReader(data)
 
-	session, err := session.NewSession(&aws.Config{
-		Region: aws.String(d.settings.Region),
-	})
-	if err != nil {
-		d.logger.Error("writeManifesttoS3.NewSession failed", err)
-		return err
-	}
-
 	// put json blob into object in s3
-	svc := s3.New(session)
 	input := s3.PutObjectInput{
 		Body:                 body,
 		Bucket:               aws.String(d.settings.SnapshotsBucketName),
@@ -696,7 +862,7 @@ func (d *dedicatedElasticsearchAdapter) writeManifestToS3(i *ElasticsearchInstan
 		ServerSideEncryption: aws.String("AES256"),
 	}
 
-	_, err = svc.PutObject(&input)
+	_, err = d.s3.PutObject(&input)
 	// Decide if AWS service call was successful
 	if success := d.didAwsCallSucceed(err); !success {
 		d.logger.Error("writeManifesttoS3.PutObject Failed", err)
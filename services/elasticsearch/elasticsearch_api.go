@@ -3,7 +3,6 @@ package elasticsearch
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -39,6 +38,7 @@ type SnapshotRepoSettings struct {
 	SSE      bool   `json:"server_side_encryption"` //we set this to true, default is false
 	Region   string `json:"region"`
 	RoleArn  string `json:"role_arn"`
+	Readonly bool   `json:"readonly"`
 }
 
 type Snapshot struct {
@@ -54,6 +54,12 @@ type Snapshots struct {
 	Snapshots []Snapshot `json:"snapshots"`
 }
 
+// IndexRecovery is the recovery of the shards of an index, e.g. while it is
+// being restored from a snapshot.
+type IndexRecovery struct {
+	Shards []json.RawMessage `json:"shards"`
+}
+
 func NewSnapshotRepo(bucketname string, path string, region string, rolearn string) *SnapshotRepo {
 	sr := &SnapshotRepo{}
 	sr.Type = "s3"
@@ -70,7 +76,6 @@ func NewSnapshotRepo(bucketname string, path string, region string, rolearn stri
 func (sr *SnapshotRepo) ToString() (string, error) {
 	bytestr, err := json.Marshal(sr)
 	if err != nil {
-		fmt.Print(err)
 		return "", err
 	}
 	repo := string(bytestr)
@@ -99,7 +104,6 @@ func (es *EsApiHandler) Send(method string, endpoint string, content string) ([]
 	// form new request
 	req, err := http.NewRequest(method, endpoint, body)
 	if err != nil {
-		fmt.Print(err)
 		return result, err
 	}
 	req.Header.Add("Content-Type", "application/json")
@@ -120,9 +124,6 @@ func (es *EsApiHandler) Send(method string, endpoint string, content string) ([]
 	}
 	defer resp.Body.Close()
 	result, err = io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Print(err)
-	}
 	//fmt.Printf("ESAPI -- Send -- Result: %v", string(result))
 	return result, err
 }
@@ -130,17 +131,34 @@ func (es *EsApiHandler) Send(method string, endpoint string, content string) ([]
 func (es *EsApiHandler) CreateSnapshotRepo(reponame string, bucketname string, path string, region string, rolearn string) (string, error) {
 	// the repo request cannot have a leading slash in the path
 	path = strings.TrimPrefix(path, "/")
+	return es.putSnapshotRepo(reponame, NewSnapshotRepo(bucketname, path, region, rolearn))
+}
+
+// CreateReadonlySnapshotRepo registers a snapshot repository that the domain
+// can only restore from, e.g. the snapshots of another domain.
+func (es *EsApiHandler) CreateReadonlySnapshotRepo(reponame string, bucketname string, path string, region string, rolearn string) (string, error) {
+	path = strings.TrimPrefix(path, "/")
+	repo := NewSnapshotRepo(bucketname, path, region, rolearn)
+	repo.Settings.Readonly = true
+	return es.putSnapshotRepo(reponame, repo)
+}
 
-	snaprepo, err := NewSnapshotRepo(bucketname, path, region, rolearn).ToString()
+// DeleteSnapshotRepo unregisters a snapshot repository from the domain,
+// leaving its snapshots in place.
+func (es *EsApiHandler) DeleteSnapshotRepo(reponame string) (string, error) {
+	resp, err := es.Send(http.MethodDelete, "/_snapshot/"+reponame, "")
+	return string(resp), err
+}
+
+func (es *EsApiHandler) putSnapshotRepo(reponame string, repo *SnapshotRepo) (string, error) {
+	snaprepo, err := repo.ToString()
 	if err != nil {
-		fmt.Print(err)
 		return "", err
 
 	}
 	endpoint := "/_snapshot/" + reponame
 	resp, err := es.Send(http.MethodPut, endpoint, snaprepo)
 	if err != nil {
-		fmt.Print(err)
 		return "", err
 	}
 	//fmt.Printf("CreateSnapshotRepo: \n\tEndpoint: %s\n\tResponse %v", endpoint, string(resp))
@@ -151,19 +169,47 @@ func (es *EsApiHandler) CreateSnapshot(reponame string, snapshotname string) (st
 
 	endpoint := "/_snapshot/" + reponame + "/" + snapshotname
 	resp, err := es.Send(http.MethodPut, endpoint, "")
-	if err != nil {
-		fmt.Printf("es_api createsnapshot error:%v", err)
-	}
 	//fmt.Printf("CreateSnapshot: \n\tEndpoint: %s\n\tResponse %v", endpoint, string(resp))
 	return string(resp), err
 }
 
-func (es *EsApiHandler) GetSnapshotRepo(reponame string) (string, error) {
-	endpoint := "/_snapshot/" + reponame
+// RestoreSnapshot restores the indices of a snapshot, leaving out the system
+// indices and cluster state of the domain the snapshot was taken of.
+func (es *EsApiHandler) RestoreSnapshot(reponame string, snapshotname string) (string, error) {
+	endpoint := "/_snapshot/" + reponame + "/" + snapshotname + "/_restore"
+	resp, err := es.Send(http.MethodPost, endpoint, `{"indices": "-.*", "include_global_state": false}`)
+	return string(resp), err
+}
+
+func (es *EsApiHandler) DeleteSnapshot(reponame string, snapshotname string) (string, error) {
+	endpoint := "/_snapshot/" + reponame + "/" + snapshotname
+	resp, err := es.Send(http.MethodDelete, endpoint, "")
+	return string(resp), err
+}
+
+// GetActiveRecoveries returns the number of shards that are still being
+// recovered, e.g. restored from a snapshot.
+func (es *EsApiHandler) GetActiveRecoveries() (int, error) {
+	endpoint := "/_recovery?active_only=true"
 	resp, err := es.Send(http.MethodGet, endpoint, "")
 	if err != nil {
-		fmt.Print(err)
+		return 0, err
 	}
+	recoveries := map[string]IndexRecovery{}
+	err = json.Unmarshal(resp, &recoveries)
+	if err != nil {
+		return 0, err
+	}
+	shards := 0
+	for _, recovery := range recoveries {
+		shards += len(recovery.Shards)
+	}
+	return shards, nil
+}
+
+func (es *EsApiHandler) GetSnapshotRepo(reponame string) (string, error) {
+	endpoint := "/_snapshot/" + reponame
+	resp, err := es.Send(http.MethodGet, endpoint, "")
 	//fmt.Printf("GetSnapshotRepo: \n\tEndpoint: %s\n\tResponse %v", endpoint, string(resp))
 	return string(resp), err
 }
@@ -173,17 +219,14 @@ func (es *EsApiHandler) GetSnapshotStatus(reponame string, snapshotname string)
 	endpoint := "/_snapshot/" + reponame + "/" + snapshotname + "/_status"
 	resp, err := es.Send(http.MethodGet, endpoint, "")
 	if err != nil {
-		fmt.Printf("es_api getsnapshot status error %v", err)
 		return "", err
 	}
 	snapshots := Snapshots{}
 	err = json.Unmarshal(resp, &snapshots)
 	if err != nil {
-		fmt.Printf("es_api unmarshall reply error: %v", err)
 		return "", err
 	}
 	if len(snapshots.Snapshots) == 0 {
-		fmt.Printf("GetSnapshotStatus - Snapshot Response: %v", snapshots)
 		return "FAILED", errors.New("SnapshotStatus returned empty")
 	}
 	//fmt.Printf("GetSnapshotSnapshot: \n\tEndpoint: %s\n\tResponse %v", endpoint, string(resp))
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
 )
 
@@ -58,7 +59,7 @@ func TestNewSnapShotRepo(t *testing.T) {
 }
 
 func TestSnapshotRepoToString(t *testing.T) {
-	expected := "{\"type\":\"s3\",\"settings\":{\"bucket\":\"" + bucket + "\",\"base_path\":\"" + path + "\",\"server_side_encryption\":true,\"region\":\"" + region + "\",\"role_arn\":\"" + rolearn + "\"}}"
+	expected := "{\"type\":\"s3\",\"settings\":{\"bucket\":\"" + bucket + "\",\"base_path\":\"" + path + "\",\"server_side_encryption\":true,\"region\":\"" + region + "\",\"role_arn\":\"" + rolearn + "\",\"readonly\":false}}"
 	snaprepo := NewSnapshotRepo(bucket, path, region, rolearn)
 	result, err := snaprepo.ToString()
 	if err != nil {
@@ -106,6 +107,34 @@ func TestCreateSnapshotRepo(t *testing.T) {
 	}
 }
 
+func TestReadonlySnapshotRepoToString(t *testing.T) {
+	snaprepo := NewSnapshotRepo(bucket, path, region, rolearn)
+	snaprepo.Settings.Readonly = true
+	result, err := snaprepo.ToString()
+	if err != nil {
+		t.Error("Got non-nil error in ToString")
+	}
+	if !strings.Contains(result, "\"readonly\":true") {
+		t.Errorf("Expected a read-only repo but got %s", result)
+	}
+}
+
+func TestCreateReadonlySnapshotRepo(t *testing.T) {
+	es := createMockESHandler("")
+	_, err := es.CreateReadonlySnapshotRepo(reponame, bucket, "/"+path, region, rolearn)
+	if err != nil {
+		t.Errorf("Err is not nil: %v", err)
+	}
+}
+
+func TestDeleteSnapshotRepo(t *testing.T) {
+	es := createMockESHandler("")
+	_, err := es.DeleteSnapshotRepo(reponame)
+	if err != nil {
+		t.Errorf("Err is not nil: %v", err)
+	}
+}
+
 func TestCreateSnapshot(t *testing.T) {
 	es := createMockESHandler("")
 	_, err := es.CreateSnapshot(reponame, snapshotname)
@@ -114,6 +143,14 @@ func TestCreateSnapshot(t *testing.T) {
 	}
 }
 
+func TestRestoreSnapshot(t *testing.T) {
+	es := createMockESHandler("")
+	_, err := es.RestoreSnapshot(reponame, snapshotname)
+	if err != nil {
+		t.Errorf("Err is not nil: %v", err)
+	}
+}
+
 func TestGetSnapshotRepo(t *testing.T) {
 	es := createMockESHandler("")
 	_, err := es.GetSnapshotRepo(reponame)
@@ -132,3 +169,31 @@ func TestGetSnapshotStatus(t *testing.T) {
 		t.Errorf("Response is %s, not SUCCESS", resp)
 	}
 }
+
+func TestDeleteSnapshot(t *testing.T) {
+	es := createMockESHandler("")
+	_, err := es.DeleteSnapshot(reponame, snapshotname)
+	if err != nil {
+		t.Errorf("Err is not nil: %v", err)
+	}
+}
+
+func TestGetActiveRecoveries(t *testing.T) {
+	es := createMockESHandler(`{"movies":{"shards":[{"id":0,"type":"SNAPSHOT","stage":"INDEX"},{"id":1,"type":"SNAPSHOT","stage":"INDEX"}]},"test":{"shards":[{"id":0,"type":"SNAPSHOT","stage":"TRANSLOG"}]}}`)
+	shards, err := es.GetActiveRecoveries()
+	if err != nil {
+		t.Errorf("Err is not nil: %v", err)
+	}
+	if shards != 3 {
+		t.Errorf("Expected 3 shards being recovered but got %d", shards)
+	}
+
+	es = createMockESHandler("{}")
+	shards, err = es.GetActiveRecoveries()
+	if err != nil {
+		t.Errorf("Err is not nil: %v", err)
+	}
+	if shards != 0 {
+		t.Errorf("Expected no shards being recovered but got %d", shards)
+	}
+}
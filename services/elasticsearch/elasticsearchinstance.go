@@ -65,6 +65,17 @@ type ElasticsearchInstance struct {
 	IndexSlowLogsGroupARN  string `sql:"size(2048)"`
 	ErrorLogsGroupARN      string `sql:"size(2048)"`
 	AuditLogsGroupARN      string `sql:"size(2048)"`
+
+	// ClonedFrom is the instance that this instance is a clone of, if any.
+	// CloneSnapshotName is restored into the instance while ClonePending;
+	// CloneRestoreStarted is set once the restore has been requested.
+	// CloneReadPolicyARN is the policy allowing the snapshot role of the
+	// instance to read the snapshots of the source until the restore is done.
+	ClonedFrom          string `sql:"size(255)"`
+	CloneSnapshotName   string `sql:"size(255)"`
+	ClonePending        bool   `sql:"size(255)"`
+	CloneRestoreStarted bool   `sql:"size(255)"`
+	CloneReadPolicyARN  string `sql:"size(255)"`
 }
 
 func (i *ElasticsearchInstance) setPassword(password, key string) error {
@@ -0,0 +1,498 @@
+package rds
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/catalog"
+	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/helpers/logging"
+)
+
+// serverlessInstanceClass is the instance class of Aurora Serverless v2 instances.
+const serverlessInstanceClass = "db.serverless"
+
+// auroraDBAdapter manages instances as Aurora DB clusters with a writer
+// instance and the reader instances of their plan. The cluster is named
+// after the database of the instance.
+type auroraDBAdapter struct {
+	Plan                 catalog.RDSPlan
+	settings             config.Settings
+	rds                  rdsiface.RDSAPI
+	parameterGroupClient parameterGroupClient
+	logger               lager.Logger
+}
+
+// auroraWriterIdentifier is the identifier of the writer instance of the cluster.
+func auroraWriterIdentifier(i *RDSInstance) string {
+	return i.Database + "-writer"
+}
+
+// auroraReaderIdentifier is the identifier of the nth reader instance of the cluster.
+func auroraReaderIdentifier(i *RDSInstance, number int64) string {
+	return fmt.Sprintf("%s-reader-%d", i.Database, number)
+}
+
+func (d *auroraDBAdapter) serverlessV2ScalingConfiguration() *rds.ServerlessV2ScalingConfiguration {
+	if d.Plan.InstanceClass != serverlessInstanceClass {
+		return nil
+	}
+	return &rds.ServerlessV2ScalingConfiguration{
+		MinCapacity: aws.Float64(d.Plan.ServerlessMinCapacity),
+		MaxCapacity: aws.Float64(d.Plan.ServerlessMaxCapacity),
+	}
+}
+
+func (d *auroraDBAdapter) prepareCreateDbClusterInput(i *RDSInstance, password string) (*rds.CreateDBClusterInput, error) {
+	rdsTags := ConvertTagsToRDSTags(i.Tags)
+
+	params := &rds.CreateDBClusterInput{
+		DBClusterIdentifier:              aws.String(i.Database),
+		DatabaseName:                     aws.String(i.FormatDBName()),
+		Engine:                           aws.String(i.engine()),
+		MasterUsername:                   aws.String(i.Username),
+		MasterUserPassword:               aws.String(password),
+		BackupRetentionPeriod:            aws.Int64(i.BackupRetentionPeriod),
+		CopyTagsToSnapshot:               aws.Bool(true),
+		StorageEncrypted:                 aws.Bool(d.Plan.Encrypted),
+		DBSubnetGroupName:                aws.String(i.DbSubnetGroup),
+		VpcSecurityGroupIds:              []*string{aws.String(i.SecGroup)},
+		ServerlessV2ScalingConfiguration: d.serverlessV2ScalingConfiguration(),
+		Tags:                             rdsTags,
+	}
+	if i.DbVersion != "" {
+		params.EngineVersion = aws.String(i.DbVersion)
+	}
+	if len(i.EnabledCloudwatchLogGroupExports) > 0 {
+		params.EnableCloudwatchLogsExports = aws.StringSlice(i.EnabledCloudwatchLogGroupExports)
+	}
+	if i.PreferredMaintenanceWindow != "" {
+		params.PreferredMaintenanceWindow = aws.String(i.PreferredMaintenanceWindow)
+	}
+	if i.PreferredBackupWindow != "" {
+		params.PreferredBackupWindow = aws.String(i.PreferredBackupWindow)
+	}
+	if i.DeletionProtection {
+		params.DeletionProtection = aws.Bool(true)
+	}
+
+	err := d.parameterGroupClient.ProvisionCustomParameterGroupIfNecessary(i, rdsTags)
+	if err != nil {
+		return nil, err
+	}
+	if i.ParameterGroupName != "" {
+		params.DBClusterParameterGroupName = aws.String(i.ParameterGroupName)
+	}
+
+	return params, nil
+}
+
+func (d *auroraDBAdapter) prepareCreateDbInstanceInput(i *RDSInstance, identifier string, promotionTier int64) *rds.CreateDBInstanceInput {
+	return &rds.CreateDBInstanceInput{
+		DBInstanceIdentifier:    aws.String(identifier),
+		DBClusterIdentifier:     aws.String(i.Database),
+		DBInstanceClass:         aws.String(d.Plan.InstanceClass),
+		Engine:                  aws.String(i.engine()),
+		AutoMinorVersionUpgrade: aws.Bool(true),
+		PubliclyAccessible:      aws.Bool(false),
+		PromotionTier:           aws.Int64(promotionTier),
+		Tags:                    ConvertTagsToRDSTags(i.Tags),
+	}
+}
+
+func (d *auroraDBAdapter) createDBInstance(i *RDSInstance, identifier string, promotionTier int64) error {
+	d.logger.Info("create-cluster-instance", lager.Data{
+		"database": i.Database,
+		"instance": identifier,
+	})
+	_, err := d.rds.CreateDBInstance(d.prepareCreateDbInstanceInput(i, identifier, promotionTier))
+	if err != nil {
+		logging.LogAWSError(d.logger, "create-db-instance", err)
+	}
+	return err
+}
+
+func (d *auroraDBAdapter) createDB(i *RDSInstance, password string) (base.InstanceState, error) {
+	if i.SnapshotIdentifier != "" || i.sourceDatabase != "" || i.pointInTimeSource != "" {
+		return base.InstanceNotCreated, errors.New("restoring instances is not supported for Aurora plans")
+	}
+
+	params, err := d.prepareCreateDbClusterInput(i, password)
+	if err != nil {
+		return base.InstanceNotCreated, err
+	}
+
+	_, err = d.rds.CreateDBCluster(params)
+	if err != nil {
+		logging.LogAWSError(d.logger, "create-db-cluster", err)
+		return base.InstanceNotCreated, err
+	}
+
+	// The instances of the cluster can be created while the cluster itself is
+	// still being created. The writer has the highest priority for failover.
+	if err := d.createDBInstance(i, auroraWriterIdentifier(i), 0); err != nil {
+		return base.InstanceNotCreated, err
+	}
+	for number := int64(1); number <= d.Plan.ReaderInstances; number++ {
+		if err := d.createDBInstance(i, auroraReaderIdentifier(i, number), 1); err != nil {
+			return base.InstanceNotCreated, err
+		}
+	}
+
+	return base.InstanceInProgress, nil
+}
+
+func (d *auroraDBAdapter) prepareModifyDbClusterInput(i *RDSInstance) (*rds.ModifyDBClusterInput, error) {
+	params := &rds.ModifyDBClusterInput{
+		DBClusterIdentifier:              aws.String(i.Database),
+		ApplyImmediately:                 aws.Bool(true),
+		BackupRetentionPeriod:            aws.Int64(i.BackupRetentionPeriod),
+		CopyTagsToSnapshot:               aws.Bool(true),
+		ServerlessV2ScalingConfiguration: d.serverlessV2ScalingConfiguration(),
+	}
+
+	if i.PreferredMaintenanceWindow != "" {
+		params.PreferredMaintenanceWindow = aws.String(i.PreferredMaintenanceWindow)
+	}
+	if i.PreferredBackupWindow != "" {
+		params.PreferredBackupWindow = aws.String(i.PreferredBackupWindow)
+	}
+	if i.updateDeletionProtection {
+		params.DeletionProtection = aws.Bool(i.DeletionProtection)
+	}
+
+	if i.ClearPassword != "" {
+		params.MasterUserPassword = aws.String(i.ClearPassword)
+	}
+
+	err := d.parameterGroupClient.ProvisionCustomParameterGroupIfNecessary(i, ConvertTagsToRDSTags(i.Tags))
+	if err != nil {
+		return nil, err
+	}
+	if i.ParameterGroupName != "" {
+		params.DBClusterParameterGroupName = aws.String(i.ParameterGroupName)
+	}
+	return params, nil
+}
+
+// modifyDB applies the plan and parameters of the instance to its cluster, and
+// the instance class and number of readers of the plan to its instances.
+func (d *auroraDBAdapter) modifyDB(i *RDSInstance, password string) (base.InstanceState, error) {
+	if i.useBlueGreen || i.upgradeMajorVersion {
+		return base.InstanceNotModified, errors.New("blue/green deployments and major version upgrades are not supported for Aurora plans")
+	}
+
+	cluster, err := d.describeCluster(i)
+	if err != nil {
+		return base.InstanceNotModified, err
+	}
+
+	params, err := d.prepareModifyDbClusterInput(i)
+	if err != nil {
+		return base.InstanceNotModified, err
+	}
+	_, err = d.rds.ModifyDBCluster(params)
+	if err != nil {
+		logging.LogAWSError(d.logger, "modify-db-cluster", err)
+		return base.InstanceNotModified, err
+	}
+
+	members := map[string]bool{}
+	for _, member := range cluster.DBClusterMembers {
+		identifier := aws.StringValue(member.DBInstanceIdentifier)
+		members[identifier] = true
+		_, err := d.rds.ModifyDBInstance(&rds.ModifyDBInstanceInput{
+			DBInstanceIdentifier: aws.String(identifier),
+			DBInstanceClass:      aws.String(d.Plan.InstanceClass),
+			ApplyImmediately:     aws.Bool(true),
+		})
+		if err != nil {
+			logging.LogAWSError(d.logger, "modify-db-instance", err)
+			return base.InstanceNotModified, err
+		}
+	}
+
+	// Create the readers that the plan adds, and delete the ones it drops.
+	for number := int64(1); number <= d.Plan.ReaderInstances; number++ {
+		identifier := auroraReaderIdentifier(i, number)
+		if members[identifier] {
+			continue
+		}
+		if err := d.createDBInstance(i, identifier, 1); err != nil {
+			return base.InstanceNotModified, err
+		}
+	}
+	for number := d.Plan.ReaderInstances + 1; members[auroraReaderIdentifier(i, number)]; number++ {
+		if err := d.deleteDBInstance(i, auroraReaderIdentifier(i, number)); err != nil {
+			return base.InstanceNotModified, err
+		}
+	}
+
+	return base.InstanceInProgress, nil
+}
+
+func (d *auroraDBAdapter) describeCluster(i *RDSInstance) (*rds.DBCluster, error) {
+	resp, err := d.rds.DescribeDBClusters(&rds.DescribeDBClustersInput{
+		DBClusterIdentifier: aws.String(i.Database),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "describe-db-clusters", err)
+		return nil, err
+	}
+	if len(resp.DBClusters) == 0 {
+		return nil, errors.New("Couldn't find any clusters.")
+	}
+	return resp.DBClusters[0], nil
+}
+
+// describeClusterInstances returns the instances of the cluster of the
+// instance, ordered by identifier.
+func (d *auroraDBAdapter) describeClusterInstances(i *RDSInstance) ([]*rds.DBInstance, error) {
+	var instances []*rds.DBInstance
+	err := d.rds.DescribeDBInstancesPages(&rds.DescribeDBInstancesInput{
+		Filters: []*rds.Filter{
+			{
+				Name:   aws.String("db-cluster-id"),
+				Values: []*string{aws.String(i.Database)},
+			},
+		},
+	}, func(page *rds.DescribeDBInstancesOutput, lastPage bool) bool {
+		instances = append(instances, page.DBInstances...)
+		return true
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "describe-db-instances", err)
+		return nil, err
+	}
+	sort.Slice(instances, func(a, b int) bool {
+		return aws.StringValue(instances[a].DBInstanceIdentifier) < aws.StringValue(instances[b].DBInstanceIdentifier)
+	})
+	return instances, nil
+}
+
+// checkDBStatus reports the instance as ready once its cluster and all of the
+// instances of the cluster are available, and records the writer and reader
+// endpoints of the cluster.
+func (d *auroraDBAdapter) checkDBStatus(i *RDSInstance) (base.InstanceState, error) {
+	if i.State == base.InstanceReady {
+		return base.InstanceNotCreated, nil
+	}
+
+	cluster, err := d.describeCluster(i)
+	if err != nil {
+		return base.InstanceNotCreated, err
+	}
+
+	status := aws.StringValue(cluster.Status)
+	d.logger.Info("db-cluster-status", lager.Data{
+		"database": i.Database,
+		"status":   status,
+	})
+	switch status {
+	case "available":
+	case "creating":
+		return base.InstanceInProgress, nil
+	case "deleting":
+		return base.InstanceNotGone, nil
+	case "failed", "inaccessible-encryption-credentials":
+		return base.InstanceNotCreated, nil
+	default:
+		return base.InstanceInProgress, nil
+	}
+
+	instances, err := d.describeClusterInstances(i)
+	if err != nil {
+		return base.InstanceNotCreated, err
+	}
+	if len(instances) == 0 {
+		return base.InstanceInProgress, nil
+	}
+	for _, instance := range instances {
+		if aws.StringValue(instance.DBInstanceStatus) != "available" {
+			return base.InstanceInProgress, nil
+		}
+	}
+
+	i.Host = aws.StringValue(cluster.Endpoint)
+	i.ReaderHost = aws.StringValue(cluster.ReaderEndpoint)
+	i.Port = aws.Int64Value(cluster.Port)
+	return base.InstanceReady, nil
+}
+
+func (d *auroraDBAdapter) bindDBToApp(i *RDSInstance, password string) (map[string]string, error) {
+	if i.State != base.InstanceReady {
+		status, err := d.checkDBStatus(i)
+		if err != nil {
+			return nil, err
+		}
+		if status != base.InstanceReady {
+			return nil, errors.New("Instance not available yet. Please wait and try again..")
+		}
+		i.State = base.InstanceReady
+	}
+	return i.getCredentials(password)
+}
+
+func (d *auroraDBAdapter) deleteDBInstance(i *RDSInstance, identifier string) error {
+	d.logger.Info("delete-cluster-instance", lager.Data{
+		"database": i.Database,
+		"instance": identifier,
+	})
+	_, err := d.rds.DeleteDBInstance(&rds.DeleteDBInstanceInput{
+		DBInstanceIdentifier: aws.String(identifier),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "delete-db-instance", err)
+	}
+	return err
+}
+
+// deleteDB deletes the instances of the cluster and then the cluster, which
+// can be deleted once its instances are being deleted.
+func (d *auroraDBAdapter) deleteDB(i *RDSInstance, finalSnapshotIdentifier string) (base.InstanceState, error) {
+	cluster, err := d.describeCluster(i)
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == rds.ErrCodeDBClusterNotFoundFault {
+			return base.InstanceGone, nil
+		}
+		return base.InstanceNotGone, err
+	}
+
+	for _, member := range cluster.DBClusterMembers {
+		if err := d.deleteDBInstance(i, aws.StringValue(member.DBInstanceIdentifier)); err != nil {
+			return base.InstanceNotGone, err
+		}
+	}
+
+	params := &rds.DeleteDBClusterInput{
+		DBClusterIdentifier: aws.String(i.Database),
+		SkipFinalSnapshot:   aws.Bool(finalSnapshotIdentifier == ""),
+	}
+	if finalSnapshotIdentifier != "" {
+		params.FinalDBSnapshotIdentifier = aws.String(finalSnapshotIdentifier)
+	}
+	_, err = d.rds.DeleteDBCluster(params)
+	if err != nil {
+		logging.LogAWSError(d.logger, "delete-db-cluster", err)
+		return base.InstanceNotGone, err
+	}
+	return base.InstanceInProgress, nil
+}
+
+func (d *auroraDBAdapter) checkDBDeleted(i *RDSInstance) (base.InstanceState, error) {
+	_, err := d.describeCluster(i)
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == rds.ErrCodeDBClusterNotFoundFault {
+			return base.InstanceGone, nil
+		}
+		return base.InstanceNotGone, err
+	}
+	return base.InstanceInProgress, nil
+}
+
+// describeDB describes the writer instance of the cluster.
+func (d *auroraDBAdapter) describeDB(i *RDSInstance) (*rds.DBInstance, error) {
+	resp, err := d.rds.DescribeDBInstances(&rds.DescribeDBInstancesInput{
+		DBInstanceIdentifier: aws.String(auroraWriterIdentifier(i)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.DBInstances) == 0 {
+		return nil, errors.New("Couldn't find any instances.")
+	}
+	return resp.DBInstances[0], nil
+}
+
+func (d *auroraDBAdapter) createReadReplica(i *RDSInstance, r *ReadReplica) (base.InstanceState, error) {
+	return base.InstanceNotCreated, errors.New("the reader instances of Aurora instances are set by their plan")
+}
+
+func (d *auroraDBAdapter) checkReadReplicaStatus(r *ReadReplica) (base.InstanceState, error) {
+	return base.InstanceNotCreated, errors.New("the reader instances of Aurora instances are set by their plan")
+}
+
+func (d *auroraDBAdapter) deleteReadReplica(r *ReadReplica) (base.InstanceState, error) {
+	return base.InstanceNotGone, errors.New("the reader instances of Aurora instances are set by their plan")
+}
+
+func (d *auroraDBAdapter) findMajorVersionUpgradeTarget(i *RDSInstance, majorVersion string) (string, error) {
+	if majorVersion == getMajorVersion(i.DbVersion) {
+		return "", nil
+	}
+	return "", errors.New("major version upgrades are not supported for Aurora plans")
+}
+
+func (d *auroraDBAdapter) createSnapshot(i *RDSInstance, name string, tags map[string]string) (base.Snapshot, error) {
+	return base.Snapshot{}, errors.New("manual snapshots are not supported for Aurora plans")
+}
+
+func (d *auroraDBAdapter) listSnapshots(i *RDSInstance) ([]base.Snapshot, error) {
+	return nil, errors.New("manual snapshots are not supported for Aurora plans")
+}
+
+func (d *auroraDBAdapter) deleteSnapshot(snapshotIdentifier string) error {
+	return errors.New("manual snapshots are not supported for Aurora plans")
+}
+
+// rotateMasterPassword applies the new master password of the instance to its
+// cluster.
+func (d *auroraDBAdapter) rotateMasterPassword(i *RDSInstance) error {
+	_, err := d.rds.ModifyDBCluster(&rds.ModifyDBClusterInput{
+		DBClusterIdentifier: aws.String(i.Database),
+		MasterUserPassword:  aws.String(i.ClearPassword),
+		ApplyImmediately:    aws.Bool(true),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "modify-db-cluster", err)
+		return err
+	}
+	return nil
+}
+
+func (d *auroraDBAdapter) getManagedMasterPassword(i *RDSInstance) (string, error) {
+	return "", errors.New("RDS-managed master passwords are not supported for Aurora plans")
+}
+
+// stopDB stops the cluster of the instance.
+func (d *auroraDBAdapter) stopDB(i *RDSInstance) error {
+	_, err := d.rds.StopDBCluster(&rds.StopDBClusterInput{
+		DBClusterIdentifier: aws.String(i.Database),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "stop-db-cluster", err)
+		return err
+	}
+	return nil
+}
+
+// startDB starts the cluster of the instance if it is stopped.
+func (d *auroraDBAdapter) startDB(i *RDSInstance) error {
+	cluster, err := d.describeCluster(i)
+	if err != nil {
+		return err
+	}
+	switch aws.StringValue(cluster.Status) {
+	case "stopped":
+	case "stopping":
+		return errors.New("the cluster of the instance is still stopping. Please wait and try again..")
+	default:
+		return nil
+	}
+	_, err = d.rds.StartDBCluster(&rds.StartDBClusterInput{
+		DBClusterIdentifier: aws.String(i.Database),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "start-db-cluster", err)
+		return err
+	}
+	return nil
+}
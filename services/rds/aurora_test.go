@@ -0,0 +1,350 @@
+package rds
+
+import (
+	"testing"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/catalog"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/go-test/deep"
+)
+
+type mockRdsClientForAuroraTests struct {
+	rdsiface.RDSAPI
+
+	cluster    *rds.DBCluster
+	clusterErr error
+	instances  []*rds.DBInstance
+
+	createdInstances []string
+	deletedInstances []string
+	deletedClusters  []*rds.DeleteDBClusterInput
+}
+
+func (m *mockRdsClientForAuroraTests) CreateDBCluster(*rds.CreateDBClusterInput) (*rds.CreateDBClusterOutput, error) {
+	return nil, nil
+}
+
+func (m *mockRdsClientForAuroraTests) ModifyDBCluster(*rds.ModifyDBClusterInput) (*rds.ModifyDBClusterOutput, error) {
+	return nil, nil
+}
+
+func (m *mockRdsClientForAuroraTests) DeleteDBCluster(input *rds.DeleteDBClusterInput) (*rds.DeleteDBClusterOutput, error) {
+	m.deletedClusters = append(m.deletedClusters, input)
+	return nil, nil
+}
+
+func (m *mockRdsClientForAuroraTests) DescribeDBClusters(*rds.DescribeDBClustersInput) (*rds.DescribeDBClustersOutput, error) {
+	if m.clusterErr != nil {
+		return nil, m.clusterErr
+	}
+	return &rds.DescribeDBClustersOutput{DBClusters: []*rds.DBCluster{m.cluster}}, nil
+}
+
+func (m *mockRdsClientForAuroraTests) CreateDBInstance(input *rds.CreateDBInstanceInput) (*rds.CreateDBInstanceOutput, error) {
+	m.createdInstances = append(m.createdInstances, aws.StringValue(input.DBInstanceIdentifier))
+	return nil, nil
+}
+
+func (m *mockRdsClientForAuroraTests) ModifyDBInstance(*rds.ModifyDBInstanceInput) (*rds.ModifyDBInstanceOutput, error) {
+	return nil, nil
+}
+
+func (m *mockRdsClientForAuroraTests) DeleteDBInstance(input *rds.DeleteDBInstanceInput) (*rds.DeleteDBInstanceOutput, error) {
+	m.deletedInstances = append(m.deletedInstances, aws.StringValue(input.DBInstanceIdentifier))
+	return nil, nil
+}
+
+func (m *mockRdsClientForAuroraTests) DescribeDBInstancesPages(input *rds.DescribeDBInstancesInput, fn func(*rds.DescribeDBInstancesOutput, bool) bool) error {
+	fn(&rds.DescribeDBInstancesOutput{DBInstances: m.instances}, true)
+	return nil
+}
+
+func newAuroraTestInstance() *RDSInstance {
+	return &RDSInstance{
+		Adapter:               "aurora",
+		Database:              "db-name",
+		DbType:                "postgres",
+		DbVersion:             "15",
+		Username:              "user",
+		BackupRetentionPeriod: 14,
+		DbSubnetGroup:         "subnet-group",
+		SecGroup:              "sg-123",
+		dbUtils:               &RDSDatabaseUtils{},
+	}
+}
+
+func TestPrepareCreateDbClusterInput(t *testing.T) {
+	testCases := map[string]struct {
+		plan           catalog.RDSPlan
+		expectedParams *rds.CreateDBClusterInput
+	}{
+		"provisioned": {
+			plan: catalog.RDSPlan{
+				InstanceClass: "db.r6g.large",
+				Encrypted:     true,
+			},
+			expectedParams: &rds.CreateDBClusterInput{
+				DBClusterIdentifier:   aws.String("db-name"),
+				DatabaseName:          aws.String("dbname"),
+				Engine:                aws.String("aurora-postgresql"),
+				EngineVersion:         aws.String("15"),
+				MasterUsername:        aws.String("user"),
+				MasterUserPassword:    aws.String("password"),
+				BackupRetentionPeriod: aws.Int64(14),
+				CopyTagsToSnapshot:    aws.Bool(true),
+				StorageEncrypted:      aws.Bool(true),
+				DBSubnetGroupName:     aws.String("subnet-group"),
+				VpcSecurityGroupIds:   []*string{aws.String("sg-123")},
+			},
+		},
+		"serverless": {
+			plan: catalog.RDSPlan{
+				InstanceClass:         "db.serverless",
+				ServerlessMinCapacity: 0.5,
+				ServerlessMaxCapacity: 8,
+			},
+			expectedParams: &rds.CreateDBClusterInput{
+				DBClusterIdentifier:   aws.String("db-name"),
+				DatabaseName:          aws.String("dbname"),
+				Engine:                aws.String("aurora-postgresql"),
+				EngineVersion:         aws.String("15"),
+				MasterUsername:        aws.String("user"),
+				MasterUserPassword:    aws.String("password"),
+				BackupRetentionPeriod: aws.Int64(14),
+				CopyTagsToSnapshot:    aws.Bool(true),
+				StorageEncrypted:      aws.Bool(false),
+				DBSubnetGroupName:     aws.String("subnet-group"),
+				VpcSecurityGroupIds:   []*string{aws.String("sg-123")},
+				ServerlessV2ScalingConfiguration: &rds.ServerlessV2ScalingConfiguration{
+					MinCapacity: aws.Float64(0.5),
+					MaxCapacity: aws.Float64(8),
+				},
+			},
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			adapter := &auroraDBAdapter{
+				Plan:                 test.plan,
+				logger:               lagertest.NewTestLogger("test"),
+				parameterGroupClient: &mockParameterGroupClient{},
+			}
+			params, err := adapter.prepareCreateDbClusterInput(newAuroraTestInstance(), "password")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := deep.Equal(params, test.expectedParams); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestAuroraCreateDB(t *testing.T) {
+	client := &mockRdsClientForAuroraTests{}
+	adapter := &auroraDBAdapter{
+		Plan:                 catalog.RDSPlan{InstanceClass: "db.r6g.large", ReaderInstances: 2},
+		rds:                  client,
+		logger:               lagertest.NewTestLogger("test"),
+		parameterGroupClient: &mockParameterGroupClient{},
+	}
+
+	status, err := adapter.createDB(newAuroraTestInstance(), "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != base.InstanceInProgress {
+		t.Errorf("expected status %s, got %s", base.InstanceInProgress, status)
+	}
+	expectedInstances := []string{"db-name-writer", "db-name-reader-1", "db-name-reader-2"}
+	if diff := deep.Equal(client.createdInstances, expectedInstances); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestAuroraModifyDB(t *testing.T) {
+	testCases := map[string]struct {
+		readerInstances  int64
+		expectedCreated  []string
+		expectedDeleted  []string
+		useBlueGreen     bool
+		expectedResponse base.InstanceState
+	}{
+		"add reader": {
+			readerInstances:  2,
+			expectedCreated:  []string{"db-name-reader-2"},
+			expectedResponse: base.InstanceInProgress,
+		},
+		"remove reader": {
+			readerInstances:  0,
+			expectedDeleted:  []string{"db-name-reader-1"},
+			expectedResponse: base.InstanceInProgress,
+		},
+		"blue/green": {
+			readerInstances:  1,
+			useBlueGreen:     true,
+			expectedResponse: base.InstanceNotModified,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			client := &mockRdsClientForAuroraTests{
+				cluster: &rds.DBCluster{
+					DBClusterMembers: []*rds.DBClusterMember{
+						{DBInstanceIdentifier: aws.String("db-name-writer")},
+						{DBInstanceIdentifier: aws.String("db-name-reader-1")},
+					},
+				},
+			}
+			adapter := &auroraDBAdapter{
+				Plan:                 catalog.RDSPlan{InstanceClass: "db.r6g.large", ReaderInstances: test.readerInstances},
+				rds:                  client,
+				logger:               lagertest.NewTestLogger("test"),
+				parameterGroupClient: &mockParameterGroupClient{},
+			}
+			i := newAuroraTestInstance()
+			i.useBlueGreen = test.useBlueGreen
+
+			status, _ := adapter.modifyDB(i, "")
+			if status != test.expectedResponse {
+				t.Errorf("expected status %s, got %s", test.expectedResponse, status)
+			}
+			if diff := deep.Equal(client.createdInstances, test.expectedCreated); diff != nil {
+				t.Error(diff)
+			}
+			if diff := deep.Equal(client.deletedInstances, test.expectedDeleted); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestAuroraCheckDBStatus(t *testing.T) {
+	cluster := &rds.DBCluster{
+		Status:         aws.String("available"),
+		Endpoint:       aws.String("writer.example.com"),
+		ReaderEndpoint: aws.String("reader.example.com"),
+		Port:           aws.Int64(5432),
+	}
+	testCases := map[string]struct {
+		cluster            *rds.DBCluster
+		instances          []*rds.DBInstance
+		expectedResponse   base.InstanceState
+		expectedHost       string
+		expectedReaderHost string
+	}{
+		"cluster creating": {
+			cluster:          &rds.DBCluster{Status: aws.String("creating")},
+			expectedResponse: base.InstanceInProgress,
+		},
+		"reader creating": {
+			cluster: cluster,
+			instances: []*rds.DBInstance{
+				{DBInstanceIdentifier: aws.String("db-name-reader-1"), DBInstanceStatus: aws.String("creating")},
+				{DBInstanceIdentifier: aws.String("db-name-writer"), DBInstanceStatus: aws.String("available")},
+			},
+			expectedResponse: base.InstanceInProgress,
+		},
+		"available": {
+			cluster: cluster,
+			instances: []*rds.DBInstance{
+				{DBInstanceIdentifier: aws.String("db-name-reader-1"), DBInstanceStatus: aws.String("available")},
+				{DBInstanceIdentifier: aws.String("db-name-writer"), DBInstanceStatus: aws.String("available")},
+			},
+			expectedResponse:   base.InstanceReady,
+			expectedHost:       "writer.example.com",
+			expectedReaderHost: "reader.example.com",
+		},
+		"failed": {
+			cluster:          &rds.DBCluster{Status: aws.String("failed")},
+			expectedResponse: base.InstanceNotCreated,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			adapter := &auroraDBAdapter{
+				rds: &mockRdsClientForAuroraTests{
+					cluster:   test.cluster,
+					instances: test.instances,
+				},
+				logger: lagertest.NewTestLogger("test"),
+			}
+			i := newAuroraTestInstance()
+
+			status, err := adapter.checkDBStatus(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status != test.expectedResponse {
+				t.Errorf("expected status %s, got %s", test.expectedResponse, status)
+			}
+			if i.Host != test.expectedHost {
+				t.Errorf("expected host %q, got %q", test.expectedHost, i.Host)
+			}
+			if i.ReaderHost != test.expectedReaderHost {
+				t.Errorf("expected reader host %q, got %q", test.expectedReaderHost, i.ReaderHost)
+			}
+		})
+	}
+}
+
+func TestAuroraDeleteDB(t *testing.T) {
+	client := &mockRdsClientForAuroraTests{
+		cluster: &rds.DBCluster{
+			DBClusterMembers: []*rds.DBClusterMember{
+				{DBInstanceIdentifier: aws.String("db-name-writer")},
+				{DBInstanceIdentifier: aws.String("db-name-reader-1")},
+			},
+		},
+	}
+	adapter := &auroraDBAdapter{
+		rds:    client,
+		logger: lagertest.NewTestLogger("test"),
+	}
+
+	status, err := adapter.deleteDB(newAuroraTestInstance(), "db-final-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != base.InstanceInProgress {
+		t.Errorf("expected status %s, got %s", base.InstanceInProgress, status)
+	}
+	if diff := deep.Equal(client.deletedInstances, []string{"db-name-writer", "db-name-reader-1"}); diff != nil {
+		t.Error(diff)
+	}
+	expectedDelete := []*rds.DeleteDBClusterInput{
+		{
+			DBClusterIdentifier:       aws.String("db-name"),
+			SkipFinalSnapshot:         aws.Bool(false),
+			FinalDBSnapshotIdentifier: aws.String("db-final-snapshot"),
+		},
+	}
+	if diff := deep.Equal(client.deletedClusters, expectedDelete); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestAuroraCheckDBDeleted(t *testing.T) {
+	adapter := &auroraDBAdapter{
+		rds: &mockRdsClientForAuroraTests{
+			clusterErr: awserr.New(rds.ErrCodeDBClusterNotFoundFault, "not found", nil),
+		},
+		logger: lagertest.NewTestLogger("test"),
+	}
+
+	status, err := adapter.checkDBDeleted(newAuroraTestInstance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != base.InstanceGone {
+		t.Errorf("expected status %s, got %s", base.InstanceGone, status)
+	}
+}
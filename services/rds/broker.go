@@ -1,14 +1,23 @@
 package rds
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
+	"sort"
 	"strings"
+	"time"
 
+	"code.cloudfoundry.org/lager"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
 	brokertags "github.com/cloud-gov/go-broker-tags"
 	"github.com/jinzhu/gorm"
 
@@ -17,6 +26,7 @@ import (
 	"github.com/18F/aws-broker/config"
 	"github.com/18F/aws-broker/helpers/request"
 	"github.com/18F/aws-broker/helpers/response"
+	"github.com/18F/aws-broker/helpers/tracing"
 )
 
 // Options is a struct containing all of the custom parameters supported by
@@ -33,6 +43,33 @@ type Options struct {
 	RotateCredentials               *bool    `json:"rotate_credentials"`
 	StorageType                     string   `json:"storage_type"`
 	EnableCloudWatchLogGroupExports []string `json:"enable_cloudwatch_log_groups_exports"`
+	SkipFinalSnapshot               *bool    `json:"skip_final_snapshot"`
+	SnapshotID                      string   `json:"snapshot_id"`
+	SourceInstanceGUID              string   `json:"source_instance_guid"`
+	RestoreFromInstance             string   `json:"restore_from_instance"`
+	RestoreTime                     string   `json:"restore_time"`
+	CloneFrom                       string   `json:"clone_from"`
+	ReadReplicas                    *int64   `json:"read_replicas"`
+	UseBlueGreen                    bool     `json:"use_blue_green"`
+	MaxAllocatedStorage             *int64   `json:"max_allocated_storage"`
+	Iops                            *int64   `json:"iops"`
+	StorageThroughput               *int64   `json:"storage_throughput"`
+	EnablePerformanceInsights       *bool    `json:"enable_performance_insights"`
+	PerformanceInsightsRetention    int64    `json:"performance_insights_retention_period"`
+	PerformanceInsightsKMSKeyID     string   `json:"performance_insights_kms_key_id"`
+	MonitoringInterval              *int64   `json:"monitoring_interval"`
+	PreferredMaintenanceWindow      string   `json:"preferred_maintenance_window"`
+	PreferredBackupWindow           string   `json:"preferred_backup_window"`
+	CreateSnapshot                  string   `json:"create_snapshot"`
+	DeletionProtection              *bool    `json:"deletion_protection"`
+	ScheduledCredentialRotation     *bool    `json:"scheduled_credential_rotation"`
+
+	// DBParameters are database parameters by name, which must be allowed by
+	// the catalog.
+	DBParameters map[string]string `json:"db_parameters"`
+	// Extensions are PostgreSQL extensions, which must be allowed by the
+	// catalog for the major version of the instance.
+	Extensions []string `json:"extensions"`
 }
 
 // Validate the custom parameters passed in via the "-c <JSON string or file>"
@@ -44,6 +81,15 @@ func (o Options) Validate(settings *config.Settings) error {
 		return fmt.Errorf("Invalid storage %d; must be <= %d", o.AllocatedStorage, settings.MaxAllocatedStorage)
 	}
 
+	// Storage autoscaling cannot grow instances beyond the maximum either.
+	if o.MaxAllocatedStorage != nil && *o.MaxAllocatedStorage > settings.MaxAllocatedStorage {
+		return fmt.Errorf("Invalid max_allocated_storage %d; must be <= %d", *o.MaxAllocatedStorage, settings.MaxAllocatedStorage)
+	}
+
+	if aws.Int64Value(o.MaxAllocatedStorage) < 0 || aws.Int64Value(o.Iops) < 0 || aws.Int64Value(o.StorageThroughput) < 0 {
+		return errors.New("max_allocated_storage, iops and storage_throughput must be positive")
+	}
+
 	if o.BackupRetentionPeriod != nil && *o.BackupRetentionPeriod > settings.MaxBackupRetention {
 		return fmt.Errorf("Invalid Retention Period %d; must be <= %d", o.BackupRetentionPeriod, settings.MaxBackupRetention)
 	}
@@ -60,17 +106,98 @@ func (o Options) Validate(settings *config.Settings) error {
 		return err
 	}
 
+	if err := validateReadReplicas(o.ReadReplicas); err != nil {
+		return err
+	}
+
+	if err := validateMonitoringInterval(o.MonitoringInterval); err != nil {
+		return err
+	}
+
+	if err := validatePerformanceInsightsRetentionPeriod(o.PerformanceInsightsRetention); err != nil {
+		return err
+	}
+
+	if err := validateWindows(o.PreferredMaintenanceWindow, o.PreferredBackupWindow); err != nil {
+		return err
+	}
+
+	for name, value := range o.DBParameters {
+		if value == "" {
+			return fmt.Errorf("Invalid db_parameters; the value of %s cannot be empty", name)
+		}
+	}
+
+	if o.CreateSnapshot != "" {
+		if err := base.ValidateSnapshotName(o.CreateSnapshot); err != nil {
+			return fmt.Errorf("Invalid create_snapshot; %s", err)
+		}
+	}
+
+	for _, extension := range o.Extensions {
+		if extension == "" {
+			return errors.New("Invalid extensions; extension names cannot be empty")
+		}
+	}
+
+	restoreSources := 0
+	for _, source := range []string{o.SnapshotID, o.SourceInstanceGUID, o.RestoreFromInstance, o.CloneFrom} {
+		if source != "" {
+			restoreSources++
+		}
+	}
+	if restoreSources > 1 {
+		return errors.New("Only one of snapshot_id, source_instance_guid, restore_from_instance and clone_from may be given")
+	}
+
+	if o.RestoreFromInstance != "" && o.RestoreTime == "" {
+		return errors.New("restore_time must be given with restore_from_instance")
+	}
+	if o.RestoreFromInstance == "" && o.RestoreTime != "" {
+		return errors.New("restore_time can only be given with restore_from_instance")
+	}
+	if _, err := o.restoreTime(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// hasStoragePerformance reports whether any of the storage autoscaling limit,
+// IOPS and throughput parameters are given.
+func (o Options) hasStoragePerformance() bool {
+	return o.MaxAllocatedStorage != nil || o.Iops != nil || o.StorageThroughput != nil
+}
+
+// hasMonitoring reports whether any of the Performance Insights and Enhanced
+// Monitoring parameters are given.
+func (o Options) hasMonitoring() bool {
+	return o.EnablePerformanceInsights != nil || o.PerformanceInsightsRetention > 0 ||
+		o.PerformanceInsightsKMSKeyID != "" || o.MonitoringInterval != nil
+}
+
+// restoreTime parses the "restore_time" parameter, which is either a time in
+// RFC3339 format or "latest". It returns nil for the latest restorable time.
+func (o Options) restoreTime() (*time.Time, error) {
+	if o.RestoreTime == "" || o.RestoreTime == "latest" {
+		return nil, nil
+	}
+	restoreTime, err := time.Parse(time.RFC3339, o.RestoreTime)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid restore_time %q; must be an RFC3339 time or \"latest\"", o.RestoreTime)
+	}
+	return &restoreTime, nil
+}
+
 type rdsBroker struct {
 	brokerDB   *gorm.DB
 	settings   *config.Settings
 	tagManager brokertags.TagManager
+	logger     lager.Logger
 }
 
 // initializeAdapter is the main function to create database instances
-func initializeAdapter(plan catalog.RDSPlan, s *config.Settings, c *catalog.Catalog) (dbAdapter, response.Response) {
+func initializeAdapter(ctx context.Context, plan catalog.RDSPlan, s *config.Settings, c *catalog.Catalog, logger lager.Logger) (dbAdapter, response.Response) {
 
 	var dbAdapter dbAdapter
 	// For test environments, use a mock adapter.
@@ -81,13 +208,39 @@ func initializeAdapter(plan catalog.RDSPlan, s *config.Settings, c *catalog.Cata
 
 	switch plan.Adapter {
 	case "dedicated":
-		rdsClient := rds.New(session.New(), aws.NewConfig().WithRegion(s.Region))
-		parameterGroupClient := NewAwsParameterGroupClient(rdsClient, *s)
+		sess := tracing.InstrumentSession(ctx, session.New())
+		rdsClient := rds.New(sess, aws.NewConfig().WithRegion(s.Region))
+		parameterGroupClient := NewAwsParameterGroupClient(rdsClient, *s, logger)
 		dbAdapter = &dedicatedDBAdapter{
 			Plan:                 plan,
 			settings:             *s,
 			rds:                  rdsClient,
 			parameterGroupClient: parameterGroupClient,
+			logger:               logger,
+			iam:                  iam.New(sess, aws.NewConfig().WithRegion(s.Region)),
+			openDB:               openInstanceDB,
+			secretsManager:       secretsmanager.New(sess, aws.NewConfig().WithRegion(s.Region)),
+		}
+	case "aurora":
+		rdsClient := rds.New(tracing.InstrumentSession(ctx, session.New()), aws.NewConfig().WithRegion(s.Region))
+		parameterGroupClient := NewAwsParameterGroupClient(rdsClient, *s, logger)
+		dbAdapter = &auroraDBAdapter{
+			Plan:                 plan,
+			settings:             *s,
+			rds:                  rdsClient,
+			parameterGroupClient: parameterGroupClient,
+			logger:               logger,
+		}
+	case "shared":
+		setting, err := c.GetResources().RdsSettings.GetRDSSettingByPlan(plan.ID)
+		if err != nil {
+			logger.Error("shared-db-setting", err, lager.Data{"plan": plan.ID})
+			return nil, response.NewErrorResponse(http.StatusInternalServerError, "The shared database server of the plan is not configured")
+		}
+		dbAdapter = &sharedDBAdapter{
+			db:     setting.DB,
+			config: setting.Config,
+			logger: logger,
 		}
 	default:
 		return nil, response.NewErrorResponse(http.StatusInternalServerError, "Adapter not found")
@@ -97,15 +250,15 @@ func initializeAdapter(plan catalog.RDSPlan, s *config.Settings, c *catalog.Cata
 }
 
 // InitRDSBroker is the constructor for the rdsBroker.
-func InitRDSBroker(brokerDB *gorm.DB, settings *config.Settings, tagManager brokertags.TagManager) base.Broker {
-	return &rdsBroker{brokerDB, settings, tagManager}
+func InitRDSBroker(brokerDB *gorm.DB, settings *config.Settings, tagManager brokertags.TagManager, logger lager.Logger) base.Broker {
+	return &rdsBroker{brokerDB, settings, tagManager, logger.Session("rds")}
 }
 
 // this helps the manager to respond appropriately depending on whether a service/plan needs an operation to be async
 func (broker *rdsBroker) AsyncOperationRequired(c *catalog.Catalog, i base.Instance, o base.Operation) bool {
 	switch o {
 	case base.DeleteOp:
-		return false
+		return true
 	case base.CreateOp:
 		return true
 	case base.ModifyOp:
@@ -117,7 +270,7 @@ func (broker *rdsBroker) AsyncOperationRequired(c *catalog.Catalog, i base.Insta
 	}
 }
 
-func (broker *rdsBroker) CreateInstance(c *catalog.Catalog, id string, createRequest request.Request) response.Response {
+func (broker *rdsBroker) CreateInstance(ctx context.Context, c *catalog.Catalog, id string, createRequest request.Request) response.Response {
 	newInstance := NewRDSInstance()
 
 	options := Options{}
@@ -130,6 +283,12 @@ func (broker *rdsBroker) CreateInstance(c *catalog.Catalog, id string, createReq
 		if err != nil {
 			return response.NewErrorResponse(http.StatusBadRequest, "Invalid parameters. Error: "+err.Error())
 		}
+		if options.UseBlueGreen {
+			return response.NewErrorResponse(http.StatusBadRequest, "Invalid parameters. Error: use_blue_green can only be given when updating an instance")
+		}
+		if options.CreateSnapshot != "" {
+			return response.NewErrorResponse(http.StatusBadRequest, "Invalid parameters. Error: create_snapshot can only be given when updating an instance")
+		}
 	}
 
 	var count int64
@@ -142,6 +301,17 @@ func (broker *rdsBroker) CreateInstance(c *catalog.Catalog, id string, createReq
 	if planErr != nil {
 		return planErr
 	}
+	if resp := checkDBParameters(c.RdsService, plan.DbType, options.DBParameters); resp != nil {
+		return resp
+	}
+	version := options.Version
+	if version == "" {
+		version = plan.DbVersion
+	}
+	if resp := checkExtensions(c.RdsService, version, options.Extensions); resp != nil {
+		return resp
+	}
+
 	// make sure it's a valid major version.
 	if options.Version != "" {
 		// Check to make sure that the version specified is allowed by the plan.
@@ -165,6 +335,7 @@ func (broker *rdsBroker) CreateInstance(c *catalog.Catalog, id string, createReq
 		false,
 	)
 	if err != nil {
+		broker.logger.Error("generate-tags", err)
 		return response.NewErrorResponse(http.StatusInternalServerError, "There was an error generating the tags. Error: "+err.Error())
 	}
 
@@ -183,7 +354,23 @@ func (broker *rdsBroker) CreateInstance(c *catalog.Catalog, id string, createReq
 		return response.NewErrorResponse(http.StatusBadRequest, "There was an error initializing the instance. Error: "+err.Error())
 	}
 
-	adapter, adapterErr := initializeAdapter(plan, broker.settings, c)
+	if options.SourceInstanceGUID != "" {
+		if resp := broker.setRestoreSource(newInstance, options.SourceInstanceGUID); resp != nil {
+			return resp
+		}
+	}
+	if options.RestoreFromInstance != "" {
+		if resp := broker.setPointInTimeRestoreSource(newInstance, options); resp != nil {
+			return resp
+		}
+	}
+	if options.CloneFrom != "" {
+		if resp := broker.setCloneSource(newInstance, options.CloneFrom); resp != nil {
+			return resp
+		}
+	}
+
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, c, broker.logger)
 	if adapterErr != nil {
 		return adapterErr
 	}
@@ -193,6 +380,7 @@ func (broker *rdsBroker) CreateInstance(c *catalog.Catalog, id string, createReq
 	if status == base.InstanceNotCreated {
 		desc := "There was an error creating the instance."
 		if err != nil {
+			broker.logger.Error("create-db", err)
 			desc = desc + " Error: " + err.Error()
 		}
 		return response.NewErrorResponse(http.StatusBadRequest, desc)
@@ -203,11 +391,91 @@ func (broker *rdsBroker) CreateInstance(c *catalog.Catalog, id string, createReq
 	broker.brokerDB.NewRecord(newInstance)
 	err = broker.brokerDB.Create(newInstance).Error
 	if err != nil {
+		broker.logger.Error("save-instance", err)
 		return response.NewErrorResponse(http.StatusBadRequest, err.Error())
 	}
+	broker.logger.Info("create-db-requested", lager.Data{"database": newInstance.Database})
 	return response.SuccessAcceptedResponse
 }
 
+// setRestoreSource sets the instance to be restored from the instance with the
+// given GUID: from its most recent snapshot if it still exists, or else from
+// its final snapshot. The source must belong to the same organization.
+func (broker *rdsBroker) setRestoreSource(i *RDSInstance, sourceInstanceGUID string) response.Response {
+	sourceInstance := RDSInstance{}
+	var count int64
+	broker.brokerDB.Where("uuid = ?", sourceInstanceGUID).First(&sourceInstance).Count(&count)
+	if count != 0 {
+		if sourceInstance.OrganizationGUID != i.OrganizationGUID {
+			return response.NewErrorResponse(http.StatusForbidden, "The source instance does not belong to this organization")
+		}
+		i.sourceDatabase = sourceInstance.Database
+		return nil
+	}
+
+	finalSnapshot := FinalSnapshot{}
+	broker.brokerDB.Where("instance_guid = ?", sourceInstanceGUID).Order("created_at desc").First(&finalSnapshot).Count(&count)
+	if count == 0 {
+		return response.NewErrorResponse(http.StatusNotFound, "The source instance does not exist")
+	}
+	if finalSnapshot.OrganizationGUID != i.OrganizationGUID {
+		return response.NewErrorResponse(http.StatusForbidden, "The source instance does not belong to this organization")
+	}
+	i.SnapshotIdentifier = finalSnapshot.SnapshotIdentifier
+	return nil
+}
+
+// setPointInTimeRestoreSource sets the instance to be restored from the
+// automated backups of the instance in the "restore_from_instance" parameter,
+// as of the "restore_time" parameter. The source must belong to the same
+// organization. The adapter checks the restore time against the restorable
+// window of the source when the instance is created.
+func (broker *rdsBroker) setPointInTimeRestoreSource(i *RDSInstance, options Options) response.Response {
+	sourceInstance := RDSInstance{}
+	var count int64
+	broker.brokerDB.Where("uuid = ?", options.RestoreFromInstance).First(&sourceInstance).Count(&count)
+	if count == 0 {
+		return response.NewErrorResponse(http.StatusNotFound, "The source instance does not exist")
+	}
+	if sourceInstance.OrganizationGUID != i.OrganizationGUID {
+		return response.NewErrorResponse(http.StatusForbidden, "The source instance does not belong to this organization")
+	}
+	if sourceInstance.DbType != i.DbType {
+		return response.NewErrorResponse(http.StatusBadRequest, "Cannot restore a "+sourceInstance.DbType+" instance to a "+i.DbType+" plan")
+	}
+
+	restoreTime, err := options.restoreTime()
+	if err != nil {
+		return response.NewErrorResponse(http.StatusBadRequest, "Invalid parameters. Error: "+err.Error())
+	}
+
+	i.pointInTimeSource = sourceInstance.Database
+	i.restoreTime = restoreTime
+	return nil
+}
+
+// setCloneSource sets the instance to be a clone of the instance in the
+// "clone_from" parameter, restored from its latest automated backup.
+func (broker *rdsBroker) setCloneSource(i *RDSInstance, cloneFrom string) response.Response {
+	if _, resp := base.FindCloneSource(broker.brokerDB, cloneFrom, i.ServiceID, i.OrganizationGUID); resp != nil {
+		return resp
+	}
+
+	sourceInstance := RDSInstance{}
+	var count int64
+	broker.brokerDB.Where("uuid = ?", cloneFrom).First(&sourceInstance).Count(&count)
+	if count == 0 {
+		return response.NewErrorResponse(http.StatusNotFound, "The instance to clone from does not exist")
+	}
+	if sourceInstance.DbType != i.DbType {
+		return response.NewErrorResponse(http.StatusBadRequest, "Cannot clone a "+sourceInstance.DbType+" instance to a "+i.DbType+" plan")
+	}
+
+	i.pointInTimeSource = sourceInstance.Database
+	i.restoreTime = nil
+	return nil
+}
+
 func (broker *rdsBroker) parseModifyOptionsFromRequest(
 	modifyRequest request.Request,
 ) (Options, error) {
@@ -221,11 +489,14 @@ func (broker *rdsBroker) parseModifyOptionsFromRequest(
 		if err != nil {
 			return options, err
 		}
+		if options.SnapshotID != "" || options.SourceInstanceGUID != "" || options.RestoreFromInstance != "" || options.CloneFrom != "" {
+			return options, errors.New("snapshot_id, source_instance_guid, restore_from_instance and clone_from can only be given when creating an instance")
+		}
 	}
 	return options, nil
 }
 
-func (broker *rdsBroker) ModifyInstance(c *catalog.Catalog, id string, modifyRequest request.Request, baseInstance base.Instance) response.Response {
+func (broker *rdsBroker) ModifyInstance(ctx context.Context, c *catalog.Catalog, id string, modifyRequest request.Request, baseInstance base.Instance) response.Response {
 	existingInstance := NewRDSInstance()
 
 	// Load the existing instance provided.
@@ -240,17 +511,39 @@ func (broker *rdsBroker) ModifyInstance(c *catalog.Catalog, id string, modifyReq
 		return response.NewErrorResponse(http.StatusBadRequest, "Invalid parameters. Error: "+err.Error())
 	}
 
+	// Snapshots are taken on their own, e.g. as a checkpoint before a risky
+	// deploy, and complete the update right away.
+	if options.CreateSnapshot != "" {
+		if !reflect.DeepEqual(options, Options{CreateSnapshot: options.CreateSnapshot}) ||
+			(modifyRequest.PlanID != "" && modifyRequest.PlanID != baseInstance.PlanID) {
+			return response.NewErrorResponse(http.StatusBadRequest, "Invalid parameters. Error: create_snapshot cannot be combined with other parameters or a change of plan")
+		}
+		snapshot, resp := broker.CreateSnapshot(ctx, c, id, baseInstance, options.CreateSnapshot)
+		if resp != nil {
+			return resp
+		}
+		return response.NewSuccessModifyResponse("The snapshot " + snapshot.Name + " was requested.")
+	}
+
 	// Fetch the new plan that has been requested.
 	newPlan, newPlanErr := c.RdsService.FetchPlan(modifyRequest.PlanID)
 	if newPlanErr != nil {
 		return newPlanErr
 	}
 
+	if resp := checkDBParameters(c.RdsService, newPlan.DbType, options.DBParameters); resp != nil {
+		return resp
+	}
+
 	err = existingInstance.modify(options, newPlan, broker.settings)
 	if err != nil {
 		return response.NewErrorResponse(http.StatusBadRequest, "Failed to modify instance. Error: "+err.Error())
 	}
 
+	if resp := checkExtensions(c.RdsService, existingInstance.DbVersion, options.Extensions); resp != nil {
+		return resp
+	}
+
 	// Check to make sure that we're not switching database engines; this is not
 	// allowed.
 	if newPlan.DbType != existingInstance.DbType {
@@ -260,6 +553,14 @@ func (broker *rdsBroker) ModifyInstance(c *catalog.Catalog, id string, modifyReq
 		)
 	}
 
+	// Instances cannot move between Aurora clusters and dedicated instances.
+	if newPlan.Adapter != existingInstance.Adapter {
+		return response.NewErrorResponse(
+			http.StatusBadRequest,
+			"Cannot switch to "+newPlan.Name+" because it uses a different adapter. Please select a plan with the same adapter.",
+		)
+	}
+
 	// Don't allow updating to a service plan that doesn't support updates.
 	if !newPlan.PlanUpdateable {
 		return response.NewErrorResponse(
@@ -268,18 +569,30 @@ func (broker *rdsBroker) ModifyInstance(c *catalog.Catalog, id string, modifyReq
 		)
 	}
 
+	// The replicas of an instance are not part of its blue/green deployment.
+	if existingInstance.useBlueGreen && broker.hasReadReplicas(existingInstance) {
+		return response.NewErrorResponse(http.StatusBadRequest, "Remove the read replicas of the instance before updating it with a blue/green deployment.")
+	}
+
 	// Connect to the existing instance.
-	adapter, adapterErr := initializeAdapter(newPlan, broker.settings, c)
+	adapter, adapterErr := initializeAdapter(ctx, newPlan, broker.settings, c, broker.logger)
 	if adapterErr != nil {
 		return adapterErr
 	}
 
+	if options.Version != "" {
+		if resp := broker.setMajorVersionUpgrade(adapter, existingInstance, newPlan, options.Version); resp != nil {
+			return resp
+		}
+	}
+
 	// Modify the database instance.
 	status, err := adapter.modifyDB(existingInstance, existingInstance.ClearPassword)
 	if status == base.InstanceNotModified {
 		desc := "There was an error modifying the instance."
 
 		if err != nil {
+			broker.logger.Error("modify-db", err)
 			desc = desc + " Error: " + err.Error()
 		}
 
@@ -292,13 +605,99 @@ func (broker *rdsBroker) ModifyInstance(c *catalog.Catalog, id string, modifyReq
 	err = broker.brokerDB.Save(existingInstance).Error
 
 	if err != nil {
+		broker.logger.Error("save-instance", err)
 		return response.NewErrorResponse(http.StatusBadRequest, err.Error())
 	}
 
 	return response.SuccessAcceptedResponse
 }
 
-func (broker *rdsBroker) LastOperation(c *catalog.Catalog, id string, baseInstance base.Instance, operation string) response.Response {
+// checkDBParameters verifies that the catalog allows tenants to set the given
+// database parameters on instances of the database engine.
+func checkDBParameters(service catalog.RDSService, dbType string, dbParameters map[string]string) response.Response {
+	notAllowed := []string{}
+	for name := range dbParameters {
+		if !service.CheckDBParameter(dbType, name) {
+			notAllowed = append(notAllowed, name)
+		}
+	}
+	if len(notAllowed) > 0 {
+		sort.Strings(notAllowed)
+		return response.NewErrorResponse(
+			http.StatusBadRequest,
+			"The parameters "+strings.Join(notAllowed, ", ")+" cannot be set; db_parameters must be one of: "+strings.Join(service.AllowedDBParameters[dbType], ", ")+".",
+		)
+	}
+	return nil
+}
+
+// checkExtensions verifies that the catalog allows tenants to create the given
+// PostgreSQL extensions on instances of the engine version.
+func checkExtensions(service catalog.RDSService, version string, extensions []string) response.Response {
+	majorVersion := getMajorVersion(version)
+	notAllowed := []string{}
+	for _, extension := range extensions {
+		if !service.CheckExtension(majorVersion, extension) {
+			notAllowed = append(notAllowed, extension)
+		}
+	}
+	if len(notAllowed) > 0 {
+		sort.Strings(notAllowed)
+		return response.NewErrorResponse(
+			http.StatusBadRequest,
+			"The extensions "+strings.Join(notAllowed, ", ")+" cannot be created on PostgreSQL "+majorVersion+"; extensions must be one of: "+strings.Join(service.AllowedExtensions[majorVersion], ", ")+".",
+		)
+	}
+	return nil
+}
+
+// setMajorVersionUpgrade sets the instance to be upgraded to the newest engine
+// version of the given major version, if it is not on that major version yet.
+// The major version must be approved by the plan, and AWS must allow the
+// upgrade from the current engine version of the instance.
+func (broker *rdsBroker) setMajorVersionUpgrade(adapter dbAdapter, i *RDSInstance, plan catalog.RDSPlan, majorVersion string) response.Response {
+	if i.DbType != "postgres" && i.DbType != "mysql" {
+		return response.NewErrorResponse(http.StatusBadRequest, "Version upgrades are only supported for MySQL and PostgreSQL instances.")
+	}
+	if !plan.CheckVersion(majorVersion) {
+		return response.NewErrorResponse(
+			http.StatusBadRequest,
+			majorVersion+" is not a supported major version; major version must be one of: "+strings.Join(plan.ApprovedMajorVersions, ", ")+".",
+		)
+	}
+
+	target, err := adapter.findMajorVersionUpgradeTarget(i, majorVersion)
+	if err != nil {
+		broker.logger.Error("find-major-version-upgrade-target", err)
+		return response.NewErrorResponse(http.StatusBadRequest, "The instance cannot be upgraded. Error: "+err.Error())
+	}
+	if target == "" {
+		return nil
+	}
+
+	if broker.hasReadReplicas(i) {
+		return response.NewErrorResponse(http.StatusBadRequest, "Remove the read replicas of the instance before upgrading it to a new major version.")
+	}
+
+	broker.logger.Info("major-version-upgrade-requested", lager.Data{
+		"database":    i.Database,
+		"old-version": i.DbVersion,
+		"new-version": target,
+	})
+	i.DbVersion = target
+	i.upgradeMajorVersion = true
+	return nil
+}
+
+// hasReadReplicas returns whether the instance has or is requested to have
+// read replicas.
+func (broker *rdsBroker) hasReadReplicas(i *RDSInstance) bool {
+	var replicaCount int64
+	broker.brokerDB.Model(&ReadReplica{}).Where("instance_guid = ?", i.Uuid).Count(&replicaCount)
+	return replicaCount > 0 || i.ReadReplicas > 0
+}
+
+func (broker *rdsBroker) LastOperation(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance, operation string) response.Response {
 	existingInstance := NewRDSInstance()
 
 	var count int64
@@ -312,13 +711,68 @@ func (broker *rdsBroker) LastOperation(c *catalog.Catalog, id string, baseInstan
 		return planErr
 	}
 
-	adapter, adapterErr := initializeAdapter(plan, broker.settings, c)
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, c, broker.logger)
 	if adapterErr != nil {
 		return adapterErr
 	}
 
 	var state string
-	status, _ := adapter.checkDBStatus(existingInstance)
+	var status base.InstanceState
+	var err error
+	switch operation {
+	case base.DeleteOp.String():
+		// The instance is deleted once its read replicas are gone.
+		var replicas []ReadReplica
+		broker.brokerDB.Where("instance_guid = ?", existingInstance.Uuid).Find(&replicas)
+		if len(replicas) > 0 {
+			status = broker.checkReadReplicasDeleted(adapter, replicas)
+			if status == base.InstanceGone {
+				status, err = broker.deleteDB(adapter, plan, existingInstance)
+				if err != nil {
+					broker.logger.Error("delete-db", err)
+				}
+			}
+			break
+		}
+		status, err = adapter.checkDBDeleted(existingInstance)
+		if err != nil {
+			broker.logger.Error("check-db-deleted", err)
+		}
+		if status == base.InstanceNotGone {
+			// Keep the cleanup steps that succeeded.
+			broker.brokerDB.Save(existingInstance)
+		}
+	default:
+		if (existingInstance.RestoreModifyPending || existingInstance.ExtensionsPending) && !existingInstance.ManageMasterUserPassword {
+			// Restored instances are given the password of the broker once
+			// available, and extensions are created with it. Instances whose
+			// password RDS manages read it from their secret instead.
+			password, err := existingInstance.dbUtils.getPassword(
+				existingInstance.Salt,
+				existingInstance.Password,
+				broker.settings.EncryptionKey,
+			)
+			if err != nil {
+				broker.logger.Error("get-password", err)
+				return response.NewErrorResponse(http.StatusInternalServerError, "Unable to get instance password.")
+			}
+			existingInstance.ClearPassword = password
+		}
+		status, err = adapter.checkDBStatus(existingInstance)
+		if err != nil {
+			broker.logger.Error("check-db-status", err)
+		}
+		for _, finalSnapshot := range existingInstance.blueGreenFinalSnapshots {
+			if err := broker.brokerDB.Create(finalSnapshot).Error; err != nil {
+				broker.logger.Error("save-final-snapshot", err)
+			}
+		}
+		broker.brokerDB.Save(existingInstance)
+		// Read replicas can only be created once their source is available.
+		if status == base.InstanceReady {
+			status = broker.reconcileReadReplicas(adapter, existingInstance)
+		}
+	}
 	switch status {
 	case base.InstanceInProgress:
 		state = "in progress"
@@ -328,6 +782,10 @@ func (broker *rdsBroker) LastOperation(c *catalog.Catalog, id string, baseInstan
 		state = "failed"
 	case base.InstanceNotModified:
 		state = "failed"
+	case base.InstanceGone:
+		state = "succeeded"
+		broker.brokerDB.Unscoped().Delete(existingInstance)
+		broker.brokerDB.Unscoped().Delete(&baseInstance)
 	case base.InstanceNotGone:
 		state = "failed"
 	default:
@@ -336,7 +794,7 @@ func (broker *rdsBroker) LastOperation(c *catalog.Catalog, id string, baseInstan
 	return response.NewSuccessLastOperation(state, "The service instance status is "+state)
 }
 
-func (broker *rdsBroker) BindInstance(c *catalog.Catalog, id string, bindRequest request.Request, baseInstance base.Instance) response.Response {
+func (broker *rdsBroker) BindInstance(ctx context.Context, c *catalog.Catalog, id string, bindRequest request.Request, baseInstance base.Instance) response.Response {
 	existingInstance := NewRDSInstance()
 
 	var count int64
@@ -350,25 +808,36 @@ func (broker *rdsBroker) BindInstance(c *catalog.Catalog, id string, bindRequest
 		return planErr
 	}
 
-	password, err := existingInstance.dbUtils.getPassword(
-		existingInstance.Salt,
-		existingInstance.Password,
-		broker.settings.EncryptionKey,
-	)
-	if err != nil {
-		return response.NewErrorResponse(http.StatusInternalServerError, "Unable to get instance password.")
-	}
-
 	// Get the correct database logic depending on the type of plan.
-	adapter, adapterErr := initializeAdapter(plan, broker.settings, c)
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, c, broker.logger)
 	if adapterErr != nil {
 		return adapterErr
 	}
 
+	password, err := broker.masterPassword(adapter, existingInstance)
+	if err != nil {
+		broker.logger.Error("get-password", err)
+		return response.NewErrorResponse(http.StatusInternalServerError, "Unable to get instance password.")
+	}
+
 	var credentials map[string]string
 	// Bind the database instance to the application.
 	originalInstanceState := existingInstance.State
-	if credentials, err = adapter.bindDBToApp(existingInstance, password); err != nil {
+	originalIamUserName, originalIamPolicyARN, originalIamAccessKeyID := existingInstance.IamUserName, existingInstance.IamPolicyARN, existingInstance.IamAccessKeyID
+	credentials, err = adapter.bindDBToApp(existingInstance, password)
+
+	// If the state or the IAM credentials of the instance have changed, update
+	// it. IAM credentials are recorded as they are created, even if binding
+	// fails, so that binding again resumes from them.
+	if existingInstance.State != originalInstanceState ||
+		existingInstance.IamUserName != originalIamUserName ||
+		existingInstance.IamPolicyARN != originalIamPolicyARN ||
+		existingInstance.IamAccessKeyID != originalIamAccessKeyID {
+		broker.brokerDB.Save(existingInstance)
+	}
+
+	if err != nil {
+		broker.logger.Error("bind-db-to-app", err)
 		desc := "There was an error binding the database instance to the application."
 		if err != nil {
 			desc = desc + " Error: " + err.Error()
@@ -376,39 +845,478 @@ func (broker *rdsBroker) BindInstance(c *catalog.Catalog, id string, bindRequest
 		return response.NewErrorResponse(http.StatusBadRequest, desc)
 	}
 
-	// If the state of the instance has changed, update it.
-	if existingInstance.State != originalInstanceState {
-		broker.brokerDB.Save(existingInstance)
+	if err := broker.addReadReplicaCredentials(existingInstance, password, credentials); err != nil {
+		broker.logger.Error("read-replica-credentials", err)
+		return response.NewErrorResponse(http.StatusInternalServerError, err.Error())
 	}
 
 	return response.NewSuccessBindResponse(credentials)
 }
 
-func (broker *rdsBroker) DeleteInstance(c *catalog.Catalog, id string, baseInstance base.Instance) response.Response {
+// masterPassword returns the master password of the instance, read from its
+// secret if RDS manages it, or decrypted from the broker's database otherwise.
+func (broker *rdsBroker) masterPassword(adapter dbAdapter, i *RDSInstance) (string, error) {
+	if i.ManageMasterUserPassword {
+		return adapter.getManagedMasterPassword(i)
+	}
+	return i.dbUtils.getPassword(i.Salt, i.Password, broker.settings.EncryptionKey)
+}
+
+// errDeletionProtected is the response to deleting an instance with deletion
+// protection.
+var errDeletionProtected = response.NewErrorResponse(
+	http.StatusUnprocessableEntity,
+	"The instance has deletion protection enabled. Turn it off with cf update-service -c '{\"deletion_protection\": false}' before deleting the instance.",
+)
+
+func (broker *rdsBroker) DeleteInstance(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance) response.Response {
 	existingInstance := NewRDSInstance()
 	var count int64
 	broker.brokerDB.Where("uuid = ?", id).First(existingInstance).Count(&count)
 	if count == 0 {
 		return response.NewErrorResponse(http.StatusNotFound, "Instance not found")
 	}
+	if existingInstance.DeletionProtection {
+		return errDeletionProtected
+	}
 
 	plan, planErr := c.RdsService.FetchPlan(baseInstance.PlanID)
 	if planErr != nil {
 		return planErr
 	}
 
-	adapter, adapterErr := initializeAdapter(plan, broker.settings, c)
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, c, broker.logger)
 	if adapterErr != nil {
 		return adapterErr
 	}
-	// Delete the database instance.
-	if status, err := adapter.deleteDB(existingInstance); status == base.InstanceNotGone {
+
+	// Remove the read replicas first, so that none of them is promoted to a
+	// standalone instance when their source is deleted. The instance itself
+	// is deleted once all of them are gone.
+	replicasGone, resp := broker.deleteReadReplicas(adapter, existingInstance)
+	if resp != nil {
+		return resp
+	}
+	if !replicasGone {
+		existingInstance.State = base.InstanceInProgress
+		broker.brokerDB.Save(existingInstance)
+		return response.NewAsyncOperationResponse(base.DeleteOp.String())
+	}
+
+	status, err := broker.deleteDB(adapter, plan, existingInstance)
+	switch status {
+	case base.InstanceGone:
+		broker.brokerDB.Unscoped().Delete(existingInstance)
+		return response.SuccessDeleteResponse
+	case base.InstanceInProgress:
+		existingInstance.State = status
+		broker.brokerDB.Save(existingInstance)
+		return response.NewAsyncOperationResponse(base.DeleteOp.String())
+	default:
+		// Keep the cleanup steps that succeeded.
+		broker.brokerDB.Save(existingInstance)
 		desc := "There was an error deleting the instance."
 		if err != nil {
+			broker.logger.Error("delete-db", err)
 			desc = desc + " Error: " + err.Error()
 		}
 		return response.NewErrorResponse(http.StatusBadRequest, desc)
 	}
-	broker.brokerDB.Unscoped().Delete(existingInstance)
-	return response.SuccessDeleteResponse
+}
+
+// deleteDB requests the deletion of the database instance, with a final
+// snapshot unless its plan skips it.
+func (broker *rdsBroker) deleteDB(adapter dbAdapter, plan catalog.RDSPlan, i *RDSInstance) (base.InstanceState, error) {
+	// Record the final snapshot before requesting it, so that it can always
+	// be found again.
+	var finalSnapshot *FinalSnapshot
+	finalSnapshotIdentifier := ""
+	if !i.skipFinalSnapshot(plan) {
+		finalSnapshotIdentifier = i.finalSnapshotIdentifier(broker.settings, time.Now())
+		finalSnapshot = newFinalSnapshot(i, finalSnapshotIdentifier)
+		if err := broker.brokerDB.Create(finalSnapshot).Error; err != nil {
+			broker.logger.Error("save-final-snapshot", err)
+			return base.InstanceNotGone, err
+		}
+	}
+
+	status, err := adapter.deleteDB(i, finalSnapshotIdentifier)
+	if finalSnapshot != nil && status != base.InstanceInProgress {
+		// No snapshot was taken.
+		broker.brokerDB.Delete(finalSnapshot)
+	}
+	if status == base.InstanceInProgress {
+		broker.logger.Info("delete-db-requested", lager.Data{
+			"database":       i.Database,
+			"final-snapshot": finalSnapshotIdentifier,
+		})
+	}
+	return status, err
+}
+
+// reconcileReadReplicas creates or deletes read replicas of the instance until
+// it has the number requested, and reports whether all of them are available.
+func (broker *rdsBroker) reconcileReadReplicas(adapter dbAdapter, i *RDSInstance) base.InstanceState {
+	var replicas []ReadReplica
+	broker.brokerDB.Where("instance_guid = ?", i.Uuid).Order("identifier").Find(&replicas)
+
+	// Remove the replicas beyond the number requested, last first.
+	for int64(len(replicas)) > i.ReadReplicas {
+		replica := replicas[len(replicas)-1]
+		status, err := adapter.deleteReadReplica(&replica)
+		if status == base.InstanceNotGone {
+			broker.logger.Error("delete-read-replica", err)
+			return base.InstanceNotModified
+		}
+		broker.logger.Info("delete-read-replica-requested", lager.Data{"replica": replica.Identifier})
+		broker.brokerDB.Delete(&replica)
+		replicas = replicas[:len(replicas)-1]
+	}
+
+	for number := len(replicas) + 1; int64(number) <= i.ReadReplicas; number++ {
+		replica := newReadReplica(i, number)
+		status, err := adapter.createReadReplica(i, replica)
+		if status == base.InstanceNotCreated {
+			broker.logger.Error("create-read-replica", err)
+			return base.InstanceNotCreated
+		}
+		broker.logger.Info("create-read-replica-requested", lager.Data{"replica": replica.Identifier})
+		replica.State = status
+		if err := broker.brokerDB.Create(replica).Error; err != nil {
+			broker.logger.Error("save-read-replica", err)
+			return base.InstanceNotCreated
+		}
+		replicas = append(replicas, *replica)
+	}
+
+	state := base.InstanceReady
+	for k := range replicas {
+		status, err := adapter.checkReadReplicaStatus(&replicas[k])
+		if err != nil {
+			broker.logger.Error("check-read-replica-status", err)
+		}
+		replicas[k].State = status
+		broker.brokerDB.Save(&replicas[k])
+		switch status {
+		case base.InstanceReady:
+		case base.InstanceNotCreated:
+			return base.InstanceNotCreated
+		default:
+			state = base.InstanceInProgress
+		}
+	}
+	return state
+}
+
+// deleteReadReplicas requests the deletion of all of the read replicas of the
+// instance, and reports whether all of them are already gone. The replicas
+// being deleted are kept until checkReadReplicasDeleted finds them gone.
+func (broker *rdsBroker) deleteReadReplicas(adapter dbAdapter, i *RDSInstance) (bool, response.Response) {
+	var replicas []ReadReplica
+	broker.brokerDB.Where("instance_guid = ?", i.Uuid).Find(&replicas)
+	gone := true
+	for k := range replicas {
+		replica := &replicas[k]
+		status, err := adapter.deleteReadReplica(replica)
+		switch status {
+		case base.InstanceGone:
+			broker.brokerDB.Delete(replica)
+		case base.InstanceNotGone:
+			desc := "There was an error deleting the read replicas of the instance."
+			if err != nil {
+				broker.logger.Error("delete-read-replica", err)
+				desc = desc + " Error: " + err.Error()
+			}
+			return false, response.NewErrorResponse(http.StatusBadRequest, desc)
+		default:
+			broker.logger.Info("delete-read-replica-requested", lager.Data{"replica": replica.Identifier})
+			replica.State = base.InstanceNotGone
+			broker.brokerDB.Save(replica)
+			gone = false
+		}
+	}
+	return gone, nil
+}
+
+// checkReadReplicasDeleted removes the read replicas that are gone, and
+// reports whether all of them are.
+func (broker *rdsBroker) checkReadReplicasDeleted(adapter dbAdapter, replicas []ReadReplica) base.InstanceState {
+	state := base.InstanceGone
+	for k := range replicas {
+		replica := &replicas[k]
+		status, err := adapter.checkReadReplicaStatus(replica)
+		if err != nil {
+			broker.logger.Error("check-read-replica-status", err)
+		}
+		if status != base.InstanceGone {
+			state = base.InstanceInProgress
+			continue
+		}
+		broker.logger.Info("read-replica-deleted", lager.Data{"replica": replica.Identifier})
+		broker.brokerDB.Delete(replica)
+	}
+	return state
+}
+
+// addReadReplicaCredentials adds the URIs of the available read replicas of
+// the instance to its credentials as a comma-separated "replica_uris".
+// Credentials without a password, those of IAM database authentication, get
+// the endpoints of the replicas as "replica_hosts" instead.
+func (broker *rdsBroker) addReadReplicaCredentials(i *RDSInstance, password string, credentials map[string]string) error {
+	var replicas []ReadReplica
+	broker.brokerDB.Where("instance_guid = ?", i.Uuid).Order("identifier").Find(&replicas)
+	if len(replicas) == 0 {
+		return nil
+	}
+	if _, ok := credentials["password"]; !ok {
+		var hosts []string
+		for _, replica := range replicas {
+			if replica.Host != "" {
+				hosts = append(hosts, fmt.Sprintf("%s:%d", replica.Host, replica.Port))
+			}
+		}
+		credentials["replica_hosts"] = strings.Join(hosts, ",")
+		return nil
+	}
+	uris, err := readReplicaURIs(i, password, replicas)
+	if err != nil {
+		return err
+	}
+	credentials["replica_uris"] = strings.Join(uris, ",")
+	return nil
+}
+
+func (broker *rdsBroker) DescribeInstance(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance) (base.InstanceDetail, response.Response) {
+	existingInstance := NewRDSInstance()
+	var count int64
+	broker.brokerDB.Where("uuid = ?", id).First(existingInstance).Count(&count)
+	if count == 0 {
+		return base.InstanceDetail{}, response.NewErrorResponse(http.StatusNotFound, "Instance not found")
+	}
+
+	plan, planErr := c.RdsService.FetchPlan(baseInstance.PlanID)
+	if planErr != nil {
+		return base.InstanceDetail{}, planErr
+	}
+
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, c, broker.logger)
+	if adapterErr != nil {
+		return base.InstanceDetail{}, adapterErr
+	}
+
+	detail := base.InstanceDetail{}
+	if dbInstance, err := adapter.describeDB(existingInstance); err != nil {
+		detail.AWSError = err.Error()
+		if awsErr, ok := err.(awserr.Error); ok {
+			detail.ResourceGone = awsErr.Code() == rds.ErrCodeDBInstanceNotFoundFault
+		}
+	} else {
+		detail.AWS = dbInstance
+	}
+
+	existingInstance.Password = ""
+	existingInstance.Salt = ""
+	existingInstance.IamSecretAccessKey = ""
+	detail.Instance = existingInstance
+	return detail, nil
+}
+
+func (broker *rdsBroker) SetInstanceState(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance, state base.InstanceState) response.Response {
+	existingInstance := NewRDSInstance()
+	var count int64
+	broker.brokerDB.Where("uuid = ?", id).First(existingInstance).Count(&count)
+	if count == 0 {
+		return response.NewErrorResponse(http.StatusNotFound, "Instance not found")
+	}
+
+	err := broker.brokerDB.Model(existingInstance).Update("state", state).Error
+	if err != nil {
+		broker.logger.Error("save-instance", err)
+		return response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+	return nil
+}
+
+// InstanceCredentials returns the credentials of the instance as recorded by
+// the broker, without changing the instance. Only the master password of
+// instances whose password RDS manages is read from AWS.
+func (broker *rdsBroker) InstanceCredentials(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance) (map[string]string, response.Response) {
+	existingInstance := NewRDSInstance()
+	var count int64
+	broker.brokerDB.Where("uuid = ?", id).First(existingInstance).Count(&count)
+	if count == 0 {
+		return nil, response.NewErrorResponse(http.StatusNotFound, "Instance not found")
+	}
+
+	plan, planErr := c.RdsService.FetchPlan(existingInstance.PlanID)
+	if planErr != nil {
+		return nil, planErr
+	}
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, c, broker.logger)
+	if adapterErr != nil {
+		return nil, adapterErr
+	}
+
+	password, err := broker.masterPassword(adapter, existingInstance)
+	if err != nil {
+		broker.logger.Error("get-password", err)
+		return nil, response.NewErrorResponse(http.StatusInternalServerError, "Unable to get instance password.")
+	}
+
+	credentials, err := existingInstance.getCredentials(password)
+	if err != nil {
+		broker.logger.Error("get-credentials", err)
+		return nil, response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+	if err := broker.addReadReplicaCredentials(existingInstance, password, credentials); err != nil {
+		broker.logger.Error("read-replica-credentials", err)
+		return nil, response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+	return credentials, nil
+}
+
+// CreateSnapshot takes a manual snapshot of the instance, tagged like the
+// instance, and deletes the oldest manual snapshots beyond the retention
+// count.
+func (broker *rdsBroker) CreateSnapshot(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance, name string) (base.Snapshot, response.Response) {
+	if err := base.ValidateSnapshotName(name); err != nil {
+		return base.Snapshot{}, response.NewErrorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	existingInstance := NewRDSInstance()
+	var count int64
+	broker.brokerDB.Where("uuid = ?", id).First(existingInstance).Count(&count)
+	if count == 0 {
+		return base.Snapshot{}, response.NewErrorResponse(http.StatusNotFound, "Instance not found")
+	}
+	if existingInstance.State != base.InstanceReady {
+		return base.Snapshot{}, response.NewErrorResponse(http.StatusConflict, "Snapshots can only be taken of instances that are ready.")
+	}
+
+	plan, planErr := c.RdsService.FetchPlan(baseInstance.PlanID)
+	if planErr != nil {
+		return base.Snapshot{}, planErr
+	}
+
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, c, broker.logger)
+	if adapterErr != nil {
+		return base.Snapshot{}, adapterErr
+	}
+
+	tags, err := broker.tagManager.GenerateTags(
+		brokertags.Update,
+		c.RdsService.Name,
+		plan.Name,
+		brokertags.ResourceGUIDs{
+			InstanceGUID:     id,
+			SpaceGUID:        baseInstance.SpaceGUID,
+			OrganizationGUID: baseInstance.OrganizationGUID,
+		},
+		false,
+	)
+	if err != nil {
+		broker.logger.Error("generate-tags", err)
+		return base.Snapshot{}, response.NewErrorResponse(http.StatusInternalServerError, "There was an error generating the tags. Error: "+err.Error())
+	}
+	existingInstance.setTags(plan, tags)
+
+	snapshot, err := adapter.createSnapshot(existingInstance, name, existingInstance.Tags)
+	if err != nil {
+		return base.Snapshot{}, response.NewErrorResponse(http.StatusBadRequest, "There was an error taking the snapshot. Error: "+err.Error())
+	}
+
+	// The snapshot is taken even if the retention count cannot be enforced;
+	// the oldest snapshots are deleted the next time.
+	snapshots, err := adapter.listSnapshots(existingInstance)
+	if err != nil {
+		broker.logger.Error("list-snapshots", err)
+		return snapshot, nil
+	}
+	for _, expired := range base.ExpiredSnapshots(snapshots, broker.settings.ManualSnapshotRetention) {
+		if err := adapter.deleteSnapshot(expired.Name); err != nil {
+			broker.logger.Error("delete-expired-snapshot", err, lager.Data{"snapshot": expired.Name})
+		}
+	}
+	return snapshot, nil
+}
+
+// ListSnapshots lists the automated and manual snapshots of the instance.
+func (broker *rdsBroker) ListSnapshots(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance) ([]base.Snapshot, response.Response) {
+	existingInstance := NewRDSInstance()
+	var count int64
+	broker.brokerDB.Where("uuid = ?", id).First(existingInstance).Count(&count)
+	if count == 0 {
+		return nil, response.NewErrorResponse(http.StatusNotFound, "Instance not found")
+	}
+
+	plan, planErr := c.RdsService.FetchPlan(baseInstance.PlanID)
+	if planErr != nil {
+		return nil, planErr
+	}
+
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, c, broker.logger)
+	if adapterErr != nil {
+		return nil, adapterErr
+	}
+
+	snapshots, err := adapter.listSnapshots(existingInstance)
+	if err != nil {
+		return nil, response.NewErrorResponse(http.StatusBadRequest, "There was an error listing the snapshots. Error: "+err.Error())
+	}
+	return snapshots, nil
+}
+
+// SuspendInstance stops the instance ahead of its delayed deletion. Instances
+// with deletion protection are refused like when they are deleted. Instances
+// that are not ready or have read replicas, which RDS cannot stop, are left
+// running, as are instances that fail to stop.
+func (broker *rdsBroker) SuspendInstance(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance) response.Response {
+	existingInstance := NewRDSInstance()
+	var count int64
+	broker.brokerDB.Where("uuid = ?", id).First(existingInstance).Count(&count)
+	if count == 0 {
+		return response.NewErrorResponse(http.StatusNotFound, "Instance not found")
+	}
+	if existingInstance.DeletionProtection {
+		return errDeletionProtected
+	}
+	if existingInstance.State != base.InstanceReady || existingInstance.ReadReplicas > 0 {
+		return nil
+	}
+
+	plan, planErr := c.RdsService.FetchPlan(baseInstance.PlanID)
+	if planErr != nil {
+		return planErr
+	}
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, c, broker.logger)
+	if adapterErr != nil {
+		return adapterErr
+	}
+	if err := adapter.stopDB(existingInstance); err != nil {
+		broker.logger.Error("stop-db", err)
+	}
+	return nil
+}
+
+// ResumeInstance starts the instance again if it was stopped.
+func (broker *rdsBroker) ResumeInstance(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance) response.Response {
+	existingInstance := NewRDSInstance()
+	var count int64
+	broker.brokerDB.Where("uuid = ?", id).First(existingInstance).Count(&count)
+	if count == 0 {
+		return response.NewErrorResponse(http.StatusNotFound, "Instance not found")
+	}
+
+	plan, planErr := c.RdsService.FetchPlan(baseInstance.PlanID)
+	if planErr != nil {
+		return planErr
+	}
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, c, broker.logger)
+	if adapterErr != nil {
+		return adapterErr
+	}
+	if err := adapter.startDB(existingInstance); err != nil {
+		return response.NewErrorResponse(http.StatusBadRequest, "There was an error starting the instance. Error: "+err.Error())
+	}
+	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/18F/aws-broker/catalog"
 	"github.com/18F/aws-broker/config"
 	"github.com/18F/aws-broker/helpers/request"
 	"github.com/aws/aws-sdk-go/aws"
@@ -38,7 +39,150 @@ func TestValidate(t *testing.T) {
 		},
 		"invalid storage type": {
 			options: Options{
-				StorageType: "io1",
+				StorageType: "st1",
+			},
+			settings:    &config.Settings{},
+			expectedErr: true,
+		},
+		"max allocated storage exceeding maximum": {
+			options: Options{
+				MaxAllocatedStorage: aws.Int64(2048),
+			},
+			settings: &config.Settings{
+				MaxAllocatedStorage: 1024,
+			},
+			expectedErr: true,
+		},
+		"negative iops": {
+			options: Options{
+				Iops: aws.Int64(-1),
+			},
+			settings:    &config.Settings{},
+			expectedErr: true,
+		},
+		"invalid monitoring interval": {
+			options: Options{
+				MonitoringInterval: aws.Int64(2),
+			},
+			settings:    &config.Settings{},
+			expectedErr: true,
+		},
+		"invalid performance insights retention period": {
+			options: Options{
+				PerformanceInsightsRetention: 14,
+			},
+			settings:    &config.Settings{},
+			expectedErr: true,
+		},
+		"overlapping windows": {
+			options: Options{
+				PreferredMaintenanceWindow: "mon:03:00-mon:04:00",
+				PreferredBackupWindow:      "03:30-04:30",
+			},
+			settings:    &config.Settings{},
+			expectedErr: true,
+		},
+		"empty db parameter": {
+			options: Options{
+				DBParameters: map[string]string{"work_mem": ""},
+			},
+			settings:    &config.Settings{},
+			expectedErr: true,
+		},
+		"invalid snapshot name": {
+			options: Options{
+				CreateSnapshot: "pre--migration",
+			},
+			settings:    &config.Settings{},
+			expectedErr: true,
+		},
+		"snapshot name": {
+			options: Options{
+				CreateSnapshot: "pre-migration",
+			},
+			settings:    &config.Settings{},
+			expectedErr: false,
+		},
+		"empty extension": {
+			options: Options{
+				Extensions: []string{""},
+			},
+			settings:    &config.Settings{},
+			expectedErr: true,
+		},
+		"snapshot ID": {
+			options: Options{
+				SnapshotID: "snapshot-1",
+			},
+			settings:    &config.Settings{},
+			expectedErr: false,
+		},
+		"snapshot ID and source instance": {
+			options: Options{
+				SnapshotID:         "snapshot-1",
+				SourceInstanceGUID: "instance-1",
+			},
+			settings:    &config.Settings{},
+			expectedErr: true,
+		},
+		"restore from instance at latest time": {
+			options: Options{
+				RestoreFromInstance: "instance-1",
+				RestoreTime:         "latest",
+			},
+			settings:    &config.Settings{},
+			expectedErr: false,
+		},
+		"restore from instance at RFC3339 time": {
+			options: Options{
+				RestoreFromInstance: "instance-1",
+				RestoreTime:         "2024-01-02T03:04:05Z",
+			},
+			settings:    &config.Settings{},
+			expectedErr: false,
+		},
+		"restore from instance without time": {
+			options: Options{
+				RestoreFromInstance: "instance-1",
+			},
+			settings:    &config.Settings{},
+			expectedErr: true,
+		},
+		"restore from instance at invalid time": {
+			options: Options{
+				RestoreFromInstance: "instance-1",
+				RestoreTime:         "yesterday",
+			},
+			settings:    &config.Settings{},
+			expectedErr: true,
+		},
+		"restore time without instance": {
+			options: Options{
+				RestoreTime: "latest",
+			},
+			settings:    &config.Settings{},
+			expectedErr: true,
+		},
+		"clone from instance": {
+			options: Options{
+				CloneFrom: "instance-1",
+			},
+			settings:    &config.Settings{},
+			expectedErr: false,
+		},
+		"clone from instance and snapshot ID": {
+			options: Options{
+				SnapshotID: "snapshot-1",
+				CloneFrom:  "instance-1",
+			},
+			settings:    &config.Settings{},
+			expectedErr: true,
+		},
+		"restore from instance and snapshot ID": {
+			options: Options{
+				SnapshotID:          "snapshot-1",
+				RestoreFromInstance: "instance-1",
+				RestoreTime:         "latest",
 			},
 			settings:    &config.Settings{},
 			expectedErr: true,
@@ -196,6 +340,18 @@ func TestParseModifyOptionsFromRequest(t *testing.T) {
 			},
 			expectErr: true,
 		},
+		"snapshot ID is rejected": {
+			broker: &rdsBroker{
+				settings: &config.Settings{},
+			},
+			modifyRequest: request.Request{
+				RawParameters: []byte(`{"snapshot_id": "snapshot-1"}`),
+			},
+			expectedOptions: Options{
+				SnapshotID: "snapshot-1",
+			},
+			expectErr: true,
+		},
 		"throws error on invalid JSON": {
 			broker: &rdsBroker{
 				settings: &config.Settings{},
@@ -228,3 +384,43 @@ func TestParseModifyOptionsFromRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckDBParameters(t *testing.T) {
+	service := catalog.RDSService{
+		AllowedDBParameters: map[string][]string{
+			"postgres": {"work_mem", "max_connections"},
+		},
+	}
+	if resp := checkDBParameters(service, "postgres", map[string]string{"work_mem": "4096"}); resp != nil {
+		t.Errorf("unexpected response %v", resp)
+	}
+	if resp := checkDBParameters(service, "postgres", nil); resp != nil {
+		t.Errorf("unexpected response %v", resp)
+	}
+	if resp := checkDBParameters(service, "postgres", map[string]string{"shared_buffers": "1024"}); resp == nil {
+		t.Error("expected shared_buffers not to be allowed")
+	}
+	if resp := checkDBParameters(service, "mysql", map[string]string{"max_connections": "200"}); resp == nil {
+		t.Error("expected max_connections not to be allowed for mysql")
+	}
+}
+
+func TestCheckExtensions(t *testing.T) {
+	service := catalog.RDSService{
+		AllowedExtensions: map[string][]string{
+			"15": {"postgis", "pgcrypto"},
+		},
+	}
+	if resp := checkExtensions(service, "15.5", []string{"postgis"}); resp != nil {
+		t.Errorf("unexpected response %v", resp)
+	}
+	if resp := checkExtensions(service, "15", nil); resp != nil {
+		t.Errorf("unexpected response %v", resp)
+	}
+	if resp := checkExtensions(service, "15", []string{"plperl"}); resp == nil {
+		t.Error("expected plperl not to be allowed")
+	}
+	if resp := checkExtensions(service, "16", []string{"postgis"}); resp == nil {
+		t.Error("expected postgis not to be allowed for PostgreSQL 16")
+	}
+}
@@ -0,0 +1,158 @@
+package rds
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/jinzhu/gorm"
+
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/catalog"
+	"github.com/18F/aws-broker/common"
+	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/helpers/logging"
+	"github.com/18F/aws-broker/taskqueue"
+)
+
+// credentialRotationTaskID is the tag of the scheduled rotation of master
+// credentials in the task queue.
+const credentialRotationTaskID = "rds-credential-rotation"
+
+// ScheduleCredentialRotation schedules the rotation of the master credentials
+// of the instances that opted in, in plans with a rotation period. Only one
+// broker sharing the database rotates them at a time, so that an instance is
+// never given two new passwords at once.
+func ScheduleCredentialRotation(q *taskqueue.QueueManager, brokerDB *gorm.DB, settings *config.Settings, c *catalog.Catalog, logger lager.Logger) error {
+	if q.IsTaskScheduled(credentialRotationTaskID) {
+		return nil
+	}
+	logger = logger.Session("rds-credential-rotation")
+	_, err := q.ScheduleTask(settings.CredentialRotationSchedule, credentialRotationTaskID, func() {
+		ran, err := common.RunExclusive(brokerDB, credentialRotationTaskID, func() {
+			rotateDueCredentials(context.Background(), brokerDB, settings, c, time.Now(), logger)
+		})
+		if err != nil {
+			logger.Error("lock-task", err)
+		} else if !ran {
+			logger.Info("task-running-elsewhere")
+		}
+	})
+	return err
+}
+
+// credentialRotationDue reports whether the master credentials of a ready
+// instance that opted in are older than the rotation period of its plan.
+// Credentials that were never rotated date from the creation of the instance,
+// and passwords that RDS manages are rotated by Secrets Manager.
+func credentialRotationDue(i *RDSInstance, plan catalog.RDSPlan, now time.Time) bool {
+	if plan.CredentialRotationDays <= 0 || !i.ScheduledCredentialRotation || i.State != base.InstanceReady || i.ManageMasterUserPassword {
+		return false
+	}
+	last := i.CreatedAt
+	if i.CredentialsRotatedAt != nil {
+		last = *i.CredentialsRotatedAt
+	}
+	return !now.Before(last.AddDate(0, 0, int(plan.CredentialRotationDays)))
+}
+
+// rotateDueCredentials rotates the master credentials of the instances that
+// are due and returns the number of instances rotated. An instance that fails
+// to rotate is retried the next time.
+func rotateDueCredentials(ctx context.Context, brokerDB *gorm.DB, settings *config.Settings, c *catalog.Catalog, now time.Time, logger lager.Logger) int {
+	instances := []RDSInstance{}
+	if err := brokerDB.Where("state = ?", base.InstanceReady).Find(&instances).Error; err != nil {
+		logger.Error("find-instances", err)
+		return 0
+	}
+
+	rotated := 0
+	for n := range instances {
+		i := &instances[n]
+		plan, resp := c.RdsService.FetchPlan(i.PlanID)
+		if resp != nil || !credentialRotationDue(i, plan, now) {
+			continue
+		}
+		if err := rotateCredentials(ctx, brokerDB, settings, c, plan, i, now, logger); err != nil {
+			logger.Error("rotate-credentials", err, lager.Data{logging.InstanceGUIDKey: i.Uuid})
+			continue
+		}
+		logger.Info("credentials-rotated", lager.Data{logging.InstanceGUIDKey: i.Uuid, "database": i.Database})
+		rotated++
+	}
+	return rotated
+}
+
+// rotateCredentials generates new master credentials for the instance,
+// applies the password to its database and records them with the time of the
+// rotation. The new credentials are saved as pending before they are applied,
+// so that the password AWS may have applied is never lost, and pending
+// credentials of an interrupted rotation are applied again instead of new
+// ones. Bindings share the master credentials, so apps lose access to the
+// database until they are bound again. The IAM keys of the instance have a
+// salt of their own and are unaffected.
+func rotateCredentials(ctx context.Context, brokerDB *gorm.DB, settings *config.Settings, c *catalog.Catalog, plan catalog.RDSPlan, i *RDSInstance, now time.Time, logger lager.Logger) error {
+	adapter, resp := initializeAdapter(ctx, plan, settings, c, logger)
+	if resp != nil {
+		return errors.New("unable to initialize the adapter of the plan")
+	}
+
+	i.dbUtils = &RDSDatabaseUtils{}
+	if i.PendingPassword == "" {
+		if err := i.generateCredentials(settings); err != nil {
+			return err
+		}
+		i.PendingSalt = i.Salt
+		i.PendingPassword = i.Password
+		// Only the credentials are updated, so that requests handled
+		// meanwhile are not overwritten.
+		err := brokerDB.Model(i).Updates(map[string]interface{}{
+			"pending_salt":     i.PendingSalt,
+			"pending_password": i.PendingPassword,
+		}).Error
+		if err != nil {
+			return err
+		}
+	} else {
+		password, err := i.dbUtils.getPassword(i.PendingSalt, i.PendingPassword, settings.EncryptionKey)
+		if err != nil {
+			return err
+		}
+		i.Salt = i.PendingSalt
+		i.Password = i.PendingPassword
+		i.ClearPassword = password
+	}
+	if err := adapter.rotateMasterPassword(i); err != nil {
+		return err
+	}
+
+	i.CredentialsRotatedAt = &now
+	i.PendingSalt = ""
+	i.PendingPassword = ""
+	return brokerDB.Model(i).Updates(map[string]interface{}{
+		"salt":                   i.Salt,
+		"password":               i.Password,
+		"pending_salt":           i.PendingSalt,
+		"pending_password":       i.PendingPassword,
+		"credentials_rotated_at": i.CredentialsRotatedAt,
+	}).Error
+}
+
+// rotateMasterPassword applies the new master password of the instance to its
+// database instance. Read replicas follow the password of their source.
+func (d *dedicatedDBAdapter) rotateMasterPassword(i *RDSInstance) error {
+	_, err := d.rds.ModifyDBInstance(&rds.ModifyDBInstanceInput{
+		DBInstanceIdentifier: aws.String(i.Database),
+		MasterUserPassword:   aws.String(i.ClearPassword),
+		ApplyImmediately:     aws.Bool(true),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "modify-db-instance", err)
+		return err
+	}
+	d.logger.Info("master-password-rotated", lager.Data{"database": i.Database})
+	return nil
+}
@@ -0,0 +1,186 @@
+package rds
+
+import (
+	"context"
+	"crypto/aes"
+	"errors"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/aws/aws-sdk-go/aws"
+
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/catalog"
+	"github.com/18F/aws-broker/common"
+	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/db"
+	"github.com/18F/aws-broker/helpers"
+)
+
+func TestCredentialRotationDue(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	rotatedAt := now.AddDate(0, 0, -30)
+	plan := catalog.RDSPlan{CredentialRotationDays: 90}
+	testCases := map[string]struct {
+		instance *RDSInstance
+		plan     catalog.RDSPlan
+		expected bool
+	}{
+		"never rotated, created before the period": {
+			instance: &RDSInstance{Instance: base.Instance{State: base.InstanceReady, CreatedAt: now.AddDate(0, 0, -90)}, ScheduledCredentialRotation: true},
+			plan:     plan,
+			expected: true,
+		},
+		"never rotated, created within the period": {
+			instance: &RDSInstance{Instance: base.Instance{State: base.InstanceReady, CreatedAt: now.AddDate(0, 0, -89)}, ScheduledCredentialRotation: true},
+			plan:     plan,
+			expected: false,
+		},
+		"rotated within the period": {
+			instance: &RDSInstance{
+				Instance:                    base.Instance{State: base.InstanceReady, CreatedAt: now.AddDate(-1, 0, 0)},
+				CredentialsRotatedAt:        &rotatedAt,
+				ScheduledCredentialRotation: true,
+			},
+			plan:     plan,
+			expected: false,
+		},
+		"not opted in": {
+			instance: &RDSInstance{Instance: base.Instance{State: base.InstanceReady, CreatedAt: now.AddDate(-1, 0, 0)}},
+			plan:     plan,
+			expected: false,
+		},
+		"no rotation period": {
+			instance: &RDSInstance{Instance: base.Instance{State: base.InstanceReady, CreatedAt: now.AddDate(-1, 0, 0)}, ScheduledCredentialRotation: true},
+			plan:     catalog.RDSPlan{},
+			expected: false,
+		},
+		"instance in progress": {
+			instance: &RDSInstance{Instance: base.Instance{State: base.InstanceInProgress, CreatedAt: now.AddDate(-1, 0, 0)}, ScheduledCredentialRotation: true},
+			plan:     plan,
+			expected: false,
+		},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if due := credentialRotationDue(test.instance, test.plan, now); due != test.expected {
+				t.Fatalf("expected %t, got %t", test.expected, due)
+			}
+		})
+	}
+}
+
+func TestRotateDueCredentials(t *testing.T) {
+	brokerDB, err := db.InternalDBInit(&common.DBConfig{DbType: "sqlite3", DbName: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrate(brokerDB, lagertest.NewTestLogger("test")); err != nil {
+		t.Fatal(err)
+	}
+	settings := &config.Settings{Environment: "test", EncryptionKey: "12345678901234567890123456789012"}
+	plan := catalog.RDSPlan{CredentialRotationDays: 90}
+	plan.ID = "plan"
+	c := &catalog.Catalog{}
+	c.RdsService.Plans = []catalog.RDSPlan{plan}
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	utils := &RDSDatabaseUtils{}
+
+	newInstance := func(uuid string, scheduled bool) *RDSInstance {
+		i := &RDSInstance{dbUtils: utils, ScheduledCredentialRotation: scheduled}
+		i.Uuid = uuid
+		i.PlanID = plan.ID
+		i.State = base.InstanceReady
+		i.CreatedAt = now.AddDate(-1, 0, 0)
+		if err := i.generateCredentials(settings); err != nil {
+			t.Fatal(err)
+		}
+		return i
+	}
+	scheduled := newInstance("scheduled", true)
+	scheduled.IamSecretAccessKeySalt = helpers.GenerateSalt(aes.BlockSize)
+	iamKey, _, err := utils.generatePassword(scheduled.IamSecretAccessKeySalt, "secret-access-key", settings.EncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduled.IamSecretAccessKey = iamKey
+	interrupted := newInstance("interrupted", true)
+	pendingSalt, pendingPassword, pendingClearPassword, err := utils.generateCredentials(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interrupted.PendingSalt = pendingSalt
+	interrupted.PendingPassword = pendingPassword
+	notScheduled := newInstance("not-scheduled", false)
+	for _, i := range []*RDSInstance{scheduled, interrupted, notScheduled} {
+		if err := brokerDB.Create(i).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if rotated := rotateDueCredentials(context.Background(), brokerDB, settings, c, now, lagertest.NewTestLogger("test")); rotated != 2 {
+		t.Fatalf("expected 2 instances to be rotated, got %d", rotated)
+	}
+
+	find := func(uuid string) *RDSInstance {
+		i := &RDSInstance{}
+		if err := brokerDB.Where("uuid = ?", uuid).First(i).Error; err != nil {
+			t.Fatal(err)
+		}
+		return i
+	}
+	i := find("scheduled")
+	if i.Salt == scheduled.Salt || i.Password == scheduled.Password || i.CredentialsRotatedAt == nil {
+		t.Error("expected the credentials to be rotated")
+	}
+	if i.PendingSalt != "" || i.PendingPassword != "" {
+		t.Error("expected no pending credentials")
+	}
+	secret, err := utils.getPassword(i.IamSecretAccessKeySalt, i.IamSecretAccessKey, settings.EncryptionKey)
+	if err != nil || secret != "secret-access-key" {
+		t.Errorf("expected the IAM secret access key to be unaffected, got %q (%v)", secret, err)
+	}
+
+	// The pending credentials of an interrupted rotation are applied.
+	i = find("interrupted")
+	if i.Salt != pendingSalt || i.Password != pendingPassword || i.PendingSalt != "" || i.PendingPassword != "" {
+		t.Error("expected the pending credentials to be applied")
+	}
+	password, err := utils.getPassword(i.Salt, i.Password, settings.EncryptionKey)
+	if err != nil || password != pendingClearPassword {
+		t.Errorf("expected the pending password, got %q (%v)", password, err)
+	}
+
+	i = find("not-scheduled")
+	if i.Salt != notScheduled.Salt || i.CredentialsRotatedAt != nil {
+		t.Error("expected the credentials not to be rotated")
+	}
+}
+
+func TestRotateMasterPassword(t *testing.T) {
+	client := &mockRdsClientForAdapterTests{}
+	adapter := &dedicatedDBAdapter{
+		rds:    client,
+		logger: lagertest.NewTestLogger("test"),
+	}
+	i := &RDSInstance{Database: "db-name", ClearPassword: "new-password"}
+
+	if err := adapter.rotateMasterPassword(i); err != nil {
+		t.Fatal(err)
+	}
+	if aws.StringValue(client.modifyDbInput.DBInstanceIdentifier) != "db-name" {
+		t.Errorf("unexpected instance %s", aws.StringValue(client.modifyDbInput.DBInstanceIdentifier))
+	}
+	if aws.StringValue(client.modifyDbInput.MasterUserPassword) != "new-password" {
+		t.Errorf("unexpected password %s", aws.StringValue(client.modifyDbInput.MasterUserPassword))
+	}
+	if !aws.BoolValue(client.modifyDbInput.ApplyImmediately) {
+		t.Error("expected the password to be applied immediately")
+	}
+
+	client.modifyDbErr = errors.New("fail")
+	if err := adapter.rotateMasterPassword(i); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,345 @@
+package rds
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/jinzhu/gorm"
+
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/catalog"
+	"github.com/18F/aws-broker/common"
+	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/helpers/logging"
+	"github.com/18F/aws-broker/helpers/tracing"
+	"github.com/18F/aws-broker/taskqueue"
+)
+
+// drSnapshotCopyTaskID is the tag of the scheduled copy of snapshots to the
+// DR regions of plans in the task queue.
+const drSnapshotCopyTaskID = "rds-dr-snapshot-copy"
+
+// The kinds of snapshots copied to DR regions, which are kept as long as
+// their kind of snapshot is kept in the region of the instance.
+const (
+	drSnapshotAutomated = "automated"
+	drSnapshotManual    = "manual"
+	drSnapshotFinal     = "final"
+)
+
+// DRSnapshotCopy records the copy of a snapshot of an instance in the DR
+// region of its plan.
+type DRSnapshotCopy struct {
+	SourceSnapshotIdentifier string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	InstanceGUID string `sql:"size(255)"`
+	PlanID       string `sql:"size(255)"`
+	Database     string `sql:"size(255)"`
+	SnapshotType string `sql:"size(255)"`
+
+	Region             string `sql:"size(255)"`
+	SnapshotIdentifier string `sql:"size(255)"`
+	SnapshotCreateTime time.Time
+
+	CreatedAt time.Time
+}
+
+// TableName keeps the DR copies apart from the instances they were taken of.
+func (DRSnapshotCopy) TableName() string {
+	return "rds_dr_snapshot_copies"
+}
+
+// drSnapshotCopier copies the snapshots of the instances of a plan from the
+// region of the broker to the DR region of the plan.
+type drSnapshotCopier struct {
+	plan     catalog.RDSPlan
+	settings config.Settings
+	source   rdsiface.RDSAPI
+	dr       rdsiface.RDSAPI
+	logger   lager.Logger
+}
+
+func newDRSnapshotCopier(ctx context.Context, plan catalog.RDSPlan, s *config.Settings, logger lager.Logger) *drSnapshotCopier {
+	sess := tracing.InstrumentSession(ctx, session.New())
+	return &drSnapshotCopier{
+		plan:     plan,
+		settings: *s,
+		source:   rds.New(sess, aws.NewConfig().WithRegion(s.Region)),
+		dr:       rds.New(sess, aws.NewConfig().WithRegion(plan.DRRegion)),
+		logger:   logger,
+	}
+}
+
+// ScheduleDRSnapshotCopy schedules the copy of the snapshots of the instances
+// of plans with a DR region. Runs of brokers sharing the database do not
+// overlap, so that a snapshot is copied once.
+func ScheduleDRSnapshotCopy(q *taskqueue.QueueManager, brokerDB *gorm.DB, settings *config.Settings, c *catalog.Catalog, logger lager.Logger) error {
+	if q.IsTaskScheduled(drSnapshotCopyTaskID) {
+		return nil
+	}
+	logger = logger.Session("rds-dr-snapshot-copy")
+	_, err := q.ScheduleTask(settings.DRSnapshotCopySchedule, drSnapshotCopyTaskID, func() {
+		ran, err := common.RunExclusive(brokerDB, drSnapshotCopyTaskID, func() {
+			copyDRSnapshots(context.Background(), brokerDB, settings, c, time.Now(), logger)
+		})
+		if err != nil {
+			logger.Error("lock-task", err)
+		} else if !ran {
+			logger.Info("task-running-elsewhere")
+		}
+	})
+	return err
+}
+
+// copyDRSnapshots copies the new snapshots of the dedicated instances of every
+// plan with a DR region, and deletes the copies that have expired. Only
+// dedicated instances have DB snapshots to copy.
+func copyDRSnapshots(ctx context.Context, brokerDB *gorm.DB, settings *config.Settings, c *catalog.Catalog, now time.Time, logger lager.Logger) {
+	for _, plan := range c.RdsService.Plans {
+		if plan.DRRegion == "" || plan.Adapter != "dedicated" {
+			continue
+		}
+		copier := newDRSnapshotCopier(ctx, plan, settings, logger.WithData(lager.Data{
+			logging.PlanKey: plan.ID,
+			"dr-region":     plan.DRRegion,
+		}))
+		copier.copySnapshots(brokerDB)
+		copier.deleteExpiredCopies(brokerDB, now)
+	}
+}
+
+// copySnapshots copies the available automated and manual snapshots of the
+// instances of the plan, and the final snapshots of its deleted instances,
+// that have not been copied yet. A snapshot that fails to copy is retried the
+// next time.
+func (d *drSnapshotCopier) copySnapshots(brokerDB *gorm.DB) {
+	copied, err := d.copiedSnapshots(brokerDB)
+	if err != nil {
+		d.logger.Error("find-dr-snapshot-copies", err)
+		return
+	}
+
+	instances := []RDSInstance{}
+	if err := brokerDB.Where("plan_id = ?", d.plan.ID).Find(&instances).Error; err != nil {
+		d.logger.Error("find-instances", err)
+		return
+	}
+	for _, i := range instances {
+		err := d.source.DescribeDBSnapshotsPages(&rds.DescribeDBSnapshotsInput{
+			DBInstanceIdentifier: aws.String(i.Database),
+		}, func(page *rds.DescribeDBSnapshotsOutput, lastPage bool) bool {
+			for _, snapshot := range page.DBSnapshots {
+				snapshotType := drSnapshotType(&i, snapshot)
+				if snapshotType == "" || copied[aws.StringValue(snapshot.DBSnapshotIdentifier)] {
+					continue
+				}
+				d.copySnapshot(brokerDB, i.Uuid, i.Database, snapshotType, snapshot)
+			}
+			return true
+		})
+		if err != nil {
+			logging.LogAWSError(d.logger, "describe-db-snapshots", err)
+		}
+	}
+
+	finalSnapshots := []FinalSnapshot{}
+	if err := brokerDB.Where("plan_id = ?", d.plan.ID).Find(&finalSnapshots).Error; err != nil {
+		d.logger.Error("find-final-snapshots", err)
+		return
+	}
+	for _, finalSnapshot := range finalSnapshots {
+		if copied[finalSnapshot.SnapshotIdentifier] {
+			continue
+		}
+		resp, err := d.source.DescribeDBSnapshots(&rds.DescribeDBSnapshotsInput{
+			DBSnapshotIdentifier: aws.String(finalSnapshot.SnapshotIdentifier),
+		})
+		if err != nil {
+			logging.LogAWSError(d.logger, "describe-db-snapshots", err)
+			continue
+		}
+		for _, snapshot := range resp.DBSnapshots {
+			if aws.StringValue(snapshot.Status) == "available" {
+				d.copySnapshot(brokerDB, finalSnapshot.InstanceGUID, finalSnapshot.Database, drSnapshotFinal, snapshot)
+			}
+		}
+	}
+}
+
+// copiedSnapshots returns the identifiers of the snapshots already copied.
+func (d *drSnapshotCopier) copiedSnapshots(brokerDB *gorm.DB) (map[string]bool, error) {
+	copies := []DRSnapshotCopy{}
+	if err := brokerDB.Find(&copies).Error; err != nil {
+		return nil, err
+	}
+	copied := make(map[string]bool, len(copies))
+	for _, c := range copies {
+		copied[c.SourceSnapshotIdentifier] = true
+	}
+	return copied, nil
+}
+
+// drSnapshotType returns the kind of an available snapshot of the instance
+// that is copied to the DR region, or "" for snapshots that are not copied:
+// those still being created, and manual snapshots the broker did not take on
+// request. Final snapshots are copied once the instance is deleted.
+func drSnapshotType(i *RDSInstance, snapshot *rds.DBSnapshot) string {
+	if aws.StringValue(snapshot.Status) != "available" {
+		return ""
+	}
+	switch aws.StringValue(snapshot.SnapshotType) {
+	case "automated":
+		return drSnapshotAutomated
+	case "manual":
+		if strings.HasPrefix(aws.StringValue(snapshot.DBSnapshotIdentifier), base.ManualSnapshotPrefix(i.Database)) {
+			return drSnapshotManual
+		}
+	}
+	return ""
+}
+
+// drSnapshotIdentifier is the identifier of the copy of a snapshot. The
+// identifiers of automated snapshots start with "rds:", which the identifiers
+// of manual snapshots cannot contain.
+func drSnapshotIdentifier(snapshotIdentifier string) string {
+	return strings.TrimPrefix(snapshotIdentifier, "rds:")
+}
+
+// copySnapshot copies the snapshot to the DR region, along with its tags, and
+// records the copy. A copy that already exists is one whose record failed to
+// be saved, and is recorded.
+func (d *drSnapshotCopier) copySnapshot(brokerDB *gorm.DB, instanceGUID string, database string, snapshotType string, snapshot *rds.DBSnapshot) {
+	sourceIdentifier := aws.StringValue(snapshot.DBSnapshotIdentifier)
+	input := &rds.CopyDBSnapshotInput{
+		SourceDBSnapshotIdentifier: snapshot.DBSnapshotArn,
+		TargetDBSnapshotIdentifier: aws.String(drSnapshotIdentifier(sourceIdentifier)),
+		SourceRegion:               aws.String(d.settings.Region),
+		CopyTags:                   aws.Bool(true),
+	}
+	if d.plan.DRKMSKeyID != "" {
+		input.KmsKeyId = aws.String(d.plan.DRKMSKeyID)
+	}
+	if _, err := d.dr.CopyDBSnapshot(input); err != nil {
+		if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != rds.ErrCodeDBSnapshotAlreadyExistsFault {
+			logging.LogAWSError(d.logger, "copy-db-snapshot", err)
+			return
+		}
+	}
+
+	drCopy := &DRSnapshotCopy{
+		SourceSnapshotIdentifier: sourceIdentifier,
+		InstanceGUID:             instanceGUID,
+		PlanID:                   d.plan.ID,
+		Database:                 database,
+		SnapshotType:             snapshotType,
+		Region:                   d.plan.DRRegion,
+		SnapshotIdentifier:       aws.StringValue(input.TargetDBSnapshotIdentifier),
+		SnapshotCreateTime:       aws.TimeValue(snapshot.SnapshotCreateTime),
+	}
+	if err := brokerDB.Create(drCopy).Error; err != nil {
+		d.logger.Error("save-dr-snapshot-copy", err, lager.Data{"snapshot": sourceIdentifier})
+		return
+	}
+	d.logger.Info("db-snapshot-copied", lager.Data{
+		logging.InstanceGUIDKey: instanceGUID,
+		"snapshot":              sourceIdentifier,
+		"dr-snapshot":           drCopy.SnapshotIdentifier,
+	})
+}
+
+// deleteExpiredCopies applies the retention of the region of the instances to
+// their copies in the DR region. Copies of automated snapshots are kept for
+// the backup retention period of their instance, or of the plan once the
+// instance is deleted, and the latest MANUAL_SNAPSHOT_RETENTION copies of
+// manual snapshots are kept per instance. Copies of final snapshots are kept
+// until the final snapshot is deleted from the region of the instance.
+func (d *drSnapshotCopier) deleteExpiredCopies(brokerDB *gorm.DB, now time.Time) {
+	copies := []DRSnapshotCopy{}
+	if err := brokerDB.Where("plan_id = ? AND region = ?", d.plan.ID, d.plan.DRRegion).Find(&copies).Error; err != nil {
+		d.logger.Error("find-dr-snapshot-copies", err)
+		return
+	}
+
+	manualCopies := map[string][]base.Snapshot{}
+	copiesByIdentifier := map[string]DRSnapshotCopy{}
+	for _, c := range copies {
+		switch c.SnapshotType {
+		case drSnapshotAutomated:
+			if now.After(c.SnapshotCreateTime.AddDate(0, 0, int(d.backupRetentionPeriod(brokerDB, c.InstanceGUID)))) {
+				d.deleteCopy(brokerDB, c)
+			}
+		case drSnapshotManual:
+			createdAt := c.SnapshotCreateTime
+			manualCopies[c.InstanceGUID] = append(manualCopies[c.InstanceGUID], base.Snapshot{
+				Name:      c.SnapshotIdentifier,
+				CreatedAt: &createdAt,
+				Manual:    true,
+			})
+			copiesByIdentifier[c.SnapshotIdentifier] = c
+		case drSnapshotFinal:
+			if d.sourceSnapshotDeleted(c) {
+				d.deleteCopy(brokerDB, c)
+			}
+		}
+	}
+	for _, snapshots := range manualCopies {
+		for _, expired := range base.ExpiredSnapshots(snapshots, d.settings.ManualSnapshotRetention) {
+			d.deleteCopy(brokerDB, copiesByIdentifier[expired.Name])
+		}
+	}
+}
+
+// sourceSnapshotDeleted reports whether the snapshot a copy was taken of no
+// longer exists in the region of the instance.
+func (d *drSnapshotCopier) sourceSnapshotDeleted(c DRSnapshotCopy) bool {
+	resp, err := d.source.DescribeDBSnapshots(&rds.DescribeDBSnapshotsInput{
+		DBSnapshotIdentifier: aws.String(c.SourceSnapshotIdentifier),
+	})
+	if err == nil {
+		return len(resp.DBSnapshots) == 0
+	}
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == rds.ErrCodeDBSnapshotNotFoundFault {
+		return true
+	}
+	logging.LogAWSError(d.logger, "describe-db-snapshots", err)
+	return false
+}
+
+// backupRetentionPeriod returns the backup retention period of the instance,
+// or of the plan if the instance was deleted.
+func (d *drSnapshotCopier) backupRetentionPeriod(brokerDB *gorm.DB, instanceGUID string) int64 {
+	i := RDSInstance{}
+	if brokerDB.Where("uuid = ?", instanceGUID).First(&i).Error == nil && i.BackupRetentionPeriod > 0 {
+		return i.BackupRetentionPeriod
+	}
+	return d.plan.BackupRetentionPeriod
+}
+
+// deleteCopy deletes the copy from the DR region and its record. Copies that
+// are already gone are only forgotten.
+func (d *drSnapshotCopier) deleteCopy(brokerDB *gorm.DB, c DRSnapshotCopy) {
+	_, err := d.dr.DeleteDBSnapshot(&rds.DeleteDBSnapshotInput{
+		DBSnapshotIdentifier: aws.String(c.SnapshotIdentifier),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != rds.ErrCodeDBSnapshotNotFoundFault {
+			logging.LogAWSError(d.logger, "delete-db-snapshot", err)
+			return
+		}
+	}
+	if err := brokerDB.Delete(&c).Error; err != nil {
+		d.logger.Error("delete-dr-snapshot-copy", err, lager.Data{"dr-snapshot": c.SnapshotIdentifier})
+		return
+	}
+	d.logger.Info("dr-snapshot-deleted", lager.Data{
+		logging.InstanceGUIDKey: c.InstanceGUID,
+		"dr-snapshot":           c.SnapshotIdentifier,
+	})
+}
@@ -0,0 +1,180 @@
+package rds
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/go-test/deep"
+
+	"github.com/18F/aws-broker/catalog"
+	"github.com/18F/aws-broker/common"
+	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/db"
+)
+
+func TestDRSnapshotType(t *testing.T) {
+	i := &RDSInstance{Database: "db"}
+	testCases := map[string]struct {
+		snapshot *rds.DBSnapshot
+		expected string
+	}{
+		"automated": {
+			snapshot: &rds.DBSnapshot{DBSnapshotIdentifier: aws.String("rds:db-2026-10-18-05-10"), SnapshotType: aws.String("automated"), Status: aws.String("available")},
+			expected: drSnapshotAutomated,
+		},
+		"manual": {
+			snapshot: &rds.DBSnapshot{DBSnapshotIdentifier: aws.String("db-manual-pre-migration"), SnapshotType: aws.String("manual"), Status: aws.String("available")},
+			expected: drSnapshotManual,
+		},
+		"manual not taken on request": {
+			snapshot: &rds.DBSnapshot{DBSnapshotIdentifier: aws.String("db-clone-20261018"), SnapshotType: aws.String("manual"), Status: aws.String("available")},
+		},
+		"being created": {
+			snapshot: &rds.DBSnapshot{DBSnapshotIdentifier: aws.String("rds:db-2026-10-18-05-10"), SnapshotType: aws.String("automated"), Status: aws.String("creating")},
+		},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if snapshotType := drSnapshotType(i, test.snapshot); snapshotType != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, snapshotType)
+			}
+		})
+	}
+}
+
+func TestCopyDRSnapshots(t *testing.T) {
+	brokerDB, err := db.InternalDBInit(&common.DBConfig{DbType: "sqlite3", DbName: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrate(brokerDB, lagertest.NewTestLogger("test")); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	plan := catalog.RDSPlan{DRRegion: "us-west-2", DRKMSKeyID: "dr-key", BackupRetentionPeriod: 7}
+	plan.ID = "plan"
+	i := &RDSInstance{Database: "db", BackupRetentionPeriod: 14}
+	i.Uuid = "instance"
+	i.PlanID = plan.ID
+	if err := brokerDB.Create(i).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := brokerDB.Create(&FinalSnapshot{SnapshotIdentifier: "gone-final", InstanceGUID: "gone", PlanID: plan.ID, Database: "gone"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := func(identifier string, snapshotType string, status string, createdAt time.Time) *rds.DBSnapshot {
+		return &rds.DBSnapshot{
+			DBSnapshotIdentifier: aws.String(identifier),
+			DBSnapshotArn:        aws.String("arn:" + identifier),
+			SnapshotType:         aws.String(snapshotType),
+			Status:               aws.String(status),
+			SnapshotCreateTime:   aws.Time(createdAt),
+		}
+	}
+	source := &mockRdsClientForAdapterTests{
+		describeDbSnapshotsResults: []*rds.DBSnapshot{
+			snapshot("rds:db-2026-10-03-05-10", "automated", "available", now.AddDate(0, 0, -15)),
+			snapshot("rds:db-2026-10-18-05-10", "automated", "creating", now),
+			snapshot("db-manual-first", "manual", "available", now.AddDate(0, 0, -2)),
+			snapshot("db-manual-second", "manual", "available", now.AddDate(0, 0, -1)),
+			snapshot("gone-final", "manual", "available", now.AddDate(0, 0, -1)),
+		},
+	}
+	dr := &mockRdsClientForAdapterTests{}
+	copier := &drSnapshotCopier{
+		plan:     plan,
+		settings: config.Settings{Region: "us-east-1", ManualSnapshotRetention: 1},
+		source:   source,
+		dr:       dr,
+		logger:   lagertest.NewTestLogger("test"),
+	}
+
+	copier.copySnapshots(brokerDB)
+	copied := []string{}
+	for _, input := range dr.copyDbSnapshotInputs {
+		copied = append(copied, aws.StringValue(input.TargetDBSnapshotIdentifier))
+		if aws.StringValue(input.SourceRegion) != "us-east-1" || aws.StringValue(input.KmsKeyId) != "dr-key" {
+			t.Errorf("unexpected copy %v", input)
+		}
+	}
+	if diff := deep.Equal(copied, []string{"db-2026-10-03-05-10", "db-manual-first", "db-manual-second", "gone-final"}); diff != nil {
+		t.Fatal(diff)
+	}
+
+	// Snapshots are only copied once.
+	copier.copySnapshots(brokerDB)
+	if len(dr.copyDbSnapshotInputs) != 4 {
+		t.Fatalf("expected no new copies, got %d", len(dr.copyDbSnapshotInputs))
+	}
+
+	copier.deleteExpiredCopies(brokerDB, now)
+	if diff := deep.Equal(dr.deletedDbSnapshots, []string{"db-2026-10-03-05-10", "db-manual-first"}); diff != nil {
+		t.Fatal(diff)
+	}
+	var remaining int64
+	brokerDB.Model(&DRSnapshotCopy{}).Count(&remaining)
+	if remaining != 2 {
+		t.Errorf("expected the copies of the latest manual and the final snapshots to be kept, got %d", remaining)
+	}
+
+	// The copy of a final snapshot is deleted with the final snapshot.
+	source.describeDbSnapshotsResults = source.describeDbSnapshotsResults[:4]
+	copier.deleteExpiredCopies(brokerDB, now)
+	if diff := deep.Equal(dr.deletedDbSnapshots, []string{"db-2026-10-03-05-10", "db-manual-first", "gone-final"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestCopySnapshotAlreadyExists(t *testing.T) {
+	brokerDB, err := db.InternalDBInit(&common.DBConfig{DbType: "sqlite3", DbName: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrate(brokerDB, lagertest.NewTestLogger("test")); err != nil {
+		t.Fatal(err)
+	}
+	plan := catalog.RDSPlan{DRRegion: "us-west-2"}
+	plan.ID = "plan"
+	snapshot := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("db-manual"),
+		DBSnapshotArn:        aws.String("arn:db-manual"),
+		SnapshotCreateTime:   aws.Time(time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)),
+	}
+	testCases := map[string]struct {
+		err          error
+		expectRecord bool
+	}{
+		"already copied": {
+			err:          awserr.New(rds.ErrCodeDBSnapshotAlreadyExistsFault, "exists", nil),
+			expectRecord: true,
+		},
+		"copy error": {
+			err: errors.New("fail"),
+		},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			brokerDB.Delete(&DRSnapshotCopy{})
+			copier := &drSnapshotCopier{
+				plan:     plan,
+				settings: config.Settings{Region: "us-east-1"},
+				dr:       &mockRdsClientForAdapterTests{copyDbSnapshotErr: test.err},
+				logger:   lagertest.NewTestLogger("test"),
+			}
+
+			copier.copySnapshot(brokerDB, "instance", "db", drSnapshotManual, snapshot)
+			var count int64
+			brokerDB.Model(&DRSnapshotCopy{}).Count(&count)
+			if recorded := count == 1; recorded != test.expectRecord {
+				t.Fatalf("expected the copy to be recorded: %t", test.expectRecord)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package rds
+
+import (
+	"errors"
+	"fmt"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/lib/pq"
+
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/common"
+)
+
+// preloadExtensions maps the PostgreSQL extensions whose library has to be in
+// shared_preload_libraries before they can be created to that library.
+var preloadExtensions = map[string]string{
+	"pg_cron":            "pg_cron",
+	"pg_hint_plan":       "pg_hint_plan",
+	"pg_partman":         "pg_partman_bgw",
+	"pg_stat_statements": "pg_stat_statements",
+	"pgaudit":            "pgaudit",
+	"pglogical":          "pglogical",
+}
+
+// extensionPreloadLibraries returns the libraries that the given extensions
+// need to be preloaded.
+func extensionPreloadLibraries(extensions []string) []string {
+	libraries := []string{}
+	for _, extension := range extensions {
+		if library, ok := preloadExtensions[extension]; ok {
+			libraries = append(libraries, library)
+		}
+	}
+	return libraries
+}
+
+// createExtensions creates the extensions of the instance once it is
+// available. If the broker added the libraries of the extensions to the
+// parameter group and it is waiting for a reboot, the instance is rebooted
+// first so that they are loaded. Other pending parameter changes never cause
+// a reboot.
+func (d *dedicatedDBAdapter) createExtensions(i *RDSInstance, dbInstance *rds.DBInstance) (base.InstanceState, error) {
+	if i.ExtensionsRebootPending {
+		for _, group := range dbInstance.DBParameterGroups {
+			switch aws.StringValue(group.ParameterApplyStatus) {
+			case "applying":
+				return base.InstanceInProgress, nil
+			case "pending-reboot":
+				_, err := d.rds.RebootDBInstance(&rds.RebootDBInstanceInput{
+					DBInstanceIdentifier: aws.String(i.Database),
+				})
+				if err != nil {
+					d.logger.Error("reboot-db-instance", err)
+					return base.InstanceNotModified, err
+				}
+				i.ExtensionsRebootPending = false
+				d.logger.Info("db-instance-rebooted", lager.Data{"database": i.Database})
+				return base.InstanceInProgress, nil
+			}
+		}
+		// Instances created with the parameter group load the libraries
+		// when they start.
+		i.ExtensionsRebootPending = false
+	}
+
+	if dbInstance.Endpoint == nil {
+		return base.InstanceInProgress, nil
+	}
+	i.Host = aws.StringValue(dbInstance.Endpoint.Address)
+	i.Port = aws.Int64Value(dbInstance.Endpoint.Port)
+
+	if i.ManageMasterUserPassword {
+		password, err := d.getManagedMasterPassword(i)
+		if err != nil {
+			return base.InstanceNotModified, err
+		}
+		i.ClearPassword = password
+	}
+
+	conn, err := d.openDB(common.DBConfig{
+		DbType:   i.DbType,
+		URL:      i.Host,
+		Username: i.Username,
+		Password: i.ClearPassword,
+		DbName:   i.FormatDBName(),
+		Sslmode:  "require",
+		Port:     i.Port,
+	})
+	if err != nil {
+		d.logger.Error("connect-to-instance", err)
+		return base.InstanceNotModified, errors.New("unable to connect to the instance to create its extensions")
+	}
+	defer conn.Close()
+	for _, extension := range i.Extensions {
+		statement := fmt.Sprintf("CREATE EXTENSION IF NOT EXISTS %s", pq.QuoteIdentifier(extension))
+		if err := conn.Exec(statement).Error; err != nil {
+			d.logger.Error("create-extension", err, lager.Data{"extension": extension})
+			return base.InstanceNotModified, err
+		}
+	}
+
+	i.ExtensionsPending = false
+	d.logger.Info("extensions-created", lager.Data{"database": i.Database, "extensions": i.Extensions})
+	return base.InstanceReady, nil
+}
@@ -0,0 +1,148 @@
+package rds
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/18F/aws-broker/base"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/go-test/deep"
+)
+
+func newExtensionsTestDBInstance(parameterApplyStatus string) *rds.DBInstance {
+	return &rds.DBInstance{
+		DBInstanceStatus: aws.String("available"),
+		DBParameterGroups: []*rds.DBParameterGroupStatus{
+			{ParameterApplyStatus: aws.String(parameterApplyStatus)},
+		},
+		Endpoint: &rds.Endpoint{
+			Address: aws.String("db-name.example.com"),
+			Port:    aws.Int64(5432),
+		},
+	}
+}
+
+func TestExtensionPreloadLibraries(t *testing.T) {
+	libraries := extensionPreloadLibraries([]string{"pg_stat_statements", "pgcrypto", "postgis", "pgaudit", "pg_partman"})
+	if diff := deep.Equal(libraries, []string{"pg_stat_statements", "pgaudit", "pg_partman_bgw"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestCreateExtensions(t *testing.T) {
+	conn := &mockInstanceDBConn{}
+	dbInstance := newExtensionsTestDBInstance("in-sync")
+	adapter := newIAMAuthTestAdapter(&mockIamClientForRDSTests{}, conn, dbInstance)
+	i := newIAMAuthTestInstance()
+	i.State = base.InstanceInProgress
+	i.Extensions = []string{"pg_stat_statements", "postgis"}
+	i.ExtensionsPending = true
+	i.ClearPassword = "password"
+
+	status, err := adapter.checkDBStatus(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != base.InstanceReady {
+		t.Errorf("expected status %s, got %s", base.InstanceReady, status)
+	}
+	expected := []string{
+		`CREATE EXTENSION IF NOT EXISTS "pg_stat_statements"`,
+		`CREATE EXTENSION IF NOT EXISTS "postgis"`,
+	}
+	if diff := deep.Equal(conn.statements, expected); diff != nil {
+		t.Error(diff)
+	}
+	if conn.config.Password != "password" || conn.config.Sslmode != "require" {
+		t.Errorf("unexpected connection config %v", conn.config)
+	}
+	if !conn.closed {
+		t.Error("expected the connection to be closed")
+	}
+	if i.ExtensionsPending {
+		t.Error("expected the extensions not to be pending anymore")
+	}
+}
+
+func TestCreateExtensionsPendingReboot(t *testing.T) {
+	conn := &mockInstanceDBConn{}
+	adapter := newIAMAuthTestAdapter(&mockIamClientForRDSTests{}, conn, newExtensionsTestDBInstance("pending-reboot"))
+	i := newIAMAuthTestInstance()
+	i.Extensions = []string{"pg_stat_statements"}
+	i.ExtensionsPending = true
+	i.ExtensionsRebootPending = true
+
+	status, err := adapter.createExtensions(i, newExtensionsTestDBInstance("pending-reboot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != base.InstanceInProgress {
+		t.Errorf("expected status %s, got %s", base.InstanceInProgress, status)
+	}
+	rebootInput := adapter.rds.(*mockRdsClientForAdapterTests).rebootDbInput
+	if rebootInput == nil || aws.StringValue(rebootInput.DBInstanceIdentifier) != "db-name" {
+		t.Errorf("expected the instance to be rebooted, got %v", rebootInput)
+	}
+	if len(conn.statements) != 0 || !i.ExtensionsPending {
+		t.Error("expected the extensions to be created after the reboot")
+	}
+	if i.ExtensionsRebootPending {
+		t.Error("expected the reboot not to be pending anymore")
+	}
+
+	// Other parameter changes waiting for a reboot are left to the tenant.
+	adapter.rds.(*mockRdsClientForAdapterTests).rebootDbInput = nil
+	status, err = adapter.createExtensions(i, newExtensionsTestDBInstance("pending-reboot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != base.InstanceReady {
+		t.Errorf("expected status %s, got %s", base.InstanceReady, status)
+	}
+	if adapter.rds.(*mockRdsClientForAdapterTests).rebootDbInput != nil {
+		t.Error("expected the instance not to be rebooted again")
+	}
+}
+
+func TestCreateExtensionsLoadedAtStart(t *testing.T) {
+	conn := &mockInstanceDBConn{}
+	adapter := newIAMAuthTestAdapter(&mockIamClientForRDSTests{}, conn, newExtensionsTestDBInstance("in-sync"))
+	i := newIAMAuthTestInstance()
+	i.Extensions = []string{"pg_stat_statements"}
+	i.ExtensionsPending = true
+	i.ExtensionsRebootPending = true
+
+	status, err := adapter.createExtensions(i, newExtensionsTestDBInstance("in-sync"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != base.InstanceReady {
+		t.Errorf("expected status %s, got %s", base.InstanceReady, status)
+	}
+	if adapter.rds.(*mockRdsClientForAdapterTests).rebootDbInput != nil {
+		t.Error("expected the instance not to be rebooted")
+	}
+	if i.ExtensionsRebootPending {
+		t.Error("expected the reboot not to be pending anymore")
+	}
+}
+
+func TestCreateExtensionsError(t *testing.T) {
+	conn := &mockInstanceDBConn{mockSharedDBConn: mockSharedDBConn{err: errors.New("fail")}}
+	adapter := newIAMAuthTestAdapter(&mockIamClientForRDSTests{}, conn, newExtensionsTestDBInstance("in-sync"))
+	i := newIAMAuthTestInstance()
+	i.Extensions = []string{"postgis"}
+	i.ExtensionsPending = true
+
+	status, err := adapter.createExtensions(i, newExtensionsTestDBInstance("in-sync"))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if status != base.InstanceNotModified {
+		t.Errorf("expected status %s, got %s", base.InstanceNotModified, status)
+	}
+	if !i.ExtensionsPending {
+		t.Error("expected the extensions to still be pending")
+	}
+}
@@ -0,0 +1,42 @@
+package rds
+
+import (
+	"time"
+)
+
+// FinalSnapshot records the snapshot taken by RDS when an instance is deleted,
+// so that the data of a deleted instance can be found and restored later.
+type FinalSnapshot struct {
+	SnapshotIdentifier string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	InstanceGUID     string `sql:"size(255)"`
+	ServiceID        string `sql:"size(255)"`
+	PlanID           string `sql:"size(255)"`
+	OrganizationGUID string `sql:"size(255)"`
+	SpaceGUID        string `sql:"size(255)"`
+
+	Database  string `sql:"size(255)"`
+	DbType    string `sql:"size(255)"`
+	DbVersion string `sql:"size(255)"`
+
+	CreatedAt time.Time
+}
+
+// TableName keeps the final snapshots apart from the instances they were taken of.
+func (FinalSnapshot) TableName() string {
+	return "rds_final_snapshots"
+}
+
+func newFinalSnapshot(i *RDSInstance, snapshotIdentifier string) *FinalSnapshot {
+	return &FinalSnapshot{
+		SnapshotIdentifier: snapshotIdentifier,
+		InstanceGUID:       i.Uuid,
+		ServiceID:          i.ServiceID,
+		PlanID:             i.PlanID,
+		OrganizationGUID:   i.OrganizationGUID,
+		SpaceGUID:          i.SpaceGUID,
+		Database:           i.Database,
+		DbType:             i.DbType,
+		DbVersion:          i.DbVersion,
+	}
+}
@@ -0,0 +1,209 @@
+package rds
+
+import (
+	"crypto/aes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/lib/pq"
+
+	"github.com/18F/aws-broker/awsiam"
+	"github.com/18F/aws-broker/common"
+	"github.com/18F/aws-broker/helpers"
+)
+
+// instanceDBConn is a connection to the database of an instance, opened as
+// its master user.
+type instanceDBConn interface {
+	dbConn
+	Close() error
+}
+
+// openInstanceDB connects to a database with common.DBInit.
+func openInstanceDB(config common.DBConfig) (instanceDBConn, error) {
+	return common.DBInit(&config)
+}
+
+// iamDBUsername is the database user that the IAM credentials of the instance
+// connect as.
+func (i *RDSInstance) iamDBUsername() string {
+	return i.Username + "_iam"
+}
+
+// createIAMDBUserStatements returns the statements creating the database user
+// of the instance that authenticates with IAM. The user is a member of the
+// master user so that it has access to the existing objects of the database.
+func createIAMDBUserStatements(i *RDSInstance) ([]string, error) {
+	switch i.DbType {
+	case "postgres":
+		username := pq.QuoteIdentifier(i.iamDBUsername())
+		// CREATE ROLE has no IF NOT EXISTS, and the statements are run again
+		// if setting up IAM credentials fails after the user was created.
+		return []string{
+			fmt.Sprintf("DO $$ BEGIN CREATE ROLE %s WITH LOGIN; EXCEPTION WHEN duplicate_object THEN NULL; END $$", username),
+			fmt.Sprintf("GRANT rds_iam TO %s", username),
+			fmt.Sprintf("GRANT %s TO %s", pq.QuoteIdentifier(i.Username), username),
+		}, nil
+	case "mysql":
+		username := fmt.Sprintf("'%s'@'%%'", i.iamDBUsername())
+		return []string{
+			fmt.Sprintf("CREATE USER IF NOT EXISTS %s IDENTIFIED WITH AWSAuthenticationPlugin AS 'RDS'", username),
+			fmt.Sprintf("GRANT ALL PRIVILEGES ON `%s`.* TO %s", i.FormatDBName(), username),
+		}, nil
+	}
+	return nil, fmt.Errorf("IAM database authentication is not supported for %s databases", i.DbType)
+}
+
+// rdsDBUserARN is the ARN of a database user of an RDS instance, as used in
+// the resources of rds-db:connect policies.
+func rdsDBUserARN(instanceARN string, resourceID string, username string) (string, error) {
+	// arn:<partition>:rds:<region>:<account>:db:<identifier>
+	parts := strings.Split(instanceARN, ":")
+	if len(parts) < 5 {
+		return "", fmt.Errorf("invalid instance ARN %s", instanceARN)
+	}
+	return fmt.Sprintf("arn:%s:rds-db:%s:%s:dbuser:%s/%s", parts[1], parts[3], parts[4], resourceID, username), nil
+}
+
+// setupIAMAuthentication creates the database user of the instance granted
+// rds_iam and an IAM user whose policy only allows it to connect as that user.
+// The access key of the IAM user is recorded on the instance.
+func (d *dedicatedDBAdapter) setupIAMAuthentication(i *RDSInstance, password string) error {
+	dbInstance, err := d.describeDB(i)
+	if err != nil {
+		return err
+	}
+	if !aws.BoolValue(dbInstance.IAMDatabaseAuthenticationEnabled) {
+		return errors.New("IAM database authentication is not enabled on the instance yet. Please wait and try again..")
+	}
+	userARN, err := rdsDBUserARN(aws.StringValue(dbInstance.DBInstanceArn), aws.StringValue(dbInstance.DbiResourceId), i.iamDBUsername())
+	if err != nil {
+		return err
+	}
+
+	statements, err := createIAMDBUserStatements(i)
+	if err != nil {
+		return err
+	}
+	conn, err := d.openDB(common.DBConfig{
+		DbType:   i.DbType,
+		URL:      i.Host,
+		Username: i.Username,
+		Password: password,
+		DbName:   i.FormatDBName(),
+		Sslmode:  "require",
+		Port:     i.Port,
+	})
+	if err != nil {
+		d.logger.Error("connect-to-instance", err)
+		return errors.New("unable to connect to the instance to create its IAM database user")
+	}
+	defer conn.Close()
+	for _, statement := range statements {
+		if err := conn.Exec(statement).Error; err != nil {
+			d.logger.Error("create-iam-db-user", err)
+			return err
+		}
+	}
+
+	user := awsiam.NewIAMUserClient(d.iam, d.logger)
+	ip := awsiam.NewIAMPolicyClient(d.iam, d.logger)
+	iamTags := awsiam.ConvertTagsMapToIAMTags(i.Tags)
+
+	// The steps that completed are recorded on the instance, so that binding
+	// again resumes after a failure.
+	if i.IamUserName == "" {
+		if _, err := user.Create(i.Database, "", iamTags); err != nil {
+			d.logger.Error("create-iam-user", err)
+			return err
+		}
+		i.IamUserName = i.Database
+	}
+
+	if i.IamPolicyARN == "" {
+		policy := `{"Version": "2012-10-17","Statement": [{"Action": ["rds-db:connect"],"Effect": "Allow","Resource": {{resources ""}}}]}`
+		policyARN, err := ip.CreatePolicyFromTemplate(i.Database, "/", policy, []string{userARN}, iamTags)
+		if err != nil {
+			return err
+		}
+		i.IamPolicyARN = policyARN
+	}
+	if err := user.AttachUserPolicy(i.IamUserName, i.IamPolicyARN); err != nil {
+		return err
+	}
+
+	accessKeyID, secretAccessKey, err := user.CreateAccessKey(i.IamUserName)
+	if err != nil {
+		return err
+	}
+	salt := helpers.GenerateSalt(aes.BlockSize)
+	encrypted, _, err := i.dbUtils.generatePassword(salt, secretAccessKey, d.settings.EncryptionKey)
+	if err != nil {
+		return err
+	}
+	i.IamAccessKeyID = accessKeyID
+	i.IamSecretAccessKey = encrypted
+	i.IamSecretAccessKeySalt = salt
+
+	d.logger.Info("iam-authentication-created", lager.Data{"database": i.Database, "db-user": i.iamDBUsername()})
+	return nil
+}
+
+// getIAMCredentials returns the bind credentials of instances with IAM
+// database authentication. Apps generate authentication tokens for the
+// database user from the AWS keys instead of using a password.
+func (d *dedicatedDBAdapter) getIAMCredentials(i *RDSInstance) (map[string]string, error) {
+	secretAccessKey, err := i.dbUtils.getPassword(i.IamSecretAccessKeySalt, i.IamSecretAccessKey, d.settings.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	dbName := i.FormatDBName()
+	return map[string]string{
+		"username":              i.iamDBUsername(),
+		"host":                  i.Host,
+		"port":                  strconv.FormatInt(i.Port, 10),
+		"db_name":               dbName,
+		"name":                  dbName,
+		"region":                d.settings.Region,
+		"aws_access_key_id":     i.IamAccessKeyID,
+		"aws_secret_access_key": secretAccessKey,
+	}, nil
+}
+
+// cleanupIAMAuthentication deletes the IAM user and policy of the instance.
+// The database user is deleted with the instance.
+func (d *dedicatedDBAdapter) cleanupIAMAuthentication(i *RDSInstance) error {
+	user := awsiam.NewIAMUserClient(d.iam, d.logger)
+	ip := awsiam.NewIAMPolicyClient(d.iam, d.logger)
+
+	if i.IamAccessKeyID != "" {
+		if err := user.DeleteAccessKey(i.IamUserName, i.IamAccessKeyID); err != nil {
+			d.logger.Error("delete-access-key", err)
+			return err
+		}
+		i.IamAccessKeyID = ""
+		i.IamSecretAccessKey = ""
+		i.IamSecretAccessKeySalt = ""
+	}
+	if i.IamPolicyARN != "" {
+		if err := user.DetachUserPolicy(i.IamUserName, i.IamPolicyARN); err != nil {
+			d.logger.Error("detach-user-policy", err)
+			return err
+		}
+		if err := ip.DeletePolicy(i.IamPolicyARN); err != nil {
+			d.logger.Error("delete-policy", err)
+			return err
+		}
+		i.IamPolicyARN = ""
+	}
+	if err := user.Delete(i.IamUserName); err != nil {
+		d.logger.Error("delete-iam-user", err)
+		return err
+	}
+	i.IamUserName = ""
+	return nil
+}
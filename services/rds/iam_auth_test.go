@@ -0,0 +1,368 @@
+package rds
+
+import (
+	"crypto/aes"
+	"errors"
+	"testing"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/catalog"
+	"github.com/18F/aws-broker/common"
+	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/helpers"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/go-test/deep"
+)
+
+type mockIamClientForRDSTests struct {
+	iamiface.IAMAPI
+
+	createUserErr error
+
+	createdUsers    []string
+	createdPolicies []*iam.CreatePolicyInput
+	attachedPolicy  string
+	deletedKeys     []string
+	deletedPolicies []string
+	deletedUsers    []string
+
+	roleExists          bool
+	attachRolePolicyErr error
+
+	createdRoles  []*iam.CreateRoleInput
+	attachedRoles []*iam.AttachRolePolicyInput
+	deletedRoles  []string
+}
+
+func (m *mockIamClientForRDSTests) CreateUser(input *iam.CreateUserInput) (*iam.CreateUserOutput, error) {
+	if m.createUserErr != nil {
+		return nil, m.createUserErr
+	}
+	m.createdUsers = append(m.createdUsers, aws.StringValue(input.UserName))
+	return &iam.CreateUserOutput{User: &iam.User{Arn: aws.String("arn:aws:iam::123456789012:user/" + aws.StringValue(input.UserName))}}, nil
+}
+
+func (m *mockIamClientForRDSTests) CreatePolicy(input *iam.CreatePolicyInput) (*iam.CreatePolicyOutput, error) {
+	m.createdPolicies = append(m.createdPolicies, input)
+	return &iam.CreatePolicyOutput{Policy: &iam.Policy{Arn: aws.String("arn:aws:iam::123456789012:policy/" + aws.StringValue(input.PolicyName))}}, nil
+}
+
+func (m *mockIamClientForRDSTests) AttachUserPolicy(input *iam.AttachUserPolicyInput) (*iam.AttachUserPolicyOutput, error) {
+	m.attachedPolicy = aws.StringValue(input.PolicyArn)
+	return &iam.AttachUserPolicyOutput{}, nil
+}
+
+func (m *mockIamClientForRDSTests) CreateAccessKey(input *iam.CreateAccessKeyInput) (*iam.CreateAccessKeyOutput, error) {
+	return &iam.CreateAccessKeyOutput{AccessKey: &iam.AccessKey{
+		AccessKeyId:     aws.String("access-key-id"),
+		SecretAccessKey: aws.String("secret-access-key"),
+	}}, nil
+}
+
+func (m *mockIamClientForRDSTests) DeleteAccessKey(input *iam.DeleteAccessKeyInput) (*iam.DeleteAccessKeyOutput, error) {
+	m.deletedKeys = append(m.deletedKeys, aws.StringValue(input.AccessKeyId))
+	return &iam.DeleteAccessKeyOutput{}, nil
+}
+
+func (m *mockIamClientForRDSTests) DetachUserPolicy(input *iam.DetachUserPolicyInput) (*iam.DetachUserPolicyOutput, error) {
+	return &iam.DetachUserPolicyOutput{}, nil
+}
+
+func (m *mockIamClientForRDSTests) ListPolicyVersions(input *iam.ListPolicyVersionsInput) (*iam.ListPolicyVersionsOutput, error) {
+	return &iam.ListPolicyVersionsOutput{}, nil
+}
+
+func (m *mockIamClientForRDSTests) DeletePolicy(input *iam.DeletePolicyInput) (*iam.DeletePolicyOutput, error) {
+	m.deletedPolicies = append(m.deletedPolicies, aws.StringValue(input.PolicyArn))
+	return &iam.DeletePolicyOutput{}, nil
+}
+
+func (m *mockIamClientForRDSTests) DeleteUser(input *iam.DeleteUserInput) (*iam.DeleteUserOutput, error) {
+	m.deletedUsers = append(m.deletedUsers, aws.StringValue(input.UserName))
+	return &iam.DeleteUserOutput{}, nil
+}
+
+func (m *mockIamClientForRDSTests) CreateRole(input *iam.CreateRoleInput) (*iam.CreateRoleOutput, error) {
+	if m.roleExists {
+		return nil, awserr.New(iam.ErrCodeEntityAlreadyExistsException, "role exists", nil)
+	}
+	m.createdRoles = append(m.createdRoles, input)
+	return &iam.CreateRoleOutput{Role: &iam.Role{
+		Arn:      aws.String("arn:aws-us-gov:iam::123456789012:role/" + aws.StringValue(input.RoleName)),
+		RoleName: input.RoleName,
+	}}, nil
+}
+
+func (m *mockIamClientForRDSTests) GetRole(input *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
+	return &iam.GetRoleOutput{Role: &iam.Role{
+		Arn:      aws.String("arn:aws-us-gov:iam::123456789012:role/" + aws.StringValue(input.RoleName)),
+		RoleName: input.RoleName,
+	}}, nil
+}
+
+func (m *mockIamClientForRDSTests) AttachRolePolicy(input *iam.AttachRolePolicyInput) (*iam.AttachRolePolicyOutput, error) {
+	if m.attachRolePolicyErr != nil {
+		return nil, m.attachRolePolicyErr
+	}
+	m.attachedRoles = append(m.attachedRoles, input)
+	return &iam.AttachRolePolicyOutput{}, nil
+}
+
+func (m *mockIamClientForRDSTests) DetachRolePolicy(input *iam.DetachRolePolicyInput) (*iam.DetachRolePolicyOutput, error) {
+	return &iam.DetachRolePolicyOutput{}, nil
+}
+
+func (m *mockIamClientForRDSTests) DeleteRole(input *iam.DeleteRoleInput) (*iam.DeleteRoleOutput, error) {
+	m.deletedRoles = append(m.deletedRoles, aws.StringValue(input.RoleName))
+	return &iam.DeleteRoleOutput{}, nil
+}
+
+type mockInstanceDBConn struct {
+	mockSharedDBConn
+	config common.DBConfig
+	closed bool
+}
+
+func (m *mockInstanceDBConn) Close() error {
+	m.closed = true
+	return nil
+}
+
+func newIAMAuthTestAdapter(iamClient *mockIamClientForRDSTests, conn *mockInstanceDBConn, dbInstance *rds.DBInstance) *dedicatedDBAdapter {
+	return &dedicatedDBAdapter{
+		Plan: catalog.RDSPlan{IAMDatabaseAuthentication: true},
+		settings: config.Settings{
+			EncryptionKey: "12345678901234567890123456789012",
+			Region:        "us-gov-west-1",
+		},
+		rds: &mockRdsClientForAdapterTests{
+			describeDbInstancesResults: &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{dbInstance},
+			},
+		},
+		logger: lagertest.NewTestLogger("test"),
+		iam:    iamClient,
+		openDB: func(config common.DBConfig) (instanceDBConn, error) {
+			conn.config = config
+			return conn, nil
+		},
+	}
+}
+
+func newIAMAuthTestInstance() *RDSInstance {
+	i := NewRDSInstance()
+	i.Database = "db-name"
+	i.Username = "user"
+	i.DbType = "postgres"
+	i.Host = "db-name.example.com"
+	i.Port = 5432
+	i.State = base.InstanceReady
+	i.Salt = helpers.GenerateSalt(aes.BlockSize)
+	return i
+}
+
+func TestRdsDBUserARN(t *testing.T) {
+	arn, err := rdsDBUserARN("arn:aws-us-gov:rds:us-gov-west-1:123456789012:db:db-name", "db-ABCDEFG", "user_iam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "arn:aws-us-gov:rds-db:us-gov-west-1:123456789012:dbuser:db-ABCDEFG/user_iam"
+	if arn != expected {
+		t.Errorf("expected %s, got %s", expected, arn)
+	}
+
+	if _, err := rdsDBUserARN("not-an-arn", "db-ABCDEFG", "user_iam"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestBindDBToAppIAMAuthentication(t *testing.T) {
+	iamClient := &mockIamClientForRDSTests{}
+	conn := &mockInstanceDBConn{}
+	adapter := newIAMAuthTestAdapter(iamClient, conn, &rds.DBInstance{
+		DBInstanceArn:                    aws.String("arn:aws-us-gov:rds:us-gov-west-1:123456789012:db:db-name"),
+		DbiResourceId:                    aws.String("db-ABCDEFG"),
+		IAMDatabaseAuthenticationEnabled: aws.Bool(true),
+	})
+	i := newIAMAuthTestInstance()
+
+	credentials, err := adapter.bindDBToApp(i, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedStatements := []string{
+		`DO $$ BEGIN CREATE ROLE "user_iam" WITH LOGIN; EXCEPTION WHEN duplicate_object THEN NULL; END $$`,
+		`GRANT rds_iam TO "user_iam"`,
+		`GRANT "user" TO "user_iam"`,
+	}
+	if diff := deep.Equal(conn.statements, expectedStatements); diff != nil {
+		t.Error(diff)
+	}
+	if !conn.closed {
+		t.Error("expected the connection to the instance to be closed")
+	}
+	if conn.config.Username != "user" || conn.config.Password != "password" || conn.config.Sslmode != "require" {
+		t.Errorf("unexpected connection config %+v", conn.config)
+	}
+
+	if diff := deep.Equal(iamClient.createdUsers, []string{"db-name"}); diff != nil {
+		t.Error(diff)
+	}
+	expectedPolicy := `{"Version": "2012-10-17","Statement": [{"Action": ["rds-db:connect"],"Effect": "Allow","Resource": ["arn:aws-us-gov:rds-db:us-gov-west-1:123456789012:dbuser:db-ABCDEFG/user_iam"]}]}`
+	if len(iamClient.createdPolicies) != 1 || aws.StringValue(iamClient.createdPolicies[0].PolicyDocument) != expectedPolicy {
+		t.Errorf("unexpected policies %v", iamClient.createdPolicies)
+	}
+	if iamClient.attachedPolicy != "arn:aws:iam::123456789012:policy/db-name" {
+		t.Errorf("unexpected attached policy %s", iamClient.attachedPolicy)
+	}
+	if i.IamSecretAccessKey == "" || i.IamSecretAccessKey == "secret-access-key" {
+		t.Error("expected the secret access key to be encrypted")
+	}
+
+	expectedCredentials := map[string]string{
+		"username":              "user_iam",
+		"host":                  "db-name.example.com",
+		"port":                  "5432",
+		"db_name":               "dbname",
+		"name":                  "dbname",
+		"region":                "us-gov-west-1",
+		"aws_access_key_id":     "access-key-id",
+		"aws_secret_access_key": "secret-access-key",
+	}
+	if diff := deep.Equal(credentials, expectedCredentials); diff != nil {
+		t.Error(diff)
+	}
+
+	// Binding again reuses the IAM user.
+	if _, err := adapter.bindDBToApp(i, "password"); err != nil {
+		t.Fatal(err)
+	}
+	if len(iamClient.createdUsers) != 1 {
+		t.Errorf("expected a single IAM user, got %v", iamClient.createdUsers)
+	}
+}
+
+func TestBindDBToAppIAMAuthenticationAfterRotation(t *testing.T) {
+	adapter := newIAMAuthTestAdapter(&mockIamClientForRDSTests{}, &mockInstanceDBConn{}, &rds.DBInstance{
+		DBInstanceArn:                    aws.String("arn:aws-us-gov:rds:us-gov-west-1:123456789012:db:db-name"),
+		DbiResourceId:                    aws.String("db-ABCDEFG"),
+		IAMDatabaseAuthenticationEnabled: aws.Bool(true),
+	})
+	i := newIAMAuthTestInstance()
+	if _, err := adapter.bindDBToApp(i, "password"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotating the credentials replaces the salt of the instance.
+	if err := i.generateCredentials(&adapter.settings); err != nil {
+		t.Fatal(err)
+	}
+	credentials, err := adapter.bindDBToApp(i, i.ClearPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials["aws_secret_access_key"] != "secret-access-key" {
+		t.Errorf("expected the secret access key to be readable, got %q", credentials["aws_secret_access_key"])
+	}
+}
+
+func TestBindDBToAppIAMAuthenticationErrors(t *testing.T) {
+	// The instance is not modified yet.
+	adapter := newIAMAuthTestAdapter(&mockIamClientForRDSTests{}, &mockInstanceDBConn{}, &rds.DBInstance{
+		DBInstanceArn: aws.String("arn:aws-us-gov:rds:us-gov-west-1:123456789012:db:db-name"),
+	})
+	if _, err := adapter.bindDBToApp(newIAMAuthTestInstance(), "password"); err == nil {
+		t.Error("expected error")
+	}
+
+	// Failing steps are resumed when binding again.
+	iamClient := &mockIamClientForRDSTests{}
+	adapter = newIAMAuthTestAdapter(iamClient, &mockInstanceDBConn{}, &rds.DBInstance{
+		DBInstanceArn:                    aws.String("arn:aws-us-gov:rds:us-gov-west-1:123456789012:db:db-name"),
+		DbiResourceId:                    aws.String("db-ABCDEFG"),
+		IAMDatabaseAuthenticationEnabled: aws.Bool(true),
+	})
+	i := newIAMAuthTestInstance()
+	i.IamUserName = "db-name"
+	iamClient.createUserErr = errors.New("EntityAlreadyExists")
+	if _, err := adapter.bindDBToApp(i, "password"); err != nil {
+		t.Fatal(err)
+	}
+	if i.IamAccessKeyID != "access-key-id" {
+		t.Errorf("expected access key access-key-id, got %s", i.IamAccessKeyID)
+	}
+}
+
+func TestDeleteDbIAMAuthentication(t *testing.T) {
+	iamClient := &mockIamClientForRDSTests{}
+	adapter := newIAMAuthTestAdapter(iamClient, &mockInstanceDBConn{}, &rds.DBInstance{})
+	adapter.parameterGroupClient = &mockParameterGroupClient{}
+	i := newIAMAuthTestInstance()
+	i.IamUserName = "db-name"
+	i.IamPolicyARN = "policy-arn"
+	i.IamAccessKeyID = "access-key-id"
+	i.IamSecretAccessKey = "secret"
+
+	status, err := adapter.deleteDB(i, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != base.InstanceInProgress {
+		t.Errorf("expected status %s, got %s", base.InstanceInProgress, status)
+	}
+	// The IAM user is kept until the instance is gone.
+	if len(iamClient.deletedUsers) > 0 || i.IamUserName == "" {
+		t.Errorf("expected the IAM user to be kept while the instance is deleted, got %v", iamClient.deletedUsers)
+	}
+
+	adapter.rds = &mockRdsClientForAdapterTests{
+		describeDbInstancesErr: awserr.New(rds.ErrCodeDBInstanceNotFoundFault, "not found", nil),
+	}
+	status, err = adapter.checkDBDeleted(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != base.InstanceGone {
+		t.Errorf("expected status %s, got %s", base.InstanceGone, status)
+	}
+	if diff := deep.Equal(iamClient.deletedKeys, []string{"access-key-id"}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(iamClient.deletedPolicies, []string{"policy-arn"}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(iamClient.deletedUsers, []string{"db-name"}); diff != nil {
+		t.Error(diff)
+	}
+	if i.IamUserName != "" || i.IamPolicyARN != "" || i.IamAccessKeyID != "" || i.IamSecretAccessKey != "" {
+		t.Error("expected the IAM credentials of the instance to be cleared")
+	}
+}
+
+func TestPrepareDbInputsIAMAuthentication(t *testing.T) {
+	adapter := newIAMAuthTestAdapter(&mockIamClientForRDSTests{}, &mockInstanceDBConn{}, &rds.DBInstance{})
+	adapter.parameterGroupClient = &mockParameterGroupClient{}
+	i := newIAMAuthTestInstance()
+
+	createParams, err := adapter.prepareCreateDbInput(i, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !aws.BoolValue(createParams.EnableIAMDatabaseAuthentication) {
+		t.Error("expected IAM database authentication to be enabled on create")
+	}
+
+	modifyParams, err := adapter.prepareModifyDbInstanceInput(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !aws.BoolValue(modifyParams.EnableIAMDatabaseAuthentication) {
+		t.Error("expected IAM database authentication to be enabled on modify")
+	}
+}
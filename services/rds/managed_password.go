@@ -0,0 +1,49 @@
+package rds
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	"github.com/18F/aws-broker/helpers/logging"
+)
+
+// managedMasterSecret is the content of the secrets in which RDS manages
+// master passwords.
+type managedMasterSecret struct {
+	Password string `json:"password"`
+}
+
+// getManagedMasterPassword reads the current master password of an instance
+// whose password RDS manages from its secret. The ARN of the secret is looked
+// up on the instance if it is not recorded yet.
+func (d *dedicatedDBAdapter) getManagedMasterPassword(i *RDSInstance) (string, error) {
+	if i.MasterUserSecretARN == "" {
+		dbInstance, err := d.describeDB(i)
+		if err != nil {
+			return "", err
+		}
+		if dbInstance.MasterUserSecret == nil || aws.StringValue(dbInstance.MasterUserSecret.SecretArn) == "" {
+			return "", errors.New("the master user secret of the instance is not available yet. Please wait and try again..")
+		}
+		i.MasterUserSecretARN = aws.StringValue(dbInstance.MasterUserSecret.SecretArn)
+	}
+
+	resp, err := d.secretsManager.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(i.MasterUserSecretARN),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "get-secret-value", err)
+		return "", err
+	}
+	var secret managedMasterSecret
+	if err := json.Unmarshal([]byte(aws.StringValue(resp.SecretString)), &secret); err != nil {
+		return "", errors.New("unable to read the master user secret of the instance")
+	}
+	if secret.Password == "" {
+		return "", errors.New("the master user secret of the instance has no password")
+	}
+	return secret.Password, nil
+}
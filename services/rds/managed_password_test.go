@@ -0,0 +1,168 @@
+package rds
+
+import (
+	"errors"
+	"testing"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+
+	"github.com/18F/aws-broker/catalog"
+	"github.com/18F/aws-broker/config"
+)
+
+type mockSecretsManagerClient struct {
+	secretsmanageriface.SecretsManagerAPI
+
+	secrets map[string]string
+}
+
+func (m *mockSecretsManagerClient) GetSecretValue(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+	secret, ok := m.secrets[aws.StringValue(input.SecretId)]
+	if !ok {
+		return nil, errors.New("secret not found")
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(secret)}, nil
+}
+
+func TestGetManagedMasterPassword(t *testing.T) {
+	secretsManager := &mockSecretsManagerClient{
+		secrets: map[string]string{
+			"secret-arn":   `{"username": "user", "password": "managed-password"}`,
+			"invalid-arn":  `not json`,
+			"no-password":  `{"username": "user"}`,
+			"describe-arn": `{"username": "user", "password": "described-password"}`,
+		},
+	}
+	testCases := map[string]struct {
+		instance          *RDSInstance
+		dbInstance        *rds.DBInstance
+		expectedPassword  string
+		expectedSecretARN string
+		expectErr         bool
+	}{
+		"recorded secret": {
+			instance:          &RDSInstance{Database: "db", MasterUserSecretARN: "secret-arn"},
+			expectedPassword:  "managed-password",
+			expectedSecretARN: "secret-arn",
+		},
+		"secret looked up on the instance": {
+			instance: &RDSInstance{Database: "db"},
+			dbInstance: &rds.DBInstance{
+				MasterUserSecret: &rds.MasterUserSecret{SecretArn: aws.String("describe-arn")},
+			},
+			expectedPassword:  "described-password",
+			expectedSecretARN: "describe-arn",
+		},
+		"secret not created yet": {
+			instance:   &RDSInstance{Database: "db"},
+			dbInstance: &rds.DBInstance{},
+			expectErr:  true,
+		},
+		"invalid secret": {
+			instance:          &RDSInstance{Database: "db", MasterUserSecretARN: "invalid-arn"},
+			expectedSecretARN: "invalid-arn",
+			expectErr:         true,
+		},
+		"secret without password": {
+			instance:          &RDSInstance{Database: "db", MasterUserSecretARN: "no-password"},
+			expectedSecretARN: "no-password",
+			expectErr:         true,
+		},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			adapter := &dedicatedDBAdapter{
+				rds: &mockRdsClientForAdapterTests{
+					describeDbInstancesResults: &rds.DescribeDBInstancesOutput{
+						DBInstances: []*rds.DBInstance{test.dbInstance},
+					},
+				},
+				secretsManager: secretsManager,
+				logger:         lagertest.NewTestLogger("test"),
+			}
+			password, err := adapter.getManagedMasterPassword(test.instance)
+			if test.expectErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if password != test.expectedPassword {
+				t.Errorf("expected password %q, got %q", test.expectedPassword, password)
+			}
+			if test.instance.MasterUserSecretARN != test.expectedSecretARN {
+				t.Errorf("expected secret %q, got %q", test.expectedSecretARN, test.instance.MasterUserSecretARN)
+			}
+		})
+	}
+}
+
+func TestSetManageMasterUserPassword(t *testing.T) {
+	testCases := map[string]struct {
+		adapter   string
+		plan      catalog.RDSPlan
+		expected  bool
+		expectErr bool
+	}{
+		"plan without managed passwords": {
+			adapter: "dedicated",
+			plan:    catalog.RDSPlan{},
+		},
+		"dedicated plan": {
+			adapter:  "dedicated",
+			plan:     catalog.RDSPlan{ManageMasterUserPassword: true},
+			expected: true,
+		},
+		"shared plan": {
+			adapter:   "shared",
+			plan:      catalog.RDSPlan{ManageMasterUserPassword: true},
+			expectErr: true,
+		},
+		"aurora plan": {
+			adapter:   "aurora",
+			plan:      catalog.RDSPlan{ManageMasterUserPassword: true},
+			expectErr: true,
+		},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			i := &RDSInstance{
+				Adapter:       test.adapter,
+				Salt:          "salt",
+				Password:      "encrypted",
+				ClearPassword: "password",
+			}
+			err := i.setManageMasterUserPassword(test.plan)
+			if test.expectErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if i.ManageMasterUserPassword != test.expected {
+				t.Fatalf("expected ManageMasterUserPassword to be %t", test.expected)
+			}
+			if test.expected && (i.Password != "" || i.ClearPassword != "" || i.Salt != "salt") {
+				t.Error("expected the broker to keep only the salt")
+			}
+		})
+	}
+}
+
+func TestModifyManagedMasterPassword(t *testing.T) {
+	i := &RDSInstance{ManageMasterUserPassword: true, dbUtils: &RDSDatabaseUtils{}}
+	i.PlanID = "managed-plan"
+
+	err := i.modify(Options{RotateCredentials: aws.Bool(true)}, catalog.RDSPlan{ManageMasterUserPassword: true}, &config.Settings{})
+	if err == nil {
+		t.Error("expected rotating a managed password to fail")
+	}
+	err = i.modify(Options{ScheduledCredentialRotation: aws.Bool(true)}, catalog.RDSPlan{ManageMasterUserPassword: true}, &config.Settings{})
+	if err == nil || i.ScheduledCredentialRotation {
+		t.Error("expected scheduling the rotation of a managed password to fail")
+	}
+
+	plan := catalog.RDSPlan{}
+	plan.ID = "other-plan"
+	if err := i.modify(Options{}, plan, &config.Settings{}); err == nil {
+		t.Error("expected updating to a plan without managed passwords to fail")
+	}
+}
@@ -0,0 +1,74 @@
+package rds
+
+import (
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/helpers/logging"
+)
+
+// newSnapshot converts a snapshot of the instance. Manual snapshots taken by
+// the broker are recognized by the prefix of their identifier.
+func newSnapshot(i *RDSInstance, snapshot *rds.DBSnapshot) base.Snapshot {
+	name := aws.StringValue(snapshot.DBSnapshotIdentifier)
+	return base.Snapshot{
+		Name:      name,
+		Type:      aws.StringValue(snapshot.SnapshotType),
+		Status:    aws.StringValue(snapshot.Status),
+		CreatedAt: snapshot.SnapshotCreateTime,
+		Manual:    aws.StringValue(snapshot.SnapshotType) == "manual" && strings.HasPrefix(name, base.ManualSnapshotPrefix(i.Database)),
+	}
+}
+
+// createSnapshot takes a manual snapshot of the instance with the given tags.
+func (d *dedicatedDBAdapter) createSnapshot(i *RDSInstance, name string, tags map[string]string) (base.Snapshot, error) {
+	resp, err := d.rds.CreateDBSnapshot(&rds.CreateDBSnapshotInput{
+		DBInstanceIdentifier: aws.String(i.Database),
+		DBSnapshotIdentifier: aws.String(base.ManualSnapshotPrefix(i.Database) + name),
+		Tags:                 ConvertTagsToRDSTags(tags),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "create-db-snapshot", err)
+		return base.Snapshot{}, err
+	}
+	d.logger.Info("db-snapshot-created", lager.Data{
+		"database": i.Database,
+		"snapshot": aws.StringValue(resp.DBSnapshot.DBSnapshotIdentifier),
+	})
+	return newSnapshot(i, resp.DBSnapshot), nil
+}
+
+// listSnapshots lists the automated and manual snapshots of the instance.
+func (d *dedicatedDBAdapter) listSnapshots(i *RDSInstance) ([]base.Snapshot, error) {
+	snapshots := []base.Snapshot{}
+	err := d.rds.DescribeDBSnapshotsPages(&rds.DescribeDBSnapshotsInput{
+		DBInstanceIdentifier: aws.String(i.Database),
+	}, func(page *rds.DescribeDBSnapshotsOutput, lastPage bool) bool {
+		for _, snapshot := range page.DBSnapshots {
+			snapshots = append(snapshots, newSnapshot(i, snapshot))
+		}
+		return true
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "describe-db-snapshots", err)
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// deleteSnapshot deletes a manual snapshot.
+func (d *dedicatedDBAdapter) deleteSnapshot(snapshotIdentifier string) error {
+	_, err := d.rds.DeleteDBSnapshot(&rds.DeleteDBSnapshotInput{
+		DBSnapshotIdentifier: aws.String(snapshotIdentifier),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "delete-db-snapshot", err)
+		return err
+	}
+	d.logger.Info("db-snapshot-deleted", lager.Data{"snapshot": snapshotIdentifier})
+	return nil
+}
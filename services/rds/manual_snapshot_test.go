@@ -0,0 +1,98 @@
+package rds
+
+import (
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/go-test/deep"
+
+	"github.com/18F/aws-broker/base"
+)
+
+func TestCreateSnapshot(t *testing.T) {
+	client := &mockRdsClientForAdapterTests{}
+	adapter := &dedicatedDBAdapter{
+		rds:    client,
+		logger: lagertest.NewTestLogger("test"),
+	}
+	i := &RDSInstance{Database: "db-name"}
+
+	snapshot, err := adapter.createSnapshot(i, "pre-migration", map[string]string{"Organization GUID": "an-org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := base.Snapshot{
+		Name:   "db-name-manual-pre-migration",
+		Type:   "manual",
+		Status: "creating",
+		Manual: true,
+	}
+	if diff := deep.Equal(snapshot, expected); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(client.createDbSnapshotInput.Tags, []*rds.Tag{{Key: aws.String("Organization GUID"), Value: aws.String("an-org")}}); diff != nil {
+		t.Error(diff)
+	}
+	if aws.StringValue(client.createDbSnapshotInput.DBInstanceIdentifier) != "db-name" {
+		t.Errorf("unexpected instance %s", aws.StringValue(client.createDbSnapshotInput.DBInstanceIdentifier))
+	}
+}
+
+func TestListSnapshots(t *testing.T) {
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	adapter := &dedicatedDBAdapter{
+		rds: &mockRdsClientForAdapterTests{
+			describeDbSnapshotsResults: []*rds.DBSnapshot{
+				{
+					DBSnapshotIdentifier: aws.String("rds:db-name-2026-01-01-00-00"),
+					SnapshotType:         aws.String("automated"),
+					Status:               aws.String("available"),
+					SnapshotCreateTime:   aws.Time(created),
+				},
+				{
+					DBSnapshotIdentifier: aws.String("db-name-manual-pre-migration"),
+					SnapshotType:         aws.String("manual"),
+					Status:               aws.String("available"),
+					SnapshotCreateTime:   aws.Time(created),
+				},
+				{
+					DBSnapshotIdentifier: aws.String("db-name-final"),
+					SnapshotType:         aws.String("manual"),
+					Status:               aws.String("available"),
+				},
+			},
+		},
+		logger: lagertest.NewTestLogger("test"),
+	}
+
+	snapshots, err := adapter.listSnapshots(&RDSInstance{Database: "db-name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []base.Snapshot{
+		{Name: "rds:db-name-2026-01-01-00-00", Type: "automated", Status: "available", CreatedAt: aws.Time(created)},
+		{Name: "db-name-manual-pre-migration", Type: "manual", Status: "available", CreatedAt: aws.Time(created), Manual: true},
+		{Name: "db-name-final", Type: "manual", Status: "available"},
+	}
+	if diff := deep.Equal(snapshots, expected); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestDeleteSnapshot(t *testing.T) {
+	client := &mockRdsClientForAdapterTests{}
+	adapter := &dedicatedDBAdapter{
+		rds:    client,
+		logger: lagertest.NewTestLogger("test"),
+	}
+
+	if err := adapter.deleteSnapshot("db-name-manual-old"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(client.deletedDbSnapshots, []string{"db-name-manual-old"}); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,132 @@
+package rds
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+
+	"github.com/18F/aws-broker/awsiam"
+)
+
+// enhancedMonitoringPolicy is the AWS managed policy allowing RDS to publish
+// Enhanced Monitoring metrics to CloudWatch Logs.
+const enhancedMonitoringPolicy = "service-role/AmazonRDSEnhancedMonitoringRole"
+
+// monitoringRoleName is the name of the Enhanced Monitoring role of the
+// instance.
+func monitoringRoleName(i *RDSInstance) string {
+	return i.Database + "-monitoring"
+}
+
+// managedPolicyARN returns the ARN of an AWS managed policy in the partition
+// of the given role.
+func managedPolicyARN(roleARN string, policy string) (string, error) {
+	parts := strings.Split(roleARN, ":")
+	if len(parts) < 2 {
+		return "", fmt.Errorf("invalid role ARN %s", roleARN)
+	}
+	return fmt.Sprintf("arn:%s:iam::aws:policy/%s", parts[1], policy), nil
+}
+
+// monitoringRolePropagationDelay is how long to wait before asking RDS again
+// to use a monitoring role it could not assume yet.
+var monitoringRolePropagationDelay = 10 * time.Second
+
+// monitoringRolePropagationRetries is how many times RDS is asked again to use
+// a new monitoring role before giving up.
+const monitoringRolePropagationRetries = 5
+
+// setupMonitoringRole creates the role that RDS assumes to publish the
+// Enhanced Monitoring metrics of the instance, if it is enabled and the role
+// does not exist yet. A role left behind by an earlier attempt is reused, and
+// its ARN is recorded as soon as it exists so that it can be cleaned up.
+func (d *dedicatedDBAdapter) setupMonitoringRole(i *RDSInstance) error {
+	if i.MonitoringInterval == 0 || i.MonitoringRoleARN != "" {
+		return nil
+	}
+
+	ip := awsiam.NewIAMPolicyClient(d.iam, d.logger)
+	policy := `{"Version": "2012-10-17","Statement": [{"Sid": "","Effect": "Allow","Principal": {"Service": "monitoring.rds.amazonaws.com"},"Action": "sts:AssumeRole"}]}`
+	role, err := ip.CreateAssumeRole(policy, monitoringRoleName(i), awsiam.ConvertTagsMapToIAMTags(i.Tags))
+	if err != nil {
+		d.logger.Error("create-monitoring-role", err)
+		return err
+	}
+	i.MonitoringRoleARN = aws.StringValue(role.Arn)
+
+	policyARN, err := managedPolicyARN(i.MonitoringRoleARN, enhancedMonitoringPolicy)
+	if err != nil {
+		return err
+	}
+	_, err = d.iam.AttachRolePolicy(&iam.AttachRolePolicyInput{
+		PolicyArn: aws.String(policyARN),
+		RoleName:  role.RoleName,
+	})
+	if err != nil {
+		d.logger.Error("attach-monitoring-role-policy", err)
+		return err
+	}
+
+	d.logger.Info("monitoring-role-created", lager.Data{"database": i.Database, "role": i.MonitoringRoleARN})
+	return nil
+}
+
+// retryMonitoringRolePropagation calls the given RDS API until it stops
+// rejecting the monitoring role of the instance. New IAM roles take a while to
+// propagate, and RDS cannot assume them until they have.
+func (d *dedicatedDBAdapter) retryMonitoringRolePropagation(i *RDSInstance, call func() error) error {
+	err := call()
+	for retry := 0; retry < monitoringRolePropagationRetries && isMonitoringRoleNotPropagated(err); retry++ {
+		d.logger.Info("retry-monitoring-role", lager.Data{
+			"database": i.Database,
+			"reason":   "possible IAM eventual consistency issue",
+		})
+		time.Sleep(monitoringRolePropagationDelay)
+		err = call()
+	}
+	return err
+}
+
+// isMonitoringRoleNotPropagated reports whether RDS rejected the monitoring
+// role because it could not assume it.
+func isMonitoringRoleNotPropagated(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == "InvalidParameterValue" && strings.Contains(awsErr.Message(), "ENHANCED_MONITORING")
+}
+
+// cleanupMonitoringRole deletes the Enhanced Monitoring role of the instance.
+func (d *dedicatedDBAdapter) cleanupMonitoringRole(i *RDSInstance) error {
+	policyARN, err := managedPolicyARN(i.MonitoringRoleARN, enhancedMonitoringPolicy)
+	if err != nil {
+		return err
+	}
+	_, err = d.iam.DetachRolePolicy(&iam.DetachRolePolicyInput{
+		PolicyArn: aws.String(policyARN),
+		RoleName:  aws.String(monitoringRoleName(i)),
+	})
+	if err != nil && !isIAMNoSuchEntity(err) {
+		d.logger.Error("detach-monitoring-role-policy", err)
+		return err
+	}
+	_, err = d.iam.DeleteRole(&iam.DeleteRoleInput{
+		RoleName: aws.String(monitoringRoleName(i)),
+	})
+	if err != nil && !isIAMNoSuchEntity(err) {
+		d.logger.Error("delete-monitoring-role", err)
+		return err
+	}
+	i.MonitoringRoleARN = ""
+	return nil
+}
+
+// isIAMNoSuchEntity reports whether an IAM call failed because the entity has
+// already been deleted.
+func isIAMNoSuchEntity(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == iam.ErrCodeNoSuchEntityException
+}
@@ -0,0 +1,228 @@
+package rds
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/18F/aws-broker/base"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/go-test/deep"
+)
+
+func newMonitoringTestInstance() *RDSInstance {
+	i := newIAMAuthTestInstance()
+	i.EnablePerformanceInsights = true
+	i.PerformanceInsightsRetention = 31
+	i.PerformanceInsightsKMSKeyID = "key-id"
+	i.MonitoringInterval = 60
+	return i
+}
+
+func TestManagedPolicyARN(t *testing.T) {
+	arn, err := managedPolicyARN("arn:aws-us-gov:iam::123456789012:role/db-name-monitoring", enhancedMonitoringPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "arn:aws-us-gov:iam::aws:policy/service-role/AmazonRDSEnhancedMonitoringRole"
+	if arn != expected {
+		t.Errorf("expected %s, got %s", expected, arn)
+	}
+
+	if _, err := managedPolicyARN("", enhancedMonitoringPolicy); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestCreateDbMonitoring(t *testing.T) {
+	iamClient := &mockIamClientForRDSTests{}
+	adapter := newIAMAuthTestAdapter(iamClient, &mockInstanceDBConn{}, &rds.DBInstance{})
+	adapter.parameterGroupClient = &mockParameterGroupClient{}
+	i := newMonitoringTestInstance()
+
+	status, err := adapter.createDB(i, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != base.InstanceInProgress {
+		t.Errorf("expected status %s, got %s", base.InstanceInProgress, status)
+	}
+	if len(iamClient.createdRoles) != 1 || aws.StringValue(iamClient.createdRoles[0].RoleName) != "db-name-monitoring" {
+		t.Fatalf("unexpected roles %v", iamClient.createdRoles)
+	}
+	if len(iamClient.attachedRoles) != 1 || aws.StringValue(iamClient.attachedRoles[0].PolicyArn) != "arn:aws-us-gov:iam::aws:policy/service-role/AmazonRDSEnhancedMonitoringRole" {
+		t.Errorf("unexpected role policies %v", iamClient.attachedRoles)
+	}
+	if i.MonitoringRoleARN != "arn:aws-us-gov:iam::123456789012:role/db-name-monitoring" {
+		t.Errorf("unexpected monitoring role %s", i.MonitoringRoleARN)
+	}
+
+	params, err := adapter.prepareCreateDbInput(i, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &rds.CreateDBInstanceInput{
+		EnablePerformanceInsights:          aws.Bool(true),
+		PerformanceInsightsRetentionPeriod: aws.Int64(31),
+		PerformanceInsightsKMSKeyId:        aws.String("key-id"),
+		MonitoringInterval:                 aws.Int64(60),
+		MonitoringRoleArn:                  aws.String(i.MonitoringRoleARN),
+	}
+	actual := &rds.CreateDBInstanceInput{
+		EnablePerformanceInsights:          params.EnablePerformanceInsights,
+		PerformanceInsightsRetentionPeriod: params.PerformanceInsightsRetentionPeriod,
+		PerformanceInsightsKMSKeyId:        params.PerformanceInsightsKMSKeyId,
+		MonitoringInterval:                 params.MonitoringInterval,
+		MonitoringRoleArn:                  params.MonitoringRoleArn,
+	}
+	if diff := deep.Equal(actual, expected); diff != nil {
+		t.Error(diff)
+	}
+
+	// The role is only created once.
+	if _, err := adapter.createDB(i, "password"); err != nil {
+		t.Fatal(err)
+	}
+	if len(iamClient.createdRoles) != 1 {
+		t.Errorf("expected a single role, got %v", iamClient.createdRoles)
+	}
+}
+
+func TestCreateDbMonitoringError(t *testing.T) {
+	iamClient := &mockIamClientForRDSTests{}
+	adapter := newIAMAuthTestAdapter(iamClient, &mockInstanceDBConn{}, &rds.DBInstance{})
+	adapter.parameterGroupClient = &mockParameterGroupClient{}
+	adapter.rds = &mockRdsClientForAdapterTests{createDbErr: errors.New("fail")}
+	i := newMonitoringTestInstance()
+
+	status, err := adapter.createDB(i, "password")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if status != base.InstanceNotCreated {
+		t.Errorf("expected status %s, got %s", base.InstanceNotCreated, status)
+	}
+	if diff := deep.Equal(iamClient.deletedRoles, []string{"db-name-monitoring"}); diff != nil {
+		t.Error(diff)
+	}
+	if i.MonitoringRoleARN != "" {
+		t.Errorf("expected the monitoring role to be cleared, got %s", i.MonitoringRoleARN)
+	}
+}
+
+func TestPrepareModifyDbInstanceInputMonitoring(t *testing.T) {
+	adapter := newIAMAuthTestAdapter(&mockIamClientForRDSTests{}, &mockInstanceDBConn{}, &rds.DBInstance{})
+	adapter.parameterGroupClient = &mockParameterGroupClient{}
+	i := newIAMAuthTestInstance()
+
+	// The settings of instances are left alone unless they are given.
+	params, err := adapter.prepareModifyDbInstanceInput(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.EnablePerformanceInsights != nil || params.MonitoringInterval != nil {
+		t.Errorf("expected no monitoring settings, got %v and %v", params.EnablePerformanceInsights, params.MonitoringInterval)
+	}
+
+	// Disabling them is applied.
+	i.updateMonitoring = true
+	params, err = adapter.prepareModifyDbInstanceInput(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.BoolValue(params.EnablePerformanceInsights) || params.MonitoringInterval == nil || *params.MonitoringInterval != 0 || params.MonitoringRoleArn != nil {
+		t.Errorf("expected monitoring to be disabled, got %v", params)
+	}
+}
+
+func TestDeleteDbMonitoring(t *testing.T) {
+	iamClient := &mockIamClientForRDSTests{}
+	adapter := newIAMAuthTestAdapter(iamClient, &mockInstanceDBConn{}, &rds.DBInstance{})
+	adapter.parameterGroupClient = &mockParameterGroupClient{}
+	i := newMonitoringTestInstance()
+	i.MonitoringRoleARN = "arn:aws-us-gov:iam::123456789012:role/db-name-monitoring"
+
+	status, err := adapter.deleteDB(i, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != base.InstanceInProgress {
+		t.Errorf("expected status %s, got %s", base.InstanceInProgress, status)
+	}
+	if diff := deep.Equal(iamClient.deletedRoles, []string{"db-name-monitoring"}); diff != nil {
+		t.Error(diff)
+	}
+	if i.MonitoringRoleARN != "" {
+		t.Errorf("expected the monitoring role to be cleared, got %s", i.MonitoringRoleARN)
+	}
+}
+
+func TestCreateDbMonitoringExistingRole(t *testing.T) {
+	iamClient := &mockIamClientForRDSTests{roleExists: true}
+	adapter := newIAMAuthTestAdapter(iamClient, &mockInstanceDBConn{}, &rds.DBInstance{})
+	i := newMonitoringTestInstance()
+
+	if err := adapter.setupMonitoringRole(i); err != nil {
+		t.Fatal(err)
+	}
+	if len(iamClient.attachedRoles) != 1 {
+		t.Errorf("expected the policy to be attached to the existing role, got %v", iamClient.attachedRoles)
+	}
+	if i.MonitoringRoleARN != "arn:aws-us-gov:iam::123456789012:role/db-name-monitoring" {
+		t.Errorf("unexpected monitoring role %s", i.MonitoringRoleARN)
+	}
+}
+
+func TestCreateDbMonitoringAttachError(t *testing.T) {
+	iamClient := &mockIamClientForRDSTests{attachRolePolicyErr: errors.New("fail")}
+	adapter := newIAMAuthTestAdapter(iamClient, &mockInstanceDBConn{}, &rds.DBInstance{})
+	adapter.parameterGroupClient = &mockParameterGroupClient{}
+	i := newMonitoringTestInstance()
+
+	status, err := adapter.createDB(i, "password")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if status != base.InstanceNotCreated {
+		t.Errorf("expected status %s, got %s", base.InstanceNotCreated, status)
+	}
+	if diff := deep.Equal(iamClient.deletedRoles, []string{"db-name-monitoring"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestMonitoringRolePropagation(t *testing.T) {
+	delay := monitoringRolePropagationDelay
+	monitoringRolePropagationDelay = 0
+	defer func() { monitoringRolePropagationDelay = delay }()
+
+	iamClient := &mockIamClientForRDSTests{}
+	adapter := newIAMAuthTestAdapter(iamClient, &mockInstanceDBConn{}, &rds.DBInstance{})
+	adapter.parameterGroupClient = &mockParameterGroupClient{}
+	rdsClient := &mockRdsClientForAdapterTests{monitoringRoleRejections: 2}
+	adapter.rds = rdsClient
+	i := newMonitoringTestInstance()
+
+	status, err := adapter.createDB(i, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != base.InstanceInProgress {
+		t.Errorf("expected status %s, got %s", base.InstanceInProgress, status)
+	}
+	if rdsClient.createDbCalls != 3 {
+		t.Errorf("expected 3 create calls, got %d", rdsClient.createDbCalls)
+	}
+
+	rdsClient.monitoringRoleRejections = monitoringRolePropagationRetries + 1
+	status, err = adapter.modifyDB(i, "password")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if status != base.InstanceNotModified {
+		t.Errorf("expected status %s, got %s", base.InstanceNotModified, status)
+	}
+	if rdsClient.modifyDbCalls != monitoringRolePropagationRetries+1 {
+		t.Errorf("expected %d modify calls, got %d", monitoringRolePropagationRetries+1, rdsClient.modifyDbCalls)
+	}
+}
@@ -3,11 +3,12 @@ package rds
 import (
 	"errors"
 	"fmt"
-	"log"
 	"regexp"
 	"strings"
 
+	"code.cloudfoundry.org/lager"
 	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/helpers/logging"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/rds"
@@ -27,6 +28,7 @@ type awsParameterGroupClient struct {
 	rds                  rdsiface.RDSAPI
 	settings             config.Settings
 	parameterGroupPrefix string
+	logger               lager.Logger
 }
 
 type paramDetails struct {
@@ -34,11 +36,12 @@ type paramDetails struct {
 	applyMethod string
 }
 
-func NewAwsParameterGroupClient(rds rdsiface.RDSAPI, settings config.Settings) *awsParameterGroupClient {
+func NewAwsParameterGroupClient(rds rdsiface.RDSAPI, settings config.Settings, logger lager.Logger) *awsParameterGroupClient {
 	return &awsParameterGroupClient{
 		rds:                  rds,
 		settings:             settings,
 		parameterGroupPrefix: "cg-aws-broker-",
+		logger:               logger.Session("parameter-group"),
 	}
 }
 
@@ -47,6 +50,12 @@ func NewAwsParameterGroupClient(rds rdsiface.RDSAPI, settings config.Settings) *
 // create a new parameter group or modify an existing one with the correct parameters for the
 // instance
 func (p *awsParameterGroupClient) ProvisionCustomParameterGroupIfNecessary(i *RDSInstance, rdsTags []*rds.Tag) error {
+	if i.upgradeMajorVersion {
+		if err := p.migrateParameterGroupFamily(i); err != nil {
+			return fmt.Errorf("encountered error migrating parameter group: %w", err)
+		}
+	}
+
 	if !p.needCustomParameters(i) {
 		return nil
 	}
@@ -61,7 +70,7 @@ func (p *awsParameterGroupClient) ProvisionCustomParameterGroupIfNecessary(i *RD
 	// apply parameter group
 	err = p.createOrModifyCustomParameterGroup(i, rdsTags, customRDSParameters)
 	if err != nil {
-		log.Println(err.Error())
+		logging.LogAWSError(p.logger, "apply-parameter-group", err)
 		return fmt.Errorf("encountered error applying parameter group: %w", err)
 	}
 	return nil
@@ -76,7 +85,10 @@ func (p *awsParameterGroupClient) CleanupCustomParameterGroups() {
 		for _, pgroup := range pgroups.DBParameterGroups {
 			matched, err := regexp.Match("^"+p.parameterGroupPrefix, []byte(*pgroup.DBParameterGroupName))
 			if err != nil {
-				log.Printf("error trying to match %s in %s: %s", p.parameterGroupPrefix, *pgroup.DBParameterGroupName, err.Error())
+				p.logger.Error("match-parameter-group-prefix", err, lager.Data{
+					"prefix":          p.parameterGroupPrefix,
+					"parameter-group": *pgroup.DBParameterGroupName,
+				})
 			}
 			if matched {
 				deleteinput := &rds.DeleteDBParameterGroupInput{
@@ -84,20 +96,20 @@ func (p *awsParameterGroupClient) CleanupCustomParameterGroups() {
 				}
 				_, err := p.rds.DeleteDBParameterGroup(deleteinput)
 				if err == nil {
-					log.Printf("cleaned up %s parameter group", *pgroup.DBParameterGroupName)
+					p.logger.Info("cleaned-up-parameter-group", lager.Data{"parameter-group": *pgroup.DBParameterGroupName})
 				} else if err.(awserr.Error).Code() != "InvalidDBParameterGroupState" {
 					// If you can't delete it because it's in use, that is fine.
 					// The db takes a while to delete, so we will clean it up the
 					// next time this is called.  Otherwise there is some sort of AWS error
 					// and we should log that.
-					log.Printf("There was an error cleaning up the %s parameter group.  The error was: %s", *pgroup.DBParameterGroupName, err.Error())
+					logging.LogAWSError(p.logger, "cleanup-parameter-group", err, lager.Data{"parameter-group": *pgroup.DBParameterGroupName})
 				}
 			}
 		}
 		return true
 	})
 	if err != nil {
-		log.Printf("Could not retrieve list of parameter groups while cleaning up: %s", err.Error())
+		logging.LogAWSError(p.logger, "describe-parameter-groups", err)
 	}
 }
 
@@ -106,6 +118,19 @@ func (p *awsParameterGroupClient) getDatabaseEngineVersion(i *RDSInstance) (stri
 		return "", errors.New("database name is required to get database engine version")
 	}
 
+	if i.isAurora() {
+		dbClusterInfo, err := p.rds.DescribeDBClusters(&rds.DescribeDBClustersInput{
+			DBClusterIdentifier: aws.String(i.Database),
+		})
+		if err != nil {
+			return "", err
+		}
+		if len(dbClusterInfo.DBClusters) == 0 || aws.StringValue(dbClusterInfo.DBClusters[0].EngineVersion) == "" {
+			return "", errors.New("could not determine DB version to set parameter group family")
+		}
+		return *dbClusterInfo.DBClusters[0].EngineVersion, nil
+	}
+
 	dbInstanceInfo, err := p.rds.DescribeDBInstances(&rds.DescribeDBInstancesInput{
 		DBInstanceIdentifier: aws.String(i.Database),
 	})
@@ -137,7 +162,7 @@ func (p *awsParameterGroupClient) getParameterGroupFamily(i *RDSInstance) error
 	}
 
 	dbEngineVersionsInput := &rds.DescribeDBEngineVersionsInput{
-		Engine:        aws.String(i.DbType),
+		Engine:        aws.String(i.engine()),
 		EngineVersion: aws.String(i.DbVersion),
 	}
 
@@ -151,11 +176,54 @@ func (p *awsParameterGroupClient) getParameterGroupFamily(i *RDSInstance) error
 	// retrieve its actual value.
 	parameterGroupFamily = *defaultEngineInfo.DBEngineVersions[0].DBParameterGroupFamily
 
-	log.Printf("got parameter group family: %s", parameterGroupFamily)
+	p.logger.Debug("got-parameter-group-family", lager.Data{"family": parameterGroupFamily})
 	i.ParameterGroupFamily = parameterGroupFamily
 	return nil
 }
 
+// migrateParameterGroupFamily moves an instance that is upgraded to a new major
+// version off a custom parameter group of the family of its previous version,
+// which cannot be used with the new version. The instance is given a custom
+// parameter group of the new family if it still needs one, or else the default
+// parameter group of the new family.
+func (p *awsParameterGroupClient) migrateParameterGroupFamily(i *RDSInstance) error {
+	if i.ParameterGroupName == "" {
+		// RDS moves instances on a default parameter group to the new default.
+		return nil
+	}
+
+	// The family of the version that the instance is upgraded to.
+	i.ParameterGroupFamily = ""
+	if err := p.getParameterGroupFamily(i); err != nil {
+		return err
+	}
+
+	resp, err := p.rds.DescribeDBParameterGroups(&rds.DescribeDBParameterGroupsInput{
+		DBParameterGroupName: aws.String(i.ParameterGroupName),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == rds.ErrCodeDBParameterGroupNotFoundFault {
+			return nil
+		}
+		return err
+	}
+	if len(resp.DBParameterGroups) > 0 && aws.StringValue(resp.DBParameterGroups[0].DBParameterGroupFamily) == i.ParameterGroupFamily {
+		return nil
+	}
+
+	p.logger.Info("migrate-parameter-group-family", lager.Data{
+		"parameter-group": i.ParameterGroupName,
+		"family":          i.ParameterGroupFamily,
+	})
+	if p.needCustomParameters(i) {
+		// Parameter group names cannot contain periods, as in "mysql8.0".
+		i.ParameterGroupName = getParameterGroupName(i, p) + "-" + strings.ReplaceAll(i.ParameterGroupFamily, ".", "-")
+	} else {
+		i.ParameterGroupName = "default." + i.ParameterGroupFamily
+	}
+	return nil
+}
+
 func (p *awsParameterGroupClient) checkIfParameterGroupExists(parameterGroupName string) bool {
 	dbParametersInput := &rds.DescribeDBParametersInput{
 		DBParameterGroupName: aws.String(parameterGroupName),
@@ -167,7 +235,7 @@ func (p *awsParameterGroupClient) checkIfParameterGroupExists(parameterGroupName
 	_, err := p.rds.DescribeDBParameters(dbParametersInput)
 	parameterGroupExists := (err == nil)
 	if parameterGroupExists {
-		log.Printf("%s parameter group already exists", parameterGroupName)
+		p.logger.Debug("parameter-group-exists", lager.Data{"parameter-group": parameterGroupName})
 	}
 	return parameterGroupExists
 }
@@ -179,6 +247,10 @@ func (p *awsParameterGroupClient) createOrModifyCustomParameterGroup(
 	rdsTags []*rds.Tag,
 	customparams map[string]map[string]paramDetails,
 ) error {
+	if i.isAurora() {
+		return p.createOrModifyCustomClusterParameterGroup(i, rdsTags, customparams)
+	}
+
 	parameterGroupExists := p.checkIfParameterGroupExists(i.ParameterGroupName)
 	if !parameterGroupExists {
 		// Otherwise, create a new parameter group in the proper family.
@@ -187,7 +259,10 @@ func (p *awsParameterGroupClient) createOrModifyCustomParameterGroup(
 			return fmt.Errorf("encountered error getting parameter group family: %w", err)
 		}
 
-		log.Printf("creating a parameter group named %s in the family of %s", i.ParameterGroupName, i.ParameterGroupFamily)
+		p.logger.Info("create-parameter-group", lager.Data{
+			"parameter-group": i.ParameterGroupName,
+			"family":          i.ParameterGroupFamily,
+		})
 		createInput := &rds.CreateDBParameterGroupInput{
 			DBParameterGroupFamily: aws.String(i.ParameterGroupFamily),
 			DBParameterGroupName:   aws.String(i.ParameterGroupName),
@@ -224,7 +299,62 @@ func (p *awsParameterGroupClient) createOrModifyCustomParameterGroup(
 	return nil
 }
 
+// createOrModifyCustomClusterParameterGroup is createOrModifyCustomParameterGroup
+// for the DB cluster parameter groups of Aurora instances, which hold the
+// custom parameters of their cluster.
+func (p *awsParameterGroupClient) createOrModifyCustomClusterParameterGroup(
+	i *RDSInstance,
+	rdsTags []*rds.Tag,
+	customparams map[string]map[string]paramDetails,
+) error {
+	_, err := p.rds.DescribeDBClusterParameterGroups(&rds.DescribeDBClusterParameterGroupsInput{
+		DBClusterParameterGroupName: aws.String(i.ParameterGroupName),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != rds.ErrCodeDBParameterGroupNotFoundFault {
+			return err
+		}
+
+		err := p.getParameterGroupFamily(i)
+		if err != nil {
+			return fmt.Errorf("encountered error getting parameter group family: %w", err)
+		}
+
+		p.logger.Info("create-cluster-parameter-group", lager.Data{
+			"parameter-group": i.ParameterGroupName,
+			"family":          i.ParameterGroupFamily,
+		})
+		_, err = p.rds.CreateDBClusterParameterGroup(&rds.CreateDBClusterParameterGroupInput{
+			DBParameterGroupFamily:      aws.String(i.ParameterGroupFamily),
+			DBClusterParameterGroupName: aws.String(i.ParameterGroupName),
+			Description:                 aws.String("aws broker cluster parameter group for " + i.FormatDBName()),
+			Tags:                        rdsTags,
+		})
+		if err != nil {
+			return fmt.Errorf("encountered error when creating cluster parameter group: %w", err)
+		}
+	}
+
+	parameters := []*rds.Parameter{}
+	for paramName, paramDetails := range customparams[i.DbType] {
+		parameters = append(parameters, &rds.Parameter{
+			ApplyMethod:    aws.String(paramDetails.applyMethod),
+			ParameterName:  aws.String(paramName),
+			ParameterValue: aws.String(paramDetails.value),
+		})
+	}
+
+	_, err = p.rds.ModifyDBClusterParameterGroup(&rds.ModifyDBClusterParameterGroupInput{
+		DBClusterParameterGroupName: aws.String(i.ParameterGroupName),
+		Parameters:                  parameters,
+	})
+	return err
+}
+
 func (p *awsParameterGroupClient) needCustomParameters(i *RDSInstance) bool {
+	if len(i.DBParameters) > 0 {
+		return true
+	}
 	if i.EnableFunctions &&
 		p.settings.EnableFunctionsFeature &&
 		(i.DbType == "mysql") {
@@ -238,6 +368,10 @@ func (p *awsParameterGroupClient) needCustomParameters(i *RDSInstance) bool {
 		(i.DbType == "postgres") {
 		return true
 	}
+	if len(extensionPreloadLibraries(i.Extensions)) > 0 &&
+		(i.DbType == "postgres") {
+		return true
+	}
 
 	return false
 }
@@ -281,23 +415,77 @@ func (p *awsParameterGroupClient) getCustomParameterValue(i *RDSInstance, parame
 	return parameterValue, err
 }
 
+// getClusterParameterValue will get the value of a parameter from the cluster parameter group
+// of an Aurora instance if it has a custom one, or from the engine default cluster parameters if not
+func (p *awsParameterGroupClient) getClusterParameterValue(i *RDSInstance, parameterName string) (string, error) {
+	if i.ParameterGroupName != "" {
+		p.logger.Debug("get-custom-cluster-parameter-value", lager.Data{"parameter": parameterName, "parameter-group": i.ParameterGroupName})
+		var parameterValueChannel = make(chan string, 1)
+		err := p.rds.DescribeDBClusterParametersPages(&rds.DescribeDBClusterParametersInput{
+			DBClusterParameterGroupName: aws.String(i.ParameterGroupName),
+			Source:                      aws.String("user"),
+		}, func(result *rds.DescribeDBClusterParametersOutput, lastPage bool) bool {
+			return handleParameterPage(parameterValueChannel, lastPage, result.Parameters, parameterName)
+		})
+		if err != nil {
+			return "", err
+		}
+		return <-parameterValueChannel, nil
+	}
+
+	p.logger.Debug("get-default-cluster-parameter-value", lager.Data{"parameter": parameterName})
+	err := p.getParameterGroupFamily(i)
+	if err != nil {
+		return "", err
+	}
+	input := &rds.DescribeEngineDefaultClusterParametersInput{
+		DBParameterGroupFamily: aws.String(i.ParameterGroupFamily),
+	}
+	for {
+		result, err := p.rds.DescribeEngineDefaultClusterParameters(input)
+		if err != nil {
+			return "", err
+		}
+		parameterValue := findParameterValueInResults(result.EngineDefaults.Parameters, parameterName)
+		if parameterValue != "" || aws.StringValue(result.EngineDefaults.Marker) == "" {
+			return parameterValue, nil
+		}
+		input.Marker = result.EngineDefaults.Marker
+	}
+}
+
 // getParameterValue will get the value of a parameter from the instance's custom parameter group if one exists
 // or from the engine default parameter group if not
 func (p *awsParameterGroupClient) getParameterValue(i *RDSInstance, parameterName string) (string, error) {
+	if i.isAurora() {
+		return p.getClusterParameterValue(i, parameterName)
+	}
 	if i.ParameterGroupName != "" {
-		log.Printf("getting parameter %s from group %s", parameterName, i.ParameterGroupName)
+		p.logger.Debug("get-custom-parameter-value", lager.Data{"parameter": parameterName, "parameter-group": i.ParameterGroupName})
 		return p.getCustomParameterValue(i, parameterName)
 	}
-	log.Printf("getting parameter %s from engine defaults", parameterName)
+	p.logger.Debug("get-default-parameter-value", lager.Data{"parameter": parameterName})
 	return p.getDefaultEngineParameterValue(i, parameterName)
 }
 
 func (p *awsParameterGroupClient) getCustomParameters(i *RDSInstance) (map[string]map[string]paramDetails, error) {
 	customRDSParameters := make(map[string]map[string]paramDetails)
 
+	// The parameters set by the tenant come first, so that the parameters
+	// managed by the broker take precedence.
+	if len(i.DBParameters) > 0 {
+		dbParameters, err := p.getDBParameters(i)
+		if err != nil {
+			return nil, err
+		}
+		customRDSParameters[i.DbType] = dbParameters
+	}
+
 	if i.DbType == "mysql" {
 		// enable functions
-		customRDSParameters["mysql"] = make(map[string]paramDetails)
+		if customRDSParameters["mysql"] == nil {
+			customRDSParameters["mysql"] = make(map[string]paramDetails)
+		}
 		if i.EnableFunctions && p.settings.EnableFunctionsFeature {
 			customRDSParameters["mysql"]["log_bin_trust_function_creators"] = paramDetails{
 				value:       "1",
@@ -321,18 +509,35 @@ func (p *awsParameterGroupClient) getCustomParameters(i *RDSInstance) (map[strin
 	}
 
 	if i.DbType == "postgres" {
-		customRDSParameters["postgres"] = make(map[string]paramDetails)
-		if i.EnablePgCron != nil {
+		if customRDSParameters["postgres"] == nil {
+			customRDSParameters["postgres"] = make(map[string]paramDetails)
+		}
+		preloadLibraries := extensionPreloadLibraries(i.Extensions)
+		if i.EnablePgCron != nil || len(preloadLibraries) > 0 {
 			parameterValue, err := p.getParameterValue(i, sharedPreloadLibrariesParameterName)
 			if err != nil {
 				return nil, err
 			}
-			var sharedPreloadLibsParamValue string
-			if *i.EnablePgCron {
-				sharedPreloadLibsParamValue = addLibraryToSharedPreloadLibraries(parameterValue, pgCronLibraryName)
-			} else {
-				sharedPreloadLibsParamValue = removeLibraryFromSharedPreloadLibraries(parameterValue, pgCronLibraryName)
+			sharedPreloadLibsParamValue := parameterValue
+			if i.EnablePgCron != nil {
+				if *i.EnablePgCron {
+					sharedPreloadLibsParamValue = addLibraryToSharedPreloadLibraries(sharedPreloadLibsParamValue, pgCronLibraryName)
+				} else {
+					sharedPreloadLibsParamValue = removeLibraryFromSharedPreloadLibraries(sharedPreloadLibsParamValue, pgCronLibraryName)
+				}
+			}
+			// The libraries of extensions are only added once, since the
+			// current value already includes them after the first update.
+			for _, library := range preloadLibraries {
+				if !hasSharedPreloadLibrary(sharedPreloadLibsParamValue, library) {
+					sharedPreloadLibsParamValue = addLibraryToSharedPreloadLibraries(sharedPreloadLibsParamValue, library)
+					i.ExtensionsRebootPending = true
+				}
 			}
+			p.logger.Debug("generate-shared-preload-libraries", lager.Data{
+				"parameter-group": i.ParameterGroupName,
+				"libraries":       sharedPreloadLibsParamValue,
+			})
 			customRDSParameters["postgres"][sharedPreloadLibrariesParameterName] = paramDetails{
 				value:       sharedPreloadLibsParamValue,
 				applyMethod: "pending-reboot",
@@ -343,6 +548,78 @@ func (p *awsParameterGroupClient) getCustomParameters(i *RDSInstance) (map[strin
 	return customRDSParameters, nil
 }
 
+// getEngineDefaultParameters gets the parameters of the parameter group family
+// of the instance by name, or the cluster parameters for Aurora instances.
+func (p *awsParameterGroupClient) getEngineDefaultParameters(i *RDSInstance) (map[string]*rds.Parameter, error) {
+	err := p.getParameterGroupFamily(i)
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := make(map[string]*rds.Parameter)
+	if i.isAurora() {
+		input := &rds.DescribeEngineDefaultClusterParametersInput{
+			DBParameterGroupFamily: aws.String(i.ParameterGroupFamily),
+		}
+		for {
+			result, err := p.rds.DescribeEngineDefaultClusterParameters(input)
+			if err != nil {
+				return nil, err
+			}
+			for _, parameter := range result.EngineDefaults.Parameters {
+				parameters[aws.StringValue(parameter.ParameterName)] = parameter
+			}
+			if aws.StringValue(result.EngineDefaults.Marker) == "" {
+				return parameters, nil
+			}
+			input.Marker = result.EngineDefaults.Marker
+		}
+	}
+
+	err = p.rds.DescribeEngineDefaultParametersPages(&rds.DescribeEngineDefaultParametersInput{
+		DBParameterGroupFamily: aws.String(i.ParameterGroupFamily),
+	}, func(result *rds.DescribeEngineDefaultParametersOutput, lastPage bool) bool {
+		for _, parameter := range result.EngineDefaults.Parameters {
+			parameters[aws.StringValue(parameter.ParameterName)] = parameter
+		}
+		return !lastPage
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parameters, nil
+}
+
+// getDBParameters checks the database parameters set by the tenant against
+// the parameters of the parameter group family of the instance. Dynamic
+// parameters are applied immediately and static ones on the next reboot.
+func (p *awsParameterGroupClient) getDBParameters(i *RDSInstance) (map[string]paramDetails, error) {
+	engineParameters, err := p.getEngineDefaultParameters(i)
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := make(map[string]paramDetails)
+	for name, value := range i.dbParameters() {
+		engineParameter, ok := engineParameters[name]
+		if !ok {
+			return nil, fmt.Errorf("%s is not a parameter of %s", name, i.ParameterGroupFamily)
+		}
+		if err := validateDBParameterValue(engineParameter, value); err != nil {
+			return nil, err
+		}
+		applyMethod := "immediate"
+		if aws.StringValue(engineParameter.ApplyType) == "static" {
+			applyMethod = "pending-reboot"
+		}
+		parameters[name] = paramDetails{
+			value:       value,
+			applyMethod: applyMethod,
+		}
+	}
+	return parameters, nil
+}
+
 // getParameterGroupName gets a parameter group name for the instance
 func getParameterGroupName(i *RDSInstance, p *awsParameterGroupClient) string {
 	// i.FormatDBName() should always return the same value for the same database name,
@@ -352,7 +629,9 @@ func getParameterGroupName(i *RDSInstance, p *awsParameterGroupClient) string {
 
 // setParameterGroupName sets the parameter group name on the instance struct
 func setParameterGroupName(i *RDSInstance, p *awsParameterGroupClient) {
-	if i.ParameterGroupName != "" {
+	// A default parameter group cannot be modified, so instances on one are
+	// given a custom parameter group when they need custom parameters.
+	if i.ParameterGroupName != "" && !strings.HasPrefix(i.ParameterGroupName, "default.") {
 		return
 	}
 	i.ParameterGroupName = getParameterGroupName(i, p)
@@ -366,7 +645,6 @@ func findParameterValueInResults(
 	var parameterValue string
 	for _, param := range parameters {
 		if *param.ParameterName == parameterName {
-			log.Printf("found parameter value %s for parameter %s", *param.ParameterValue, parameterName)
 			parameterValue = *param.ParameterValue
 		}
 	}
@@ -399,9 +677,17 @@ func addLibraryToSharedPreloadLibraries(
 	if currentParameterValue != "" {
 		libraries = append(libraries, currentParameterValue)
 	}
-	customSharePreloadLibrariesParam := strings.Join(libraries, ",")
-	log.Printf("generated custom %s param: %s", sharedPreloadLibrariesParameterName, customSharePreloadLibrariesParam)
-	return customSharePreloadLibrariesParam
+	return strings.Join(libraries, ",")
+}
+
+// hasSharedPreloadLibrary reports whether the specified library is in the value of the shared_preload_libraries parameter.
+func hasSharedPreloadLibrary(currentParameterValue string, library string) bool {
+	for _, currentLibrary := range strings.Split(currentParameterValue, ",") {
+		if strings.TrimSpace(currentLibrary) == library {
+			return true
+		}
+	}
+	return false
 }
 
 // removeLibraryFromSharedPreloadLibraries removes the specified custom library name from the current value of the shared_preload_libraries parameter.
@@ -410,7 +696,6 @@ func removeLibraryFromSharedPreloadLibraries(
 	customLibrary string,
 ) string {
 	if currentParameterValue == "" {
-		log.Printf("Parameter value for %s is required\n, none found", sharedPreloadLibrariesParameterName)
 		return currentParameterValue
 	}
 	libraries := strings.Split(currentParameterValue, ",")
@@ -419,7 +704,5 @@ func removeLibraryFromSharedPreloadLibraries(
 			libraries = append(libraries[:idx], libraries[idx+1:]...)
 		}
 	}
-	customSharePreloadLibrariesParam := strings.Join(libraries, ",")
-	log.Printf("generated custom %s param: %s", sharedPreloadLibrariesParameterName, customSharePreloadLibrariesParam)
-	return customSharePreloadLibrariesParam
+	return strings.Join(libraries, ",")
 }
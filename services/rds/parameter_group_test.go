@@ -5,8 +5,10 @@ import (
 	"reflect"
 	"testing"
 
+	"code.cloudfoundry.org/lager/lagertest"
 	"github.com/18F/aws-broker/config"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/rds"
 	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
 )
@@ -28,6 +30,11 @@ type mockRDSClient struct {
 	describeDbParamsPageNum             int
 	describeDbInstancesResults          *rds.DescribeDBInstancesOutput
 	describeDbInstancesErr              error
+	describeDbParamGroupsResults        []*rds.DBParameterGroup
+	describeDbParamGroupsErr            error
+	describeDbClusterParamGroupsErr     error
+	createDbClusterParamGroupErr        error
+	createdDbClusterParamGroup          *rds.CreateDBClusterParameterGroupInput
 }
 
 func (m mockRDSClient) DescribeDBParameters(*rds.DescribeDBParametersInput) (*rds.DescribeDBParametersOutput, error) {
@@ -98,6 +105,35 @@ func (m *mockRDSClient) DescribeDBInstances(input *rds.DescribeDBInstancesInput)
 	return m.describeDbInstancesResults, nil
 }
 
+func (m *mockRDSClient) DescribeDBParameterGroups(input *rds.DescribeDBParameterGroupsInput) (*rds.DescribeDBParameterGroupsOutput, error) {
+	if m.describeDbParamGroupsErr != nil {
+		return nil, m.describeDbParamGroupsErr
+	}
+	return &rds.DescribeDBParameterGroupsOutput{DBParameterGroups: m.describeDbParamGroupsResults}, nil
+}
+
+func (m *mockRDSClient) DescribeDBClusterParameterGroups(input *rds.DescribeDBClusterParameterGroupsInput) (*rds.DescribeDBClusterParameterGroupsOutput, error) {
+	if m.describeDbClusterParamGroupsErr != nil {
+		return nil, m.describeDbClusterParamGroupsErr
+	}
+	return &rds.DescribeDBClusterParameterGroupsOutput{}, nil
+}
+
+func (m *mockRDSClient) CreateDBClusterParameterGroup(input *rds.CreateDBClusterParameterGroupInput) (*rds.CreateDBClusterParameterGroupOutput, error) {
+	m.createdDbClusterParamGroup = input
+	if m.createDbClusterParamGroupErr != nil {
+		return nil, m.createDbClusterParamGroupErr
+	}
+	return nil, nil
+}
+
+func (m *mockRDSClient) ModifyDBClusterParameterGroup(*rds.ModifyDBClusterParameterGroupInput) (*rds.DBClusterParameterGroupNameMessage, error) {
+	if m.modifyDbParamGroupErr != nil {
+		return nil, m.modifyDbParamGroupErr
+	}
+	return nil, nil
+}
+
 func createTestRdsInstance(i *RDSInstance) *RDSInstance {
 	i.dbUtils = &RDSDatabaseUtils{}
 	return i
@@ -107,6 +143,7 @@ func TestNewParameterGroupAdapter(t *testing.T) {
 	parameterGroupAdapter := NewAwsParameterGroupClient(
 		&mockRDSClient{},
 		config.Settings{},
+		lagertest.NewTestLogger("test"),
 	)
 	if parameterGroupAdapter.parameterGroupPrefix != "cg-aws-broker-" {
 		t.Errorf("actual prefix: %s", parameterGroupAdapter.parameterGroupPrefix)
@@ -115,6 +152,7 @@ func TestNewParameterGroupAdapter(t *testing.T) {
 
 func TestGetParameterGroupName(t *testing.T) {
 	p := &awsParameterGroupClient{
+		logger:               lagertest.NewTestLogger("test"),
 		parameterGroupPrefix: "prefix-",
 	}
 	i := createTestRdsInstance(&RDSInstance{
@@ -135,6 +173,7 @@ func TestSetParameterGroupName(t *testing.T) {
 	}{
 		"no existing value": {
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:               lagertest.NewTestLogger("test"),
 				parameterGroupPrefix: "prefix-",
 			},
 			dbInstance: &RDSInstance{
@@ -144,13 +183,27 @@ func TestSetParameterGroupName(t *testing.T) {
 			expectedParameterGroupName: "prefix-db1234",
 		},
 		"has existing value": {
-			parameterGroupAdapter: &awsParameterGroupClient{},
+			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
+			},
 			dbInstance: &RDSInstance{
 				ParameterGroupName: "param-group-1234",
 				dbUtils:            &RDSDatabaseUtils{},
 			},
 			expectedParameterGroupName: "param-group-1234",
 		},
+		"has default parameter group": {
+			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:               lagertest.NewTestLogger("test"),
+				parameterGroupPrefix: "prefix-",
+			},
+			dbInstance: &RDSInstance{
+				Database:           "db1234",
+				ParameterGroupName: "default.postgres15",
+				dbUtils:            &RDSDatabaseUtils{},
+			},
+			expectedParameterGroupName: "prefix-db1234",
+		},
 	}
 
 	for name, test := range testCases {
@@ -175,6 +228,7 @@ func TestNeedCustomParameters(t *testing.T) {
 			},
 			expectedOk: false,
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
 				settings: config.Settings{},
 			},
 		},
@@ -185,10 +239,47 @@ func TestNeedCustomParameters(t *testing.T) {
 				dbUtils:         &RDSDatabaseUtils{},
 			},
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
 				settings: config.Settings{},
 			},
 			expectedOk: true,
 		},
+		"db parameters": {
+			dbInstance: &RDSInstance{
+				DBParameters: []string{"work_mem=4096"},
+				DbType:       "postgres",
+				dbUtils:      &RDSDatabaseUtils{},
+			},
+			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
+				settings: config.Settings{},
+			},
+			expectedOk: true,
+		},
+		"extensions with preload libraries": {
+			dbInstance: &RDSInstance{
+				Extensions: []string{"pg_stat_statements"},
+				DbType:     "postgres",
+				dbUtils:    &RDSDatabaseUtils{},
+			},
+			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
+				settings: config.Settings{},
+			},
+			expectedOk: true,
+		},
+		"extensions without preload libraries": {
+			dbInstance: &RDSInstance{
+				Extensions: []string{"pgcrypto"},
+				DbType:     "postgres",
+				dbUtils:    &RDSDatabaseUtils{},
+			},
+			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
+				settings: config.Settings{},
+			},
+			expectedOk: false,
+		},
 		"valid binary log format, wrong database type": {
 			dbInstance: &RDSInstance{
 				BinaryLogFormat: "ROW",
@@ -196,6 +287,7 @@ func TestNeedCustomParameters(t *testing.T) {
 				dbUtils:         &RDSDatabaseUtils{},
 			},
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
 				settings: config.Settings{},
 			},
 			expectedOk: false,
@@ -207,6 +299,7 @@ func TestNeedCustomParameters(t *testing.T) {
 				dbUtils:         &RDSDatabaseUtils{},
 			},
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				settings: config.Settings{
 					EnableFunctionsFeature: false,
 				},
@@ -220,6 +313,7 @@ func TestNeedCustomParameters(t *testing.T) {
 				dbUtils:         &RDSDatabaseUtils{},
 			},
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				settings: config.Settings{
 					EnableFunctionsFeature: true,
 				},
@@ -233,6 +327,7 @@ func TestNeedCustomParameters(t *testing.T) {
 				dbUtils:         &RDSDatabaseUtils{},
 			},
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				settings: config.Settings{
 					EnableFunctionsFeature: true,
 				},
@@ -246,6 +341,7 @@ func TestNeedCustomParameters(t *testing.T) {
 				dbUtils:         &RDSDatabaseUtils{},
 			},
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				settings: config.Settings{
 					EnableFunctionsFeature: true,
 				},
@@ -260,6 +356,7 @@ func TestNeedCustomParameters(t *testing.T) {
 			},
 			expectedOk: true,
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
 				settings: config.Settings{},
 			},
 		},
@@ -271,6 +368,7 @@ func TestNeedCustomParameters(t *testing.T) {
 			},
 			expectedOk: true,
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
 				settings: config.Settings{},
 			},
 		},
@@ -280,6 +378,7 @@ func TestNeedCustomParameters(t *testing.T) {
 			},
 			expectedOk: false,
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
 				settings: config.Settings{},
 			},
 		},
@@ -314,6 +413,7 @@ func TestGetDefaultEngineParameterValue(t *testing.T) {
 			paramName:          "shared_preload_libraries",
 			expectedParamValue: "",
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					describeEngineDefaultParamsResults: []*rds.DescribeEngineDefaultParametersOutput{
 						{
@@ -341,6 +441,7 @@ func TestGetDefaultEngineParameterValue(t *testing.T) {
 			paramName:          "shared_preload_libraries",
 			expectedParamValue: "random-library",
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					describeEngineDefaultParamsResults: []*rds.DescribeEngineDefaultParametersOutput{
 						{
@@ -372,6 +473,7 @@ func TestGetDefaultEngineParameterValue(t *testing.T) {
 			},
 			paramName: "shared_preload_libraries",
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					describeEngineDefaultParamsResults: []*rds.DescribeEngineDefaultParametersOutput{
 						{
@@ -417,6 +519,7 @@ func TestGetDefaultEngineParameterValue(t *testing.T) {
 			expectedErr:        describeEngineDefaultParamsErr,
 			expectedParamValue: "",
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					describeEngineDefaultParamsErr: describeEngineDefaultParamsErr,
 					dbEngineVersions: []*rds.DBEngineVersion{
@@ -437,6 +540,7 @@ func TestGetDefaultEngineParameterValue(t *testing.T) {
 			expectedErr:        describeEngVersionsErr,
 			expectedParamValue: "",
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					describeEngVersionsErr: describeEngVersionsErr,
 					describeEngineDefaultParamsResults: []*rds.DescribeEngineDefaultParametersOutput{
@@ -524,6 +628,7 @@ func TestGetCustomParameterValue(t *testing.T) {
 	}{
 		"no value": {
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					describeDbParamsResults: []*rds.DescribeDBParametersOutput{
 						{
@@ -543,6 +648,7 @@ func TestGetCustomParameterValue(t *testing.T) {
 		},
 		"gets value": {
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					describeDbParamsResults: []*rds.DescribeDBParametersOutput{
 						{
@@ -567,6 +673,7 @@ func TestGetCustomParameterValue(t *testing.T) {
 		},
 		"gets value, with paging": {
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					describeDbParamsResults: []*rds.DescribeDBParametersOutput{
 						{
@@ -599,6 +706,7 @@ func TestGetCustomParameterValue(t *testing.T) {
 		},
 		"error getting DB params": {
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					describeDbParamsErr: describeDbParamsError,
 				},
@@ -714,7 +822,8 @@ func TestGetCustomParameters(t *testing.T) {
 				},
 			},
 			parameterGroupAdapter: &awsParameterGroupClient{
-				rds: &mockRDSClient{},
+				logger: lagertest.NewTestLogger("test"),
+				rds:    &mockRDSClient{},
 				settings: config.Settings{
 					EnableFunctionsFeature: true,
 				},
@@ -734,7 +843,8 @@ func TestGetCustomParameters(t *testing.T) {
 				},
 			},
 			parameterGroupAdapter: &awsParameterGroupClient{
-				rds: &mockRDSClient{},
+				logger: lagertest.NewTestLogger("test"),
+				rds:    &mockRDSClient{},
 				settings: config.Settings{
 					EnableFunctionsFeature: true,
 				},
@@ -754,7 +864,8 @@ func TestGetCustomParameters(t *testing.T) {
 				},
 			},
 			parameterGroupAdapter: &awsParameterGroupClient{
-				rds: &mockRDSClient{},
+				logger: lagertest.NewTestLogger("test"),
+				rds:    &mockRDSClient{},
 				settings: config.Settings{
 					EnableFunctionsFeature: false,
 				},
@@ -778,6 +889,7 @@ func TestGetCustomParameters(t *testing.T) {
 				},
 			},
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
 				rds:      &mockRDSClient{},
 				settings: config.Settings{},
 			},
@@ -797,6 +909,7 @@ func TestGetCustomParameters(t *testing.T) {
 				},
 			},
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
 				settings: config.Settings{},
 				rds: &mockRDSClient{
 					describeEngineDefaultParamsResults: []*rds.DescribeEngineDefaultParametersOutput{
@@ -831,6 +944,7 @@ func TestGetCustomParameters(t *testing.T) {
 				},
 			},
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
 				settings: config.Settings{},
 				rds: &mockRDSClient{
 					describeDbParamsResults: []*rds.DescribeDBParametersOutput{
@@ -857,6 +971,7 @@ func TestGetCustomParameters(t *testing.T) {
 				},
 			},
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
 				settings: config.Settings{},
 				rds: &mockRDSClient{
 					describeEngineDefaultParamsResults: []*rds.DescribeEngineDefaultParametersOutput{
@@ -906,6 +1021,7 @@ func TestGetCustomParameters(t *testing.T) {
 				},
 			},
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
 				settings: config.Settings{},
 				rds: &mockRDSClient{
 					describeDbParamsResults: []*rds.DescribeDBParametersOutput{
@@ -931,6 +1047,7 @@ func TestGetCustomParameters(t *testing.T) {
 			expectedParams: nil,
 			expectedErr:    describeEngineParamsErr,
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
 				settings: config.Settings{},
 				rds: &mockRDSClient{
 					describeEngineDefaultParamsErr: describeEngineParamsErr,
@@ -952,12 +1069,126 @@ func TestGetCustomParameters(t *testing.T) {
 			expectedParams: nil,
 			expectedErr:    describeDbParamsErr,
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
 				settings: config.Settings{},
 				rds: &mockRDSClient{
 					describeDbParamsErr: describeDbParamsErr,
 				},
 			},
 		},
+		"extensions, existing parameter group": {
+			dbInstance: &RDSInstance{
+				Extensions:         []string{"pg_stat_statements", "pgaudit", "postgis"},
+				DbType:             "postgres",
+				DbVersion:          "15",
+				ParameterGroupName: "group1",
+			},
+			expectedParams: map[string]map[string]paramDetails{
+				"postgres": {
+					"shared_preload_libraries": paramDetails{
+						value:       "pgaudit,pg_stat_statements,foo",
+						applyMethod: "pending-reboot",
+					},
+				},
+			},
+			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
+				settings: config.Settings{},
+				rds: &mockRDSClient{
+					describeDbParamsResults: []*rds.DescribeDBParametersOutput{
+						{
+							Parameters: []*rds.Parameter{
+								{
+									ParameterName:  aws.String("shared_preload_libraries"),
+									ParameterValue: aws.String("pg_stat_statements,foo"),
+								},
+							},
+						},
+					},
+					describeDbParamsNumPages: 1,
+				},
+			},
+		},
+		"db parameters": {
+			dbInstance: &RDSInstance{
+				DBParameters: []string{"max_connections=200", "work_mem=8192"},
+				DbType:       "postgres",
+				DbVersion:    "15",
+			},
+			expectedParams: map[string]map[string]paramDetails{
+				"postgres": {
+					"max_connections": paramDetails{
+						value:       "200",
+						applyMethod: "pending-reboot",
+					},
+					"work_mem": paramDetails{
+						value:       "8192",
+						applyMethod: "immediate",
+					},
+				},
+			},
+			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
+				settings: config.Settings{},
+				rds: &mockRDSClient{
+					dbEngineVersions: []*rds.DBEngineVersion{
+						{
+							DBParameterGroupFamily: aws.String("postgres15"),
+						},
+					},
+					describeEngineDefaultParamsResults: []*rds.DescribeEngineDefaultParametersOutput{
+						{
+							EngineDefaults: &rds.EngineDefaults{
+								Parameters: []*rds.Parameter{
+									{
+										ParameterName: aws.String("max_connections"),
+										AllowedValues: aws.String("6-8388607"),
+										ApplyType:     aws.String("static"),
+										DataType:      aws.String("integer"),
+										IsModifiable:  aws.Bool(true),
+									},
+								},
+							},
+						},
+						{
+							EngineDefaults: &rds.EngineDefaults{
+								Parameters: []*rds.Parameter{
+									{
+										ParameterName: aws.String("work_mem"),
+										AllowedValues: aws.String("64-2147483647"),
+										ApplyType:     aws.String("dynamic"),
+										DataType:      aws.String("integer"),
+										IsModifiable:  aws.Bool(true),
+									},
+								},
+							},
+						},
+					},
+					describeEngineDefaultParamsNumPages: 2,
+				},
+			},
+		},
+		"db parameters, describe db default params error": {
+			dbInstance: &RDSInstance{
+				DBParameters: []string{"work_mem=8192"},
+				DbType:       "postgres",
+				DbVersion:    "15",
+			},
+			expectedParams: nil,
+			expectedErr:    describeEngineParamsErr,
+			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
+				settings: config.Settings{},
+				rds: &mockRDSClient{
+					describeEngineDefaultParamsErr: describeEngineParamsErr,
+					dbEngineVersions: []*rds.DBEngineVersion{
+						{
+							DBParameterGroupFamily: aws.String("postgres15"),
+						},
+					},
+				},
+			},
+		},
 		"disable PG cron, describe db default params error": {
 			dbInstance: &RDSInstance{
 				EnablePgCron: aws.Bool(false),
@@ -967,6 +1198,7 @@ func TestGetCustomParameters(t *testing.T) {
 			expectedParams: nil,
 			expectedErr:    describeEngineParamsErr,
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
 				settings: config.Settings{},
 				rds: &mockRDSClient{
 					describeEngineDefaultParamsErr: describeEngineParamsErr,
@@ -988,6 +1220,7 @@ func TestGetCustomParameters(t *testing.T) {
 			expectedParams: nil,
 			expectedErr:    describeDbParamsErr,
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:   lagertest.NewTestLogger("test"),
 				settings: config.Settings{},
 				rds: &mockRDSClient{
 					describeDbParamsErr: describeDbParamsErr,
@@ -1011,6 +1244,100 @@ func TestGetCustomParameters(t *testing.T) {
 	}
 }
 
+func TestGetCustomParametersExtensionsRebootPending(t *testing.T) {
+	newAdapter := func() *awsParameterGroupClient {
+		return &awsParameterGroupClient{
+			logger:   lagertest.NewTestLogger("test"),
+			settings: config.Settings{},
+			rds: &mockRDSClient{
+				describeDbParamsResults: []*rds.DescribeDBParametersOutput{
+					{
+						Parameters: []*rds.Parameter{
+							{
+								ParameterName:  aws.String("shared_preload_libraries"),
+								ParameterValue: aws.String("pg_stat_statements"),
+							},
+						},
+					},
+				},
+				describeDbParamsNumPages: 1,
+			},
+		}
+	}
+
+	// Libraries that are already preloaded do not need a reboot.
+	i := createTestRdsInstance(&RDSInstance{
+		Extensions:         []string{"pg_stat_statements"},
+		DbType:             "postgres",
+		DbVersion:          "15",
+		ParameterGroupName: "group1",
+	})
+	if _, err := newAdapter().getCustomParameters(i); err != nil {
+		t.Fatal(err)
+	}
+	if i.ExtensionsRebootPending {
+		t.Error("expected no reboot to be pending")
+	}
+
+	i.Extensions = []string{"pg_stat_statements", "pgaudit"}
+	if _, err := newAdapter().getCustomParameters(i); err != nil {
+		t.Fatal(err)
+	}
+	if !i.ExtensionsRebootPending {
+		t.Error("expected a reboot to be pending")
+	}
+}
+
+func TestGetDBParametersErrors(t *testing.T) {
+	testCases := map[string]struct {
+		dbParameters []string
+		expectedErr  string
+	}{
+		"unknown parameter": {
+			dbParameters: []string{"not_a_parameter=1"},
+			expectedErr:  "not_a_parameter is not a parameter of postgres15",
+		},
+		"invalid value": {
+			dbParameters: []string{"work_mem=1"},
+			expectedErr:  `Invalid value "1" for parameter work_mem; must be within 64-2147483647`,
+		},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			p := &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
+				rds: &mockRDSClient{
+					describeEngineDefaultParamsResults: []*rds.DescribeEngineDefaultParametersOutput{
+						{
+							EngineDefaults: &rds.EngineDefaults{
+								Parameters: []*rds.Parameter{
+									{
+										ParameterName: aws.String("work_mem"),
+										AllowedValues: aws.String("64-2147483647"),
+										ApplyType:     aws.String("dynamic"),
+										DataType:      aws.String("integer"),
+										IsModifiable:  aws.Bool(true),
+									},
+								},
+							},
+						},
+					},
+					describeEngineDefaultParamsNumPages: 1,
+				},
+			}
+			i := createTestRdsInstance(&RDSInstance{
+				DBParameters:         test.dbParameters,
+				DbType:               "postgres",
+				ParameterGroupFamily: "postgres15",
+			})
+			_, err := p.getDBParameters(i)
+			if err == nil || err.Error() != test.expectedErr {
+				t.Errorf("expected error %q, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
 func TestGetDatabaseEngineVersion(t *testing.T) {
 	testCases := map[string]struct {
 		dbInstance            *RDSInstance
@@ -1025,6 +1352,7 @@ func TestGetDatabaseEngineVersion(t *testing.T) {
 			},
 			expectedDbVersion: "version1",
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					describeDbInstancesResults: &rds.DescribeDBInstancesOutput{
 						DBInstances: []*rds.DBInstance{
@@ -1042,7 +1370,8 @@ func TestGetDatabaseEngineVersion(t *testing.T) {
 			},
 			expectedErr: "database name is required to get database engine version",
 			parameterGroupAdapter: &awsParameterGroupClient{
-				rds: &mockRDSClient{},
+				logger: lagertest.NewTestLogger("test"),
+				rds:    &mockRDSClient{},
 			},
 		},
 		"no db version, error getting database instance info": {
@@ -1052,6 +1381,7 @@ func TestGetDatabaseEngineVersion(t *testing.T) {
 			},
 			expectedErr: "describe db instances error",
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					describeDbInstancesErr: errors.New("describe db instances error"),
 				},
@@ -1088,6 +1418,7 @@ func TestGetParameterGroupFamily(t *testing.T) {
 			},
 			expectedPGroupFamily: "postgres1",
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					describeDbInstancesResults: &rds.DescribeDBInstancesOutput{
 						DBInstances: []*rds.DBInstance{
@@ -1111,6 +1442,7 @@ func TestGetParameterGroupFamily(t *testing.T) {
 			},
 			expectedPGroupFamily: "postgres13",
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					dbEngineVersions: []*rds.DBEngineVersion{
 						{
@@ -1127,6 +1459,7 @@ func TestGetParameterGroupFamily(t *testing.T) {
 			},
 			expectedErr: "fail",
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					describeEngVersionsErr: errors.New("fail"),
 				},
@@ -1138,7 +1471,8 @@ func TestGetParameterGroupFamily(t *testing.T) {
 			},
 			expectedPGroupFamily: "random-family",
 			parameterGroupAdapter: &awsParameterGroupClient{
-				rds: &mockRDSClient{},
+				logger: lagertest.NewTestLogger("test"),
+				rds:    &mockRDSClient{},
 			},
 		},
 	}
@@ -1171,6 +1505,7 @@ func TestCheckIfParameterGroupExists(t *testing.T) {
 			},
 			expectedExists: false,
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					describeDbParamsErr: dbParamsErr,
 				},
@@ -1182,7 +1517,8 @@ func TestCheckIfParameterGroupExists(t *testing.T) {
 			},
 			expectedExists: true,
 			parameterGroupAdapter: &awsParameterGroupClient{
-				rds: &mockRDSClient{},
+				logger: lagertest.NewTestLogger("test"),
+				rds:    &mockRDSClient{},
 			},
 		},
 	}
@@ -1215,6 +1551,7 @@ func TestCreateOrModifyCustomParameterGroup(t *testing.T) {
 			},
 			expectedErr: describeEngVersionsErr,
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					describeDbParamsErr:    errors.New("describe DB params err"),
 					describeEngVersionsErr: describeEngVersionsErr,
@@ -1230,6 +1567,7 @@ func TestCreateOrModifyCustomParameterGroup(t *testing.T) {
 			},
 			expectedErr: createDbParamGroupErr,
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					describeDbParamsErr:   errors.New("describe DB params err"),
 					createDbParamGroupErr: createDbParamGroupErr,
@@ -1250,6 +1588,7 @@ func TestCreateOrModifyCustomParameterGroup(t *testing.T) {
 			},
 			expectedErr: modifyDbParamGroupErr,
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					modifyDbParamGroupErr: modifyDbParamGroupErr,
 					dbEngineVersions: []*rds.DBEngineVersion{
@@ -1268,6 +1607,7 @@ func TestCreateOrModifyCustomParameterGroup(t *testing.T) {
 				ParameterGroupName: "foobar",
 			},
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					dbEngineVersions: []*rds.DBEngineVersion{
 						{
@@ -1292,6 +1632,72 @@ func TestCreateOrModifyCustomParameterGroup(t *testing.T) {
 	}
 }
 
+func TestCreateOrModifyCustomClusterParameterGroup(t *testing.T) {
+	createDbClusterParamGroupErr := errors.New("create DB cluster params err")
+	notFoundErr := awserr.New(rds.ErrCodeDBParameterGroupNotFoundFault, "not found", nil)
+
+	testCases := map[string]struct {
+		rds            *mockRDSClient
+		expectedErr    error
+		expectedFamily string
+	}{
+		"existing cluster parameter group": {
+			rds: &mockRDSClient{},
+		},
+		"new cluster parameter group": {
+			rds: &mockRDSClient{
+				describeDbClusterParamGroupsErr: notFoundErr,
+				dbEngineVersions: []*rds.DBEngineVersion{
+					{
+						DBParameterGroupFamily: aws.String("aurora-postgresql15"),
+					},
+				},
+			},
+			expectedFamily: "aurora-postgresql15",
+		},
+		"error creating cluster parameter group": {
+			rds: &mockRDSClient{
+				describeDbClusterParamGroupsErr: notFoundErr,
+				createDbClusterParamGroupErr:    createDbClusterParamGroupErr,
+				dbEngineVersions: []*rds.DBEngineVersion{
+					{
+						DBParameterGroupFamily: aws.String("aurora-postgresql15"),
+					},
+				},
+			},
+			expectedErr:    createDbClusterParamGroupErr,
+			expectedFamily: "aurora-postgresql15",
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			p := &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
+				rds:    test.rds,
+			}
+			i := createTestRdsInstance(&RDSInstance{
+				Adapter:            "aurora",
+				Database:           "foobar",
+				DbType:             "postgres",
+				DbVersion:          "15",
+				ParameterGroupName: "foobar",
+			})
+			err := p.createOrModifyCustomParameterGroup(i, nil, nil)
+			if !errors.Is(err, test.expectedErr) {
+				t.Errorf("expected error: %s, got: %s", test.expectedErr, err)
+			}
+			family := ""
+			if test.rds.createdDbClusterParamGroup != nil {
+				family = aws.StringValue(test.rds.createdDbClusterParamGroup.DBParameterGroupFamily)
+			}
+			if family != test.expectedFamily {
+				t.Errorf("expected family %q, got %q", test.expectedFamily, family)
+			}
+		})
+	}
+}
+
 func TestProvisionCustomParameterGroupIfNecessary(t *testing.T) {
 	modifyDbParamGroupErr := errors.New("create DB param group error")
 	testCases := map[string]struct {
@@ -1308,7 +1714,8 @@ func TestProvisionCustomParameterGroupIfNecessary(t *testing.T) {
 			},
 			expectedPGroupName: "",
 			parameterGroupAdapter: &awsParameterGroupClient{
-				rds: &mockRDSClient{},
+				logger: lagertest.NewTestLogger("test"),
+				rds:    &mockRDSClient{},
 			},
 		},
 		"enable binary log format, success": {
@@ -1319,6 +1726,7 @@ func TestProvisionCustomParameterGroupIfNecessary(t *testing.T) {
 			},
 			expectedPGroupName: "prefix-database1",
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger:               lagertest.NewTestLogger("test"),
 				rds:                  &mockRDSClient{},
 				parameterGroupPrefix: "prefix-",
 			},
@@ -1331,6 +1739,7 @@ func TestProvisionCustomParameterGroupIfNecessary(t *testing.T) {
 				Database:     "database2",
 			},
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					describeEngineDefaultParamsResults: []*rds.DescribeEngineDefaultParametersOutput{
 						{
@@ -1364,6 +1773,7 @@ func TestProvisionCustomParameterGroupIfNecessary(t *testing.T) {
 			},
 			expectedErr: modifyDbParamGroupErr,
 			parameterGroupAdapter: &awsParameterGroupClient{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRDSClient{
 					modifyDbParamGroupErr: modifyDbParamGroupErr,
 					describeDbParamsResults: []*rds.DescribeDBParametersOutput{
@@ -1402,3 +1812,108 @@ func TestProvisionCustomParameterGroupIfNecessary(t *testing.T) {
 		})
 	}
 }
+
+func TestMigrateParameterGroupFamily(t *testing.T) {
+	testCases := map[string]struct {
+		dbInstance                 *RDSInstance
+		rds                        *mockRDSClient
+		expectedParameterGroupName string
+		expectedErr                string
+	}{
+		"default parameter group": {
+			dbInstance: &RDSInstance{
+				DbType:    "postgres",
+				DbVersion: "15.5",
+			},
+			rds:                        &mockRDSClient{},
+			expectedParameterGroupName: "",
+		},
+		"parameter group of the new family": {
+			dbInstance: &RDSInstance{
+				DbType:             "postgres",
+				DbVersion:          "15.5",
+				EnablePgCron:       aws.Bool(true),
+				ParameterGroupName: "prefix-db1234",
+			},
+			rds: &mockRDSClient{
+				dbEngineVersions: []*rds.DBEngineVersion{
+					{DBParameterGroupFamily: aws.String("postgres15")},
+				},
+				describeDbParamGroupsResults: []*rds.DBParameterGroup{
+					{DBParameterGroupFamily: aws.String("postgres15")},
+				},
+			},
+			expectedParameterGroupName: "prefix-db1234",
+		},
+		"parameter group of the previous family": {
+			dbInstance: &RDSInstance{
+				Database:           "db1234",
+				DbType:             "postgres",
+				DbVersion:          "15.5",
+				EnablePgCron:       aws.Bool(true),
+				ParameterGroupName: "prefix-db1234",
+			},
+			rds: &mockRDSClient{
+				dbEngineVersions: []*rds.DBEngineVersion{
+					{DBParameterGroupFamily: aws.String("postgres15")},
+				},
+				describeDbParamGroupsResults: []*rds.DBParameterGroup{
+					{DBParameterGroupFamily: aws.String("postgres12")},
+				},
+			},
+			expectedParameterGroupName: "prefix-db1234-postgres15",
+		},
+		"parameter group of the previous family without custom parameters": {
+			dbInstance: &RDSInstance{
+				Database:           "db1234",
+				DbType:             "mysql",
+				DbVersion:          "8.0.35",
+				ParameterGroupName: "prefix-db1234",
+			},
+			rds: &mockRDSClient{
+				dbEngineVersions: []*rds.DBEngineVersion{
+					{DBParameterGroupFamily: aws.String("mysql8.0")},
+				},
+				describeDbParamGroupsResults: []*rds.DBParameterGroup{
+					{DBParameterGroupFamily: aws.String("mysql5.7")},
+				},
+			},
+			expectedParameterGroupName: "default.mysql8.0",
+		},
+		"describe error": {
+			dbInstance: &RDSInstance{
+				DbType:             "postgres",
+				DbVersion:          "15.5",
+				ParameterGroupName: "prefix-db1234",
+			},
+			rds: &mockRDSClient{
+				dbEngineVersions: []*rds.DBEngineVersion{
+					{DBParameterGroupFamily: aws.String("postgres15")},
+				},
+				describeDbParamGroupsErr: errors.New("fail"),
+			},
+			expectedParameterGroupName: "prefix-db1234",
+			expectedErr:                "fail",
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			p := &awsParameterGroupClient{
+				logger:               lagertest.NewTestLogger("test"),
+				rds:                  test.rds,
+				parameterGroupPrefix: "prefix-",
+			}
+			err := p.migrateParameterGroupFamily(createTestRdsInstance(test.dbInstance))
+			if test.expectedErr == "" && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if test.expectedErr != "" && (err == nil || err.Error() != test.expectedErr) {
+				t.Errorf("expected error: %s, got: %s", test.expectedErr, err)
+			}
+			if test.dbInstance.ParameterGroupName != test.expectedParameterGroupName {
+				t.Errorf("expected parameter group name: %s, got: %s", test.expectedParameterGroupName, test.dbInstance.ParameterGroupName)
+			}
+		})
+	}
+}
@@ -1,17 +1,26 @@
 package rds
 
 import (
+	"code.cloudfoundry.org/lager"
 	"github.com/18F/aws-broker/base"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/aws/aws-sdk-go/service/rds"
 	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	brokertags "github.com/cloud-gov/go-broker-tags"
 
 	"github.com/18F/aws-broker/catalog"
+	"github.com/18F/aws-broker/common"
 	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/helpers/logging"
 
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type dbAdapter interface {
@@ -19,7 +28,20 @@ type dbAdapter interface {
 	modifyDB(i *RDSInstance, password string) (base.InstanceState, error)
 	checkDBStatus(i *RDSInstance) (base.InstanceState, error)
 	bindDBToApp(i *RDSInstance, password string) (map[string]string, error)
-	deleteDB(i *RDSInstance) (base.InstanceState, error)
+	deleteDB(i *RDSInstance, finalSnapshotIdentifier string) (base.InstanceState, error)
+	checkDBDeleted(i *RDSInstance) (base.InstanceState, error)
+	describeDB(i *RDSInstance) (*rds.DBInstance, error)
+	createReadReplica(i *RDSInstance, r *ReadReplica) (base.InstanceState, error)
+	checkReadReplicaStatus(r *ReadReplica) (base.InstanceState, error)
+	deleteReadReplica(r *ReadReplica) (base.InstanceState, error)
+	findMajorVersionUpgradeTarget(i *RDSInstance, majorVersion string) (string, error)
+	createSnapshot(i *RDSInstance, name string, tags map[string]string) (base.Snapshot, error)
+	listSnapshots(i *RDSInstance) ([]base.Snapshot, error)
+	deleteSnapshot(snapshotIdentifier string) error
+	rotateMasterPassword(i *RDSInstance) error
+	getManagedMasterPassword(i *RDSInstance) (string, error)
+	stopDB(i *RDSInstance) error
+	startDB(i *RDSInstance) error
 }
 
 // MockDBAdapter is a struct meant for testing.
@@ -49,11 +71,82 @@ func (d *mockDBAdapter) bindDBToApp(i *RDSInstance, password string) (map[string
 	return i.getCredentials(password)
 }
 
-func (d *mockDBAdapter) deleteDB(i *RDSInstance) (base.InstanceState, error) {
+func (d *mockDBAdapter) deleteDB(i *RDSInstance, finalSnapshotIdentifier string) (base.InstanceState, error) {
+	// Deleting without a final snapshot completes immediately.
+	if finalSnapshotIdentifier == "" {
+		return base.InstanceGone, nil
+	}
+	return base.InstanceInProgress, nil
+}
+
+func (d *mockDBAdapter) checkDBDeleted(i *RDSInstance) (base.InstanceState, error) {
 	// TODO
 	return base.InstanceGone, nil
 }
 
+func (d *mockDBAdapter) describeDB(i *RDSInstance) (*rds.DBInstance, error) {
+	// TODO
+	return &rds.DBInstance{DBInstanceIdentifier: aws.String(i.Database)}, nil
+}
+
+func (d *mockDBAdapter) createReadReplica(i *RDSInstance, r *ReadReplica) (base.InstanceState, error) {
+	return base.InstanceInProgress, nil
+}
+
+func (d *mockDBAdapter) checkReadReplicaStatus(r *ReadReplica) (base.InstanceState, error) {
+	// Replicas being deleted are gone by the time they are checked again.
+	if r.State == base.InstanceNotGone {
+		return base.InstanceGone, nil
+	}
+	r.Host = r.Identifier + ".example.com"
+	r.Port = 5432
+	return base.InstanceReady, nil
+}
+
+func (d *mockDBAdapter) deleteReadReplica(r *ReadReplica) (base.InstanceState, error) {
+	return base.InstanceInProgress, nil
+}
+
+func (d *mockDBAdapter) findMajorVersionUpgradeTarget(i *RDSInstance, majorVersion string) (string, error) {
+	if majorVersion == getMajorVersion(i.DbVersion) {
+		return "", nil
+	}
+	return majorVersion, nil
+}
+
+func (d *mockDBAdapter) createSnapshot(i *RDSInstance, name string, tags map[string]string) (base.Snapshot, error) {
+	return base.Snapshot{
+		Name:   base.ManualSnapshotPrefix(i.Database) + name,
+		Type:   "manual",
+		Status: "creating",
+		Manual: true,
+	}, nil
+}
+
+func (d *mockDBAdapter) listSnapshots(i *RDSInstance) ([]base.Snapshot, error) {
+	return []base.Snapshot{}, nil
+}
+
+func (d *mockDBAdapter) deleteSnapshot(snapshotIdentifier string) error {
+	return nil
+}
+
+func (d *mockDBAdapter) rotateMasterPassword(i *RDSInstance) error {
+	return nil
+}
+
+func (d *mockDBAdapter) getManagedMasterPassword(i *RDSInstance) (string, error) {
+	return "managed-password", nil
+}
+
+func (d *mockDBAdapter) stopDB(i *RDSInstance) error {
+	return nil
+}
+
+func (d *mockDBAdapter) startDB(i *RDSInstance) error {
+	return nil
+}
+
 // END MockDBAdpater
 
 type dedicatedDBAdapter struct {
@@ -61,6 +154,13 @@ type dedicatedDBAdapter struct {
 	settings             config.Settings
 	rds                  rdsiface.RDSAPI
 	parameterGroupClient parameterGroupClient
+	logger               lager.Logger
+	// iam and openDB set up the credentials of plans with IAM database
+	// authentication.
+	iam    iamiface.IAMAPI
+	openDB func(common.DBConfig) (instanceDBConn, error)
+	// secretsManager reads the master passwords that RDS manages.
+	secretsManager secretsmanageriface.SecretsManagerAPI
 }
 
 func (d *dedicatedDBAdapter) prepareCreateDbInput(
@@ -77,9 +177,9 @@ func (d *dedicatedDBAdapter) prepareCreateDbInput(
 		DBInstanceIdentifier:    &i.Database,
 		DBName:                  aws.String(i.FormatDBName()),
 		Engine:                  aws.String(i.DbType),
-		MasterUserPassword:      &password,
 		MasterUsername:          &i.Username,
 		AutoMinorVersionUpgrade: aws.Bool(true),
+		CopyTagsToSnapshot:      aws.Bool(true),
 		MultiAZ:                 aws.Bool(d.Plan.Redundant),
 		StorageEncrypted:        aws.Bool(d.Plan.Encrypted),
 		StorageType:             aws.String(i.StorageType),
@@ -91,12 +191,54 @@ func (d *dedicatedDBAdapter) prepareCreateDbInput(
 			&i.SecGroup,
 		},
 	}
+	if i.ManageMasterUserPassword {
+		params.ManageMasterUserPassword = aws.Bool(true)
+		if d.Plan.MasterUserSecretKMSKeyID != "" {
+			params.MasterUserSecretKmsKeyId = aws.String(d.Plan.MasterUserSecretKMSKeyID)
+		}
+	} else {
+		params.MasterUserPassword = aws.String(password)
+	}
 	if i.DbVersion != "" {
 		params.EngineVersion = aws.String(i.DbVersion)
 	}
 	if i.LicenseModel != "" {
 		params.LicenseModel = aws.String(i.LicenseModel)
 	}
+	if d.Plan.IAMDatabaseAuthentication {
+		params.EnableIAMDatabaseAuthentication = aws.Bool(true)
+	}
+	if i.DeletionProtection {
+		params.DeletionProtection = aws.Bool(true)
+	}
+	if i.MaxAllocatedStorage > 0 {
+		params.MaxAllocatedStorage = aws.Int64(i.MaxAllocatedStorage)
+	}
+	if i.Iops > 0 {
+		params.Iops = aws.Int64(i.Iops)
+	}
+	if i.StorageThroughput > 0 {
+		params.StorageThroughput = aws.Int64(i.StorageThroughput)
+	}
+	if i.EnablePerformanceInsights {
+		params.EnablePerformanceInsights = aws.Bool(true)
+		if i.PerformanceInsightsRetention > 0 {
+			params.PerformanceInsightsRetentionPeriod = aws.Int64(i.PerformanceInsightsRetention)
+		}
+		if i.PerformanceInsightsKMSKeyID != "" {
+			params.PerformanceInsightsKMSKeyId = aws.String(i.PerformanceInsightsKMSKeyID)
+		}
+	}
+	if i.MonitoringInterval > 0 {
+		params.MonitoringInterval = aws.Int64(i.MonitoringInterval)
+		params.MonitoringRoleArn = aws.String(i.MonitoringRoleARN)
+	}
+	if i.PreferredMaintenanceWindow != "" {
+		params.PreferredMaintenanceWindow = aws.String(i.PreferredMaintenanceWindow)
+	}
+	if i.PreferredBackupWindow != "" {
+		params.PreferredBackupWindow = aws.String(i.PreferredBackupWindow)
+	}
 
 	// If a custom parameter has been requested, and the feature is enabled,
 	// create/update a custom parameter group for our custom parameters.
@@ -111,6 +253,109 @@ func (d *dedicatedDBAdapter) prepareCreateDbInput(
 	return params, nil
 }
 
+// findRestoreSnapshot finds the snapshot that the instance is restored from:
+// either the snapshot it names, or the most recent snapshot of its source
+// instance. The snapshot must carry the organization tag that the broker
+// applies, so that instances are only restored within the same organization.
+func (d *dedicatedDBAdapter) findRestoreSnapshot(i *RDSInstance) (*rds.DBSnapshot, error) {
+	params := &rds.DescribeDBSnapshotsInput{}
+	if i.SnapshotIdentifier != "" {
+		params.DBSnapshotIdentifier = aws.String(i.SnapshotIdentifier)
+	} else {
+		params.DBInstanceIdentifier = aws.String(i.sourceDatabase)
+	}
+
+	var snapshot *rds.DBSnapshot
+	err := d.rds.DescribeDBSnapshotsPages(params, func(page *rds.DescribeDBSnapshotsOutput, lastPage bool) bool {
+		for _, s := range page.DBSnapshots {
+			if aws.StringValue(s.Status) != "available" {
+				continue
+			}
+			if snapshot == nil || aws.TimeValue(s.SnapshotCreateTime).After(aws.TimeValue(snapshot.SnapshotCreateTime)) {
+				snapshot = s
+			}
+		}
+		return true
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "describe-db-snapshots", err)
+		return nil, err
+	}
+	if snapshot == nil {
+		return nil, errors.New("Couldn't find an available snapshot to restore from.")
+	}
+
+	if aws.StringValue(snapshot.Engine) != i.DbType {
+		return nil, fmt.Errorf("snapshot %s is of a %s database and cannot be restored to a %s plan", aws.StringValue(snapshot.DBSnapshotIdentifier), aws.StringValue(snapshot.Engine), i.DbType)
+	}
+
+	tags, err := d.rds.ListTagsForResource(&rds.ListTagsForResourceInput{
+		ResourceName: snapshot.DBSnapshotArn,
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "list-snapshot-tags", err)
+		return nil, err
+	}
+	organizationGUID := ""
+	for _, tag := range tags.TagList {
+		if aws.StringValue(tag.Key) == brokertags.OrganizationGUIDTagKey {
+			organizationGUID = aws.StringValue(tag.Value)
+		}
+	}
+	if organizationGUID == "" || organizationGUID != i.OrganizationGUID {
+		return nil, fmt.Errorf("snapshot %s does not belong to this organization", aws.StringValue(snapshot.DBSnapshotIdentifier))
+	}
+
+	return snapshot, nil
+}
+
+func (d *dedicatedDBAdapter) prepareRestoreDbInput(
+	i *RDSInstance,
+	snapshot *rds.DBSnapshot,
+) (*rds.RestoreDBInstanceFromDBSnapshotInput, error) {
+	// The restored instance keeps the master username, engine version and
+	// storage of the snapshot.
+	i.SnapshotIdentifier = aws.StringValue(snapshot.DBSnapshotIdentifier)
+	i.Username = aws.StringValue(snapshot.MasterUsername)
+	i.DbVersion = aws.StringValue(snapshot.EngineVersion)
+	// The password, storage and backup retention of the plan are applied once
+	// the instance has been restored.
+	i.RestoreModifyPending = true
+
+	rdsTags := ConvertTagsToRDSTags(i.Tags)
+
+	params := &rds.RestoreDBInstanceFromDBSnapshotInput{
+		// Instance class is defined by the plan
+		DBInstanceClass:         &d.Plan.InstanceClass,
+		DBInstanceIdentifier:    &i.Database,
+		DBSnapshotIdentifier:    aws.String(i.SnapshotIdentifier),
+		Engine:                  aws.String(i.DbType),
+		AutoMinorVersionUpgrade: aws.Bool(true),
+		CopyTagsToSnapshot:      aws.Bool(true),
+		MultiAZ:                 aws.Bool(d.Plan.Redundant),
+		StorageType:             aws.String(i.StorageType),
+		Tags:                    rdsTags,
+		PubliclyAccessible:      aws.Bool(d.settings.PubliclyAccessibleFeature && i.PubliclyAccessible),
+		DBSubnetGroupName:       &i.DbSubnetGroup,
+		VpcSecurityGroupIds: []*string{
+			&i.SecGroup,
+		},
+	}
+	if i.LicenseModel != "" {
+		params.LicenseModel = aws.String(i.LicenseModel)
+	}
+
+	err := d.parameterGroupClient.ProvisionCustomParameterGroupIfNecessary(i, rdsTags)
+	if err != nil {
+		return nil, err
+	}
+	if i.ParameterGroupName != "" {
+		params.DBParameterGroupName = aws.String(i.ParameterGroupName)
+	}
+
+	return params, nil
+}
+
 func (d *dedicatedDBAdapter) prepareModifyDbInstanceInput(i *RDSInstance) (*rds.ModifyDBInstanceInput, error) {
 	// Standard parameters (https://docs.aws.amazon.com/sdk-for-go/api/service/rds/#RDS.ModifyDBInstance)
 	// These actions are applied immediately.
@@ -122,11 +367,68 @@ func (d *dedicatedDBAdapter) prepareModifyDbInstanceInput(i *RDSInstance) (*rds.
 		DBInstanceIdentifier:     &i.Database,
 		AllowMajorVersionUpgrade: aws.Bool(false),
 		BackupRetentionPeriod:    aws.Int64(i.BackupRetentionPeriod),
+		CopyTagsToSnapshot:       aws.Bool(true),
+	}
+
+	if d.Plan.IAMDatabaseAuthentication {
+		params.EnableIAMDatabaseAuthentication = aws.Bool(true)
+	}
+
+	if i.upgradeMajorVersion {
+		params.EngineVersion = aws.String(i.DbVersion)
+		params.AllowMajorVersionUpgrade = aws.Bool(true)
 	}
 
 	if i.StorageType != "" {
 		params.StorageType = aws.String(i.StorageType)
 	}
+	if i.MaxAllocatedStorage > 0 {
+		params.MaxAllocatedStorage = aws.Int64(i.MaxAllocatedStorage)
+	} else if i.updateStoragePerformance {
+		// Storage autoscaling is turned off by setting its limit to the
+		// storage of the instance.
+		params.MaxAllocatedStorage = aws.Int64(i.AllocatedStorage)
+	}
+	// Cleared IOPS and throughput of gp3 storage go back to the baseline
+	// included with the storage, and other storage types have none to clear.
+	clearGp3Performance := i.updateStoragePerformance && i.StorageType == "gp3" &&
+		i.AllocatedStorage >= gp3BaselineStorage(i.DbType)
+	if i.Iops > 0 {
+		params.Iops = aws.Int64(i.Iops)
+	} else if clearGp3Performance {
+		params.Iops = aws.Int64(minGp3Iops)
+	}
+	if i.StorageThroughput > 0 {
+		params.StorageThroughput = aws.Int64(i.StorageThroughput)
+	} else if clearGp3Performance {
+		params.StorageThroughput = aws.Int64(minGp3Throughput)
+	}
+
+	// Performance Insights and Enhanced Monitoring are only changed when
+	// requested, since they can be disabled.
+	if i.updateMonitoring {
+		params.EnablePerformanceInsights = aws.Bool(i.EnablePerformanceInsights)
+		if i.EnablePerformanceInsights && i.PerformanceInsightsRetention > 0 {
+			params.PerformanceInsightsRetentionPeriod = aws.Int64(i.PerformanceInsightsRetention)
+		}
+		if i.EnablePerformanceInsights && i.PerformanceInsightsKMSKeyID != "" {
+			params.PerformanceInsightsKMSKeyId = aws.String(i.PerformanceInsightsKMSKeyID)
+		}
+		params.MonitoringInterval = aws.Int64(i.MonitoringInterval)
+		if i.MonitoringInterval > 0 {
+			params.MonitoringRoleArn = aws.String(i.MonitoringRoleARN)
+		}
+	}
+
+	if i.PreferredMaintenanceWindow != "" {
+		params.PreferredMaintenanceWindow = aws.String(i.PreferredMaintenanceWindow)
+	}
+	if i.PreferredBackupWindow != "" {
+		params.PreferredBackupWindow = aws.String(i.PreferredBackupWindow)
+	}
+	if i.updateDeletionProtection {
+		params.DeletionProtection = aws.Bool(i.DeletionProtection)
+	}
 
 	if i.ClearPassword != "" {
 		params.MasterUserPassword = aws.String(i.ClearPassword)
@@ -147,12 +449,40 @@ func (d *dedicatedDBAdapter) prepareModifyDbInstanceInput(i *RDSInstance) (*rds.
 }
 
 func (d *dedicatedDBAdapter) createDB(i *RDSInstance, password string) (base.InstanceState, error) {
+	status := base.InstanceNotCreated
+	err := d.setupMonitoringRole(i)
+	if err == nil {
+		status, err = d.createDBInstance(i, password)
+	}
+	if status == base.InstanceNotCreated && i.MonitoringRoleARN != "" {
+		// The broker does not record instances that were not created, so
+		// their monitoring role would never be deleted.
+		if cleanupErr := d.cleanupMonitoringRole(i); cleanupErr != nil {
+			d.logger.Error("cleanup-monitoring-role", cleanupErr)
+		}
+	}
+	return status, err
+}
+
+// createDBInstance creates the instance, either empty or restored from
+// another instance.
+func (d *dedicatedDBAdapter) createDBInstance(i *RDSInstance, password string) (base.InstanceState, error) {
+	if i.SnapshotIdentifier != "" || i.sourceDatabase != "" {
+		return d.restoreDB(i)
+	}
+	if i.pointInTimeSource != "" {
+		return d.restoreDBToPointInTime(i)
+	}
+
 	params, err := d.prepareCreateDbInput(i, password)
 	if err != nil {
 		return base.InstanceNotCreated, err
 	}
 
-	_, err = d.rds.CreateDBInstance(params)
+	err = d.retryMonitoringRolePropagation(i, func() error {
+		_, err := d.rds.CreateDBInstance(params)
+		return err
+	})
 	if err != nil {
 		return base.InstanceNotCreated, err
 	}
@@ -164,15 +494,231 @@ func (d *dedicatedDBAdapter) createDB(i *RDSInstance, password string) (base.Ins
 	return base.InstanceNotCreated, nil
 }
 
+// restoreDB creates the instance from a snapshot instead of an empty database.
+func (d *dedicatedDBAdapter) restoreDB(i *RDSInstance) (base.InstanceState, error) {
+	snapshot, err := d.findRestoreSnapshot(i)
+	if err != nil {
+		return base.InstanceNotCreated, err
+	}
+
+	params, err := d.prepareRestoreDbInput(i, snapshot)
+	if err != nil {
+		return base.InstanceNotCreated, err
+	}
+
+	_, err = d.rds.RestoreDBInstanceFromDBSnapshot(params)
+	if err != nil {
+		logging.LogAWSError(d.logger, "restore-db-instance-from-db-snapshot", err)
+		return base.InstanceNotCreated, err
+	}
+	return base.InstanceInProgress, nil
+}
+
+// restoreDBToPointInTime creates the instance from the automated backups of
+// another instance.
+func (d *dedicatedDBAdapter) restoreDBToPointInTime(i *RDSInstance) (base.InstanceState, error) {
+	resp, err := d.rds.DescribeDBInstances(&rds.DescribeDBInstancesInput{
+		DBInstanceIdentifier: aws.String(i.pointInTimeSource),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "describe-db-instances", err)
+		return base.InstanceNotCreated, err
+	}
+	if len(resp.DBInstances) == 0 {
+		return base.InstanceNotCreated, errors.New("Couldn't find any instances.")
+	}
+	source := resp.DBInstances[0]
+
+	if err := checkRestoreWindow(source, i.restoreTime, time.Now()); err != nil {
+		return base.InstanceNotCreated, err
+	}
+
+	params, err := d.preparePointInTimeRestoreInput(i, source)
+	if err != nil {
+		return base.InstanceNotCreated, err
+	}
+
+	_, err = d.rds.RestoreDBInstanceToPointInTime(params)
+	if err != nil {
+		logging.LogAWSError(d.logger, "restore-db-instance-to-point-in-time", err)
+		return base.InstanceNotCreated, err
+	}
+	return base.InstanceInProgress, nil
+}
+
+// checkRestoreWindow checks that a point-in-time restore of the source
+// instance is possible at the given time, or at its latest restorable time if
+// the time is nil.
+func checkRestoreWindow(source *rds.DBInstance, restoreTime *time.Time, now time.Time) error {
+	if source.LatestRestorableTime == nil || aws.Int64Value(source.BackupRetentionPeriod) == 0 {
+		return fmt.Errorf("instance %s has no automated backups to restore from", aws.StringValue(source.DBInstanceIdentifier))
+	}
+	if restoreTime == nil {
+		return nil
+	}
+
+	earliest := now.AddDate(0, 0, -int(aws.Int64Value(source.BackupRetentionPeriod)))
+	if created := aws.TimeValue(source.InstanceCreateTime); created.After(earliest) {
+		earliest = created
+	}
+	latest := aws.TimeValue(source.LatestRestorableTime)
+	if restoreTime.Before(earliest) || restoreTime.After(latest) {
+		return fmt.Errorf(
+			"restore time %s is outside of the restorable window of %s to %s",
+			restoreTime.Format(time.RFC3339),
+			earliest.Format(time.RFC3339),
+			latest.Format(time.RFC3339),
+		)
+	}
+	return nil
+}
+
+func (d *dedicatedDBAdapter) preparePointInTimeRestoreInput(
+	i *RDSInstance,
+	source *rds.DBInstance,
+) (*rds.RestoreDBInstanceToPointInTimeInput, error) {
+	// The restored instance keeps the master username and engine version of
+	// the source.
+	i.Username = aws.StringValue(source.MasterUsername)
+	i.DbVersion = aws.StringValue(source.EngineVersion)
+	// The password, storage and backup retention of the plan are applied once
+	// the instance has been restored.
+	i.RestoreModifyPending = true
+
+	rdsTags := ConvertTagsToRDSTags(i.Tags)
+
+	params := &rds.RestoreDBInstanceToPointInTimeInput{
+		SourceDBInstanceIdentifier: aws.String(i.pointInTimeSource),
+		TargetDBInstanceIdentifier: &i.Database,
+		// Instance class is defined by the plan
+		DBInstanceClass:         &d.Plan.InstanceClass,
+		Engine:                  aws.String(i.DbType),
+		AutoMinorVersionUpgrade: aws.Bool(true),
+		CopyTagsToSnapshot:      aws.Bool(true),
+		MultiAZ:                 aws.Bool(d.Plan.Redundant),
+		StorageType:             aws.String(i.StorageType),
+		Tags:                    rdsTags,
+		PubliclyAccessible:      aws.Bool(d.settings.PubliclyAccessibleFeature && i.PubliclyAccessible),
+		DBSubnetGroupName:       &i.DbSubnetGroup,
+		VpcSecurityGroupIds: []*string{
+			&i.SecGroup,
+		},
+	}
+	if i.restoreTime != nil {
+		params.RestoreTime = i.restoreTime
+	} else {
+		params.UseLatestRestorableTime = aws.Bool(true)
+	}
+	if i.LicenseModel != "" {
+		params.LicenseModel = aws.String(i.LicenseModel)
+	}
+
+	err := d.parameterGroupClient.ProvisionCustomParameterGroupIfNecessary(i, rdsTags)
+	if err != nil {
+		return nil, err
+	}
+	if i.ParameterGroupName != "" {
+		params.DBParameterGroupName = aws.String(i.ParameterGroupName)
+	}
+
+	return params, nil
+}
+
+// modifyRestoredDB applies the settings that cannot be given when restoring a
+// snapshot to a newly restored instance.
+func (d *dedicatedDBAdapter) modifyRestoredDB(i *RDSInstance, dbInstance *rds.DBInstance) (base.InstanceState, error) {
+	params := &rds.ModifyDBInstanceInput{
+		DBInstanceIdentifier:  &i.Database,
+		ApplyImmediately:      aws.Bool(true),
+		BackupRetentionPeriod: aws.Int64(i.BackupRetentionPeriod),
+	}
+	if i.ManageMasterUserPassword {
+		params.ManageMasterUserPassword = aws.Bool(true)
+		if d.Plan.MasterUserSecretKMSKeyID != "" {
+			params.MasterUserSecretKmsKeyId = aws.String(d.Plan.MasterUserSecretKMSKeyID)
+		}
+	} else {
+		params.MasterUserPassword = aws.String(i.ClearPassword)
+	}
+	if d.Plan.IAMDatabaseAuthentication {
+		params.EnableIAMDatabaseAuthentication = aws.Bool(true)
+	}
+	if i.DeletionProtection {
+		params.DeletionProtection = aws.Bool(true)
+	}
+	if i.MaxAllocatedStorage > 0 {
+		params.MaxAllocatedStorage = aws.Int64(i.MaxAllocatedStorage)
+	}
+	if i.EnablePerformanceInsights {
+		params.EnablePerformanceInsights = aws.Bool(true)
+		if i.PerformanceInsightsRetention > 0 {
+			params.PerformanceInsightsRetentionPeriod = aws.Int64(i.PerformanceInsightsRetention)
+		}
+		if i.PerformanceInsightsKMSKeyID != "" {
+			params.PerformanceInsightsKMSKeyId = aws.String(i.PerformanceInsightsKMSKeyID)
+		}
+	}
+	if i.MonitoringInterval > 0 {
+		params.MonitoringInterval = aws.Int64(i.MonitoringInterval)
+		params.MonitoringRoleArn = aws.String(i.MonitoringRoleARN)
+	}
+	if i.PreferredMaintenanceWindow != "" {
+		params.PreferredMaintenanceWindow = aws.String(i.PreferredMaintenanceWindow)
+	}
+	if i.PreferredBackupWindow != "" {
+		params.PreferredBackupWindow = aws.String(i.PreferredBackupWindow)
+	}
+	// Storage can only grow, so the snapshot's storage is kept if it is larger.
+	if i.AllocatedStorage > aws.Int64Value(dbInstance.AllocatedStorage) {
+		params.AllocatedStorage = aws.Int64(i.AllocatedStorage)
+	} else {
+		i.AllocatedStorage = aws.Int64Value(dbInstance.AllocatedStorage)
+	}
+
+	_, err := d.rds.ModifyDBInstance(params)
+	if err != nil {
+		logging.LogAWSError(d.logger, "modify-restored-db-instance", err)
+		return base.InstanceNotCreated, err
+	}
+
+	// The restored database keeps the name it had in the snapshot.
+	i.DbName = aws.StringValue(dbInstance.DBName)
+	i.RestoreModifyPending = false
+	return base.InstanceInProgress, nil
+}
+
 // This should ultimately get exposed as part of the "update-service" method for the broker:
 // cf update-service SERVICE_INSTANCE [-p NEW_PLAN] [-c PARAMETERS_AS_JSON] [-t TAGS] [--upgrade]
 func (d *dedicatedDBAdapter) modifyDB(i *RDSInstance, password string) (base.InstanceState, error) {
+	if i.useBlueGreen {
+		return d.createBlueGreenDeployment(i)
+	}
+
+	if err := d.setupMonitoringRole(i); err != nil {
+		return base.InstanceNotModified, err
+	}
+
+	// Storage autoscaling may have grown the instance beyond the storage the
+	// broker recorded, which AWS would reject as a decrease.
+	if i.MaxAllocatedStorage > 0 || i.updateStoragePerformance {
+		dbInstance, err := d.describeDB(i)
+		if err != nil {
+			return base.InstanceNotModified, err
+		}
+		if current := aws.Int64Value(dbInstance.AllocatedStorage); current > i.AllocatedStorage {
+			i.AllocatedStorage = current
+		}
+	}
+
 	params, err := d.prepareModifyDbInstanceInput(i)
 	if err != nil {
 		return base.InstanceNotModified, err
 	}
 
-	_, err = d.rds.ModifyDBInstance(params)
+	err = d.retryMonitoringRolePropagation(i, func() error {
+		_, err := d.rds.ModifyDBInstance(params)
+		return err
+	})
 	if err != nil {
 		return base.InstanceNotModified, err
 	}
@@ -185,7 +731,193 @@ func (d *dedicatedDBAdapter) modifyDB(i *RDSInstance, password string) (base.Ins
 	return base.InstanceNotModified, nil
 }
 
+// blueGreenDeploymentName is the name of the blue/green deployment that
+// updates the instance.
+func blueGreenDeploymentName(i *RDSInstance) string {
+	return i.Database + "-bg"
+}
+
+func (d *dedicatedDBAdapter) prepareCreateBlueGreenDeploymentInput(i *RDSInstance, sourceArn string) (*rds.CreateBlueGreenDeploymentInput, error) {
+	params := &rds.CreateBlueGreenDeploymentInput{
+		BlueGreenDeploymentName: aws.String(blueGreenDeploymentName(i)),
+		Source:                  aws.String(sourceArn),
+		TargetDBInstanceClass:   aws.String(d.Plan.InstanceClass),
+		Tags:                    ConvertTagsToRDSTags(i.Tags),
+	}
+
+	if i.upgradeMajorVersion {
+		params.TargetEngineVersion = aws.String(i.DbVersion)
+	}
+
+	// The green environment is created with the parameter group of the
+	// update, which leaves the group of the blue environment untouched.
+	err := d.parameterGroupClient.ProvisionCustomParameterGroupIfNecessary(i, params.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if i.ParameterGroupName != "" {
+		params.TargetDBParameterGroupName = aws.String(i.ParameterGroupName)
+	}
+	return params, nil
+}
+
+// createBlueGreenDeployment starts to update the instance through a
+// blue/green deployment, which is switched over by checkDBStatus once the
+// green environment is available.
+func (d *dedicatedDBAdapter) createBlueGreenDeployment(i *RDSInstance) (base.InstanceState, error) {
+	dbInstance, err := d.describeDB(i)
+	if err != nil {
+		return base.InstanceNotModified, err
+	}
+
+	params, err := d.prepareCreateBlueGreenDeploymentInput(i, aws.StringValue(dbInstance.DBInstanceArn))
+	if err != nil {
+		return base.InstanceNotModified, err
+	}
+
+	resp, err := d.rds.CreateBlueGreenDeployment(params)
+	if err != nil {
+		logging.LogAWSError(d.logger, "create-blue-green-deployment", err)
+		return base.InstanceNotModified, err
+	}
+
+	i.BlueGreenDeploymentIdentifier = aws.StringValue(resp.BlueGreenDeployment.BlueGreenDeploymentIdentifier)
+	d.logger.Info("blue-green-deployment-created", lager.Data{
+		"database":   i.Database,
+		"deployment": i.BlueGreenDeploymentIdentifier,
+	})
+	return base.InstanceInProgress, nil
+}
+
+// checkBlueGreenDeployment switches over the blue/green deployment of the
+// instance once its green environment is available, and deletes the
+// deployment and the old environment once the switchover is complete.
+func (d *dedicatedDBAdapter) checkBlueGreenDeployment(i *RDSInstance) (base.InstanceState, error) {
+	resp, err := d.rds.DescribeBlueGreenDeployments(&rds.DescribeBlueGreenDeploymentsInput{
+		BlueGreenDeploymentIdentifier: aws.String(i.BlueGreenDeploymentIdentifier),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "describe-blue-green-deployments", err)
+		return base.InstanceNotModified, err
+	}
+	if len(resp.BlueGreenDeployments) == 0 {
+		return base.InstanceNotModified, fmt.Errorf("blue/green deployment %s not found", i.BlueGreenDeploymentIdentifier)
+	}
+
+	deployment := resp.BlueGreenDeployments[0]
+	status := aws.StringValue(deployment.Status)
+	d.logger.Info("blue-green-deployment-status", lager.Data{
+		"database":   i.Database,
+		"deployment": i.BlueGreenDeploymentIdentifier,
+		"status":     status,
+	})
+
+	switch status {
+	case "AVAILABLE":
+		_, err := d.rds.SwitchoverBlueGreenDeployment(&rds.SwitchoverBlueGreenDeploymentInput{
+			BlueGreenDeploymentIdentifier: aws.String(i.BlueGreenDeploymentIdentifier),
+		})
+		if err != nil {
+			logging.LogAWSError(d.logger, "switchover-blue-green-deployment", err)
+			return base.InstanceNotModified, err
+		}
+		return base.InstanceInProgress, nil
+	case "SWITCHOVER_COMPLETED":
+		if err := d.cleanupBlueGreenDeployment(i, deployment); err != nil {
+			return base.InstanceNotModified, err
+		}
+		i.BlueGreenDeploymentIdentifier = ""
+		return base.InstanceReady, nil
+	case "PROVISIONING_FAILED", "SWITCHOVER_FAILED", "INVALID_CONFIGURATION":
+		// The instance is left as it was, so only the green environment is deleted.
+		_, err := d.rds.DeleteBlueGreenDeployment(&rds.DeleteBlueGreenDeploymentInput{
+			BlueGreenDeploymentIdentifier: aws.String(i.BlueGreenDeploymentIdentifier),
+			DeleteTarget:                  aws.Bool(true),
+		})
+		if err != nil {
+			logging.LogAWSError(d.logger, "delete-blue-green-deployment", err)
+			return base.InstanceNotModified, err
+		}
+		i.BlueGreenDeploymentIdentifier = ""
+		return base.InstanceNotModified, fmt.Errorf("blue/green deployment failed: %s", aws.StringValue(deployment.StatusDetails))
+	default:
+		return base.InstanceInProgress, nil
+	}
+}
+
+// isBlueGreenOldInstance reports whether the identifier is that of the old
+// instance the switchover of a blue/green deployment of the instance renamed,
+// e.g. "db-name-old1".
+func isBlueGreenOldInstance(i *RDSInstance, identifier string) bool {
+	suffix, found := strings.CutPrefix(identifier, i.Database+"-old")
+	if !found || suffix == "" {
+		return false
+	}
+	_, err := strconv.Atoi(suffix)
+	return err == nil
+}
+
+// cleanupBlueGreenDeployment deletes a switched over blue/green deployment
+// and the old instances it renamed during the switchover. The old instances
+// are deleted with a final snapshot, and any other instance is left alone.
+func (d *dedicatedDBAdapter) cleanupBlueGreenDeployment(i *RDSInstance, deployment *rds.BlueGreenDeployment) error {
+	_, err := d.rds.DeleteBlueGreenDeployment(&rds.DeleteBlueGreenDeploymentInput{
+		BlueGreenDeploymentIdentifier: deployment.BlueGreenDeploymentIdentifier,
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "delete-blue-green-deployment", err)
+		return err
+	}
+
+	for _, detail := range deployment.SwitchoverDetails {
+		resp, err := d.rds.DescribeDBInstances(&rds.DescribeDBInstancesInput{
+			Filters: []*rds.Filter{
+				{
+					Name:   aws.String("db-instance-id"),
+					Values: []*string{detail.SourceMember},
+				},
+			},
+		})
+		if err != nil {
+			logging.LogAWSError(d.logger, "describe-db-instances", err)
+			return err
+		}
+		for _, oldInstance := range resp.DBInstances {
+			identifier := aws.StringValue(oldInstance.DBInstanceIdentifier)
+			if !isBlueGreenOldInstance(i, identifier) {
+				d.logger.Info("skip-blue-green-source-instance", lager.Data{
+					"database": i.Database,
+					"instance": identifier,
+				})
+				continue
+			}
+
+			finalSnapshotIdentifier := i.finalSnapshotIdentifier(&d.settings, time.Now())
+			d.logger.Info("delete-blue-green-old-instance", lager.Data{
+				"database":       i.Database,
+				"old-instance":   identifier,
+				"final-snapshot": finalSnapshotIdentifier,
+			})
+			_, err := d.rds.DeleteDBInstance(&rds.DeleteDBInstanceInput{
+				DBInstanceIdentifier:      aws.String(identifier),
+				FinalDBSnapshotIdentifier: aws.String(finalSnapshotIdentifier),
+				SkipFinalSnapshot:         aws.Bool(false),
+			})
+			if err != nil {
+				logging.LogAWSError(d.logger, "delete-db-instance", err)
+				return err
+			}
+			i.blueGreenFinalSnapshots = append(i.blueGreenFinalSnapshots, newFinalSnapshot(i, finalSnapshotIdentifier))
+		}
+	}
+	return nil
+}
+
 func (d *dedicatedDBAdapter) checkDBStatus(i *RDSInstance) (base.InstanceState, error) {
+	if i.BlueGreenDeploymentIdentifier != "" {
+		return d.checkBlueGreenDeployment(i)
+	}
+
 	// First, we need to check if the instance is up and available.
 	// Only search for details if the instance was not indicated as ready.
 	if i.State != base.InstanceReady {
@@ -195,18 +927,7 @@ func (d *dedicatedDBAdapter) checkDBStatus(i *RDSInstance) (base.InstanceState,
 
 		resp, err := d.rds.DescribeDBInstances(params)
 		if err != nil {
-			if awsErr, ok := err.(awserr.Error); ok {
-				// Generic AWS error with Code, Message, and original error (if any)
-				fmt.Println(awsErr.Code(), awsErr.Message(), awsErr.OrigErr())
-				if reqErr, ok := err.(awserr.RequestFailure); ok {
-					// A service error occurred
-					fmt.Println(reqErr.Code(), reqErr.Message(), reqErr.StatusCode(), reqErr.RequestID())
-				}
-			} else {
-				// This case should never be hit, the SDK should always return an
-				// error which satisfies the awserr.Error interface.
-				fmt.Println(err.Error())
-			}
+			logging.LogAWSError(d.logger, "describe-db-instances", err)
 			return base.InstanceNotCreated, err
 		}
 
@@ -215,9 +936,21 @@ func (d *dedicatedDBAdapter) checkDBStatus(i *RDSInstance) (base.InstanceState,
 		if numOfInstances > 0 {
 			for _, value := range resp.DBInstances {
 				// First check that the instance is up.
-				fmt.Println("Database Instance:" + i.Database + " is " + *(value.DBInstanceStatus))
+				d.logger.Info("db-instance-status", lager.Data{
+					"database": i.Database,
+					"status":   aws.StringValue(value.DBInstanceStatus),
+				})
 				switch *(value.DBInstanceStatus) {
 				case "available":
+					if value.MasterUserSecret != nil {
+						i.MasterUserSecretARN = aws.StringValue(value.MasterUserSecret.SecretArn)
+					}
+					if i.RestoreModifyPending {
+						return d.modifyRestoredDB(i, value)
+					}
+					if i.ExtensionsPending {
+						return d.createExtensions(i, value)
+					}
 					return base.InstanceReady, nil
 				case "creating":
 					return base.InstanceInProgress, nil
@@ -250,18 +983,7 @@ func (d *dedicatedDBAdapter) bindDBToApp(i *RDSInstance, password string) (map[s
 
 		resp, err := d.rds.DescribeDBInstances(params)
 		if err != nil {
-			if awsErr, ok := err.(awserr.Error); ok {
-				// Generic AWS error with Code, Message, and original error (if any)
-				fmt.Println(awsErr.Code(), awsErr.Message(), awsErr.OrigErr())
-				if reqErr, ok := err.(awserr.RequestFailure); ok {
-					// A service error occurred
-					fmt.Println(reqErr.Code(), reqErr.Message(), reqErr.StatusCode(), reqErr.RequestID())
-				}
-			} else {
-				// This case should never be hit, the SDK should always return an
-				// error which satisfies the awserr.Error interface.
-				fmt.Println(err.Error())
-			}
+			logging.LogAWSError(d.logger, "describe-db-instances", err)
 			return nil, err
 		}
 
@@ -272,7 +994,10 @@ func (d *dedicatedDBAdapter) bindDBToApp(i *RDSInstance, password string) (map[s
 				// First check that the instance is up.
 				if value.DBInstanceStatus != nil && *(value.DBInstanceStatus) == "available" {
 					if value.Endpoint != nil && value.Endpoint.Address != nil && value.Endpoint.Port != nil {
-						fmt.Printf("host: %s port: %d \n", *(value.Endpoint.Address), *(value.Endpoint.Port))
+						d.logger.Debug("db-instance-endpoint", lager.Data{
+							"host": *(value.Endpoint.Address),
+							"port": *(value.Endpoint.Port),
+						})
 						i.Port = *(value.Endpoint.Port)
 						i.Host = *(value.Endpoint.Address)
 						i.State = base.InstanceReady
@@ -293,42 +1018,252 @@ func (d *dedicatedDBAdapter) bindDBToApp(i *RDSInstance, password string) (map[s
 		}
 	}
 	// If we get here that means the instance is up and we have the information for it.
+	if d.Plan.IAMDatabaseAuthentication {
+		if i.IamAccessKeyID == "" {
+			if err := d.setupIAMAuthentication(i, password); err != nil {
+				return nil, err
+			}
+		}
+		return d.getIAMCredentials(i)
+	}
 	return i.getCredentials(password)
 }
 
-func (d *dedicatedDBAdapter) deleteDB(i *RDSInstance) (base.InstanceState, error) {
+// deleteDB requests the deletion of the instance, taking a final snapshot
+// with the given identifier unless it is empty. The deletion completes
+// asynchronously and is followed with checkDBDeleted.
+func (d *dedicatedDBAdapter) deleteDB(i *RDSInstance, finalSnapshotIdentifier string) (base.InstanceState, error) {
+	if i.MonitoringRoleARN != "" {
+		if err := d.cleanupMonitoringRole(i); err != nil {
+			return base.InstanceNotGone, err
+		}
+	}
 	params := &rds.DeleteDBInstanceInput{
-		DBInstanceIdentifier: aws.String(i.Database), // Required
-		// FinalDBSnapshotIdentifier: aws.String("String"),
+		DBInstanceIdentifier:   aws.String(i.Database), // Required
 		DeleteAutomatedBackups: aws.Bool(false),
-		SkipFinalSnapshot:      aws.Bool(true),
+		SkipFinalSnapshot:      aws.Bool(finalSnapshotIdentifier == ""),
+	}
+	if finalSnapshotIdentifier != "" {
+		params.FinalDBSnapshotIdentifier = aws.String(finalSnapshotIdentifier)
 	}
 	_, err := d.rds.DeleteDBInstance(params)
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == rds.ErrCodeDBInstanceNotFoundFault {
+			// The instance has already been deleted.
+			return d.cleanupDeletedDB(i)
+		}
+		logging.LogAWSError(d.logger, "delete-db-instance", err)
+		return base.InstanceNotGone, err
+	}
+	return base.InstanceInProgress, nil
+}
 
-	// Decide if AWS service call was successful
-	if yes := d.didAwsCallSucceed(err); yes {
-		// clean up custom parameter groups
-		d.parameterGroupClient.CleanupCustomParameterGroups()
-		return base.InstanceGone, nil
+// cleanupDeletedDB removes the custom parameter groups and the IAM user of an
+// instance once RDS has deleted it, so that a failed deletion leaves the
+// instance usable. The IAM fields of the instance are cleared as each step
+// succeeds, so that a failed cleanup is resumed on the next check.
+func (d *dedicatedDBAdapter) cleanupDeletedDB(i *RDSInstance) (base.InstanceState, error) {
+	d.parameterGroupClient.CleanupCustomParameterGroups()
+	if i.IamUserName != "" {
+		if err := d.cleanupIAMAuthentication(i); err != nil {
+			return base.InstanceNotGone, err
+		}
 	}
-	return base.InstanceNotGone, nil
+	return base.InstanceGone, nil
 }
 
-func (d *dedicatedDBAdapter) didAwsCallSucceed(err error) bool {
-	// TODO Eventually return a formatted error object.
+// checkDBDeleted reports whether a deletion requested by deleteDB, including
+// its final snapshot, has completed.
+func (d *dedicatedDBAdapter) checkDBDeleted(i *RDSInstance) (base.InstanceState, error) {
+	resp, err := d.rds.DescribeDBInstances(&rds.DescribeDBInstancesInput{
+		DBInstanceIdentifier: aws.String(i.Database),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == rds.ErrCodeDBInstanceNotFoundFault {
+			return d.cleanupDeletedDB(i)
+		}
+		logging.LogAWSError(d.logger, "describe-db-instances", err)
+		return base.InstanceNotGone, err
+	}
+
+	for _, value := range resp.DBInstances {
+		d.logger.Info("db-instance-status", lager.Data{
+			"database": i.Database,
+			"status":   aws.StringValue(value.DBInstanceStatus),
+		})
+		// An instance that is available again is no longer being deleted.
+		if aws.StringValue(value.DBInstanceStatus) == "available" {
+			return base.InstanceNotGone, nil
+		}
+	}
+	return base.InstanceInProgress, nil
+}
+
+func (d *dedicatedDBAdapter) describeDB(i *RDSInstance) (*rds.DBInstance, error) {
+	resp, err := d.rds.DescribeDBInstances(&rds.DescribeDBInstancesInput{
+		DBInstanceIdentifier: aws.String(i.Database),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "describe-db-instances", err)
+		return nil, err
+	}
+	if len(resp.DBInstances) == 0 {
+		return nil, errors.New("Couldn't find any instances.")
+	}
+	return resp.DBInstances[0], nil
+}
+
+// getMajorVersion returns the major version of a MySQL or PostgreSQL engine
+// version, which is e.g. "8.0" for MySQL 8.0.35 and "15" for PostgreSQL 15.5.
+func getMajorVersion(version string) string {
+	parts := strings.Split(version, ".")
+	if major, err := strconv.Atoi(parts[0]); (err == nil && major >= 10) || len(parts) == 1 {
+		return parts[0]
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// findMajorVersionUpgradeTarget returns the newest engine version of the given
+// major version that AWS allows the instance to be upgraded to. It returns an
+// empty version if the instance already runs the major version.
+func (d *dedicatedDBAdapter) findMajorVersionUpgradeTarget(i *RDSInstance, majorVersion string) (string, error) {
+	dbInstance, err := d.describeDB(i)
+	if err != nil {
+		return "", err
+	}
+	currentVersion := aws.StringValue(dbInstance.EngineVersion)
+	if getMajorVersion(currentVersion) == majorVersion {
+		return "", nil
+	}
+
+	resp, err := d.rds.DescribeDBEngineVersions(&rds.DescribeDBEngineVersionsInput{
+		Engine:        aws.String(i.DbType),
+		EngineVersion: aws.String(currentVersion),
+	})
 	if err != nil {
-		if awsErr, ok := err.(awserr.Error); ok {
-			// Generic AWS Error with Code, Message, and original error (if any)
-			fmt.Println(awsErr.Code(), awsErr.Message(), awsErr.OrigErr())
-			if reqErr, ok := err.(awserr.RequestFailure); ok {
-				// A service error occurred
-				fmt.Println(reqErr.Code(), reqErr.Message(), reqErr.StatusCode(), reqErr.RequestID())
+		logging.LogAWSError(d.logger, "describe-db-engine-versions", err)
+		return "", err
+	}
+
+	target := ""
+	for _, engineVersion := range resp.DBEngineVersions {
+		// The valid upgrade targets are listed from oldest to newest.
+		for _, upgradeTarget := range engineVersion.ValidUpgradeTarget {
+			version := aws.StringValue(upgradeTarget.EngineVersion)
+			if aws.BoolValue(upgradeTarget.IsMajorVersionUpgrade) && getMajorVersion(version) == majorVersion {
+				target = version
 			}
-		} else {
-			// This case should never be hit, The SDK should alwsy return an
-			// error which satisfies the awserr.Error interface.
-			fmt.Println(err.Error())
 		}
+	}
+	if target == "" {
+		return "", fmt.Errorf("%s %s cannot be upgraded to version %s", i.DbType, currentVersion, majorVersion)
+	}
+	return target, nil
+}
+
+func (d *dedicatedDBAdapter) prepareCreateReadReplicaInput(i *RDSInstance, r *ReadReplica) *rds.CreateDBInstanceReadReplicaInput {
+	params := &rds.CreateDBInstanceReadReplicaInput{
+		DBInstanceIdentifier:       aws.String(r.Identifier),
+		SourceDBInstanceIdentifier: aws.String(i.Database),
+		// Replicas have the instance class of the plan of their source.
+		DBInstanceClass:         aws.String(d.Plan.InstanceClass),
+		AutoMinorVersionUpgrade: aws.Bool(true),
+		CopyTagsToSnapshot:      aws.Bool(true),
+		PubliclyAccessible:      aws.Bool(false),
+		StorageType:             aws.String(i.StorageType),
+		Tags:                    ConvertTagsToRDSTags(i.Tags),
+		VpcSecurityGroupIds: []*string{
+			aws.String(i.SecGroup),
+		},
+	}
+	if i.ParameterGroupName != "" {
+		params.DBParameterGroupName = aws.String(i.ParameterGroupName)
+	}
+	return params
+}
+
+// createReadReplica requests a read replica of the instance, which must be
+// available.
+func (d *dedicatedDBAdapter) createReadReplica(i *RDSInstance, r *ReadReplica) (base.InstanceState, error) {
+	// The security group of the instance is not stored, so replicas requested
+	// after it was created are put in the group the instance is in.
+	if i.SecGroup == "" {
+		dbInstance, err := d.describeDB(i)
+		if err != nil {
+			return base.InstanceNotCreated, err
+		}
+		if len(dbInstance.VpcSecurityGroups) == 0 {
+			return base.InstanceNotCreated, errors.New("Couldn't find the security group of the instance.")
+		}
+		i.SecGroup = aws.StringValue(dbInstance.VpcSecurityGroups[0].VpcSecurityGroupId)
+	}
+
+	_, err := d.rds.CreateDBInstanceReadReplica(d.prepareCreateReadReplicaInput(i, r))
+	if err != nil {
+		logging.LogAWSError(d.logger, "create-db-instance-read-replica", err)
+		return base.InstanceNotCreated, err
+	}
+	return base.InstanceInProgress, nil
+}
+
+// checkReadReplicaStatus reports whether a read replica is available, and
+// records its endpoint once it is.
+func (d *dedicatedDBAdapter) checkReadReplicaStatus(r *ReadReplica) (base.InstanceState, error) {
+	resp, err := d.rds.DescribeDBInstances(&rds.DescribeDBInstancesInput{
+		DBInstanceIdentifier: aws.String(r.Identifier),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == rds.ErrCodeDBInstanceNotFoundFault {
+			return base.InstanceGone, nil
+		}
+		logging.LogAWSError(d.logger, "describe-db-instances", err)
+		return base.InstanceNotCreated, err
+	}
+	if len(resp.DBInstances) == 0 {
+		return base.InstanceNotCreated, errors.New("Couldn't find the read replica.")
+	}
+
+	replica := resp.DBInstances[0]
+	d.logger.Info("db-read-replica-status", lager.Data{
+		"replica": r.Identifier,
+		"status":  aws.StringValue(replica.DBInstanceStatus),
+	})
+	switch aws.StringValue(replica.DBInstanceStatus) {
+	case "available":
+		if replica.Endpoint == nil {
+			return base.InstanceInProgress, nil
+		}
+		r.Host = aws.StringValue(replica.Endpoint.Address)
+		r.Port = aws.Int64Value(replica.Endpoint.Port)
+		return base.InstanceReady, nil
+	case "failed", "incompatible-parameters", "incompatible-restore":
+		return base.InstanceNotCreated, nil
+	default:
+		return base.InstanceInProgress, nil
+	}
+}
+
+// deleteReadReplica requests the deletion of a read replica. Replicas have no
+// automated backups of their own and are deleted without a final snapshot.
+func (d *dedicatedDBAdapter) deleteReadReplica(r *ReadReplica) (base.InstanceState, error) {
+	_, err := d.rds.DeleteDBInstance(&rds.DeleteDBInstanceInput{
+		DBInstanceIdentifier: aws.String(r.Identifier),
+		SkipFinalSnapshot:    aws.Bool(true),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == rds.ErrCodeDBInstanceNotFoundFault {
+			return base.InstanceGone, nil
+		}
+		logging.LogAWSError(d.logger, "delete-db-instance", err)
+		return base.InstanceNotGone, err
+	}
+	return base.InstanceInProgress, nil
+}
+
+func (d *dedicatedDBAdapter) didAwsCallSucceed(err error) bool {
+	// TODO Eventually return a formatted error object.
+	if err != nil {
+		logging.LogAWSError(d.logger, "aws-call-failed", err)
 		return false
 	}
 	return true
@@ -2,12 +2,16 @@ package rds
 
 import (
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
+	"code.cloudfoundry.org/lager/lagertest"
 	"github.com/18F/aws-broker/base"
 	"github.com/18F/aws-broker/catalog"
 	"github.com/18F/aws-broker/config"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/rds"
 	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
 	"github.com/go-test/deep"
@@ -34,22 +38,167 @@ type mockRdsClientForAdapterTests struct {
 
 	createDbErr error
 	modifyDbErr error
+	deleteDbErr error
+
+	// monitoringRoleRejections is how many more calls fail as if the
+	// monitoring role had not propagated yet.
+	monitoringRoleRejections int
+	createDbCalls            int
+	modifyDbCalls            int
+
+	deleteDbInput *rds.DeleteDBInstanceInput
+	modifyDbInput *rds.ModifyDBInstanceInput
+	rebootDbInput *rds.RebootDBInstanceInput
+	stopDbInput   *rds.StopDBInstanceInput
+	startDbInput  *rds.StartDBInstanceInput
+
+	createDbReadReplicaInput *rds.CreateDBInstanceReadReplicaInput
+
+	createDbSnapshotInput *rds.CreateDBSnapshotInput
+	copyDbSnapshotInputs  []*rds.CopyDBSnapshotInput
+	copyDbSnapshotErr     error
+	deletedDbSnapshots    []string
+
+	describeDbSnapshotsResults []*rds.DBSnapshot
+	listTagsResults            map[string][]*rds.Tag
+
+	describeDbInstancesResults *rds.DescribeDBInstancesOutput
+	describeDbInstancesErr     error
+
+	describeDbEngineVersionsResults *rds.DescribeDBEngineVersionsOutput
+
+	describeBlueGreenDeploymentsResults []*rds.BlueGreenDeployment
+	switchoverBlueGreenDeploymentInput  *rds.SwitchoverBlueGreenDeploymentInput
+	deleteBlueGreenDeploymentInput      *rds.DeleteBlueGreenDeploymentInput
 }
 
-func (m mockRdsClientForAdapterTests) CreateDBInstance(*rds.CreateDBInstanceInput) (*rds.CreateDBInstanceOutput, error) {
+func (m *mockRdsClientForAdapterTests) CreateDBInstance(*rds.CreateDBInstanceInput) (*rds.CreateDBInstanceOutput, error) {
+	m.createDbCalls++
+	if err := m.rejectMonitoringRole(); err != nil {
+		return nil, err
+	}
 	if m.createDbErr != nil {
 		return nil, m.createDbErr
 	}
 	return nil, nil
 }
 
-func (m mockRdsClientForAdapterTests) ModifyDBInstance(*rds.ModifyDBInstanceInput) (*rds.ModifyDBInstanceOutput, error) {
+func (m *mockRdsClientForAdapterTests) rejectMonitoringRole() error {
+	if m.monitoringRoleRejections == 0 {
+		return nil
+	}
+	m.monitoringRoleRejections--
+	return awserr.New("InvalidParameterValue", "IAM role ARN value is invalid or does not include the required permissions for: ENHANCED_MONITORING", nil)
+}
+
+func (m *mockRdsClientForAdapterTests) CreateDBInstanceReadReplica(input *rds.CreateDBInstanceReadReplicaInput) (*rds.CreateDBInstanceReadReplicaOutput, error) {
+	m.createDbReadReplicaInput = input
+	return &rds.CreateDBInstanceReadReplicaOutput{}, nil
+}
+
+func (m *mockRdsClientForAdapterTests) RebootDBInstance(input *rds.RebootDBInstanceInput) (*rds.RebootDBInstanceOutput, error) {
+	m.rebootDbInput = input
+	return &rds.RebootDBInstanceOutput{}, nil
+}
+
+func (m *mockRdsClientForAdapterTests) ModifyDBInstance(input *rds.ModifyDBInstanceInput) (*rds.ModifyDBInstanceOutput, error) {
+	m.modifyDbInput = input
+	m.modifyDbCalls++
+	if err := m.rejectMonitoringRole(); err != nil {
+		return nil, err
+	}
 	if m.modifyDbErr != nil {
 		return nil, m.modifyDbErr
 	}
 	return nil, nil
 }
 
+func (m *mockRdsClientForAdapterTests) DeleteDBInstance(input *rds.DeleteDBInstanceInput) (*rds.DeleteDBInstanceOutput, error) {
+	m.deleteDbInput = input
+	if m.deleteDbErr != nil {
+		return nil, m.deleteDbErr
+	}
+	return nil, nil
+}
+
+func (m *mockRdsClientForAdapterTests) StopDBInstance(input *rds.StopDBInstanceInput) (*rds.StopDBInstanceOutput, error) {
+	m.stopDbInput = input
+	return &rds.StopDBInstanceOutput{}, nil
+}
+
+func (m *mockRdsClientForAdapterTests) StartDBInstance(input *rds.StartDBInstanceInput) (*rds.StartDBInstanceOutput, error) {
+	m.startDbInput = input
+	return &rds.StartDBInstanceOutput{}, nil
+}
+
+func (m mockRdsClientForAdapterTests) DescribeDBInstances(*rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+	if m.describeDbInstancesErr != nil {
+		return nil, m.describeDbInstancesErr
+	}
+	return m.describeDbInstancesResults, nil
+}
+
+func (m mockRdsClientForAdapterTests) DescribeDBEngineVersions(*rds.DescribeDBEngineVersionsInput) (*rds.DescribeDBEngineVersionsOutput, error) {
+	return m.describeDbEngineVersionsResults, nil
+}
+
+func (m mockRdsClientForAdapterTests) DescribeBlueGreenDeployments(*rds.DescribeBlueGreenDeploymentsInput) (*rds.DescribeBlueGreenDeploymentsOutput, error) {
+	return &rds.DescribeBlueGreenDeploymentsOutput{BlueGreenDeployments: m.describeBlueGreenDeploymentsResults}, nil
+}
+
+func (m *mockRdsClientForAdapterTests) SwitchoverBlueGreenDeployment(input *rds.SwitchoverBlueGreenDeploymentInput) (*rds.SwitchoverBlueGreenDeploymentOutput, error) {
+	m.switchoverBlueGreenDeploymentInput = input
+	return nil, nil
+}
+
+func (m *mockRdsClientForAdapterTests) DeleteBlueGreenDeployment(input *rds.DeleteBlueGreenDeploymentInput) (*rds.DeleteBlueGreenDeploymentOutput, error) {
+	m.deleteBlueGreenDeploymentInput = input
+	return nil, nil
+}
+
+func (m mockRdsClientForAdapterTests) DescribeDBSnapshotsPages(input *rds.DescribeDBSnapshotsInput, fn func(*rds.DescribeDBSnapshotsOutput, bool) bool) error {
+	fn(&rds.DescribeDBSnapshotsOutput{DBSnapshots: m.describeDbSnapshotsResults}, true)
+	return nil
+}
+
+func (m *mockRdsClientForAdapterTests) CreateDBSnapshot(input *rds.CreateDBSnapshotInput) (*rds.CreateDBSnapshotOutput, error) {
+	m.createDbSnapshotInput = input
+	return &rds.CreateDBSnapshotOutput{
+		DBSnapshot: &rds.DBSnapshot{
+			DBSnapshotIdentifier: input.DBSnapshotIdentifier,
+			SnapshotType:         aws.String("manual"),
+			Status:               aws.String("creating"),
+		},
+	}, nil
+}
+
+func (m mockRdsClientForAdapterTests) DescribeDBSnapshots(input *rds.DescribeDBSnapshotsInput) (*rds.DescribeDBSnapshotsOutput, error) {
+	snapshots := []*rds.DBSnapshot{}
+	for _, snapshot := range m.describeDbSnapshotsResults {
+		if aws.StringValue(snapshot.DBSnapshotIdentifier) == aws.StringValue(input.DBSnapshotIdentifier) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return &rds.DescribeDBSnapshotsOutput{DBSnapshots: snapshots}, nil
+}
+
+func (m *mockRdsClientForAdapterTests) CopyDBSnapshot(input *rds.CopyDBSnapshotInput) (*rds.CopyDBSnapshotOutput, error) {
+	m.copyDbSnapshotInputs = append(m.copyDbSnapshotInputs, input)
+	if m.copyDbSnapshotErr != nil {
+		return nil, m.copyDbSnapshotErr
+	}
+	return &rds.CopyDBSnapshotOutput{}, nil
+}
+
+func (m *mockRdsClientForAdapterTests) DeleteDBSnapshot(input *rds.DeleteDBSnapshotInput) (*rds.DeleteDBSnapshotOutput, error) {
+	m.deletedDbSnapshots = append(m.deletedDbSnapshots, aws.StringValue(input.DBSnapshotIdentifier))
+	return &rds.DeleteDBSnapshotOutput{}, nil
+}
+
+func (m mockRdsClientForAdapterTests) ListTagsForResource(input *rds.ListTagsForResourceInput) (*rds.ListTagsForResourceOutput, error) {
+	return &rds.ListTagsForResourceOutput{TagList: m.listTagsResults[aws.StringValue(input.ResourceName)]}, nil
+}
+
 func TestPrepareCreateDbInstanceInput(t *testing.T) {
 	testErr := errors.New("fail")
 	testCases := map[string]struct {
@@ -67,6 +216,7 @@ func TestPrepareCreateDbInstanceInput(t *testing.T) {
 				dbUtils:         &RDSDatabaseUtils{},
 			},
 			dbAdapter: &dedicatedDBAdapter{
+				logger: lagertest.NewTestLogger("test"),
 				parameterGroupClient: &mockParameterGroupClient{
 					returnErr: testErr,
 					rds:       &mockRDSClient{},
@@ -94,6 +244,7 @@ func TestPrepareCreateDbInstanceInput(t *testing.T) {
 				SecGroup:              "sec-group-1",
 			},
 			dbAdapter: &dedicatedDBAdapter{
+				logger: lagertest.NewTestLogger("test"),
 				parameterGroupClient: &mockParameterGroupClient{
 					rds:              &mockRDSClient{},
 					customPgroupName: "parameter-group-1",
@@ -117,6 +268,7 @@ func TestPrepareCreateDbInstanceInput(t *testing.T) {
 				MasterUserPassword:      aws.String("fake-password"),
 				MasterUsername:          aws.String("fake-user"),
 				AutoMinorVersionUpgrade: aws.Bool(true),
+				CopyTagsToSnapshot:      aws.Bool(true),
 				MultiAZ:                 aws.Bool(true),
 				StorageEncrypted:        aws.Bool(true),
 				StorageType:             aws.String("storage-1"),
@@ -135,6 +287,53 @@ func TestPrepareCreateDbInstanceInput(t *testing.T) {
 				DBParameterGroupName: aws.String("parameter-group-1"),
 			},
 		},
+		"RDS-managed master password": {
+			dbInstance: &RDSInstance{
+				AllocatedStorage: 10,
+				Database:         "db-1",
+				DbType:           "postgres",
+				dbUtils: &MockDbUtils{
+					mockFormattedDbName: "formatted-name",
+				},
+				Username:                 "fake-user",
+				StorageType:              "gp3",
+				BackupRetentionPeriod:    14,
+				DbSubnetGroup:            "subnet-group-1",
+				SecGroup:                 "sec-group-1",
+				ManageMasterUserPassword: true,
+			},
+			dbAdapter: &dedicatedDBAdapter{
+				logger: lagertest.NewTestLogger("test"),
+				parameterGroupClient: &mockParameterGroupClient{
+					rds: &mockRDSClient{},
+				},
+				Plan: catalog.RDSPlan{
+					InstanceClass:            "class-1",
+					MasterUserSecretKMSKeyID: "key-1",
+				},
+			},
+			expectedParams: &rds.CreateDBInstanceInput{
+				AllocatedStorage:         aws.Int64(10),
+				DBInstanceClass:          aws.String("class-1"),
+				DBInstanceIdentifier:     aws.String("db-1"),
+				DBName:                   aws.String("formatted-name"),
+				Engine:                   aws.String("postgres"),
+				ManageMasterUserPassword: aws.Bool(true),
+				MasterUserSecretKmsKeyId: aws.String("key-1"),
+				MasterUsername:           aws.String("fake-user"),
+				AutoMinorVersionUpgrade:  aws.Bool(true),
+				CopyTagsToSnapshot:       aws.Bool(true),
+				MultiAZ:                  aws.Bool(false),
+				StorageEncrypted:         aws.Bool(false),
+				StorageType:              aws.String("gp3"),
+				PubliclyAccessible:       aws.Bool(false),
+				BackupRetentionPeriod:    aws.Int64(14),
+				DBSubnetGroupName:        aws.String("subnet-group-1"),
+				VpcSecurityGroupIds: []*string{
+					aws.String("sec-group-1"),
+				},
+			},
+		},
 	}
 
 	for name, test := range testCases {
@@ -164,6 +363,7 @@ func TestCreateDb(t *testing.T) {
 	}{
 		"create DB error": {
 			dbAdapter: &dedicatedDBAdapter{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRdsClientForAdapterTests{
 					createDbErr: createDbErr,
 				},
@@ -202,6 +402,7 @@ func TestModifyDb(t *testing.T) {
 	}{
 		"modify DB error": {
 			dbAdapter: &dedicatedDBAdapter{
+				logger: lagertest.NewTestLogger("test"),
 				rds: &mockRdsClientForAdapterTests{
 					modifyDbErr: modifyDbErr,
 				},
@@ -229,6 +430,33 @@ func TestModifyDb(t *testing.T) {
 	}
 }
 
+func TestModifyDbStorageAutoscaling(t *testing.T) {
+	client := &mockRdsClientForAdapterTests{
+		describeDbInstancesResults: &rds.DescribeDBInstancesOutput{
+			DBInstances: []*rds.DBInstance{{AllocatedStorage: aws.Int64(120)}},
+		},
+	}
+	adapter := &dedicatedDBAdapter{
+		logger:               lagertest.NewTestLogger("test"),
+		rds:                  client,
+		parameterGroupClient: &mockParameterGroupClient{},
+	}
+	i := NewRDSInstance()
+	i.AllocatedStorage = 100
+	i.MaxAllocatedStorage = 200
+
+	if _, err := adapter.modifyDB(i, ""); err != nil {
+		t.Fatal(err)
+	}
+	// The storage grown by autoscaling is kept.
+	if aws.Int64Value(client.modifyDbInput.AllocatedStorage) != 120 {
+		t.Errorf("expected storage 120, got %d", aws.Int64Value(client.modifyDbInput.AllocatedStorage))
+	}
+	if aws.Int64Value(client.modifyDbInput.MaxAllocatedStorage) != 200 {
+		t.Errorf("expected max allocated storage 200, got %d", aws.Int64Value(client.modifyDbInput.MaxAllocatedStorage))
+	}
+}
+
 func TestPrepareModifyDbInstanceInput(t *testing.T) {
 	testErr := errors.New("fail")
 	testCases := map[string]struct {
@@ -248,6 +476,7 @@ func TestPrepareModifyDbInstanceInput(t *testing.T) {
 				BackupRetentionPeriod: 14,
 			},
 			dbAdapter: &dedicatedDBAdapter{
+				logger: lagertest.NewTestLogger("test"),
 				parameterGroupClient: &mockParameterGroupClient{
 					customPgroupName: "foobar",
 					rds:              &mockRDSClient{},
@@ -265,6 +494,7 @@ func TestPrepareModifyDbInstanceInput(t *testing.T) {
 				MultiAZ:                  aws.Bool(true),
 				DBInstanceIdentifier:     aws.String("db-name"),
 				AllowMajorVersionUpgrade: aws.Bool(false),
+				CopyTagsToSnapshot:       aws.Bool(true),
 				BackupRetentionPeriod:    aws.Int64(14),
 				DBParameterGroupName:     aws.String("foobar"),
 			},
@@ -279,6 +509,7 @@ func TestPrepareModifyDbInstanceInput(t *testing.T) {
 				BackupRetentionPeriod: 14,
 			},
 			dbAdapter: &dedicatedDBAdapter{
+				logger: lagertest.NewTestLogger("test"),
 				parameterGroupClient: &mockParameterGroupClient{
 					rds:       &mockRDSClient{},
 					returnErr: testErr,
@@ -301,6 +532,7 @@ func TestPrepareModifyDbInstanceInput(t *testing.T) {
 				BackupRetentionPeriod: 14,
 			},
 			dbAdapter: &dedicatedDBAdapter{
+				logger: lagertest.NewTestLogger("test"),
 				parameterGroupClient: &mockParameterGroupClient{
 					rds: &mockRDSClient{},
 				},
@@ -317,6 +549,7 @@ func TestPrepareModifyDbInstanceInput(t *testing.T) {
 				MultiAZ:                  aws.Bool(true),
 				DBInstanceIdentifier:     aws.String("db-name"),
 				AllowMajorVersionUpgrade: aws.Bool(false),
+				CopyTagsToSnapshot:       aws.Bool(true),
 				BackupRetentionPeriod:    aws.Int64(14),
 				MasterUserPassword:       aws.String("fake-pw"),
 			},
@@ -331,6 +564,7 @@ func TestPrepareModifyDbInstanceInput(t *testing.T) {
 				BackupRetentionPeriod: 14,
 			},
 			dbAdapter: &dedicatedDBAdapter{
+				logger: lagertest.NewTestLogger("test"),
 				Plan: catalog.RDSPlan{
 					InstanceClass: "class",
 					Redundant:     true,
@@ -347,10 +581,80 @@ func TestPrepareModifyDbInstanceInput(t *testing.T) {
 				MultiAZ:                  aws.Bool(true),
 				DBInstanceIdentifier:     aws.String("db-name"),
 				AllowMajorVersionUpgrade: aws.Bool(false),
+				CopyTagsToSnapshot:       aws.Bool(true),
 				BackupRetentionPeriod:    aws.Int64(14),
 				StorageType:              aws.String("gp3"),
 			},
 		},
+		"upgrade major version": {
+			dbInstance: &RDSInstance{
+				dbUtils:               &RDSDatabaseUtils{},
+				DbType:                "postgres",
+				DbVersion:             "15.4",
+				AllocatedStorage:      20,
+				Database:              "db-name",
+				BackupRetentionPeriod: 14,
+				upgradeMajorVersion:   true,
+			},
+			dbAdapter: &dedicatedDBAdapter{
+				logger: lagertest.NewTestLogger("test"),
+				Plan: catalog.RDSPlan{
+					InstanceClass: "class",
+					Redundant:     true,
+				},
+				parameterGroupClient: &mockParameterGroupClient{
+					rds: &mockRDSClient{},
+				},
+				rds: &mockRDSClient{},
+			},
+			expectedParams: &rds.ModifyDBInstanceInput{
+				AllocatedStorage:         aws.Int64(20),
+				ApplyImmediately:         aws.Bool(true),
+				DBInstanceClass:          aws.String("class"),
+				MultiAZ:                  aws.Bool(true),
+				DBInstanceIdentifier:     aws.String("db-name"),
+				AllowMajorVersionUpgrade: aws.Bool(true),
+				EngineVersion:            aws.String("15.4"),
+				CopyTagsToSnapshot:       aws.Bool(true),
+				BackupRetentionPeriod:    aws.Int64(14),
+			},
+		},
+		"clear storage autoscaling and gp3 performance": {
+			dbInstance: &RDSInstance{
+				dbUtils:                  &RDSDatabaseUtils{},
+				DbType:                   "postgres",
+				StorageType:              "gp3",
+				AllocatedStorage:         400,
+				Database:                 "db-name",
+				BackupRetentionPeriod:    14,
+				updateStoragePerformance: true,
+			},
+			dbAdapter: &dedicatedDBAdapter{
+				logger: lagertest.NewTestLogger("test"),
+				Plan: catalog.RDSPlan{
+					InstanceClass: "class",
+					Redundant:     true,
+				},
+				parameterGroupClient: &mockParameterGroupClient{
+					rds: &mockRDSClient{},
+				},
+				rds: &mockRDSClient{},
+			},
+			expectedParams: &rds.ModifyDBInstanceInput{
+				AllocatedStorage:         aws.Int64(400),
+				ApplyImmediately:         aws.Bool(true),
+				DBInstanceClass:          aws.String("class"),
+				MultiAZ:                  aws.Bool(true),
+				DBInstanceIdentifier:     aws.String("db-name"),
+				AllowMajorVersionUpgrade: aws.Bool(false),
+				CopyTagsToSnapshot:       aws.Bool(true),
+				BackupRetentionPeriod:    aws.Int64(14),
+				StorageType:              aws.String("gp3"),
+				MaxAllocatedStorage:      aws.Int64(400),
+				Iops:                     aws.Int64(12000),
+				StorageThroughput:        aws.Int64(500),
+			},
+		},
 	}
 
 	for name, test := range testCases {
@@ -367,3 +671,917 @@ func TestPrepareModifyDbInstanceInput(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteDb(t *testing.T) {
+	deleteDbErr := errors.New("delete DB error")
+	testCases := map[string]struct {
+		rds                     *mockRdsClientForAdapterTests
+		finalSnapshotIdentifier string
+		expectedErr             error
+		expectedResponseCode    base.InstanceState
+		expectedInput           *rds.DeleteDBInstanceInput
+	}{
+		"with final snapshot": {
+			rds:                     &mockRdsClientForAdapterTests{},
+			finalSnapshotIdentifier: "db-final-uuid-20240101-120000",
+			expectedResponseCode:    base.InstanceInProgress,
+			expectedInput: &rds.DeleteDBInstanceInput{
+				DBInstanceIdentifier:      aws.String("db"),
+				DeleteAutomatedBackups:    aws.Bool(false),
+				FinalDBSnapshotIdentifier: aws.String("db-final-uuid-20240101-120000"),
+				SkipFinalSnapshot:         aws.Bool(false),
+			},
+		},
+		"without final snapshot": {
+			rds:                  &mockRdsClientForAdapterTests{},
+			expectedResponseCode: base.InstanceInProgress,
+			expectedInput: &rds.DeleteDBInstanceInput{
+				DBInstanceIdentifier:   aws.String("db"),
+				DeleteAutomatedBackups: aws.Bool(false),
+				SkipFinalSnapshot:      aws.Bool(true),
+			},
+		},
+		"already deleted": {
+			rds: &mockRdsClientForAdapterTests{
+				deleteDbErr: awserr.New(rds.ErrCodeDBInstanceNotFoundFault, "not found", nil),
+			},
+			expectedResponseCode: base.InstanceGone,
+		},
+		"delete DB error": {
+			rds: &mockRdsClientForAdapterTests{
+				deleteDbErr: deleteDbErr,
+			},
+			expectedErr:          deleteDbErr,
+			expectedResponseCode: base.InstanceNotGone,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			adapter := &dedicatedDBAdapter{
+				logger:               lagertest.NewTestLogger("test"),
+				rds:                  test.rds,
+				parameterGroupClient: &mockParameterGroupClient{},
+			}
+			responseCode, err := adapter.deleteDB(&RDSInstance{Database: "db"}, test.finalSnapshotIdentifier)
+			if !errors.Is(test.expectedErr, err) {
+				t.Errorf("expected error: %s, got: %s", test.expectedErr, err)
+			}
+			if responseCode != test.expectedResponseCode {
+				t.Errorf("expected response: %s, got: %s", test.expectedResponseCode, responseCode)
+			}
+			if test.expectedInput != nil {
+				if diff := deep.Equal(test.rds.deleteDbInput, test.expectedInput); diff != nil {
+					t.Error(diff)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckDBDeleted(t *testing.T) {
+	describeErr := errors.New("describe error")
+	testCases := map[string]struct {
+		rds                  *mockRdsClientForAdapterTests
+		expectedErr          error
+		expectedResponseCode base.InstanceState
+	}{
+		"deleting": {
+			rds: &mockRdsClientForAdapterTests{
+				describeDbInstancesResults: &rds.DescribeDBInstancesOutput{
+					DBInstances: []*rds.DBInstance{
+						{DBInstanceStatus: aws.String("deleting")},
+					},
+				},
+			},
+			expectedResponseCode: base.InstanceInProgress,
+		},
+		"available": {
+			rds: &mockRdsClientForAdapterTests{
+				describeDbInstancesResults: &rds.DescribeDBInstancesOutput{
+					DBInstances: []*rds.DBInstance{
+						{DBInstanceStatus: aws.String("available")},
+					},
+				},
+			},
+			expectedResponseCode: base.InstanceNotGone,
+		},
+		"deleted": {
+			rds: &mockRdsClientForAdapterTests{
+				describeDbInstancesErr: awserr.New(rds.ErrCodeDBInstanceNotFoundFault, "not found", nil),
+			},
+			expectedResponseCode: base.InstanceGone,
+		},
+		"describe error": {
+			rds: &mockRdsClientForAdapterTests{
+				describeDbInstancesErr: describeErr,
+			},
+			expectedErr:          describeErr,
+			expectedResponseCode: base.InstanceNotGone,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			adapter := &dedicatedDBAdapter{
+				logger:               lagertest.NewTestLogger("test"),
+				rds:                  test.rds,
+				parameterGroupClient: &mockParameterGroupClient{},
+			}
+			responseCode, err := adapter.checkDBDeleted(&RDSInstance{Database: "db"})
+			if !errors.Is(test.expectedErr, err) {
+				t.Errorf("expected error: %s, got: %s", test.expectedErr, err)
+			}
+			if responseCode != test.expectedResponseCode {
+				t.Errorf("expected response: %s, got: %s", test.expectedResponseCode, responseCode)
+			}
+		})
+	}
+}
+
+func TestFindRestoreSnapshot(t *testing.T) {
+	orgTags := map[string][]*rds.Tag{
+		"arn-1": {{Key: aws.String("Organization GUID"), Value: aws.String("org-1")}},
+		"arn-2": {{Key: aws.String("Organization GUID"), Value: aws.String("org-1")}},
+		"arn-3": {{Key: aws.String("Organization GUID"), Value: aws.String("org-2")}},
+	}
+	testCases := map[string]struct {
+		dbInstance         *RDSInstance
+		snapshots          []*rds.DBSnapshot
+		expectedSnapshotID string
+		expectErr          bool
+	}{
+		"most recent snapshot of source instance": {
+			dbInstance: &RDSInstance{DbType: "postgres", sourceDatabase: "db-source"},
+			snapshots: []*rds.DBSnapshot{
+				{DBSnapshotIdentifier: aws.String("snapshot-1"), DBSnapshotArn: aws.String("arn-1"), Engine: aws.String("postgres"), Status: aws.String("available"), SnapshotCreateTime: aws.Time(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))},
+				{DBSnapshotIdentifier: aws.String("snapshot-2"), DBSnapshotArn: aws.String("arn-2"), Engine: aws.String("postgres"), Status: aws.String("available"), SnapshotCreateTime: aws.Time(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))},
+				{DBSnapshotIdentifier: aws.String("snapshot-4"), DBSnapshotArn: aws.String("arn-2"), Engine: aws.String("postgres"), Status: aws.String("creating"), SnapshotCreateTime: aws.Time(time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC))},
+			},
+			expectedSnapshotID: "snapshot-2",
+		},
+		"snapshot of another organization": {
+			dbInstance: &RDSInstance{DbType: "postgres", SnapshotIdentifier: "snapshot-3"},
+			snapshots: []*rds.DBSnapshot{
+				{DBSnapshotIdentifier: aws.String("snapshot-3"), DBSnapshotArn: aws.String("arn-3"), Engine: aws.String("postgres"), Status: aws.String("available")},
+			},
+			expectErr: true,
+		},
+		"snapshot without tags": {
+			dbInstance: &RDSInstance{DbType: "postgres", SnapshotIdentifier: "snapshot-5"},
+			snapshots: []*rds.DBSnapshot{
+				{DBSnapshotIdentifier: aws.String("snapshot-5"), DBSnapshotArn: aws.String("arn-5"), Engine: aws.String("postgres"), Status: aws.String("available")},
+			},
+			expectErr: true,
+		},
+		"snapshot of another engine": {
+			dbInstance: &RDSInstance{DbType: "mysql", SnapshotIdentifier: "snapshot-1"},
+			snapshots: []*rds.DBSnapshot{
+				{DBSnapshotIdentifier: aws.String("snapshot-1"), DBSnapshotArn: aws.String("arn-1"), Engine: aws.String("postgres"), Status: aws.String("available")},
+			},
+			expectErr: true,
+		},
+		"no available snapshot": {
+			dbInstance: &RDSInstance{DbType: "postgres", sourceDatabase: "db-source"},
+			expectErr:  true,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			test.dbInstance.OrganizationGUID = "org-1"
+			adapter := &dedicatedDBAdapter{
+				logger: lagertest.NewTestLogger("test"),
+				rds: &mockRdsClientForAdapterTests{
+					describeDbSnapshotsResults: test.snapshots,
+					listTagsResults:            orgTags,
+				},
+			}
+			snapshot, err := adapter.findRestoreSnapshot(test.dbInstance)
+			if test.expectErr && err == nil {
+				t.Fatal("expected error")
+			}
+			if !test.expectErr && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if snapshot != nil && aws.StringValue(snapshot.DBSnapshotIdentifier) != test.expectedSnapshotID {
+				t.Errorf("expected snapshot %s, got %s", test.expectedSnapshotID, aws.StringValue(snapshot.DBSnapshotIdentifier))
+			}
+		})
+	}
+}
+
+func TestPrepareRestoreDbInput(t *testing.T) {
+	dbInstance := &RDSInstance{
+		Database:      "db-1",
+		DbType:        "postgres",
+		Username:      "generated-user",
+		StorageType:   "gp3",
+		DbSubnetGroup: "subnet-group-1",
+		SecGroup:      "sec-group-1",
+		Tags: map[string]string{
+			"foo": "bar",
+		},
+	}
+	adapter := &dedicatedDBAdapter{
+		logger: lagertest.NewTestLogger("test"),
+		parameterGroupClient: &mockParameterGroupClient{
+			customPgroupName: "parameter-group-1",
+		},
+		Plan: catalog.RDSPlan{
+			InstanceClass: "class-1",
+			Redundant:     true,
+		},
+	}
+	snapshot := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("snapshot-1"),
+		MasterUsername:       aws.String("snapshot-user"),
+		EngineVersion:        aws.String("15.5"),
+	}
+
+	params, err := adapter.prepareRestoreDbInput(dbInstance, snapshot)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expectedParams := &rds.RestoreDBInstanceFromDBSnapshotInput{
+		DBInstanceClass:         aws.String("class-1"),
+		DBInstanceIdentifier:    aws.String("db-1"),
+		DBSnapshotIdentifier:    aws.String("snapshot-1"),
+		Engine:                  aws.String("postgres"),
+		AutoMinorVersionUpgrade: aws.Bool(true),
+		CopyTagsToSnapshot:      aws.Bool(true),
+		MultiAZ:                 aws.Bool(true),
+		StorageType:             aws.String("gp3"),
+		Tags: []*rds.Tag{
+			{
+				Key:   aws.String("foo"),
+				Value: aws.String("bar"),
+			},
+		},
+		PubliclyAccessible: aws.Bool(false),
+		DBSubnetGroupName:  aws.String("subnet-group-1"),
+		VpcSecurityGroupIds: []*string{
+			aws.String("sec-group-1"),
+		},
+		DBParameterGroupName: aws.String("parameter-group-1"),
+	}
+	if diff := deep.Equal(params, expectedParams); diff != nil {
+		t.Error(diff)
+	}
+	if dbInstance.Username != "snapshot-user" {
+		t.Errorf("expected the username of the snapshot, got %s", dbInstance.Username)
+	}
+	if dbInstance.DbVersion != "15.5" {
+		t.Errorf("expected the version of the snapshot, got %s", dbInstance.DbVersion)
+	}
+	if !dbInstance.RestoreModifyPending {
+		t.Error("expected the instance to be modified once restored")
+	}
+}
+
+func TestCheckDBStatusRestored(t *testing.T) {
+	client := &mockRdsClientForAdapterTests{
+		describeDbInstancesResults: &rds.DescribeDBInstancesOutput{
+			DBInstances: []*rds.DBInstance{
+				{
+					DBInstanceStatus: aws.String("available"),
+					DBName:           aws.String("sourcedb"),
+					AllocatedStorage: aws.Int64(20),
+				},
+			},
+		},
+	}
+	adapter := &dedicatedDBAdapter{
+		logger: lagertest.NewTestLogger("test"),
+		rds:    client,
+	}
+	dbInstance := &RDSInstance{
+		Database:              "db-1",
+		ClearPassword:         "password",
+		AllocatedStorage:      10,
+		BackupRetentionPeriod: 14,
+		RestoreModifyPending:  true,
+	}
+
+	status, err := adapter.checkDBStatus(dbInstance)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if status != base.InstanceInProgress {
+		t.Errorf("expected %s, got %s", base.InstanceInProgress, status)
+	}
+	expectedParams := &rds.ModifyDBInstanceInput{
+		DBInstanceIdentifier:  aws.String("db-1"),
+		ApplyImmediately:      aws.Bool(true),
+		MasterUserPassword:    aws.String("password"),
+		BackupRetentionPeriod: aws.Int64(14),
+	}
+	if diff := deep.Equal(client.modifyDbInput, expectedParams); diff != nil {
+		t.Error(diff)
+	}
+	if dbInstance.RestoreModifyPending {
+		t.Error("expected the restored instance to be modified")
+	}
+	if dbInstance.FormatDBName() != "sourcedb" {
+		t.Errorf("expected the database name of the snapshot, got %s", dbInstance.FormatDBName())
+	}
+	if dbInstance.AllocatedStorage != 20 {
+		t.Errorf("expected the storage of the snapshot, got %d", dbInstance.AllocatedStorage)
+	}
+}
+
+func TestCheckRestoreWindow(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	source := &rds.DBInstance{
+		DBInstanceIdentifier:  aws.String("db-source"),
+		BackupRetentionPeriod: aws.Int64(7),
+		InstanceCreateTime:    aws.Time(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)),
+		LatestRestorableTime:  aws.Time(now.Add(-5 * time.Minute)),
+	}
+	testCases := map[string]struct {
+		source      *rds.DBInstance
+		restoreTime *time.Time
+		expectErr   bool
+	}{
+		"latest restorable time": {
+			source: source,
+		},
+		"within retention period": {
+			source:      source,
+			restoreTime: aws.Time(now.AddDate(0, 0, -3)),
+		},
+		"before retention period": {
+			source:      source,
+			restoreTime: aws.Time(now.AddDate(0, 0, -8)),
+			expectErr:   true,
+		},
+		"after latest restorable time": {
+			source:      source,
+			restoreTime: aws.Time(now.Add(-time.Minute)),
+			expectErr:   true,
+		},
+		"before instance was created": {
+			source: &rds.DBInstance{
+				DBInstanceIdentifier:  aws.String("db-source"),
+				BackupRetentionPeriod: aws.Int64(7),
+				InstanceCreateTime:    aws.Time(now.AddDate(0, 0, -1)),
+				LatestRestorableTime:  aws.Time(now.Add(-5 * time.Minute)),
+			},
+			restoreTime: aws.Time(now.AddDate(0, 0, -2)),
+			expectErr:   true,
+		},
+		"no automated backups": {
+			source: &rds.DBInstance{
+				DBInstanceIdentifier:  aws.String("db-source"),
+				BackupRetentionPeriod: aws.Int64(0),
+			},
+			expectErr: true,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := checkRestoreWindow(test.source, test.restoreTime, now)
+			if test.expectErr && err == nil {
+				t.Fatal("expected error")
+			}
+			if !test.expectErr && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestPreparePointInTimeRestoreInput(t *testing.T) {
+	restoreTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	testCases := map[string]struct {
+		restoreTime    *time.Time
+		expectedParams *rds.RestoreDBInstanceToPointInTimeInput
+	}{
+		"latest restorable time": {
+			expectedParams: &rds.RestoreDBInstanceToPointInTimeInput{
+				SourceDBInstanceIdentifier: aws.String("db-source"),
+				TargetDBInstanceIdentifier: aws.String("db-1"),
+				DBInstanceClass:            aws.String("class-1"),
+				Engine:                     aws.String("postgres"),
+				AutoMinorVersionUpgrade:    aws.Bool(true),
+				CopyTagsToSnapshot:         aws.Bool(true),
+				MultiAZ:                    aws.Bool(false),
+				StorageType:                aws.String("gp3"),
+				PubliclyAccessible:         aws.Bool(false),
+				DBSubnetGroupName:          aws.String("subnet-group-1"),
+				VpcSecurityGroupIds: []*string{
+					aws.String("sec-group-1"),
+				},
+				UseLatestRestorableTime: aws.Bool(true),
+			},
+		},
+		"restore time": {
+			restoreTime: &restoreTime,
+			expectedParams: &rds.RestoreDBInstanceToPointInTimeInput{
+				SourceDBInstanceIdentifier: aws.String("db-source"),
+				TargetDBInstanceIdentifier: aws.String("db-1"),
+				DBInstanceClass:            aws.String("class-1"),
+				Engine:                     aws.String("postgres"),
+				AutoMinorVersionUpgrade:    aws.Bool(true),
+				CopyTagsToSnapshot:         aws.Bool(true),
+				MultiAZ:                    aws.Bool(false),
+				StorageType:                aws.String("gp3"),
+				PubliclyAccessible:         aws.Bool(false),
+				DBSubnetGroupName:          aws.String("subnet-group-1"),
+				VpcSecurityGroupIds: []*string{
+					aws.String("sec-group-1"),
+				},
+				RestoreTime: &restoreTime,
+			},
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dbInstance := &RDSInstance{
+				Database:          "db-1",
+				DbType:            "postgres",
+				StorageType:       "gp3",
+				DbSubnetGroup:     "subnet-group-1",
+				SecGroup:          "sec-group-1",
+				pointInTimeSource: "db-source",
+				restoreTime:       test.restoreTime,
+			}
+			adapter := &dedicatedDBAdapter{
+				logger:               lagertest.NewTestLogger("test"),
+				parameterGroupClient: &mockParameterGroupClient{},
+				Plan: catalog.RDSPlan{
+					InstanceClass: "class-1",
+				},
+			}
+			source := &rds.DBInstance{
+				MasterUsername: aws.String("source-user"),
+				EngineVersion:  aws.String("15.5"),
+			}
+
+			params, err := adapter.preparePointInTimeRestoreInput(dbInstance, source)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := deep.Equal(params, test.expectedParams); diff != nil {
+				t.Error(diff)
+			}
+			if dbInstance.Username != "source-user" {
+				t.Errorf("expected the username of the source, got %s", dbInstance.Username)
+			}
+			if !dbInstance.RestoreModifyPending {
+				t.Error("expected the instance to be modified once restored")
+			}
+		})
+	}
+}
+
+func TestPrepareCreateReadReplicaInput(t *testing.T) {
+	dbInstance := &RDSInstance{
+		Database:           "db-1",
+		StorageType:        "gp3",
+		ParameterGroupName: "group-1",
+		SecGroup:           "sec-group-2",
+	}
+	adapter := &dedicatedDBAdapter{
+		logger: lagertest.NewTestLogger("test"),
+		Plan: catalog.RDSPlan{
+			InstanceClass: "class-1",
+			SecurityGroup: "sec-group-1",
+		},
+	}
+	expectedParams := &rds.CreateDBInstanceReadReplicaInput{
+		DBInstanceIdentifier:       aws.String("db-1-replica-1"),
+		SourceDBInstanceIdentifier: aws.String("db-1"),
+		DBInstanceClass:            aws.String("class-1"),
+		AutoMinorVersionUpgrade:    aws.Bool(true),
+		CopyTagsToSnapshot:         aws.Bool(true),
+		PubliclyAccessible:         aws.Bool(false),
+		StorageType:                aws.String("gp3"),
+		DBParameterGroupName:       aws.String("group-1"),
+		VpcSecurityGroupIds: []*string{
+			aws.String("sec-group-2"),
+		},
+	}
+
+	params := adapter.prepareCreateReadReplicaInput(dbInstance, newReadReplica(dbInstance, 1))
+	if diff := deep.Equal(params, expectedParams); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestCreateReadReplicaSecurityGroup(t *testing.T) {
+	rdsClient := &mockRdsClientForAdapterTests{
+		describeDbInstancesResults: &rds.DescribeDBInstancesOutput{
+			DBInstances: []*rds.DBInstance{
+				{
+					VpcSecurityGroups: []*rds.VpcSecurityGroupMembership{
+						{VpcSecurityGroupId: aws.String("sec-group-2")},
+					},
+				},
+			},
+		},
+	}
+	adapter := &dedicatedDBAdapter{
+		logger: lagertest.NewTestLogger("test"),
+		rds:    rdsClient,
+		Plan:   catalog.RDSPlan{SecurityGroup: "sec-group-1"},
+	}
+	dbInstance := &RDSInstance{Database: "db-1"}
+
+	status, err := adapter.createReadReplica(dbInstance, newReadReplica(dbInstance, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != base.InstanceInProgress {
+		t.Errorf("expected response: %s, got: %s", base.InstanceInProgress, status)
+	}
+	groups := rdsClient.createDbReadReplicaInput.VpcSecurityGroupIds
+	if len(groups) != 1 || aws.StringValue(groups[0]) != "sec-group-2" {
+		t.Errorf("expected the replica to be in the security group of the instance, got %v", aws.StringValueSlice(groups))
+	}
+}
+
+func TestCheckReadReplicaStatus(t *testing.T) {
+	describeErr := errors.New("describe error")
+	testCases := map[string]struct {
+		rds                  *mockRdsClientForAdapterTests
+		expectedErr          error
+		expectedResponseCode base.InstanceState
+		expectedHost         string
+	}{
+		"creating": {
+			rds: &mockRdsClientForAdapterTests{
+				describeDbInstancesResults: &rds.DescribeDBInstancesOutput{
+					DBInstances: []*rds.DBInstance{
+						{DBInstanceStatus: aws.String("creating")},
+					},
+				},
+			},
+			expectedResponseCode: base.InstanceInProgress,
+		},
+		"available": {
+			rds: &mockRdsClientForAdapterTests{
+				describeDbInstancesResults: &rds.DescribeDBInstancesOutput{
+					DBInstances: []*rds.DBInstance{
+						{
+							DBInstanceStatus: aws.String("available"),
+							Endpoint: &rds.Endpoint{
+								Address: aws.String("replica.example.com"),
+								Port:    aws.Int64(5432),
+							},
+						},
+					},
+				},
+			},
+			expectedResponseCode: base.InstanceReady,
+			expectedHost:         "replica.example.com",
+		},
+		"failed": {
+			rds: &mockRdsClientForAdapterTests{
+				describeDbInstancesResults: &rds.DescribeDBInstancesOutput{
+					DBInstances: []*rds.DBInstance{
+						{DBInstanceStatus: aws.String("failed")},
+					},
+				},
+			},
+			expectedResponseCode: base.InstanceNotCreated,
+		},
+		"describe error": {
+			rds: &mockRdsClientForAdapterTests{
+				describeDbInstancesErr: describeErr,
+			},
+			expectedErr:          describeErr,
+			expectedResponseCode: base.InstanceNotCreated,
+		},
+		"deleted": {
+			rds: &mockRdsClientForAdapterTests{
+				describeDbInstancesErr: awserr.New(rds.ErrCodeDBInstanceNotFoundFault, "not found", nil),
+			},
+			expectedResponseCode: base.InstanceGone,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			adapter := &dedicatedDBAdapter{
+				logger: lagertest.NewTestLogger("test"),
+				rds:    test.rds,
+			}
+			replica := &ReadReplica{Identifier: "db-replica-1"}
+			responseCode, err := adapter.checkReadReplicaStatus(replica)
+			if !errors.Is(test.expectedErr, err) {
+				t.Errorf("expected error: %s, got: %s", test.expectedErr, err)
+			}
+			if responseCode != test.expectedResponseCode {
+				t.Errorf("expected response: %s, got: %s", test.expectedResponseCode, responseCode)
+			}
+			if replica.Host != test.expectedHost {
+				t.Errorf("expected host: %s, got: %s", test.expectedHost, replica.Host)
+			}
+		})
+	}
+}
+
+func TestGetMajorVersion(t *testing.T) {
+	testCases := map[string]string{
+		"15.4":   "15",
+		"15":     "15",
+		"9.6.24": "9.6",
+		"8.0.35": "8.0",
+		"5.7":    "5.7",
+	}
+
+	for version, expected := range testCases {
+		t.Run(version, func(t *testing.T) {
+			if majorVersion := getMajorVersion(version); majorVersion != expected {
+				t.Errorf("expected: %s, got: %s", expected, majorVersion)
+			}
+		})
+	}
+}
+
+func TestFindMajorVersionUpgradeTarget(t *testing.T) {
+	describeDbInstancesResults := &rds.DescribeDBInstancesOutput{
+		DBInstances: []*rds.DBInstance{
+			{EngineVersion: aws.String("14.9")},
+		},
+	}
+	describeDbEngineVersionsResults := &rds.DescribeDBEngineVersionsOutput{
+		DBEngineVersions: []*rds.DBEngineVersion{
+			{
+				ValidUpgradeTarget: []*rds.UpgradeTarget{
+					{EngineVersion: aws.String("14.10"), IsMajorVersionUpgrade: aws.Bool(false)},
+					{EngineVersion: aws.String("15.4"), IsMajorVersionUpgrade: aws.Bool(true)},
+					{EngineVersion: aws.String("15.5"), IsMajorVersionUpgrade: aws.Bool(true)},
+					{EngineVersion: aws.String("16.1"), IsMajorVersionUpgrade: aws.Bool(true)},
+				},
+			},
+		},
+	}
+	testCases := map[string]struct {
+		majorVersion   string
+		expectedTarget string
+		expectErr      bool
+	}{
+		"newest version of major version": {
+			majorVersion:   "15",
+			expectedTarget: "15.5",
+		},
+		"already on major version": {
+			majorVersion:   "14",
+			expectedTarget: "",
+		},
+		"no valid upgrade target": {
+			majorVersion: "17",
+			expectErr:    true,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			adapter := &dedicatedDBAdapter{
+				logger: lagertest.NewTestLogger("test"),
+				rds: &mockRdsClientForAdapterTests{
+					describeDbInstancesResults:      describeDbInstancesResults,
+					describeDbEngineVersionsResults: describeDbEngineVersionsResults,
+				},
+			}
+			target, err := adapter.findMajorVersionUpgradeTarget(&RDSInstance{
+				DbType:   "postgres",
+				Database: "db-name",
+			}, test.majorVersion)
+			if test.expectErr && err == nil {
+				t.Fatal("expected error")
+			}
+			if !test.expectErr && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if target != test.expectedTarget {
+				t.Errorf("expected target: %s, got: %s", test.expectedTarget, target)
+			}
+		})
+	}
+}
+
+func TestPrepareCreateBlueGreenDeploymentInput(t *testing.T) {
+	adapter := &dedicatedDBAdapter{
+		logger: lagertest.NewTestLogger("test"),
+		parameterGroupClient: &mockParameterGroupClient{
+			customPgroupName: "group-15",
+			rds:              &mockRDSClient{},
+		},
+		Plan: catalog.RDSPlan{
+			InstanceClass: "class",
+		},
+	}
+	i := &RDSInstance{
+		Database:            "db-name",
+		DbType:              "postgres",
+		DbVersion:           "15.5",
+		upgradeMajorVersion: true,
+		Tags:                map[string]string{"foo": "bar"},
+	}
+
+	params, err := adapter.prepareCreateBlueGreenDeploymentInput(i, "arn:aws:rds:us-gov-west-1:123:db:db-name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedParams := &rds.CreateBlueGreenDeploymentInput{
+		BlueGreenDeploymentName:    aws.String("db-name-bg"),
+		Source:                     aws.String("arn:aws:rds:us-gov-west-1:123:db:db-name"),
+		TargetDBInstanceClass:      aws.String("class"),
+		TargetEngineVersion:        aws.String("15.5"),
+		TargetDBParameterGroupName: aws.String("group-15"),
+		Tags: []*rds.Tag{
+			{Key: aws.String("foo"), Value: aws.String("bar")},
+		},
+	}
+	if diff := deep.Equal(params, expectedParams); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestCheckBlueGreenDeployment(t *testing.T) {
+	testCases := map[string]struct {
+		deployment                   *rds.BlueGreenDeployment
+		expectedResponseCode         base.InstanceState
+		expectErr                    bool
+		expectSwitchover             bool
+		expectedDeleteTarget         *bool
+		expectDeploymentDeleted      bool
+		sourceInstance               string
+		expectedDeletedInstance      string
+		expectedDeploymentIdentifier string
+	}{
+		"provisioning": {
+			deployment:                   &rds.BlueGreenDeployment{Status: aws.String("PROVISIONING")},
+			expectedResponseCode:         base.InstanceInProgress,
+			expectedDeploymentIdentifier: "bgd-1",
+		},
+		"available": {
+			deployment:                   &rds.BlueGreenDeployment{Status: aws.String("AVAILABLE")},
+			expectedResponseCode:         base.InstanceInProgress,
+			expectSwitchover:             true,
+			expectedDeploymentIdentifier: "bgd-1",
+		},
+		"switchover completed": {
+			deployment: &rds.BlueGreenDeployment{
+				BlueGreenDeploymentIdentifier: aws.String("bgd-1"),
+				Status:                        aws.String("SWITCHOVER_COMPLETED"),
+				SwitchoverDetails: []*rds.SwitchoverDetail{
+					{SourceMember: aws.String("arn:aws:rds:us-gov-west-1:123:db:db-name-old1")},
+				},
+			},
+			expectedResponseCode:    base.InstanceReady,
+			expectDeploymentDeleted: true,
+			sourceInstance:          "db-name-old1",
+			expectedDeletedInstance: "db-name-old1",
+		},
+		"switchover source is the instance": {
+			deployment: &rds.BlueGreenDeployment{
+				BlueGreenDeploymentIdentifier: aws.String("bgd-1"),
+				Status:                        aws.String("SWITCHOVER_COMPLETED"),
+				SwitchoverDetails: []*rds.SwitchoverDetail{
+					{SourceMember: aws.String("arn:aws:rds:us-gov-west-1:123:db:db-name")},
+				},
+			},
+			expectedResponseCode:    base.InstanceReady,
+			expectDeploymentDeleted: true,
+			sourceInstance:          "db-name",
+		},
+		"switchover source is another instance": {
+			deployment: &rds.BlueGreenDeployment{
+				BlueGreenDeploymentIdentifier: aws.String("bgd-1"),
+				Status:                        aws.String("SWITCHOVER_COMPLETED"),
+				SwitchoverDetails: []*rds.SwitchoverDetail{
+					{SourceMember: aws.String("arn:aws:rds:us-gov-west-1:123:db:db-name-2-old1")},
+				},
+			},
+			expectedResponseCode:    base.InstanceReady,
+			expectDeploymentDeleted: true,
+			sourceInstance:          "db-name-2-old1",
+		},
+		"provisioning failed": {
+			deployment:              &rds.BlueGreenDeployment{Status: aws.String("PROVISIONING_FAILED")},
+			expectedResponseCode:    base.InstanceNotModified,
+			expectErr:               true,
+			expectDeploymentDeleted: true,
+			expectedDeleteTarget:    aws.Bool(true),
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			client := &mockRdsClientForAdapterTests{
+				describeBlueGreenDeploymentsResults: []*rds.BlueGreenDeployment{test.deployment},
+				describeDbInstancesResults: &rds.DescribeDBInstancesOutput{
+					DBInstances: []*rds.DBInstance{
+						{DBInstanceIdentifier: aws.String(test.sourceInstance)},
+					},
+				},
+			}
+			adapter := &dedicatedDBAdapter{
+				logger:   lagertest.NewTestLogger("test"),
+				rds:      client,
+				settings: config.Settings{DbNamePrefix: "cg-aws-broker-"},
+			}
+			i := &RDSInstance{Database: "db-name", BlueGreenDeploymentIdentifier: "bgd-1"}
+			i.Uuid = "uuid"
+
+			responseCode, err := adapter.checkBlueGreenDeployment(i)
+			if test.expectErr && err == nil {
+				t.Fatal("expected error")
+			}
+			if !test.expectErr && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if responseCode != test.expectedResponseCode {
+				t.Errorf("expected response: %s, got: %s", test.expectedResponseCode, responseCode)
+			}
+			if i.BlueGreenDeploymentIdentifier != test.expectedDeploymentIdentifier {
+				t.Errorf("expected deployment identifier: %q, got: %q", test.expectedDeploymentIdentifier, i.BlueGreenDeploymentIdentifier)
+			}
+			if test.expectSwitchover != (client.switchoverBlueGreenDeploymentInput != nil) {
+				t.Errorf("expected switchover: %t", test.expectSwitchover)
+			}
+			if test.expectDeploymentDeleted != (client.deleteBlueGreenDeploymentInput != nil) {
+				t.Fatalf("expected deployment deleted: %t", test.expectDeploymentDeleted)
+			}
+			if test.expectDeploymentDeleted {
+				if diff := deep.Equal(client.deleteBlueGreenDeploymentInput.DeleteTarget, test.expectedDeleteTarget); diff != nil {
+					t.Error(diff)
+				}
+			}
+			deletedInstance := ""
+			if client.deleteDbInput != nil {
+				deletedInstance = aws.StringValue(client.deleteDbInput.DBInstanceIdentifier)
+			}
+			if deletedInstance != test.expectedDeletedInstance {
+				t.Errorf("expected deleted instance: %q, got: %q", test.expectedDeletedInstance, deletedInstance)
+			}
+			if deletedInstance == "" {
+				if len(i.blueGreenFinalSnapshots) != 0 {
+					t.Errorf("expected no final snapshots, got %d", len(i.blueGreenFinalSnapshots))
+				}
+				return
+			}
+			finalSnapshotIdentifier := aws.StringValue(client.deleteDbInput.FinalDBSnapshotIdentifier)
+			if aws.BoolValue(client.deleteDbInput.SkipFinalSnapshot) || !strings.HasPrefix(finalSnapshotIdentifier, "cg-aws-broker-final-uuid-") {
+				t.Errorf("expected a final snapshot of the old instance, got %q", finalSnapshotIdentifier)
+			}
+			if len(i.blueGreenFinalSnapshots) != 1 || i.blueGreenFinalSnapshots[0].SnapshotIdentifier != finalSnapshotIdentifier {
+				t.Errorf("expected the final snapshot %q to be recorded, got %v", finalSnapshotIdentifier, i.blueGreenFinalSnapshots)
+			}
+		})
+	}
+}
+
+func TestIsBlueGreenOldInstance(t *testing.T) {
+	i := &RDSInstance{Database: "db-name"}
+	testCases := map[string]bool{
+		"db-name-old1":   true,
+		"db-name-old12":  true,
+		"db-name":        false,
+		"db-name-old":    false,
+		"db-name-oldest": false,
+		"db-name-2-old1": false,
+		"other-old1":     false,
+	}
+	for identifier, expected := range testCases {
+		t.Run(identifier, func(t *testing.T) {
+			if isOld := isBlueGreenOldInstance(i, identifier); isOld != expected {
+				t.Errorf("expected %t, got %t", expected, isOld)
+			}
+		})
+	}
+}
+
+func TestPrepareDbInputsWindows(t *testing.T) {
+	adapter := &dedicatedDBAdapter{
+		parameterGroupClient: &mockParameterGroupClient{},
+		logger:               lagertest.NewTestLogger("test"),
+	}
+	i := NewRDSInstance()
+	i.PreferredMaintenanceWindow = "sun:06:00-sun:07:00"
+	i.PreferredBackupWindow = "03:00-04:00"
+
+	createParams, err := adapter.prepareCreateDbInput(i, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.StringValue(createParams.PreferredMaintenanceWindow) != i.PreferredMaintenanceWindow ||
+		aws.StringValue(createParams.PreferredBackupWindow) != i.PreferredBackupWindow {
+		t.Errorf("unexpected windows %v and %v on create", createParams.PreferredMaintenanceWindow, createParams.PreferredBackupWindow)
+	}
+
+	modifyParams, err := adapter.prepareModifyDbInstanceInput(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.StringValue(modifyParams.PreferredMaintenanceWindow) != i.PreferredMaintenanceWindow ||
+		aws.StringValue(modifyParams.PreferredBackupWindow) != i.PreferredBackupWindow {
+		t.Errorf("unexpected windows %v and %v on modify", modifyParams.PreferredMaintenanceWindow, modifyParams.PreferredBackupWindow)
+	}
+}
@@ -9,7 +9,10 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/18F/aws-broker/catalog"
 	"github.com/18F/aws-broker/config"
@@ -59,11 +62,125 @@ type RDSInstance struct {
 	BinaryLogFormat      string `sql:"size(255)"`
 	EnablePgCron         *bool  `sql:"size(255)"`
 	ParameterGroupFamily string `sql:"-"`
-	ParameterGroupName   string `sql:"size(255)"`
+	// ParameterGroupName is the DB cluster parameter group of Aurora instances.
+	ParameterGroupName string `sql:"size(255)"`
+	// DBParameters are the "name=value" database parameters set by the
+	// tenant, which are applied with the custom parameter group.
+	DBParameters pq.StringArray `sql:"type:text[]"`
+	// Extensions are the PostgreSQL extensions requested by the tenant.
+	// ExtensionsPending is set until they are created once the instance is
+	// available. ExtensionsRebootPending is set when the broker added their
+	// libraries to shared_preload_libraries, until the instance is rebooted
+	// to load them.
+	Extensions              pq.StringArray `sql:"type:text[]"`
+	ExtensionsPending       bool           `sql:"size(255)"`
+	ExtensionsRebootPending bool           `sql:"size(255)"`
 
 	EnabledCloudwatchLogGroupExports pq.StringArray `sql:"type:text[]"`
 
 	StorageType string `sql:"size(255)"`
+	// MaxAllocatedStorage is the storage autoscaling limit of the instance,
+	// or 0 if storage autoscaling is disabled.
+	MaxAllocatedStorage int64 `sql:"size(255)"`
+	// Iops and StorageThroughput are the provisioned IOPS and throughput of
+	// gp3, io1 and io2 storage, or 0 for the defaults of the storage type.
+	Iops              int64 `sql:"size(255)"`
+	StorageThroughput int64 `sql:"size(255)"`
+
+	SkipFinalSnapshot *bool `sql:"size(255)"`
+
+	// SnapshotIdentifier is the snapshot the instance was restored from, if any.
+	SnapshotIdentifier   string `sql:"size(255)"`
+	RestoreModifyPending bool   `sql:"size(255)"`
+	DbName               string `sql:"size(255)"`
+
+	// ReadReplicas is the number of read replicas requested for the instance.
+	ReadReplicas int64 `sql:"size(255)"`
+
+	// ReaderHost is the reader endpoint of the cluster of Aurora instances.
+	ReaderHost string `sql:"size(255)"`
+
+	// BlueGreenDeploymentIdentifier is the blue/green deployment the instance
+	// is being updated with, if any.
+	BlueGreenDeploymentIdentifier string `sql:"size(255)"`
+
+	// IamUserName, IamPolicyARN and IamAccessKeyID are the IAM user, its
+	// rds-db:connect policy and its access key that instances of plans with
+	// IAM database authentication are bound with. IamSecretAccessKey is
+	// encrypted like Password, with a salt of its own so that it can still be
+	// read after the credentials of the instance are rotated.
+	IamUserName            string `sql:"size(255)"`
+	IamPolicyARN           string `sql:"size(255)"`
+	IamAccessKeyID         string `sql:"size(255)"`
+	IamSecretAccessKey     string `sql:"size(255)"`
+	IamSecretAccessKeySalt string `sql:"size(255)"`
+
+	// EnablePerformanceInsights, PerformanceInsightsRetention (in days) and
+	// PerformanceInsightsKMSKeyID are the Performance Insights settings of the
+	// instance.
+	EnablePerformanceInsights    bool   `sql:"size(255)"`
+	PerformanceInsightsRetention int64  `sql:"size(255)"`
+	PerformanceInsightsKMSKeyID  string `sql:"size(255)"`
+	// MonitoringInterval is the Enhanced Monitoring interval of the instance in
+	// seconds, or 0 if Enhanced Monitoring is disabled. MonitoringRoleARN is
+	// the role created by the broker that RDS publishes the metrics with.
+	MonitoringInterval int64  `sql:"size(255)"`
+	MonitoringRoleARN  string `sql:"size(255)"`
+
+	// PreferredMaintenanceWindow and PreferredBackupWindow are the weekly
+	// maintenance window and daily backup window of the instance in UTC, or
+	// empty for windows chosen by AWS.
+	PreferredMaintenanceWindow string `sql:"size(255)"`
+	PreferredBackupWindow      string `sql:"size(255)"`
+
+	// CredentialsRotatedAt is when the master credentials of the instance were
+	// last rotated, on request or by the rotation policy of its plan, or nil
+	// if they were never rotated.
+	CredentialsRotatedAt *time.Time
+	// ScheduledCredentialRotation is set for instances that opted in to the
+	// rotation of their master credentials by the rotation period of their
+	// plan. PendingSalt and PendingPassword hold the new credentials of a
+	// scheduled rotation until they are applied, so that a rotation
+	// interrupted after AWS applied the password is completed with it.
+	ScheduledCredentialRotation bool   `sql:"size(255)"`
+	PendingSalt                 string `sql:"size(255)"`
+	PendingPassword             string `sql:"size(255)"`
+
+	// ManageMasterUserPassword is set for instances whose master password RDS
+	// manages in AWS Secrets Manager, for which the broker keeps no Password.
+	// MasterUserSecretARN is the ARN of that secret once RDS created it.
+	ManageMasterUserPassword bool   `sql:"size(255)"`
+	MasterUserSecretARN      string `sql:"size(255)"`
+
+	// DeletionProtection is set for instances that cannot be deleted until
+	// it is turned off.
+	DeletionProtection bool `sql:"size(255)"`
+
+	// useBlueGreen is set when the update of the instance is applied through
+	// a blue/green deployment instead of modifying it in place.
+	useBlueGreen bool `sql:"-"`
+	// updateMonitoring is set when the Performance Insights or Enhanced
+	// Monitoring settings of the instance are given, so that they are only
+	// applied to instances whose settings the broker manages.
+	updateMonitoring bool `sql:"-"`
+	// updateStoragePerformance is set when the storage autoscaling limit,
+	// IOPS or throughput are given, so that cleared values are applied.
+	updateStoragePerformance bool `sql:"-"`
+	// updateDeletionProtection is set when deletion protection is given, so
+	// that it is only applied to instances whose setting the broker manages.
+	updateDeletionProtection bool `sql:"-"`
+	// upgradeMajorVersion is set when DbVersion is a new major version that the
+	// instance is upgraded to.
+	upgradeMajorVersion bool `sql:"-"`
+	// sourceDatabase is the instance whose latest snapshot is restored.
+	sourceDatabase string `sql:"-"`
+	// pointInTimeSource is the instance restored from as of restoreTime, or
+	// as of its latest restorable time if restoreTime is nil.
+	pointInTimeSource string     `sql:"-"`
+	restoreTime       *time.Time `sql:"-"`
+	// blueGreenFinalSnapshots are the final snapshots of the old instances
+	// deleted after a blue/green switchover, which the broker records.
+	blueGreenFinalSnapshots []*FinalSnapshot `sql:"-"`
 }
 
 func (u *RDSDatabaseUtils) FormatDBName(dbType string, database string) string {
@@ -139,6 +256,20 @@ func (u *RDSDatabaseUtils) getCredentials(i *RDSInstance, password string) (map[
 		"db_name":  dbName,
 		"name":     dbName,
 	}
+
+	// The host of Aurora instances is the writer endpoint of their cluster.
+	if i.ReaderHost != "" {
+		credentials["reader_host"] = i.ReaderHost
+		credentials["reader_uri"] = fmt.Sprintf(
+			"%s://%s:%s@%s:%d/%s",
+			dbScheme,
+			i.Username,
+			password,
+			i.ReaderHost,
+			i.Port,
+			dbName,
+		)
+	}
 	return credentials, nil
 }
 
@@ -170,7 +301,34 @@ func NewRDSInstance() *RDSInstance {
 	}
 }
 
+// isAurora reports whether the instance is an Aurora cluster.
+func (i *RDSInstance) isAurora() bool {
+	return i.Adapter == "aurora"
+}
+
+// isShared reports whether the instance is a database on the shared database
+// server of its plan.
+func (i *RDSInstance) isShared() bool {
+	return i.Adapter == "shared"
+}
+
+// engine is the RDS engine of the instance, e.g. "aurora-postgresql" for
+// Aurora PostgreSQL instances.
+func (i *RDSInstance) engine() string {
+	if !i.isAurora() {
+		return i.DbType
+	}
+	if i.DbType == "postgres" {
+		return "aurora-postgresql"
+	}
+	return "aurora-" + i.DbType
+}
+
 func (i *RDSInstance) FormatDBName() string {
+	// Restored instances keep the database name of their snapshot.
+	if i.DbName != "" {
+		return i.DbName
+	}
 	return i.dbUtils.FormatDBName(i.DbType, i.Database)
 }
 
@@ -190,6 +348,16 @@ func (i *RDSInstance) generateCredentials(settings *config.Settings) error {
 }
 
 func (i *RDSInstance) modify(options Options, plan catalog.RDSPlan, settings *config.Settings) error {
+	if plan.ID != i.PlanID && plan.ManageMasterUserPassword != i.ManageMasterUserPassword {
+		return errors.New("instances cannot be updated between plans with and without RDS-managed master passwords")
+	}
+
+	if options.UseBlueGreen {
+		if err := i.setUseBlueGreen(options); err != nil {
+			return err
+		}
+	}
+
 	// Check to see if there is a storage size change and if so, check to make sure it's a valid change.
 	if options.AllocatedStorage > 0 {
 		// Check that we are not decreasing the size of the instance.
@@ -207,6 +375,38 @@ func (i *RDSInstance) modify(options Options, plan catalog.RDSPlan, settings *co
 
 	if options.StorageType != i.StorageType {
 		i.StorageType = options.StorageType
+		// The provisioned performance of the previous storage type does not
+		// carry over.
+		i.Iops = 0
+		i.StorageThroughput = 0
+	}
+
+	if err := i.setStoragePerformance(options); err != nil {
+		return err
+	}
+
+	if err := i.setMonitoring(options); err != nil {
+		return err
+	}
+
+	if err := i.setWindows(options); err != nil {
+		return err
+	}
+
+	if err := i.setDBParameters(options.DBParameters); err != nil {
+		return err
+	}
+
+	if err := i.setExtensions(options.Extensions); err != nil {
+		return err
+	}
+
+	if err := i.setDeletionProtection(options.DeletionProtection); err != nil {
+		return err
+	}
+
+	if err := i.setScheduledCredentialRotation(options.ScheduledCredentialRotation); err != nil {
+		return err
 	}
 
 	// Check if there is a backup retention change
@@ -236,14 +436,98 @@ func (i *RDSInstance) modify(options Options, plan catalog.RDSPlan, settings *co
 	}
 
 	if options.RotateCredentials != nil && *options.RotateCredentials {
+		if i.ManageMasterUserPassword {
+			return errors.New("the master password of the instance is managed by AWS Secrets Manager and is rotated on the rotation schedule of its secret")
+		}
 		err := i.generateCredentials(settings)
 		if err != nil {
 			return err
 		}
+		rotatedAt := time.Now()
+		i.CredentialsRotatedAt = &rotatedAt
 	}
 
 	i.setEnabledCloudwatchLogGroupExports(options.EnableCloudWatchLogGroupExports)
 
+	if options.SkipFinalSnapshot != nil {
+		i.SkipFinalSnapshot = options.SkipFinalSnapshot
+	}
+
+	return i.setReadReplicas(options.ReadReplicas)
+}
+
+// setManageMasterUserPassword has RDS manage the master password of the
+// instance in AWS Secrets Manager if its plan asks for it. The broker then
+// keeps no password, only the salt that the IAM keys of the instance are
+// encrypted with.
+func (i *RDSInstance) setManageMasterUserPassword(plan catalog.RDSPlan) error {
+	if !plan.ManageMasterUserPassword {
+		return nil
+	}
+	if i.isShared() || i.isAurora() {
+		return fmt.Errorf("RDS-managed master passwords are not supported for %s plans", i.Adapter)
+	}
+	i.ManageMasterUserPassword = true
+	i.Password = ""
+	i.ClearPassword = ""
+	return nil
+}
+
+// setDeletionProtection turns the deletion protection of the instance on or
+// off, if given.
+func (i *RDSInstance) setDeletionProtection(deletionProtection *bool) error {
+	if deletionProtection == nil {
+		return nil
+	}
+	if i.isShared() {
+		return errors.New("deletion protection is not supported for shared databases")
+	}
+	i.DeletionProtection = *deletionProtection
+	i.updateDeletionProtection = true
+	return nil
+}
+
+// setScheduledCredentialRotation opts the instance in or out of the scheduled
+// rotation of its master credentials, if given.
+func (i *RDSInstance) setScheduledCredentialRotation(enabled *bool) error {
+	if enabled == nil {
+		return nil
+	}
+	if *enabled && i.ManageMasterUserPassword {
+		return errors.New("the master password of the instance is managed by AWS Secrets Manager and is rotated on the rotation schedule of its secret")
+	}
+	i.ScheduledCredentialRotation = *enabled
+	return nil
+}
+
+// setUseBlueGreen sets the update of the instance to be applied through a
+// blue/green deployment. Blue/green deployments can change the version, the
+// instance class and the parameters of the instance, but not its storage or
+// credentials.
+func (i *RDSInstance) setUseBlueGreen(options Options) error {
+	if (i.DbType != "postgres" && i.DbType != "mysql") || i.isAurora() {
+		return fmt.Errorf("blue/green deployments are not supported for %s databases", i.engine())
+	}
+	if i.isShared() {
+		return errors.New("blue/green deployments are not supported for shared databases")
+	}
+	if options.AllocatedStorage > i.AllocatedStorage || (options.StorageType != "" && options.StorageType != i.StorageType) ||
+		options.hasStoragePerformance() {
+		return errors.New("the storage of an instance cannot be changed with use_blue_green")
+	}
+	if options.RotateCredentials != nil && *options.RotateCredentials {
+		return errors.New("credentials cannot be rotated with use_blue_green")
+	}
+	if options.hasMonitoring() {
+		return errors.New("Performance Insights and Enhanced Monitoring cannot be changed with use_blue_green")
+	}
+	if options.PreferredMaintenanceWindow != "" || options.PreferredBackupWindow != "" {
+		return errors.New("maintenance and backup windows cannot be changed with use_blue_green")
+	}
+	if i.BlueGreenDeploymentIdentifier != "" {
+		return errors.New("a blue/green deployment of the instance is already in progress")
+	}
+	i.useBlueGreen = true
 	return nil
 }
 
@@ -297,6 +581,9 @@ func (i *RDSInstance) init(
 	if err != nil {
 		return err
 	}
+	if err := i.setManageMasterUserPassword(plan); err != nil {
+		return err
+	}
 
 	i.setTags(plan, tags)
 
@@ -306,16 +593,221 @@ func (i *RDSInstance) init(
 	if i.AllocatedStorage == 0 {
 		i.AllocatedStorage = plan.AllocatedStorage
 	}
+	if err := i.setStoragePerformance(options); err != nil {
+		return err
+	}
+	if err := i.setMonitoring(options); err != nil {
+		return err
+	}
+	i.PreferredMaintenanceWindow = plan.PreferredMaintenanceWindow
+	i.PreferredBackupWindow = plan.PreferredBackupWindow
+	if err := i.setWindows(options); err != nil {
+		return err
+	}
+	if err := i.setDBParameters(options.DBParameters); err != nil {
+		return err
+	}
+	if err := i.setExtensions(options.Extensions); err != nil {
+		return err
+	}
+	if err := i.setDeletionProtection(options.DeletionProtection); err != nil {
+		return err
+	}
+	if err := i.setScheduledCredentialRotation(options.ScheduledCredentialRotation); err != nil {
+		return err
+	}
 	i.EnableFunctions = options.EnableFunctions
 	i.PubliclyAccessible = options.PubliclyAccessible
 	i.BinaryLogFormat = options.BinaryLogFormat
 	i.EnablePgCron = options.EnablePgCron
+	i.SkipFinalSnapshot = options.SkipFinalSnapshot
 
 	i.setEnabledCloudwatchLogGroupExports(options.EnableCloudWatchLogGroupExports)
 
+	return i.setReadReplicas(options.ReadReplicas)
+}
+
+// setStoragePerformance sets the storage autoscaling limit, IOPS and
+// throughput of the instance, if given, and checks them against the limits of
+// its storage type. An explicit 0 clears them.
+func (i *RDSInstance) setStoragePerformance(options Options) error {
+	if !options.hasStoragePerformance() {
+		return validateStoragePerformance(i.DbType, i.StorageType, i.AllocatedStorage, i.MaxAllocatedStorage, i.Iops, i.StorageThroughput)
+	}
+	if i.isAurora() || i.isShared() {
+		return errors.New("max_allocated_storage, iops and storage_throughput are only supported for dedicated instances")
+	}
+	if options.MaxAllocatedStorage != nil {
+		i.MaxAllocatedStorage = *options.MaxAllocatedStorage
+	}
+	if options.Iops != nil {
+		i.Iops = *options.Iops
+	}
+	if options.StorageThroughput != nil {
+		i.StorageThroughput = *options.StorageThroughput
+	}
+	i.updateStoragePerformance = true
+	return validateStoragePerformance(i.DbType, i.StorageType, i.AllocatedStorage, i.MaxAllocatedStorage, i.Iops, i.StorageThroughput)
+}
+
+// setMonitoring sets the Performance Insights and Enhanced Monitoring settings
+// of the instance, if given.
+func (i *RDSInstance) setMonitoring(options Options) error {
+	if !options.hasMonitoring() {
+		return nil
+	}
+	if i.isAurora() || i.isShared() {
+		return errors.New("Performance Insights and Enhanced Monitoring are only supported for dedicated instances")
+	}
+
+	// RDS encrypts Performance Insights data with the key it was first
+	// enabled with, which is the default RDS key if none is given.
+	if options.PerformanceInsightsKMSKeyID != "" && i.EnablePerformanceInsights &&
+		options.PerformanceInsightsKMSKeyID != i.PerformanceInsightsKMSKeyID {
+		return errors.New("the KMS key of Performance Insights cannot be changed once it is enabled")
+	}
+
+	if options.EnablePerformanceInsights != nil {
+		i.EnablePerformanceInsights = *options.EnablePerformanceInsights
+	}
+	if !i.EnablePerformanceInsights && (options.PerformanceInsightsRetention > 0 || options.PerformanceInsightsKMSKeyID != "") {
+		return errors.New("performance_insights_retention_period and performance_insights_kms_key_id require enable_performance_insights")
+	}
+	if options.PerformanceInsightsRetention > 0 {
+		i.PerformanceInsightsRetention = options.PerformanceInsightsRetention
+	}
+	if options.PerformanceInsightsKMSKeyID != "" {
+		i.PerformanceInsightsKMSKeyID = options.PerformanceInsightsKMSKeyID
+	}
+	if options.MonitoringInterval != nil {
+		i.MonitoringInterval = *options.MonitoringInterval
+	}
+	i.updateMonitoring = true
 	return nil
 }
 
+// setWindows sets the maintenance and backup windows of the instance, if
+// given, and checks that they do not overlap.
+func (i *RDSInstance) setWindows(options Options) error {
+	if options.PreferredMaintenanceWindow != "" || options.PreferredBackupWindow != "" {
+		if i.isShared() {
+			return errors.New("maintenance and backup windows are not supported for shared databases")
+		}
+	}
+	if options.PreferredMaintenanceWindow != "" {
+		i.PreferredMaintenanceWindow = options.PreferredMaintenanceWindow
+	}
+	if options.PreferredBackupWindow != "" {
+		i.PreferredBackupWindow = options.PreferredBackupWindow
+	}
+	return validateWindows(i.PreferredMaintenanceWindow, i.PreferredBackupWindow)
+}
+
+// dbParameters returns the database parameters set on the instance by name.
+func (i *RDSInstance) dbParameters() map[string]string {
+	parameters := make(map[string]string)
+	for _, parameter := range i.DBParameters {
+		name, value, _ := strings.Cut(parameter, "=")
+		parameters[name] = value
+	}
+	return parameters
+}
+
+// setDBParameters adds the given database parameters to those of the
+// instance, replacing the values of parameters that are already set.
+func (i *RDSInstance) setDBParameters(dbParameters map[string]string) error {
+	if len(dbParameters) == 0 {
+		return nil
+	}
+	if i.isShared() {
+		return errors.New("db_parameters are not supported for shared databases")
+	}
+
+	parameters := i.dbParameters()
+	for name, value := range dbParameters {
+		parameters[name] = value
+	}
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	i.DBParameters = pq.StringArray{}
+	for _, name := range names {
+		i.DBParameters = append(i.DBParameters, name+"="+parameters[name])
+	}
+	return nil
+}
+
+// setExtensions adds the given PostgreSQL extensions to those of the instance
+// and marks them to be created. Extensions are never dropped, since dropping
+// them could remove the data of the tenant.
+func (i *RDSInstance) setExtensions(extensions []string) error {
+	if len(extensions) == 0 {
+		return nil
+	}
+	if i.DbType != "postgres" || i.isShared() || i.isAurora() {
+		return errors.New("extensions are only supported for dedicated PostgreSQL instances")
+	}
+
+	for _, extension := range extensions {
+		found := false
+		for _, existing := range i.Extensions {
+			if existing == extension {
+				found = true
+				break
+			}
+		}
+		if !found {
+			i.Extensions = append(i.Extensions, extension)
+		}
+	}
+	sort.Strings(i.Extensions)
+	i.ExtensionsPending = true
+	return nil
+}
+
+// setReadReplicas sets the number of read replicas of the instance, if given.
+func (i *RDSInstance) setReadReplicas(readReplicas *int64) error {
+	if readReplicas == nil {
+		return nil
+	}
+	if *readReplicas > 0 && i.DbType != "postgres" && i.DbType != "mysql" {
+		return errors.New("read replicas are only supported for MySQL and PostgreSQL instances")
+	}
+	if *readReplicas > 0 && i.isAurora() {
+		return errors.New("the reader instances of Aurora instances are set by their plan")
+	}
+	if *readReplicas > 0 && i.isShared() {
+		return errors.New("read replicas are not supported for shared databases")
+	}
+	i.ReadReplicas = *readReplicas
+	return nil
+}
+
+// skipFinalSnapshot reports whether the instance should be deleted without
+// taking a final snapshot. The "skip_final_snapshot" parameter of the instance
+// takes precedence over the setting of the plan.
+func (i *RDSInstance) skipFinalSnapshot(plan catalog.RDSPlan) bool {
+	// Shared databases are dropped from their server without a snapshot.
+	if i.isShared() {
+		return true
+	}
+	if i.SkipFinalSnapshot != nil {
+		return *i.SkipFinalSnapshot
+	}
+	return plan.SkipFinalSnapshot
+}
+
+// finalSnapshotIdentifier names the final snapshot of the instance from its
+// GUID and the time of deletion, e.g. "db-final-<guid>-20240101-120000".
+func (i *RDSInstance) finalSnapshotIdentifier(settings *config.Settings, now time.Time) string {
+	// Snapshot identifiers may not contain consecutive hyphens.
+	prefix := strings.TrimRight(settings.DbNamePrefix, "-")
+	return fmt.Sprintf("%s-final-%s-%s", prefix, i.Uuid, now.UTC().Format("20060102-150405"))
+}
+
 func (i *RDSInstance) setTags(
 	plan catalog.RDSPlan,
 	tags map[string]string,
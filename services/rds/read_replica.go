@@ -0,0 +1,57 @@
+package rds
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/18F/aws-broker/base"
+)
+
+// ReadReplica is a read replica of an RDS instance that the broker manages.
+type ReadReplica struct {
+	Identifier string `gorm:"primary_key" sql:"type:varchar(255) PRIMARY KEY"`
+
+	InstanceGUID string `sql:"size(255)"`
+
+	Host string `sql:"size(255)"`
+	Port int64
+
+	State base.InstanceState
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName keeps the read replicas apart from the instances they replicate.
+func (ReadReplica) TableName() string {
+	return "rds_read_replicas"
+}
+
+// newReadReplica returns the read replica of the instance with the given
+// number. Replicas are numbered from 1.
+func newReadReplica(i *RDSInstance, number int) *ReadReplica {
+	return &ReadReplica{
+		Identifier:   fmt.Sprintf("%s-replica-%d", i.Database, number),
+		InstanceGUID: i.Uuid,
+	}
+}
+
+// readReplicaURIs returns the connection URIs of the replicas of the instance
+// that are available.
+func readReplicaURIs(i *RDSInstance, password string, replicas []ReadReplica) ([]string, error) {
+	var uris []string
+	for _, replica := range replicas {
+		if replica.Host == "" {
+			continue
+		}
+		replicaInstance := *i
+		replicaInstance.Host = replica.Host
+		replicaInstance.Port = replica.Port
+		credentials, err := replicaInstance.getCredentials(password)
+		if err != nil {
+			return nil, err
+		}
+		uris = append(uris, credentials["uri"])
+	}
+	return uris, nil
+}
@@ -0,0 +1,257 @@
+package rds
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/common"
+)
+
+// dbConn runs statements on a database server, such as the shared server of a
+// plan or the database of an instance.
+type dbConn interface {
+	Exec(sql string, values ...interface{}) *gorm.DB
+}
+
+// sharedDBAdapter manages instances as databases on the shared database server
+// of their plan, from the RDS settings loaded from the secrets. Each instance
+// is a database owned by a role of its own, so status checks are trivial.
+type sharedDBAdapter struct {
+	db     dbConn
+	config common.DBConfig
+	logger lager.Logger
+}
+
+// quoteIdentifier quotes a database or role name for the engine of the server.
+func (d *sharedDBAdapter) quoteIdentifier(name string) string {
+	if d.config.DbType == "mysql" {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return pq.QuoteIdentifier(name)
+}
+
+// quoteLiteral quotes a password for the engine of the server.
+func (d *sharedDBAdapter) quoteLiteral(value string) string {
+	if d.config.DbType == "mysql" {
+		return "'" + strings.ReplaceAll(strings.ReplaceAll(value, `\`, `\\`), "'", "''") + "'"
+	}
+	return pq.QuoteLiteral(value)
+}
+
+// exec runs the statements in order, stopping at the first that fails.
+func (d *sharedDBAdapter) exec(statements ...string) error {
+	for _, statement := range statements {
+		if err := d.db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sharedDBStep is a statement that creates part of an instance, and the
+// statement that removes it again, if any.
+type sharedDBStep struct {
+	statement string
+	cleanup   string
+}
+
+// execSteps runs the statements of the steps in order. When one fails, the
+// steps that were applied are cleaned up in reverse order, so that a failed
+// creation can be retried.
+func (d *sharedDBAdapter) execSteps(steps ...sharedDBStep) error {
+	for n, step := range steps {
+		err := d.db.Exec(step.statement).Error
+		if err == nil {
+			continue
+		}
+		for k := n - 1; k >= 0; k-- {
+			if steps[k].cleanup == "" {
+				continue
+			}
+			if cleanupErr := d.db.Exec(steps[k].cleanup).Error; cleanupErr != nil {
+				d.logger.Error("shared-db-cleanup", cleanupErr)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (d *sharedDBAdapter) createDB(i *RDSInstance, password string) (base.InstanceState, error) {
+	if i.SnapshotIdentifier != "" || i.sourceDatabase != "" || i.pointInTimeSource != "" {
+		return base.InstanceNotCreated, errors.New("restoring instances is not supported for shared database plans")
+	}
+	if i.DbType != d.config.DbType {
+		return base.InstanceNotCreated, fmt.Errorf("the shared database server of the plan is not a %s server", i.DbType)
+	}
+
+	database := d.quoteIdentifier(i.FormatDBName())
+	username := d.quoteIdentifier(i.Username)
+
+	var err error
+	switch i.DbType {
+	case "postgres":
+		// The broker has to be a member of the owner role to create a
+		// database owned by it. Dropping the role also drops the membership.
+		err = d.execSteps(
+			sharedDBStep{
+				statement: fmt.Sprintf("CREATE ROLE %s WITH LOGIN PASSWORD %s", username, d.quoteLiteral(password)),
+				cleanup:   fmt.Sprintf("DROP ROLE IF EXISTS %s", username),
+			},
+			sharedDBStep{statement: fmt.Sprintf("GRANT %s TO %s", username, d.quoteIdentifier(d.config.Username))},
+			sharedDBStep{
+				statement: fmt.Sprintf("CREATE DATABASE %s OWNER %s", database, username),
+				cleanup:   fmt.Sprintf("DROP DATABASE IF EXISTS %s", database),
+			},
+			sharedDBStep{statement: fmt.Sprintf("REVOKE ALL ON DATABASE %s FROM PUBLIC", database)},
+		)
+	case "mysql":
+		err = d.execSteps(
+			sharedDBStep{
+				statement: fmt.Sprintf("CREATE DATABASE %s", database),
+				cleanup:   fmt.Sprintf("DROP DATABASE IF EXISTS %s", database),
+			},
+			sharedDBStep{
+				statement: fmt.Sprintf("CREATE USER %s@'%%' IDENTIFIED BY %s", username, d.quoteLiteral(password)),
+				cleanup:   fmt.Sprintf("DROP USER IF EXISTS %s@'%%'", username),
+			},
+			sharedDBStep{statement: fmt.Sprintf("GRANT ALL PRIVILEGES ON %s.* TO %s@'%%'", database, username)},
+		)
+	default:
+		return base.InstanceNotCreated, fmt.Errorf("shared databases are not supported for %s plans", i.DbType)
+	}
+	if err != nil {
+		return base.InstanceNotCreated, err
+	}
+
+	d.logger.Info("shared-db-created", lager.Data{"database": i.FormatDBName()})
+	i.Host = d.config.URL
+	i.Port = d.config.Port
+	return base.InstanceReady, nil
+}
+
+// modifyDB applies new credentials of the instance to its owner role. The
+// other parameters of instances do not apply to shared databases.
+func (d *sharedDBAdapter) modifyDB(i *RDSInstance, password string) (base.InstanceState, error) {
+	if i.useBlueGreen || i.upgradeMajorVersion {
+		return base.InstanceNotModified, errors.New("blue/green deployments and major version upgrades are not supported for shared database plans")
+	}
+	if i.ClearPassword == "" {
+		return base.InstanceReady, nil
+	}
+
+	var err error
+	switch i.DbType {
+	case "postgres":
+		err = d.exec(fmt.Sprintf("ALTER ROLE %s WITH PASSWORD %s", d.quoteIdentifier(i.Username), d.quoteLiteral(i.ClearPassword)))
+	case "mysql":
+		err = d.exec(fmt.Sprintf("ALTER USER %s@'%%' IDENTIFIED BY %s", d.quoteIdentifier(i.Username), d.quoteLiteral(i.ClearPassword)))
+	}
+	if err != nil {
+		return base.InstanceNotModified, err
+	}
+	return base.InstanceReady, nil
+}
+
+func (d *sharedDBAdapter) checkDBStatus(i *RDSInstance) (base.InstanceState, error) {
+	return base.InstanceReady, nil
+}
+
+func (d *sharedDBAdapter) bindDBToApp(i *RDSInstance, password string) (map[string]string, error) {
+	i.Host = d.config.URL
+	i.Port = d.config.Port
+	i.State = base.InstanceReady
+	return i.getCredentials(password)
+}
+
+// deleteDB drops the database and owner role of the instance. Shared
+// databases have no snapshots, so the final snapshot is ignored.
+func (d *sharedDBAdapter) deleteDB(i *RDSInstance, finalSnapshotIdentifier string) (base.InstanceState, error) {
+	database := d.quoteIdentifier(i.FormatDBName())
+	username := d.quoteIdentifier(i.Username)
+
+	var err error
+	switch i.DbType {
+	case "postgres":
+		err = d.exec(
+			fmt.Sprintf("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = %s", d.quoteLiteral(i.FormatDBName())),
+			fmt.Sprintf("DROP DATABASE IF EXISTS %s", database),
+			fmt.Sprintf("DROP ROLE IF EXISTS %s", username),
+		)
+	case "mysql":
+		err = d.exec(
+			fmt.Sprintf("DROP DATABASE IF EXISTS %s", database),
+			fmt.Sprintf("DROP USER IF EXISTS %s@'%%'", username),
+		)
+	}
+	if err != nil {
+		return base.InstanceNotGone, err
+	}
+
+	d.logger.Info("shared-db-deleted", lager.Data{"database": i.FormatDBName()})
+	return base.InstanceGone, nil
+}
+
+func (d *sharedDBAdapter) checkDBDeleted(i *RDSInstance) (base.InstanceState, error) {
+	return base.InstanceGone, nil
+}
+
+func (d *sharedDBAdapter) describeDB(i *RDSInstance) (*rds.DBInstance, error) {
+	return nil, errors.New("shared databases are not RDS instances")
+}
+
+func (d *sharedDBAdapter) createReadReplica(i *RDSInstance, r *ReadReplica) (base.InstanceState, error) {
+	return base.InstanceNotCreated, errors.New("read replicas are not supported for shared database plans")
+}
+
+func (d *sharedDBAdapter) checkReadReplicaStatus(r *ReadReplica) (base.InstanceState, error) {
+	return base.InstanceNotCreated, errors.New("read replicas are not supported for shared database plans")
+}
+
+func (d *sharedDBAdapter) deleteReadReplica(r *ReadReplica) (base.InstanceState, error) {
+	return base.InstanceNotGone, errors.New("read replicas are not supported for shared database plans")
+}
+
+func (d *sharedDBAdapter) findMajorVersionUpgradeTarget(i *RDSInstance, majorVersion string) (string, error) {
+	return "", errors.New("the version of shared databases is that of their server")
+}
+
+func (d *sharedDBAdapter) createSnapshot(i *RDSInstance, name string, tags map[string]string) (base.Snapshot, error) {
+	return base.Snapshot{}, errors.New("manual snapshots are not supported for shared database plans")
+}
+
+func (d *sharedDBAdapter) listSnapshots(i *RDSInstance) ([]base.Snapshot, error) {
+	return nil, errors.New("manual snapshots are not supported for shared database plans")
+}
+
+func (d *sharedDBAdapter) deleteSnapshot(snapshotIdentifier string) error {
+	return errors.New("manual snapshots are not supported for shared database plans")
+}
+
+// rotateMasterPassword applies the new password of the instance to its owner
+// role.
+func (d *sharedDBAdapter) rotateMasterPassword(i *RDSInstance) error {
+	_, err := d.modifyDB(i, i.ClearPassword)
+	return err
+}
+
+func (d *sharedDBAdapter) getManagedMasterPassword(i *RDSInstance) (string, error) {
+	return "", errors.New("RDS-managed master passwords are not supported for shared database plans")
+}
+
+// stopDB leaves the database running, since the shared server cannot be
+// stopped for a single database.
+func (d *sharedDBAdapter) stopDB(i *RDSInstance) error {
+	return nil
+}
+
+func (d *sharedDBAdapter) startDB(i *RDSInstance) error {
+	return nil
+}
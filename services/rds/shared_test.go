@@ -0,0 +1,217 @@
+package rds
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/common"
+	"github.com/go-test/deep"
+	"github.com/jinzhu/gorm"
+)
+
+type mockSharedDBConn struct {
+	statements []string
+	err        error
+	// failOn fails only the statements starting with it, if set.
+	failOn string
+}
+
+func (m *mockSharedDBConn) Exec(sql string, values ...interface{}) *gorm.DB {
+	m.statements = append(m.statements, sql)
+	if m.failOn != "" && !strings.HasPrefix(sql, m.failOn) {
+		return &gorm.DB{}
+	}
+	return &gorm.DB{Error: m.err}
+}
+
+func newSharedTestAdapter(conn *mockSharedDBConn, dbType string) *sharedDBAdapter {
+	return &sharedDBAdapter{
+		db: conn,
+		config: common.DBConfig{
+			DbType:   dbType,
+			URL:      "shared.example.com",
+			Username: "broker",
+			Port:     5432,
+		},
+		logger: lagertest.NewTestLogger("test"),
+	}
+}
+
+func newSharedTestInstance(dbType string) *RDSInstance {
+	i := NewRDSInstance()
+	i.Database = "db-name"
+	i.Username = "user"
+	i.DbType = dbType
+	i.Adapter = "shared"
+	return i
+}
+
+func TestSharedCreateDB(t *testing.T) {
+	testCases := map[string]struct {
+		dbType             string
+		expectedStatements []string
+	}{
+		"postgres": {
+			dbType: "postgres",
+			expectedStatements: []string{
+				`CREATE ROLE "user" WITH LOGIN PASSWORD 'pass''word'`,
+				`GRANT "user" TO "broker"`,
+				`CREATE DATABASE "dbname" OWNER "user"`,
+				`REVOKE ALL ON DATABASE "dbname" FROM PUBLIC`,
+			},
+		},
+		"mysql": {
+			dbType: "mysql",
+			expectedStatements: []string{
+				"CREATE DATABASE `dbname`",
+				"CREATE USER `user`@'%' IDENTIFIED BY 'pass''word'",
+				"GRANT ALL PRIVILEGES ON `dbname`.* TO `user`@'%'",
+			},
+		},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			conn := &mockSharedDBConn{}
+			adapter := newSharedTestAdapter(conn, test.dbType)
+			i := newSharedTestInstance(test.dbType)
+
+			status, err := adapter.createDB(i, "pass'word")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status != base.InstanceReady {
+				t.Errorf("expected status %s, got %s", base.InstanceReady, status)
+			}
+			if diff := deep.Equal(conn.statements, test.expectedStatements); diff != nil {
+				t.Error(diff)
+			}
+			if i.Host != "shared.example.com" || i.Port != 5432 {
+				t.Errorf("unexpected endpoint %s:%d", i.Host, i.Port)
+			}
+		})
+	}
+}
+
+func TestSharedCreateDBErrors(t *testing.T) {
+	conn := &mockSharedDBConn{err: errors.New("fail")}
+	adapter := newSharedTestAdapter(conn, "postgres")
+	status, err := adapter.createDB(newSharedTestInstance("postgres"), "password")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if status != base.InstanceNotCreated {
+		t.Errorf("expected status %s, got %s", base.InstanceNotCreated, status)
+	}
+	if len(conn.statements) != 1 {
+		t.Errorf("expected the statements to stop at the first error, got %v", conn.statements)
+	}
+
+	adapter = newSharedTestAdapter(&mockSharedDBConn{}, "mysql")
+	if _, err := adapter.createDB(newSharedTestInstance("postgres"), "password"); err == nil {
+		t.Error("expected error for a server of another engine")
+	}
+
+	i := newSharedTestInstance("postgres")
+	i.SnapshotIdentifier = "snapshot"
+	adapter = newSharedTestAdapter(&mockSharedDBConn{}, "postgres")
+	if _, err := adapter.createDB(i, "password"); err == nil {
+		t.Error("expected error for restores")
+	}
+}
+
+func TestSharedCreateDBCleanup(t *testing.T) {
+	testCases := map[string]struct {
+		dbType             string
+		failOn             string
+		expectedStatements []string
+	}{
+		"postgres": {
+			dbType: "postgres",
+			failOn: "REVOKE",
+			expectedStatements: []string{
+				`CREATE ROLE "user" WITH LOGIN PASSWORD 'password'`,
+				`GRANT "user" TO "broker"`,
+				`CREATE DATABASE "dbname" OWNER "user"`,
+				`REVOKE ALL ON DATABASE "dbname" FROM PUBLIC`,
+				`DROP DATABASE IF EXISTS "dbname"`,
+				`DROP ROLE IF EXISTS "user"`,
+			},
+		},
+		"mysql": {
+			dbType: "mysql",
+			failOn: "CREATE USER",
+			expectedStatements: []string{
+				"CREATE DATABASE `dbname`",
+				"CREATE USER `user`@'%' IDENTIFIED BY 'password'",
+				"DROP DATABASE IF EXISTS `dbname`",
+			},
+		},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			conn := &mockSharedDBConn{err: errors.New("fail"), failOn: test.failOn}
+			adapter := newSharedTestAdapter(conn, test.dbType)
+
+			status, err := adapter.createDB(newSharedTestInstance(test.dbType), "password")
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if status != base.InstanceNotCreated {
+				t.Errorf("expected status %s, got %s", base.InstanceNotCreated, status)
+			}
+			if diff := deep.Equal(conn.statements, test.expectedStatements); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestSharedModifyDB(t *testing.T) {
+	conn := &mockSharedDBConn{}
+	adapter := newSharedTestAdapter(conn, "postgres")
+	i := newSharedTestInstance("postgres")
+
+	status, err := adapter.modifyDB(i, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != base.InstanceReady {
+		t.Errorf("expected status %s, got %s", base.InstanceReady, status)
+	}
+	if len(conn.statements) != 0 {
+		t.Errorf("expected no statements, got %v", conn.statements)
+	}
+
+	i.ClearPassword = "new-password"
+	if _, err := adapter.modifyDB(i, i.ClearPassword); err != nil {
+		t.Fatal(err)
+	}
+	expectedStatements := []string{`ALTER ROLE "user" WITH PASSWORD 'new-password'`}
+	if diff := deep.Equal(conn.statements, expectedStatements); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestSharedDeleteDB(t *testing.T) {
+	conn := &mockSharedDBConn{}
+	adapter := newSharedTestAdapter(conn, "postgres")
+
+	status, err := adapter.deleteDB(newSharedTestInstance("postgres"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != base.InstanceGone {
+		t.Errorf("expected status %s, got %s", base.InstanceGone, status)
+	}
+	expectedStatements := []string{
+		`SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = 'dbname'`,
+		`DROP DATABASE IF EXISTS "dbname"`,
+		`DROP ROLE IF EXISTS "user"`,
+	}
+	if diff := deep.Equal(conn.statements, expectedStatements); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,56 @@
+package rds
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+
+	"github.com/18F/aws-broker/helpers/logging"
+)
+
+// stopDB stops the database instance if it is available. Instances that are
+// already stopped or stopping are left alone.
+func (d *dedicatedDBAdapter) stopDB(i *RDSInstance) error {
+	dbInstance, err := d.describeDB(i)
+	if err != nil {
+		return err
+	}
+	if aws.StringValue(dbInstance.DBInstanceStatus) != "available" {
+		return nil
+	}
+	_, err = d.rds.StopDBInstance(&rds.StopDBInstanceInput{
+		DBInstanceIdentifier: aws.String(i.Database),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "stop-db-instance", err)
+		return err
+	}
+	d.logger.Info("db-instance-stopped", lager.Data{"database": i.Database})
+	return nil
+}
+
+// startDB starts the database instance if it is stopped.
+func (d *dedicatedDBAdapter) startDB(i *RDSInstance) error {
+	dbInstance, err := d.describeDB(i)
+	if err != nil {
+		return err
+	}
+	switch aws.StringValue(dbInstance.DBInstanceStatus) {
+	case "stopped":
+	case "stopping":
+		return errors.New("the instance is still stopping. Please wait and try again..")
+	default:
+		return nil
+	}
+	_, err = d.rds.StartDBInstance(&rds.StartDBInstanceInput{
+		DBInstanceIdentifier: aws.String(i.Database),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "start-db-instance", err)
+		return err
+	}
+	d.logger.Info("db-instance-started", lager.Data{"database": i.Database})
+	return nil
+}
@@ -0,0 +1,109 @@
+package rds
+
+import (
+	"testing"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+func TestStopDB(t *testing.T) {
+	testCases := map[string]struct {
+		status        string
+		expectStopped bool
+	}{
+		"available": {
+			status:        "available",
+			expectStopped: true,
+		},
+		"stopped": {
+			status: "stopped",
+		},
+		"stopping": {
+			status: "stopping",
+		},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			client := &mockRdsClientForAdapterTests{
+				describeDbInstancesResults: &rds.DescribeDBInstancesOutput{
+					DBInstances: []*rds.DBInstance{{DBInstanceStatus: aws.String(test.status)}},
+				},
+			}
+			adapter := &dedicatedDBAdapter{
+				rds:    client,
+				logger: lagertest.NewTestLogger("test"),
+			}
+
+			if err := adapter.stopDB(&RDSInstance{Database: "db-name"}); err != nil {
+				t.Fatal(err)
+			}
+			if stopped := client.stopDbInput != nil; stopped != test.expectStopped {
+				t.Fatalf("expected stopped to be %t", test.expectStopped)
+			}
+			if test.expectStopped && aws.StringValue(client.stopDbInput.DBInstanceIdentifier) != "db-name" {
+				t.Errorf("unexpected instance %s", aws.StringValue(client.stopDbInput.DBInstanceIdentifier))
+			}
+		})
+	}
+}
+
+func TestStartDB(t *testing.T) {
+	testCases := map[string]struct {
+		status        string
+		expectStarted bool
+		expectErr     bool
+	}{
+		"stopped": {
+			status:        "stopped",
+			expectStarted: true,
+		},
+		"stopping": {
+			status:    "stopping",
+			expectErr: true,
+		},
+		"available": {
+			status: "available",
+		},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			client := &mockRdsClientForAdapterTests{
+				describeDbInstancesResults: &rds.DescribeDBInstancesOutput{
+					DBInstances: []*rds.DBInstance{{DBInstanceStatus: aws.String(test.status)}},
+				},
+			}
+			adapter := &dedicatedDBAdapter{
+				rds:    client,
+				logger: lagertest.NewTestLogger("test"),
+			}
+
+			err := adapter.startDB(&RDSInstance{Database: "db-name"})
+			if test.expectErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if started := client.startDbInput != nil; started != test.expectStarted {
+				t.Errorf("expected started to be %t", test.expectStarted)
+			}
+		})
+	}
+}
+
+func TestSetDeletionProtection(t *testing.T) {
+	i := &RDSInstance{Adapter: "dedicated"}
+	if err := i.setDeletionProtection(nil); err != nil || i.updateDeletionProtection {
+		t.Fatal("expected no change without the option")
+	}
+	if err := i.setDeletionProtection(aws.Bool(true)); err != nil {
+		t.Fatal(err)
+	}
+	if !i.DeletionProtection || !i.updateDeletionProtection {
+		t.Error("expected deletion protection to be turned on")
+	}
+
+	shared := &RDSInstance{Adapter: "shared"}
+	if err := shared.setDeletionProtection(aws.Bool(true)); err == nil {
+		t.Error("expected deletion protection to be refused for shared databases")
+	}
+}
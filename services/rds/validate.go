@@ -1,6 +1,14 @@
 package rds
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
 
 func validateBinaryLogFormat(format string) error {
 	switch format {
@@ -13,9 +21,316 @@ func validateBinaryLogFormat(format string) error {
 
 func validateStorageType(storageType string) error {
 	switch storageType {
-	case "", "gp3":
+	case "", "gp3", "io1", "io2":
 		return nil
 	default:
 		return fmt.Errorf("storage type is not supported: %s", storageType)
 	}
 }
+
+// maxReadReplicas is the number of read replicas that the broker creates for
+// an instance at most.
+const maxReadReplicas = 5
+
+func validateReadReplicas(readReplicas *int64) error {
+	if readReplicas == nil {
+		return nil
+	}
+	if *readReplicas < 0 || *readReplicas > maxReadReplicas {
+		return fmt.Errorf("Invalid read_replicas %d; must be between 0 and %d", *readReplicas, maxReadReplicas)
+	}
+	return nil
+}
+
+// The provisioned IOPS and throughput limits of RDS storage types, see
+// https://docs.aws.amazon.com/AmazonRDS/latest/UserGuide/CHAP_Storage.html
+const (
+	minProvisionedIops = 1000
+	maxProvisionedIops = 256000
+
+	minGp3Iops       = 12000
+	maxGp3Iops       = 64000
+	minGp3Throughput = 500
+	maxGp3Throughput = 4000
+)
+
+// gp3BaselineStorage is the storage below which gp3 volumes of the engine
+// have a fixed baseline of 3,000 IOPS and 125 MiBps.
+func gp3BaselineStorage(dbType string) int64 {
+	if strings.HasPrefix(dbType, "oracle") {
+		return 200
+	}
+	return 400
+}
+
+// validateStoragePerformance checks the storage autoscaling limit, IOPS and
+// throughput of an instance against the limits of its storage type.
+func validateStoragePerformance(dbType string, storageType string, allocatedStorage int64, maxAllocatedStorage int64, iops int64, throughput int64) error {
+	// The autoscaling limit has to leave room to grow by at least 10%.
+	if maxAllocatedStorage > 0 && maxAllocatedStorage*10 < allocatedStorage*11 {
+		return fmt.Errorf("Invalid max_allocated_storage %d; must be at least 10%% more than the storage %d", maxAllocatedStorage, allocatedStorage)
+	}
+
+	switch storageType {
+	case "io1", "io2":
+		if iops == 0 {
+			return fmt.Errorf("iops must be given for %s storage", storageType)
+		}
+		if iops < minProvisionedIops || iops > maxProvisionedIops {
+			return fmt.Errorf("Invalid iops %d; must be between %d and %d", iops, minProvisionedIops, maxProvisionedIops)
+		}
+		maxIopsPerGB := int64(50)
+		if storageType == "io2" {
+			maxIopsPerGB = 1000
+		}
+		if iops > allocatedStorage*maxIopsPerGB {
+			return fmt.Errorf("Invalid iops %d; %s storage allows at most %d IOPS per GB of storage", iops, storageType, maxIopsPerGB)
+		}
+		if throughput > 0 {
+			return fmt.Errorf("storage_throughput cannot be set for %s storage", storageType)
+		}
+	case "gp3":
+		if iops == 0 && throughput == 0 {
+			return nil
+		}
+		if baseline := gp3BaselineStorage(dbType); allocatedStorage < baseline {
+			return fmt.Errorf("iops and storage_throughput can only be set for gp3 storage of at least %d GB", baseline)
+		}
+		if iops > 0 && (iops < minGp3Iops || iops > maxGp3Iops) {
+			return fmt.Errorf("Invalid iops %d; must be between %d and %d", iops, minGp3Iops, maxGp3Iops)
+		}
+		if throughput > 0 && (throughput < minGp3Throughput || throughput > maxGp3Throughput) {
+			return fmt.Errorf("Invalid storage_throughput %d; must be between %d and %d", throughput, minGp3Throughput, maxGp3Throughput)
+		}
+		// gp3 allows at most 0.25 MiBps of throughput per IOPS.
+		if iops > 0 && throughput*4 > iops {
+			return fmt.Errorf("Invalid storage_throughput %d; must be at most a quarter of the iops %d", throughput, iops)
+		}
+	default:
+		if iops > 0 || throughput > 0 {
+			return errors.New("iops and storage_throughput are only supported for gp3, io1 and io2 storage")
+		}
+	}
+	return nil
+}
+
+// validateMonitoringInterval checks the interval of Enhanced Monitoring, in
+// seconds, where 0 disables it.
+func validateMonitoringInterval(interval *int64) error {
+	if interval == nil {
+		return nil
+	}
+	switch *interval {
+	case 0, 1, 5, 10, 15, 30, 60:
+		return nil
+	default:
+		return fmt.Errorf("Invalid monitoring_interval %d; must be one of 0, 1, 5, 10, 15, 30 or 60", *interval)
+	}
+}
+
+// validatePerformanceInsightsRetentionPeriod checks the retention of
+// Performance Insights data, in days: 7, a multiple of 31 up to 23 months, or
+// 731 for 2 years.
+func validatePerformanceInsightsRetentionPeriod(period int64) error {
+	if period == 0 || period == 7 || period == 731 || (period%31 == 0 && period/31 <= 23) {
+		return nil
+	}
+	return fmt.Errorf("Invalid performance_insights_retention_period %d; must be 7, a multiple of 31 up to 713, or 731", period)
+}
+
+// minWindowDuration is the shortest maintenance and backup window, in minutes,
+// that RDS accepts.
+const minWindowDuration = 30
+
+const (
+	minutesPerDay  = 24 * 60
+	minutesPerWeek = 7 * minutesPerDay
+)
+
+var weekdays = []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+
+// parseTimeOfDay parses an "hh24:mi" UTC time into minutes after midnight.
+func parseTimeOfDay(value string) (int, bool) {
+	if len(value) != 5 || value[2] != ':' {
+		return 0, false
+	}
+	digits := value[:2] + value[3:]
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	hours := int(digits[0]-'0')*10 + int(digits[1]-'0')
+	minutes := int(digits[2]-'0')*10 + int(digits[3]-'0')
+	if hours > 23 || minutes > 59 {
+		return 0, false
+	}
+	return hours*60 + minutes, true
+}
+
+// parseTimeOfWeek parses a "ddd:hh24:mi" UTC time into minutes after the
+// start of Monday.
+func parseTimeOfWeek(value string) (int, bool) {
+	day, timeOfDay, found := strings.Cut(value, ":")
+	if !found {
+		return 0, false
+	}
+	minutes, ok := parseTimeOfDay(timeOfDay)
+	if !ok {
+		return 0, false
+	}
+	for number, weekday := range weekdays {
+		if strings.ToLower(day) == weekday {
+			return number*minutesPerDay + minutes, true
+		}
+	}
+	return 0, false
+}
+
+// parseMaintenanceWindow parses a "ddd:hh24:mi-ddd:hh24:mi" maintenance window
+// into its start and end in minutes after the start of Monday. The end is
+// after the start, and may be in the following week.
+func parseMaintenanceWindow(window string) (int, int, error) {
+	startValue, endValue, _ := strings.Cut(window, "-")
+	start, startOk := parseTimeOfWeek(startValue)
+	end, endOk := parseTimeOfWeek(endValue)
+	if !startOk || !endOk {
+		return 0, 0, fmt.Errorf("Invalid preferred_maintenance_window %q; must be in the format ddd:hh24:mi-ddd:hh24:mi", window)
+	}
+	if end <= start {
+		end += minutesPerWeek
+	}
+	if end-start < minWindowDuration {
+		return 0, 0, fmt.Errorf("Invalid preferred_maintenance_window %q; must be at least %d minutes", window, minWindowDuration)
+	}
+	return start, end, nil
+}
+
+// parseBackupWindow parses a daily "hh24:mi-hh24:mi" backup window into its
+// start and end in minutes after midnight. The end is after the start, and may
+// be on the following day.
+func parseBackupWindow(window string) (int, int, error) {
+	startValue, endValue, _ := strings.Cut(window, "-")
+	start, startOk := parseTimeOfDay(startValue)
+	end, endOk := parseTimeOfDay(endValue)
+	if !startOk || !endOk {
+		return 0, 0, fmt.Errorf("Invalid preferred_backup_window %q; must be in the format hh24:mi-hh24:mi", window)
+	}
+	if end <= start {
+		end += minutesPerDay
+	}
+	if end-start < minWindowDuration {
+		return 0, 0, fmt.Errorf("Invalid preferred_backup_window %q; must be at least %d minutes", window, minWindowDuration)
+	}
+	return start, end, nil
+}
+
+// validateWindows checks the syntax and duration of the maintenance and
+// backup windows of an instance, if given, and that the daily backup window
+// does not overlap the weekly maintenance window.
+func validateWindows(maintenanceWindow string, backupWindow string) error {
+	var maintenanceStart, maintenanceEnd, backupStart, backupEnd int
+	var err error
+	if maintenanceWindow != "" {
+		if maintenanceStart, maintenanceEnd, err = parseMaintenanceWindow(maintenanceWindow); err != nil {
+			return err
+		}
+	}
+	if backupWindow != "" {
+		if backupStart, backupEnd, err = parseBackupWindow(backupWindow); err != nil {
+			return err
+		}
+	}
+	if maintenanceWindow == "" || backupWindow == "" {
+		return nil
+	}
+
+	// Compare the backup window of every day, including the day before the
+	// week, with the maintenance window of the week and the week after it.
+	for day := -1; day < 7; day++ {
+		for _, week := range []int{0, minutesPerWeek} {
+			start := day*minutesPerDay + backupStart
+			end := day*minutesPerDay + backupEnd
+			if start < maintenanceEnd-week && maintenanceStart-week < end {
+				return fmt.Errorf("preferred_backup_window %q must not overlap preferred_maintenance_window %q", backupWindow, maintenanceWindow)
+			}
+		}
+	}
+	return nil
+}
+
+// inNumericRange reports whether a number is within an allowed value of a
+// parameter, which is either a single value or a range such as "-1-2147483647".
+func inNumericRange(allowedValue string, number float64) bool {
+	separator := strings.Index(strings.TrimPrefix(allowedValue, "-"), "-")
+	if separator < 0 {
+		value, err := strconv.ParseFloat(allowedValue, 64)
+		return err == nil && number == value
+	}
+	if strings.HasPrefix(allowedValue, "-") {
+		separator++
+	}
+	min, minErr := strconv.ParseFloat(allowedValue[:separator], 64)
+	max, maxErr := strconv.ParseFloat(allowedValue[separator+1:], 64)
+	return minErr == nil && maxErr == nil && number >= min && number <= max
+}
+
+// validateDBParameterValue checks a value of a database parameter against
+// its data type and allowed values, as described by RDS.
+func validateDBParameterValue(parameter *rds.Parameter, value string) error {
+	name := aws.StringValue(parameter.ParameterName)
+	if !aws.BoolValue(parameter.IsModifiable) {
+		return fmt.Errorf("parameter %s cannot be modified", name)
+	}
+
+	dataType := aws.StringValue(parameter.DataType)
+	allowedValues := strings.Split(aws.StringValue(parameter.AllowedValues), ",")
+	if aws.StringValue(parameter.AllowedValues) == "" {
+		allowedValues = nil
+	}
+
+	switch dataType {
+	case "integer", "float":
+		var number float64
+		var err error
+		if dataType == "integer" {
+			var integer int64
+			integer, err = strconv.ParseInt(value, 10, 64)
+			number = float64(integer)
+		} else {
+			number, err = strconv.ParseFloat(value, 64)
+		}
+		if err != nil {
+			return fmt.Errorf("Invalid value %q for parameter %s; must be of type %s", value, name, dataType)
+		}
+		if allowedValues == nil {
+			return nil
+		}
+		for _, allowedValue := range allowedValues {
+			if inNumericRange(strings.TrimSpace(allowedValue), number) {
+				return nil
+			}
+		}
+	default:
+		if allowedValues == nil {
+			return nil
+		}
+		values := []string{value}
+		if dataType == "list" {
+			values = strings.Split(value, ",")
+		}
+		for _, v := range values {
+			found := false
+			for _, allowedValue := range allowedValues {
+				if strings.EqualFold(strings.TrimSpace(v), strings.TrimSpace(allowedValue)) {
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("Invalid value %q for parameter %s; must be one of %s", value, name, aws.StringValue(parameter.AllowedValues))
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("Invalid value %q for parameter %s; must be within %s", value, name, aws.StringValue(parameter.AllowedValues))
+}
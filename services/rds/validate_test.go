@@ -5,6 +5,7 @@ import (
 
 	"github.com/18F/aws-broker/config"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
 )
 
 func TestBinaryLogFormatValidation(t *testing.T) {
@@ -53,7 +54,7 @@ func TestValidateStorageType(t *testing.T) {
 		expectedErr bool
 	}{
 		"invalid": {
-			storageType: "io1",
+			storageType: "st1",
 			expectedErr: true,
 		},
 		"empty": {
@@ -68,6 +69,14 @@ func TestValidateStorageType(t *testing.T) {
 			storageType: "gp3",
 			expectedErr: false,
 		},
+		"io1": {
+			storageType: "io1",
+			expectedErr: false,
+		},
+		"io2": {
+			storageType: "io2",
+			expectedErr: false,
+		},
 	}
 
 	for name, test := range testCases {
@@ -83,6 +92,130 @@ func TestValidateStorageType(t *testing.T) {
 	}
 }
 
+func TestValidateStoragePerformance(t *testing.T) {
+	testCases := map[string]struct {
+		dbType              string
+		storageType         string
+		allocatedStorage    int64
+		maxAllocatedStorage int64
+		iops                int64
+		throughput          int64
+		expectedErr         bool
+	}{
+		"defaults": {
+			dbType:           "postgres",
+			storageType:      "gp3",
+			allocatedStorage: 20,
+		},
+		"autoscaling": {
+			dbType:              "postgres",
+			storageType:         "gp3",
+			allocatedStorage:    20,
+			maxAllocatedStorage: 100,
+		},
+		"autoscaling limit too close to the storage": {
+			dbType:              "postgres",
+			storageType:         "gp3",
+			allocatedStorage:    100,
+			maxAllocatedStorage: 105,
+			expectedErr:         true,
+		},
+		"gp3 performance": {
+			dbType:           "postgres",
+			storageType:      "gp3",
+			allocatedStorage: 400,
+			iops:             12000,
+			throughput:       1000,
+		},
+		"gp3 performance below the baseline storage": {
+			dbType:           "postgres",
+			storageType:      "gp3",
+			allocatedStorage: 100,
+			iops:             12000,
+			expectedErr:      true,
+		},
+		"gp3 performance at the oracle baseline storage": {
+			dbType:           "oracle-se2",
+			storageType:      "gp3",
+			allocatedStorage: 200,
+			iops:             12000,
+		},
+		"gp3 iops out of range": {
+			dbType:           "postgres",
+			storageType:      "gp3",
+			allocatedStorage: 400,
+			iops:             70000,
+			expectedErr:      true,
+		},
+		"gp3 throughput out of range": {
+			dbType:           "postgres",
+			storageType:      "gp3",
+			allocatedStorage: 400,
+			throughput:       5000,
+			expectedErr:      true,
+		},
+		"gp3 throughput above a quarter of the iops": {
+			dbType:           "postgres",
+			storageType:      "gp3",
+			allocatedStorage: 400,
+			iops:             12000,
+			throughput:       4000,
+			expectedErr:      true,
+		},
+		"io1 iops": {
+			dbType:           "mysql",
+			storageType:      "io1",
+			allocatedStorage: 100,
+			iops:             5000,
+		},
+		"io1 without iops": {
+			dbType:           "mysql",
+			storageType:      "io1",
+			allocatedStorage: 100,
+			expectedErr:      true,
+		},
+		"io1 iops above the ratio to the storage": {
+			dbType:           "mysql",
+			storageType:      "io1",
+			allocatedStorage: 100,
+			iops:             6000,
+			expectedErr:      true,
+		},
+		"io2 iops": {
+			dbType:           "mysql",
+			storageType:      "io2",
+			allocatedStorage: 100,
+			iops:             64000,
+		},
+		"io2 throughput": {
+			dbType:           "mysql",
+			storageType:      "io2",
+			allocatedStorage: 100,
+			iops:             64000,
+			throughput:       1000,
+			expectedErr:      true,
+		},
+		"iops of the default storage type": {
+			dbType:           "postgres",
+			allocatedStorage: 100,
+			iops:             1000,
+			expectedErr:      true,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validateStoragePerformance(test.dbType, test.storageType, test.allocatedStorage, test.maxAllocatedStorage, test.iops, test.throughput)
+			if test.expectedErr && err == nil {
+				t.Fatalf("expected error")
+			}
+			if !test.expectedErr && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
 func TestValidateRetentionPeriod(t *testing.T) {
 	testCases := map[string]struct {
 		retentionPeriod int64
@@ -123,3 +256,263 @@ func TestValidateRetentionPeriod(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateReadReplicas(t *testing.T) {
+	testCases := map[string]struct {
+		readReplicas *int64
+		expectedErr  bool
+	}{
+		"not specified": {
+			readReplicas: nil,
+			expectedErr:  false,
+		},
+		"none": {
+			readReplicas: aws.Int64(0),
+			expectedErr:  false,
+		},
+		"maximum": {
+			readReplicas: aws.Int64(maxReadReplicas),
+			expectedErr:  false,
+		},
+		"more than maximum": {
+			readReplicas: aws.Int64(maxReadReplicas + 1),
+			expectedErr:  true,
+		},
+		"negative": {
+			readReplicas: aws.Int64(-1),
+			expectedErr:  true,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validateReadReplicas(test.readReplicas)
+			if test.expectedErr && err == nil {
+				t.Fatalf("expected error")
+			}
+			if !test.expectedErr && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestValidateMonitoringInterval(t *testing.T) {
+	testCases := map[string]struct {
+		interval    *int64
+		expectedErr bool
+	}{
+		"not specified": {
+			interval: nil,
+		},
+		"disabled": {
+			interval: aws.Int64(0),
+		},
+		"valid": {
+			interval: aws.Int64(15),
+		},
+		"invalid": {
+			interval:    aws.Int64(20),
+			expectedErr: true,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validateMonitoringInterval(test.interval)
+			if test.expectedErr && err == nil {
+				t.Fatalf("expected error")
+			}
+			if !test.expectedErr && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestValidatePerformanceInsightsRetentionPeriod(t *testing.T) {
+	testCases := map[string]struct {
+		period      int64
+		expectedErr bool
+	}{
+		"not specified": {period: 0},
+		"free tier":     {period: 7},
+		"months":        {period: 31 * 12},
+		"23 months":     {period: 713},
+		"2 years":       {period: 731},
+		"24 months": {
+			period:      31 * 24,
+			expectedErr: true,
+		},
+		"invalid": {
+			period:      30,
+			expectedErr: true,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validatePerformanceInsightsRetentionPeriod(test.period)
+			if test.expectedErr && err == nil {
+				t.Fatalf("expected error")
+			}
+			if !test.expectedErr && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestValidateWindows(t *testing.T) {
+	testCases := map[string]struct {
+		maintenanceWindow string
+		backupWindow      string
+		expectedErr       bool
+	}{
+		"not specified": {},
+		"maintenance window": {
+			maintenanceWindow: "sun:06:00-sun:07:00",
+		},
+		"backup window": {
+			backupWindow: "03:00-04:00",
+		},
+		"both": {
+			maintenanceWindow: "Sun:06:00-Sun:07:00",
+			backupWindow:      "03:00-04:00",
+		},
+		"maintenance window across weeks": {
+			maintenanceWindow: "sun:23:30-mon:00:30",
+			backupWindow:      "01:00-02:00",
+		},
+		"invalid maintenance window syntax": {
+			maintenanceWindow: "sunday:06:00-sunday:07:00",
+			expectedErr:       true,
+		},
+		"invalid maintenance window time": {
+			maintenanceWindow: "sun:24:00-mon:01:00",
+			expectedErr:       true,
+		},
+		"short maintenance window": {
+			maintenanceWindow: "sun:06:00-sun:06:15",
+			expectedErr:       true,
+		},
+		"invalid backup window syntax": {
+			backupWindow: "3:00-4:00",
+			expectedErr:  true,
+		},
+		"short backup window": {
+			backupWindow: "23:50-00:10",
+			expectedErr:  true,
+		},
+		"overlap": {
+			maintenanceWindow: "wed:03:30-wed:04:30",
+			backupWindow:      "03:00-04:00",
+			expectedErr:       true,
+		},
+		"overlap across days": {
+			maintenanceWindow: "tue:00:00-tue:00:30",
+			backupWindow:      "23:30-00:15",
+			expectedErr:       true,
+		},
+		"overlap across weeks": {
+			maintenanceWindow: "sun:23:30-mon:00:30",
+			backupWindow:      "00:00-01:00",
+			expectedErr:       true,
+		},
+		"adjacent": {
+			maintenanceWindow: "mon:04:00-mon:05:00",
+			backupWindow:      "03:00-04:00",
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validateWindows(test.maintenanceWindow, test.backupWindow)
+			if test.expectedErr && err == nil {
+				t.Fatalf("expected error")
+			}
+			if !test.expectedErr && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestValidateDBParameterValue(t *testing.T) {
+	testCases := map[string]struct {
+		parameter   *rds.Parameter
+		value       string
+		expectedErr bool
+	}{
+		"integer in range": {
+			parameter: &rds.Parameter{DataType: aws.String("integer"), AllowedValues: aws.String("64-2147483647"), IsModifiable: aws.Bool(true)},
+			value:     "4096",
+		},
+		"integer out of range": {
+			parameter:   &rds.Parameter{DataType: aws.String("integer"), AllowedValues: aws.String("64-2147483647"), IsModifiable: aws.Bool(true)},
+			value:       "32",
+			expectedErr: true,
+		},
+		"negative integer in range": {
+			parameter: &rds.Parameter{DataType: aws.String("integer"), AllowedValues: aws.String("-1-2147483647"), IsModifiable: aws.Bool(true)},
+			value:     "-1",
+		},
+		"integer in one of the ranges": {
+			parameter: &rds.Parameter{DataType: aws.String("integer"), AllowedValues: aws.String("0,10-20"), IsModifiable: aws.Bool(true)},
+			value:     "15",
+		},
+		"not an integer": {
+			parameter:   &rds.Parameter{DataType: aws.String("integer"), AllowedValues: aws.String("64-2147483647"), IsModifiable: aws.Bool(true)},
+			value:       "4MB",
+			expectedErr: true,
+		},
+		"float": {
+			parameter: &rds.Parameter{DataType: aws.String("float"), AllowedValues: aws.String("0-31536000"), IsModifiable: aws.Bool(true)},
+			value:     "0.5",
+		},
+		"boolean": {
+			parameter: &rds.Parameter{DataType: aws.String("boolean"), AllowedValues: aws.String("0,1"), IsModifiable: aws.Bool(true)},
+			value:     "1",
+		},
+		"invalid boolean": {
+			parameter:   &rds.Parameter{DataType: aws.String("boolean"), AllowedValues: aws.String("0,1"), IsModifiable: aws.Bool(true)},
+			value:       "2",
+			expectedErr: true,
+		},
+		"string": {
+			parameter: &rds.Parameter{DataType: aws.String("string"), AllowedValues: aws.String("none,ddl,mod,all"), IsModifiable: aws.Bool(true)},
+			value:     "DDL",
+		},
+		"list": {
+			parameter: &rds.Parameter{DataType: aws.String("list"), AllowedValues: aws.String("a,b,c"), IsModifiable: aws.Bool(true)},
+			value:     "a,c",
+		},
+		"invalid list": {
+			parameter:   &rds.Parameter{DataType: aws.String("list"), AllowedValues: aws.String("a,b,c"), IsModifiable: aws.Bool(true)},
+			value:       "a,d",
+			expectedErr: true,
+		},
+		"no allowed values": {
+			parameter: &rds.Parameter{DataType: aws.String("string"), IsModifiable: aws.Bool(true)},
+			value:     "%m:%u@%d:",
+		},
+		"not modifiable": {
+			parameter:   &rds.Parameter{DataType: aws.String("integer"), IsModifiable: aws.Bool(false)},
+			value:       "1",
+			expectedErr: true,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			test.parameter.ParameterName = aws.String("parameter")
+			err := validateDBParameterValue(test.parameter, test.value)
+			if test.expectedErr && err == nil {
+				t.Fatalf("expected error")
+			}
+			if !test.expectedErr && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
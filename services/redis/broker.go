@@ -1,14 +1,17 @@
 package redis
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
-	"os"
 
 	"code.cloudfoundry.org/lager"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/s3"
 	brokertags "github.com/cloud-gov/go-broker-tags"
 	"github.com/jinzhu/gorm"
 
@@ -17,13 +20,21 @@ import (
 	"github.com/18F/aws-broker/config"
 	"github.com/18F/aws-broker/helpers/request"
 	"github.com/18F/aws-broker/helpers/response"
+	"github.com/18F/aws-broker/helpers/tracing"
 )
 
 type RedisOptions struct {
-	EngineVersion string `json:"engineVersion"`
+	EngineVersion  string `json:"engineVersion"`
+	CloneFrom      string `json:"clone_from"`
+	CreateSnapshot string `json:"create_snapshot"`
 }
 
 func (r RedisOptions) Validate(settings *config.Settings) error {
+	if r.CreateSnapshot != "" {
+		if err := base.ValidateSnapshotName(r.CreateSnapshot); err != nil {
+			return fmt.Errorf("Invalid create_snapshot; %s", err)
+		}
+	}
 	return nil
 }
 
@@ -39,10 +50,9 @@ func InitRedisBroker(
 	brokerDB *gorm.DB,
 	settings *config.Settings,
 	tagManager brokertags.TagManager,
+	logger lager.Logger,
 ) base.Broker {
-	logger := lager.NewLogger("aws-redis-broker")
-	logger.RegisterSink(lager.NewWriterSink(os.Stdout, lager.INFO))
-	return &redisBroker{brokerDB, settings, logger, tagManager}
+	return &redisBroker{brokerDB, settings, logger.Session("redis"), tagManager}
 }
 
 // this helps the manager to respond appropriately depending on whether a service/plan needs an operation to be async
@@ -62,7 +72,7 @@ func (broker *redisBroker) AsyncOperationRequired(c *catalog.Catalog, i base.Ins
 }
 
 // initializeAdapter is the main function to create database instances
-func initializeAdapter(plan catalog.RedisPlan, s *config.Settings, c *catalog.Catalog, logger lager.Logger) (redisAdapter, response.Response) {
+func initializeAdapter(ctx context.Context, plan catalog.RedisPlan, s *config.Settings, c *catalog.Catalog, logger lager.Logger) (redisAdapter, response.Response) {
 
 	var redisAdapter redisAdapter
 
@@ -71,17 +81,18 @@ func initializeAdapter(plan catalog.RedisPlan, s *config.Settings, c *catalog.Ca
 		return redisAdapter, nil
 	}
 
-	elasticacheClient := elasticache.New(session.New(), aws.NewConfig().WithRegion(s.Region))
+	sess := tracing.InstrumentSession(ctx, session.New())
 	redisAdapter = &dedicatedRedisAdapter{
 		Plan:        plan,
 		settings:    *s,
 		logger:      logger,
-		elasticache: elasticacheClient,
+		elasticache: elasticache.New(sess, aws.NewConfig().WithRegion(s.Region)),
+		s3:          s3.New(sess, aws.NewConfig().WithRegion(s.Region)),
 	}
 	return redisAdapter, nil
 }
 
-func (broker *redisBroker) CreateInstance(c *catalog.Catalog, id string, createRequest request.Request) response.Response {
+func (broker *redisBroker) CreateInstance(ctx context.Context, c *catalog.Catalog, id string, createRequest request.Request) response.Response {
 	newInstance := RedisInstance{}
 
 	options := RedisOptions{}
@@ -94,6 +105,9 @@ func (broker *redisBroker) CreateInstance(c *catalog.Catalog, id string, createR
 		if err != nil {
 			return response.NewErrorResponse(http.StatusBadRequest, "Invalid parameters. Error: "+err.Error())
 		}
+		if options.CreateSnapshot != "" {
+			return response.NewErrorResponse(http.StatusBadRequest, "Invalid parameters. Error: create_snapshot can only be given when updating an instance")
+		}
 	}
 
 	var count int64
@@ -128,6 +142,7 @@ func (broker *redisBroker) CreateInstance(c *catalog.Catalog, id string, createR
 		false,
 	)
 	if err != nil {
+		broker.logger.Error("generate-tags", err)
 		return response.NewErrorResponse(http.StatusInternalServerError, "There was an error generating the tags. Error: "+err.Error())
 	}
 
@@ -146,7 +161,23 @@ func (broker *redisBroker) CreateInstance(c *catalog.Catalog, id string, createR
 		return response.NewErrorResponse(http.StatusBadRequest, "There was an error initializing the instance. Error: "+err.Error())
 	}
 
-	adapter, adapterErr := initializeAdapter(plan, broker.settings, c, broker.logger)
+	if options.CloneFrom != "" {
+		if _, resp := base.FindCloneSource(broker.brokerDB, options.CloneFrom, newInstance.ServiceID, newInstance.OrganizationGUID); resp != nil {
+			return resp
+		}
+		sourceInstance := RedisInstance{}
+		broker.brokerDB.Where("uuid = ?", options.CloneFrom).First(&sourceInstance).Count(&count)
+		if count == 0 {
+			return response.NewErrorResponse(http.StatusNotFound, "The instance to clone from does not exist")
+		}
+		newInstance.setCloneSource(&sourceInstance)
+		// A snapshot can only be restored to the same or a later engine version.
+		if options.EngineVersion == "" {
+			newInstance.EngineVersion = sourceInstance.EngineVersion
+		}
+	}
+
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, c, broker.logger)
 	if adapterErr != nil {
 		return adapterErr
 	}
@@ -155,6 +186,7 @@ func (broker *redisBroker) CreateInstance(c *catalog.Catalog, id string, createR
 	if status == base.InstanceNotCreated {
 		desc := "There was an error creating the instance."
 		if err != nil {
+			broker.logger.Error("create-redis", err)
 			desc = desc + " Error: " + err.Error()
 		}
 		return response.NewErrorResponse(http.StatusBadRequest, desc)
@@ -164,17 +196,43 @@ func (broker *redisBroker) CreateInstance(c *catalog.Catalog, id string, createR
 	broker.brokerDB.NewRecord(newInstance)
 	err = broker.brokerDB.Create(&newInstance).Error
 	if err != nil {
+		broker.logger.Error("save-instance", err)
 		return response.NewErrorResponse(http.StatusBadRequest, err.Error())
 	}
+	broker.logger.Info("create-redis-requested", lager.Data{"cluster-id": newInstance.ClusterID})
 	return response.SuccessAcceptedResponse
 }
 
-func (broker *redisBroker) ModifyInstance(c *catalog.Catalog, id string, updateRequest request.Request, baseInstance base.Instance) response.Response {
+func (broker *redisBroker) ModifyInstance(ctx context.Context, c *catalog.Catalog, id string, updateRequest request.Request, baseInstance base.Instance) response.Response {
+	options := RedisOptions{}
+	if len(updateRequest.RawParameters) > 0 {
+		err := json.Unmarshal(updateRequest.RawParameters, &options)
+		if err != nil {
+			return response.NewErrorResponse(http.StatusBadRequest, "Invalid parameters. Error: "+err.Error())
+		}
+		err = options.Validate(broker.settings)
+		if err != nil {
+			return response.NewErrorResponse(http.StatusBadRequest, "Invalid parameters. Error: "+err.Error())
+		}
+	}
+
+	// Taking a manual snapshot is the only update supported for Redis
+	// instances.
+	if options.CreateSnapshot != "" &&
+		options == (RedisOptions{CreateSnapshot: options.CreateSnapshot}) &&
+		(updateRequest.PlanID == "" || updateRequest.PlanID == baseInstance.PlanID) {
+		snapshot, resp := broker.CreateSnapshot(ctx, c, id, baseInstance, options.CreateSnapshot)
+		if resp != nil {
+			return resp
+		}
+		return response.NewSuccessModifyResponse("The snapshot " + snapshot.Name + " was requested.")
+	}
+
 	// Note:  This is not currently supported for Redis instances.
 	return response.NewErrorResponse(http.StatusBadRequest, "Updating Redis service instances is not supported at this time.")
 }
 
-func (broker *redisBroker) LastOperation(c *catalog.Catalog, id string, baseInstance base.Instance, operation string) response.Response {
+func (broker *redisBroker) LastOperation(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance, operation string) response.Response {
 	existingInstance := RedisInstance{}
 
 	var count int64
@@ -188,14 +246,28 @@ func (broker *redisBroker) LastOperation(c *catalog.Catalog, id string, baseInst
 		return planErr
 	}
 
-	adapter, adapterErr := initializeAdapter(plan, broker.settings, c, broker.logger)
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, c, broker.logger)
 	if adapterErr != nil {
 		return adapterErr
 	}
 
 	var state string
 
-	status, _ := adapter.checkRedisStatus(&existingInstance)
+	if existingInstance.ClonePending {
+		// Clones are created with the password of the broker.
+		password, err := existingInstance.getPassword(broker.settings.EncryptionKey)
+		if err != nil {
+			broker.logger.Error("get-password", err)
+			return response.NewErrorResponse(http.StatusInternalServerError, "Unable to get instance password.")
+		}
+		existingInstance.ClearPassword = password
+	}
+
+	status, err := adapter.checkRedisStatus(&existingInstance)
+	if err != nil {
+		broker.logger.Error("check-redis-status", err)
+	}
+	broker.brokerDB.Save(&existingInstance)
 	switch status {
 	case base.InstanceInProgress:
 		state = "in progress"
@@ -211,7 +283,7 @@ func (broker *redisBroker) LastOperation(c *catalog.Catalog, id string, baseInst
 	return response.NewSuccessLastOperation(state, "The service instance status is "+state)
 }
 
-func (broker *redisBroker) BindInstance(c *catalog.Catalog, id string, bindRequest request.Request, baseInstance base.Instance) response.Response {
+func (broker *redisBroker) BindInstance(ctx context.Context, c *catalog.Catalog, id string, bindRequest request.Request, baseInstance base.Instance) response.Response {
 	existingInstance := RedisInstance{}
 
 	var count int64
@@ -227,11 +299,12 @@ func (broker *redisBroker) BindInstance(c *catalog.Catalog, id string, bindReque
 
 	password, err := existingInstance.getPassword(broker.settings.EncryptionKey)
 	if err != nil {
+		broker.logger.Error("get-password", err)
 		return response.NewErrorResponse(http.StatusInternalServerError, "Unable to get instance password.")
 	}
 
 	// Get the correct database logic depending on the type of plan. (shared vs dedicated)
-	adapter, adapterErr := initializeAdapter(plan, broker.settings, c, broker.logger)
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, c, broker.logger)
 	if adapterErr != nil {
 		return adapterErr
 	}
@@ -240,6 +313,7 @@ func (broker *redisBroker) BindInstance(c *catalog.Catalog, id string, bindReque
 	// Bind the database instance to the application.
 	originalInstanceState := existingInstance.State
 	if credentials, err = adapter.bindRedisToApp(&existingInstance, password); err != nil {
+		broker.logger.Error("bind-redis-to-app", err)
 		desc := "There was an error binding the database instance to the application."
 		if err != nil {
 			desc = desc + " Error: " + err.Error()
@@ -255,7 +329,7 @@ func (broker *redisBroker) BindInstance(c *catalog.Catalog, id string, bindReque
 	return response.NewSuccessBindResponse(credentials)
 }
 
-func (broker *redisBroker) DeleteInstance(c *catalog.Catalog, id string, baseInstance base.Instance) response.Response {
+func (broker *redisBroker) DeleteInstance(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance) response.Response {
 	existingInstance := RedisInstance{}
 	var count int64
 	broker.brokerDB.Where("uuid = ?", id).First(&existingInstance).Count(&count)
@@ -268,7 +342,7 @@ func (broker *redisBroker) DeleteInstance(c *catalog.Catalog, id string, baseIns
 		return planErr
 	}
 
-	adapter, adapterErr := initializeAdapter(plan, broker.settings, c, broker.logger)
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, c, broker.logger)
 	if adapterErr != nil {
 		return adapterErr
 	}
@@ -276,6 +350,7 @@ func (broker *redisBroker) DeleteInstance(c *catalog.Catalog, id string, baseIns
 	if status, err := adapter.deleteRedis(&existingInstance); status == base.InstanceNotGone {
 		desc := "There was an error deleting the instance."
 		if err != nil {
+			broker.logger.Error("delete-redis", err)
 			desc = desc + " Error: " + err.Error()
 		}
 		return response.NewErrorResponse(http.StatusBadRequest, desc)
@@ -283,3 +358,178 @@ func (broker *redisBroker) DeleteInstance(c *catalog.Catalog, id string, baseIns
 	broker.brokerDB.Unscoped().Delete(&existingInstance)
 	return response.SuccessDeleteResponse
 }
+
+func (broker *redisBroker) DescribeInstance(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance) (base.InstanceDetail, response.Response) {
+	existingInstance := RedisInstance{}
+	var count int64
+	broker.brokerDB.Where("uuid = ?", id).First(&existingInstance).Count(&count)
+	if count == 0 {
+		return base.InstanceDetail{}, response.NewErrorResponse(http.StatusNotFound, "Instance not found")
+	}
+
+	plan, planErr := c.RedisService.FetchPlan(baseInstance.PlanID)
+	if planErr != nil {
+		return base.InstanceDetail{}, planErr
+	}
+
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, c, broker.logger)
+	if adapterErr != nil {
+		return base.InstanceDetail{}, adapterErr
+	}
+
+	detail := base.InstanceDetail{}
+	if replicationGroup, err := adapter.describeRedis(&existingInstance); err != nil {
+		detail.AWSError = err.Error()
+		if awsErr, ok := err.(awserr.Error); ok {
+			detail.ResourceGone = awsErr.Code() == elasticache.ErrCodeReplicationGroupNotFoundFault
+		}
+	} else {
+		detail.AWS = replicationGroup
+	}
+
+	existingInstance.Password = ""
+	existingInstance.Salt = ""
+	detail.Instance = existingInstance
+	return detail, nil
+}
+
+func (broker *redisBroker) SetInstanceState(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance, state base.InstanceState) response.Response {
+	existingInstance := RedisInstance{}
+	var count int64
+	broker.brokerDB.Where("uuid = ?", id).First(&existingInstance).Count(&count)
+	if count == 0 {
+		return response.NewErrorResponse(http.StatusNotFound, "Instance not found")
+	}
+
+	err := broker.brokerDB.Model(&existingInstance).Update("state", state).Error
+	if err != nil {
+		broker.logger.Error("save-instance", err)
+		return response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+	return nil
+}
+
+// InstanceCredentials returns the credentials of the instance as recorded by
+// the broker, without contacting AWS or changing the instance.
+func (broker *redisBroker) InstanceCredentials(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance) (map[string]string, response.Response) {
+	existingInstance := RedisInstance{}
+	var count int64
+	broker.brokerDB.Where("uuid = ?", id).First(&existingInstance).Count(&count)
+	if count == 0 {
+		return nil, response.NewErrorResponse(http.StatusNotFound, "Instance not found")
+	}
+
+	password, err := existingInstance.getPassword(broker.settings.EncryptionKey)
+	if err != nil {
+		broker.logger.Error("get-password", err)
+		return nil, response.NewErrorResponse(http.StatusInternalServerError, "Unable to get instance password.")
+	}
+
+	credentials, err := existingInstance.getCredentials(password)
+	if err != nil {
+		broker.logger.Error("get-credentials", err)
+		return nil, response.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+	return credentials, nil
+}
+
+// CreateSnapshot takes a manual snapshot of the instance, tagged like the
+// instance, and deletes the oldest manual snapshots beyond the retention
+// count.
+func (broker *redisBroker) CreateSnapshot(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance, name string) (base.Snapshot, response.Response) {
+	if err := base.ValidateSnapshotName(name); err != nil {
+		return base.Snapshot{}, response.NewErrorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	existingInstance := RedisInstance{}
+	var count int64
+	broker.brokerDB.Where("uuid = ?", id).First(&existingInstance).Count(&count)
+	if count == 0 {
+		return base.Snapshot{}, response.NewErrorResponse(http.StatusNotFound, "Instance not found")
+	}
+	if existingInstance.State != base.InstanceReady {
+		return base.Snapshot{}, response.NewErrorResponse(http.StatusConflict, "Snapshots can only be taken of instances that are ready.")
+	}
+
+	plan, planErr := c.RedisService.FetchPlan(baseInstance.PlanID)
+	if planErr != nil {
+		return base.Snapshot{}, planErr
+	}
+
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, c, broker.logger)
+	if adapterErr != nil {
+		return base.Snapshot{}, adapterErr
+	}
+
+	tags, err := broker.tagManager.GenerateTags(
+		brokertags.Update,
+		c.RedisService.Name,
+		plan.Name,
+		brokertags.ResourceGUIDs{
+			InstanceGUID:     id,
+			SpaceGUID:        baseInstance.SpaceGUID,
+			OrganizationGUID: baseInstance.OrganizationGUID,
+		},
+		false,
+	)
+	if err != nil {
+		broker.logger.Error("generate-tags", err)
+		return base.Snapshot{}, response.NewErrorResponse(http.StatusInternalServerError, "There was an error generating the tags. Error: "+err.Error())
+	}
+	existingInstance.setTags(plan, tags)
+
+	snapshot, err := adapter.createSnapshot(&existingInstance, name, existingInstance.Tags)
+	if err != nil {
+		return base.Snapshot{}, response.NewErrorResponse(http.StatusBadRequest, "There was an error taking the snapshot. Error: "+err.Error())
+	}
+
+	// The snapshot is taken even if the retention count cannot be enforced;
+	// the oldest snapshots are deleted the next time.
+	snapshots, err := adapter.listSnapshots(&existingInstance)
+	if err != nil {
+		broker.logger.Error("list-snapshots", err)
+		return snapshot, nil
+	}
+	for _, expired := range base.ExpiredSnapshots(snapshots, broker.settings.ManualSnapshotRetention) {
+		if err := adapter.deleteSnapshot(expired.Name); err != nil {
+			broker.logger.Error("delete-expired-snapshot", err, lager.Data{"snapshot": expired.Name})
+		}
+	}
+	return snapshot, nil
+}
+
+// ListSnapshots lists the automated and manual snapshots of the instance.
+func (broker *redisBroker) ListSnapshots(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance) ([]base.Snapshot, response.Response) {
+	existingInstance := RedisInstance{}
+	var count int64
+	broker.brokerDB.Where("uuid = ?", id).First(&existingInstance).Count(&count)
+	if count == 0 {
+		return nil, response.NewErrorResponse(http.StatusNotFound, "Instance not found")
+	}
+
+	plan, planErr := c.RedisService.FetchPlan(baseInstance.PlanID)
+	if planErr != nil {
+		return nil, planErr
+	}
+
+	adapter, adapterErr := initializeAdapter(ctx, plan, broker.settings, c, broker.logger)
+	if adapterErr != nil {
+		return nil, adapterErr
+	}
+
+	snapshots, err := adapter.listSnapshots(&existingInstance)
+	if err != nil {
+		return nil, response.NewErrorResponse(http.StatusBadRequest, "There was an error listing the snapshots. Error: "+err.Error())
+	}
+	return snapshots, nil
+}
+
+// SuspendInstance leaves the replication group running, since ElastiCache
+// clusters cannot be stopped.
+func (broker *redisBroker) SuspendInstance(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance) response.Response {
+	return nil
+}
+
+func (broker *redisBroker) ResumeInstance(ctx context.Context, c *catalog.Catalog, id string, baseInstance base.Instance) response.Response {
+	return nil
+}
@@ -0,0 +1,78 @@
+package redis
+
+import (
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+
+	"github.com/18F/aws-broker/base"
+	"github.com/18F/aws-broker/helpers/logging"
+)
+
+// newSnapshot converts a snapshot of the instance. Manual snapshots taken by
+// the broker are recognized by the prefix of their name. ElastiCache records
+// the creation time of each node; that of the first node is used.
+func newSnapshot(i *RedisInstance, snapshot *elasticache.Snapshot) base.Snapshot {
+	name := aws.StringValue(snapshot.SnapshotName)
+	s := base.Snapshot{
+		Name:   name,
+		Type:   aws.StringValue(snapshot.SnapshotSource),
+		Status: aws.StringValue(snapshot.SnapshotStatus),
+		Manual: aws.StringValue(snapshot.SnapshotSource) == "manual" && strings.HasPrefix(name, base.ManualSnapshotPrefix(i.ClusterID)),
+	}
+	if len(snapshot.NodeSnapshots) > 0 {
+		s.CreatedAt = snapshot.NodeSnapshots[0].SnapshotCreateTime
+	}
+	return s
+}
+
+// createSnapshot takes a manual snapshot of the instance with the given tags.
+func (d *dedicatedRedisAdapter) createSnapshot(i *RedisInstance, name string, tags map[string]string) (base.Snapshot, error) {
+	resp, err := d.elasticache.CreateSnapshot(&elasticache.CreateSnapshotInput{
+		ReplicationGroupId: aws.String(i.ClusterID),
+		SnapshotName:       aws.String(base.ManualSnapshotPrefix(i.ClusterID) + name),
+		Tags:               ConvertTagsToElasticacheTags(tags),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "create-snapshot", err)
+		return base.Snapshot{}, err
+	}
+	d.logger.Info("redis-snapshot-created", lager.Data{
+		"cluster-id": i.ClusterID,
+		"snapshot":   aws.StringValue(resp.Snapshot.SnapshotName),
+	})
+	return newSnapshot(i, resp.Snapshot), nil
+}
+
+// listSnapshots lists the automated and manual snapshots of the instance.
+func (d *dedicatedRedisAdapter) listSnapshots(i *RedisInstance) ([]base.Snapshot, error) {
+	snapshots := []base.Snapshot{}
+	err := d.elasticache.DescribeSnapshotsPages(&elasticache.DescribeSnapshotsInput{
+		ReplicationGroupId: aws.String(i.ClusterID),
+	}, func(page *elasticache.DescribeSnapshotsOutput, lastPage bool) bool {
+		for _, snapshot := range page.Snapshots {
+			snapshots = append(snapshots, newSnapshot(i, snapshot))
+		}
+		return true
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "describe-snapshots", err)
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// deleteSnapshot deletes a manual snapshot.
+func (d *dedicatedRedisAdapter) deleteSnapshot(snapshotName string) error {
+	_, err := d.elasticache.DeleteSnapshot(&elasticache.DeleteSnapshotInput{
+		SnapshotName: aws.String(snapshotName),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "delete-snapshot", err)
+		return err
+	}
+	d.logger.Info("redis-snapshot-deleted", lager.Data{"snapshot": snapshotName})
+	return nil
+}
@@ -0,0 +1,71 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/go-test/deep"
+
+	"github.com/18F/aws-broker/base"
+)
+
+func TestNewSnapshot(t *testing.T) {
+	created := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	i := &RedisInstance{ClusterID: "cluster"}
+	testCases := map[string]struct {
+		snapshot *elasticache.Snapshot
+		expected base.Snapshot
+	}{
+		"manual": {
+			snapshot: &elasticache.Snapshot{
+				SnapshotName:   aws.String("cluster-manual-pre-migration"),
+				SnapshotSource: aws.String("manual"),
+				SnapshotStatus: aws.String("available"),
+				NodeSnapshots: []*elasticache.NodeSnapshot{
+					{SnapshotCreateTime: aws.Time(created)},
+				},
+			},
+			expected: base.Snapshot{
+				Name:      "cluster-manual-pre-migration",
+				Type:      "manual",
+				Status:    "available",
+				CreatedAt: aws.Time(created),
+				Manual:    true,
+			},
+		},
+		"clone": {
+			snapshot: &elasticache.Snapshot{
+				SnapshotName:   aws.String("other-clone"),
+				SnapshotSource: aws.String("manual"),
+				SnapshotStatus: aws.String("creating"),
+			},
+			expected: base.Snapshot{
+				Name:   "other-clone",
+				Type:   "manual",
+				Status: "creating",
+			},
+		},
+		"automated": {
+			snapshot: &elasticache.Snapshot{
+				SnapshotName:   aws.String("automatic.cluster-2026-10-18"),
+				SnapshotSource: aws.String("automated"),
+				SnapshotStatus: aws.String("available"),
+			},
+			expected: base.Snapshot{
+				Name:   "automatic.cluster-2026-10-18",
+				Type:   "automated",
+				Status: "available",
+			},
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if diff := deep.Equal(newSnapshot(i, test.snapshot), test.expected); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
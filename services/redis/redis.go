@@ -9,18 +9,16 @@ import (
 	"github.com/18F/aws-broker/base"
 	"github.com/18F/aws-broker/catalog"
 	"github.com/18F/aws-broker/config"
+	"github.com/18F/aws-broker/helpers/logging"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/awsutil"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/elasticache"
 	"github.com/aws/aws-sdk-go/service/elasticache/elasticacheiface"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/jinzhu/gorm"
 
 	"bytes"
-	"fmt"
-	"log"
 )
 
 type redisAdapter interface {
@@ -29,6 +27,10 @@ type redisAdapter interface {
 	checkRedisStatus(i *RedisInstance) (base.InstanceState, error)
 	bindRedisToApp(i *RedisInstance, password string) (map[string]string, error)
 	deleteRedis(i *RedisInstance) (base.InstanceState, error)
+	describeRedis(i *RedisInstance) (*elasticache.ReplicationGroup, error)
+	createSnapshot(i *RedisInstance, name string, tags map[string]string) (base.Snapshot, error)
+	listSnapshots(i *RedisInstance) ([]base.Snapshot, error)
+	deleteSnapshot(snapshotName string) error
 }
 
 type mockRedisAdapter struct {
@@ -59,6 +61,28 @@ func (d *mockRedisAdapter) deleteRedis(i *RedisInstance) (base.InstanceState, er
 	return base.InstanceGone, nil
 }
 
+func (d *mockRedisAdapter) describeRedis(i *RedisInstance) (*elasticache.ReplicationGroup, error) {
+	// TODO
+	return &elasticache.ReplicationGroup{ReplicationGroupId: aws.String(i.ClusterID)}, nil
+}
+
+func (d *mockRedisAdapter) createSnapshot(i *RedisInstance, name string, tags map[string]string) (base.Snapshot, error) {
+	return base.Snapshot{
+		Name:   base.ManualSnapshotPrefix(i.ClusterID) + name,
+		Type:   "manual",
+		Status: "creating",
+		Manual: true,
+	}, nil
+}
+
+func (d *mockRedisAdapter) listSnapshots(i *RedisInstance) ([]base.Snapshot, error) {
+	return []base.Snapshot{}, nil
+}
+
+func (d *mockRedisAdapter) deleteSnapshot(snapshotName string) error {
+	return nil
+}
+
 type sharedRedisAdapter struct {
 	SharedRedisConn *gorm.DB
 }
@@ -88,19 +112,24 @@ type dedicatedRedisAdapter struct {
 	settings    config.Settings
 	logger      lager.Logger
 	elasticache elasticacheiface.ElastiCacheAPI
+	s3          s3iface.S3API
 }
 
 // This is the prefix for all pgroups created by the broker.
 const PgroupPrefix = "cg-redis-broker-"
 
 func (d *dedicatedRedisAdapter) createRedis(i *RedisInstance, password string) (base.InstanceState, error) {
+	// Clones are created once the snapshot of their source is available.
+	if i.ClonePending {
+		return d.snapshotCloneSource(i)
+	}
+
 	// Standard parameters
 	params := prepareCreateReplicationGroupInput(i, password)
 
 	resp, err := d.elasticache.CreateReplicationGroup(params)
 
-	// Pretty-print the response data.
-	log.Println(awsutil.StringValue(resp))
+	d.logger.Debug("create-replication-group", lager.Data{"output": resp})
 	// Decide if AWS service call was successful
 	if yes := d.didAwsCallSucceed(err); yes {
 		return base.InstanceInProgress, nil
@@ -108,6 +137,76 @@ func (d *dedicatedRedisAdapter) createRedis(i *RedisInstance, password string) (
 	return base.InstanceNotCreated, nil
 }
 
+// snapshotCloneSource takes the snapshot that a clone is created from.
+func (d *dedicatedRedisAdapter) snapshotCloneSource(i *RedisInstance) (base.InstanceState, error) {
+	_, err := d.elasticache.CreateSnapshot(&elasticache.CreateSnapshotInput{
+		ReplicationGroupId: aws.String(i.cloneSourceClusterID),
+		SnapshotName:       aws.String(i.CloneSnapshotName),
+		Tags:               ConvertTagsToElasticacheTags(i.Tags),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "create-snapshot", err)
+		// Remove a snapshot that was partially started before the failure.
+		d.deleteCloneSnapshot(i)
+		return base.InstanceNotCreated, err
+	}
+	return base.InstanceInProgress, nil
+}
+
+// checkCloneStatus creates a clone once the snapshot of its source is
+// available.
+func (d *dedicatedRedisAdapter) checkCloneStatus(i *RedisInstance) (base.InstanceState, error) {
+	resp, err := d.elasticache.DescribeSnapshots(&elasticache.DescribeSnapshotsInput{
+		SnapshotName: aws.String(i.CloneSnapshotName),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "describe-snapshots", err)
+		return base.InstanceNotCreated, err
+	}
+	if len(resp.Snapshots) == 0 {
+		return base.InstanceNotCreated, errors.New("Couldn't find the snapshot to clone from.")
+	}
+
+	status := aws.StringValue(resp.Snapshots[0].SnapshotStatus)
+	d.logger.Info("redis-clone-snapshot-status", lager.Data{
+		"snapshot": i.CloneSnapshotName,
+		"status":   status,
+	})
+	switch status {
+	case "available":
+		params := prepareCreateReplicationGroupInput(i, i.ClearPassword)
+		_, err := d.elasticache.CreateReplicationGroup(params)
+		if err != nil {
+			logging.LogAWSError(d.logger, "create-replication-group", err)
+			d.deleteCloneSnapshot(i)
+			return base.InstanceNotCreated, err
+		}
+		i.ClonePending = false
+		return base.InstanceInProgress, nil
+	case "failed":
+		d.deleteCloneSnapshot(i)
+		return base.InstanceNotCreated, nil
+	default:
+		return base.InstanceInProgress, nil
+	}
+}
+
+// deleteCloneSnapshot deletes the snapshot that a clone was created from. A
+// snapshot that no longer exists is treated as deleted.
+func (d *dedicatedRedisAdapter) deleteCloneSnapshot(i *RedisInstance) error {
+	_, err := d.elasticache.DeleteSnapshot(&elasticache.DeleteSnapshotInput{
+		SnapshotName: aws.String(i.CloneSnapshotName),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != elasticache.ErrCodeSnapshotNotFoundFault {
+			logging.LogAWSError(d.logger, "delete-snapshot", err)
+			return err
+		}
+	}
+	i.CloneSnapshotName = ""
+	return nil
+}
+
 func (d *dedicatedRedisAdapter) modifyRedis(i *RedisInstance, password string) (base.InstanceState, error) {
 	return base.InstanceNotModified, nil
 }
@@ -116,37 +215,39 @@ func (d *dedicatedRedisAdapter) checkRedisStatus(i *RedisInstance) (base.Instanc
 	// First, we need to check if the instance state
 	// Only search for details if the instance was not indicated as ready.
 	if i.State != base.InstanceReady {
+		if i.ClonePending {
+			return d.checkCloneStatus(i)
+		}
+
 		params := &elasticache.DescribeReplicationGroupsInput{
 			ReplicationGroupId: aws.String(i.ClusterID), // Required
 		}
 
 		resp, err := d.elasticache.DescribeReplicationGroups(params)
 		if err != nil {
-			if awsErr, ok := err.(awserr.Error); ok {
-				// Generic AWS error with Code, Message, and original error (if any)
-				fmt.Println(awsErr.Code(), awsErr.Message(), awsErr.OrigErr())
-				if reqErr, ok := err.(awserr.RequestFailure); ok {
-					// A service error occurred
-					fmt.Println(reqErr.Code(), reqErr.Message(), reqErr.StatusCode(), reqErr.RequestID())
-				}
-			} else {
-				// This case should never be hit, the SDK should always return an
-				// error which satisfies the awserr.Error interface.
-				fmt.Println(err.Error())
-			}
+			logging.LogAWSError(d.logger, "describe-replication-groups", err)
 			return base.InstanceNotCreated, err
 		}
 
 		numOfInstances := len(resp.ReplicationGroups)
 		if numOfInstances > 0 {
 			for _, value := range resp.ReplicationGroups {
-				fmt.Println("Redis Instance:" + i.ClusterID + " is " + *(value.Status))
+				d.logger.Info("redis-instance-status", lager.Data{
+					"cluster-id": i.ClusterID,
+					"status":     aws.StringValue(value.Status),
+				})
 				switch *(value.Status) {
 				case "available":
+					if i.CloneSnapshotName != "" {
+						d.deleteCloneSnapshot(i)
+					}
 					return base.InstanceReady, nil
 				case "creating":
 					return base.InstanceInProgress, nil
 				case "create-failed":
+					if i.CloneSnapshotName != "" {
+						d.deleteCloneSnapshot(i)
+					}
 					return base.InstanceNotCreated, nil
 				case "deleting":
 					return base.InstanceNotGone, nil
@@ -173,18 +274,7 @@ func (d *dedicatedRedisAdapter) bindRedisToApp(i *RedisInstance, password string
 
 		resp, err := d.elasticache.DescribeReplicationGroups(params)
 		if err != nil {
-			if awsErr, ok := err.(awserr.Error); ok {
-				// Generic AWS error with Code, Message, and original error (if any)
-				fmt.Println(awsErr.Code(), awsErr.Message(), awsErr.OrigErr())
-				if reqErr, ok := err.(awserr.RequestFailure); ok {
-					// A service error occurred
-					fmt.Println(reqErr.Code(), reqErr.Message(), reqErr.StatusCode(), reqErr.RequestID())
-				}
-			} else {
-				// This case should never be hit, the SDK should always return an
-				// error which satisfies the awserr.Error interface.
-				fmt.Println(err.Error())
-			}
+			logging.LogAWSError(d.logger, "describe-replication-groups", err)
 			return nil, err
 		}
 
@@ -194,7 +284,10 @@ func (d *dedicatedRedisAdapter) bindRedisToApp(i *RedisInstance, password string
 				// First check that the instance is up.
 				if value.Status != nil && *(value.Status) == "available" {
 					if value.NodeGroups[0].PrimaryEndpoint != nil && value.NodeGroups[0].PrimaryEndpoint.Address != nil && value.NodeGroups[0].PrimaryEndpoint.Port != nil {
-						fmt.Printf("host: %s port: %d \n", *(value.NodeGroups[0].PrimaryEndpoint.Address), *(value.NodeGroups[0].PrimaryEndpoint.Port))
+						d.logger.Debug("redis-instance-endpoint", lager.Data{
+							"host": *(value.NodeGroups[0].PrimaryEndpoint.Address),
+							"port": *(value.NodeGroups[0].PrimaryEndpoint.Port),
+						})
 						i.Port = *(value.NodeGroups[0].PrimaryEndpoint.Port)
 						i.Host = *(value.NodeGroups[0].PrimaryEndpoint.Address)
 						i.State = base.InstanceReady
@@ -216,6 +309,15 @@ func (d *dedicatedRedisAdapter) bindRedisToApp(i *RedisInstance, password string
 }
 
 func (d *dedicatedRedisAdapter) deleteRedis(i *RedisInstance) (base.InstanceState, error) {
+	// A clone that is still waiting on its source snapshot has no replication
+	// group yet; only the snapshot needs to be removed.
+	if i.ClonePending {
+		if err := d.deleteCloneSnapshot(i); err != nil {
+			return base.InstanceNotGone, err
+		}
+		return base.InstanceGone, nil
+	}
+
 	params := &elasticache.DeleteReplicationGroupInput{
 		ReplicationGroupId:      aws.String(i.ClusterID), // Required
 		FinalSnapshotIdentifier: aws.String(i.ClusterID + "-final"),
@@ -224,44 +326,44 @@ func (d *dedicatedRedisAdapter) deleteRedis(i *RedisInstance) (base.InstanceStat
 
 	// Decide if AWS service call was successful
 	if yes := d.didAwsCallSucceed(err); yes {
+		if i.CloneSnapshotName != "" {
+			d.deleteCloneSnapshot(i)
+		}
 		go d.exportRedisSnapshot(i)
 		return base.InstanceGone, nil
 	}
 	return base.InstanceNotGone, nil
 }
 
+func (d *dedicatedRedisAdapter) describeRedis(i *RedisInstance) (*elasticache.ReplicationGroup, error) {
+	resp, err := d.elasticache.DescribeReplicationGroups(&elasticache.DescribeReplicationGroupsInput{
+		ReplicationGroupId: aws.String(i.ClusterID),
+	})
+	if err != nil {
+		logging.LogAWSError(d.logger, "describe-replication-groups", err)
+		return nil, err
+	}
+	if len(resp.ReplicationGroups) == 0 {
+		return nil, errors.New("Couldn't find any instances.")
+	}
+	return resp.ReplicationGroups[0], nil
+}
+
 func (d *dedicatedRedisAdapter) didAwsCallSucceed(err error) bool {
 	// TODO Eventually return a formatted error object.
 	if err != nil {
-		if awsErr, ok := err.(awserr.Error); ok {
-			// Generic AWS Error with Code, Message, and original error (if any)
-			fmt.Println(awsErr.Code(), awsErr.Message(), awsErr.OrigErr())
-			if reqErr, ok := err.(awserr.RequestFailure); ok {
-				// A service error occurred
-				fmt.Println(reqErr.Code(), reqErr.Message(), reqErr.StatusCode(), reqErr.RequestID())
-			}
-		} else {
-			// This case should never be hit, The SDK should alwsy return an
-			// error which satisfies the awserr.Error interface.
-			fmt.Println(err.Error())
-		}
+		logging.LogAWSError(d.logger, "aws-call-failed", err)
 		return false
 	}
 	return true
 }
 
 func (d *dedicatedRedisAdapter) exportRedisSnapshot(i *RedisInstance) {
-	aws_session, err := session.NewSession(aws.NewConfig().WithRegion(d.settings.Region))
-	if success := d.didAwsCallSucceed(err); !success {
-		d.logger.Error("exportRedisSnapshot: aws.NewSession Failed", err)
-		return
-	}
 	path := i.OrganizationGUID + "/" + i.SpaceGUID + "/" + i.ServiceID + "/" + i.Uuid
 	bucket := d.settings.SnapshotsBucketName
-	s3_svc := s3.New(aws_session)
 	snapshot_name := i.ClusterID + "-final"
 	sleep := 30 * time.Second
-	d.logger.Info("exportRedisSnapshot: Waiting for Instance Snapshot to Complete", lager.Data{"uuid": i.Uuid})
+	d.logger.Info("exportRedisSnapshot: Waiting for Instance Snapshot to Complete", lager.Data{logging.InstanceGUIDKey: i.Uuid})
 	// poll for snapshot being available
 	check_input := &elasticache.DescribeSnapshotsInput{
 		SnapshotName: &snapshot_name,
@@ -269,7 +371,7 @@ func (d *dedicatedRedisAdapter) exportRedisSnapshot(i *RedisInstance) {
 	for {
 		resp, err := d.elasticache.DescribeSnapshots(check_input)
 		if success := d.didAwsCallSucceed(err); !success {
-			d.logger.Error("exportRedisSnapshot: Redis.DescribeSnapshots Failed", err, lager.Data{"uuid": i.Uuid})
+			d.logger.Error("exportRedisSnapshot: Redis.DescribeSnapshots Failed", err, lager.Data{logging.InstanceGUIDKey: i.Uuid})
 			return
 		}
 		if *(resp.Snapshots[0].SnapshotStatus) == "available" {
@@ -277,19 +379,19 @@ func (d *dedicatedRedisAdapter) exportRedisSnapshot(i *RedisInstance) {
 		}
 		time.Sleep(sleep)
 	}
-	d.logger.Info("exportRedisSnapshot: Exporting Instance Snapshot to s3", lager.Data{"uuid": i.Uuid})
+	d.logger.Info("exportRedisSnapshot: Exporting Instance Snapshot to s3", lager.Data{logging.InstanceGUIDKey: i.Uuid})
 	// export to s3 bucket so copy will autoexpire after 14 days
 	copy_input := &elasticache.CopySnapshotInput{
 		TargetBucket:       aws.String(bucket),
 		TargetSnapshotName: aws.String(path + "/" + snapshot_name),
 		SourceSnapshotName: aws.String(snapshot_name),
 	}
-	_, err = d.elasticache.CopySnapshot(copy_input)
+	_, err := d.elasticache.CopySnapshot(copy_input)
 	if success := d.didAwsCallSucceed(err); !success {
-		d.logger.Error("exportRedisSnapshot: Redis.CopySnapshot Failed", err, lager.Data{"uuid": i.Uuid})
+		d.logger.Error("exportRedisSnapshot: Redis.CopySnapshot Failed", err, lager.Data{logging.InstanceGUIDKey: i.Uuid})
 		return
 	}
-	d.logger.Info("exportRedisSnapshot: Writing Instance manisfest to s3", lager.Data{"uuid": i.Uuid})
+	d.logger.Info("exportRedisSnapshot: Writing Instance manisfest to s3", lager.Data{logging.InstanceGUIDKey: i.Uuid})
 	// write instance to manifest
 	// marshall instance to bytes.
 	data, err := json.Marshal(i)
@@ -304,14 +406,14 @@ func (d *dedicatedRedisAdapter) exportRedisSnapshot(i *RedisInstance) {
 		ServerSideEncryption: aws.String("AES256"),
 	}
 	// drop info to s3
-	_, err = s3_svc.PutObject(&input)
+	_, err = d.s3.PutObject(&input)
 	// Decide if AWS service call was successful
 	if success := d.didAwsCallSucceed(err); !success {
-		d.logger.Error("exportRedisSnapshot: S3.PutObject Failed", err, lager.Data{"uuid": i.Uuid})
+		d.logger.Error("exportRedisSnapshot: S3.PutObject Failed", err, lager.Data{logging.InstanceGUIDKey: i.Uuid})
 		return
 	}
 
-	d.logger.Info("exportRedisSnapshot: Waiting for Instance Snapshot Copy to Complete", lager.Data{"uuid": i.Uuid})
+	d.logger.Info("exportRedisSnapshot: Waiting for Instance Snapshot Copy to Complete", lager.Data{logging.InstanceGUIDKey: i.Uuid})
 	// poll for snapshot being available again before delete
 	check_input = &elasticache.DescribeSnapshotsInput{
 		SnapshotName: &snapshot_name,
@@ -319,7 +421,7 @@ func (d *dedicatedRedisAdapter) exportRedisSnapshot(i *RedisInstance) {
 	for {
 		resp, err := d.elasticache.DescribeSnapshots(check_input)
 		if success := d.didAwsCallSucceed(err); !success {
-			d.logger.Error("exportRedisSnapshot: Redis.DescribeSnapshots Failed", err, lager.Data{"uuid": i.Uuid})
+			d.logger.Error("exportRedisSnapshot: Redis.DescribeSnapshots Failed", err, lager.Data{logging.InstanceGUIDKey: i.Uuid})
 			return
 		}
 		if *(resp.Snapshots[0].SnapshotStatus) == "available" {
@@ -328,17 +430,17 @@ func (d *dedicatedRedisAdapter) exportRedisSnapshot(i *RedisInstance) {
 		time.Sleep(sleep)
 	}
 
-	d.logger.Info("exportRedisSnapshot: Deleting ElatiCache Service Snapshot", lager.Data{"uuid": i.Uuid})
+	d.logger.Info("exportRedisSnapshot: Deleting ElatiCache Service Snapshot", lager.Data{logging.InstanceGUIDKey: i.Uuid})
 	// now cleanup snapshot from ElastiCache
 	delete_input := &elasticache.DeleteSnapshotInput{
 		SnapshotName: aws.String(snapshot_name),
 	}
 	_, err = d.elasticache.DeleteSnapshot(delete_input)
 	if success := d.didAwsCallSucceed(err); !success {
-		d.logger.Error("Redis.DeleteSnapshot: Failed", err, lager.Data{"uuid": i.Uuid})
+		d.logger.Error("Redis.DeleteSnapshot: Failed", err, lager.Data{logging.InstanceGUIDKey: i.Uuid})
 		return
 	}
-	d.logger.Info("exportRedisSnapshot: Snapshot and Manifest backup to s3 Complete.", lager.Data{"uuid": i.Uuid})
+	d.logger.Info("exportRedisSnapshot: Snapshot and Manifest backup to s3 Complete.", lager.Data{logging.InstanceGUIDKey: i.Uuid})
 }
 
 func prepareCreateReplicationGroupInput(
@@ -374,5 +476,8 @@ func prepareCreateReplicationGroupInput(
 	if i.EngineVersion != "" {
 		params.EngineVersion = aws.String(i.EngineVersion)
 	}
+	if i.CloneSnapshotName != "" {
+		params.SnapshotName = aws.String(i.CloneSnapshotName)
+	}
 	return params
 }
@@ -3,11 +3,54 @@ package redis
 import (
 	"testing"
 
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/18F/aws-broker/base"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/elasticache/elasticacheiface"
 	"github.com/go-test/deep"
 )
 
+type mockElasticacheClientForCloneTests struct {
+	elasticacheiface.ElastiCacheAPI
+
+	createSnapshotErr         error
+	snapshotStatus            string
+	createReplicationGroupErr error
+	deleteSnapshotErr         error
+
+	deletedSnapshots         []string
+	deletedReplicationGroups []string
+}
+
+func (m *mockElasticacheClientForCloneTests) CreateSnapshot(*elasticache.CreateSnapshotInput) (*elasticache.CreateSnapshotOutput, error) {
+	return nil, m.createSnapshotErr
+}
+
+func (m *mockElasticacheClientForCloneTests) DescribeSnapshots(*elasticache.DescribeSnapshotsInput) (*elasticache.DescribeSnapshotsOutput, error) {
+	return &elasticache.DescribeSnapshotsOutput{
+		Snapshots: []*elasticache.Snapshot{{SnapshotStatus: aws.String(m.snapshotStatus)}},
+	}, nil
+}
+
+func (m *mockElasticacheClientForCloneTests) CreateReplicationGroup(*elasticache.CreateReplicationGroupInput) (*elasticache.CreateReplicationGroupOutput, error) {
+	return nil, m.createReplicationGroupErr
+}
+
+func (m *mockElasticacheClientForCloneTests) DeleteSnapshot(input *elasticache.DeleteSnapshotInput) (*elasticache.DeleteSnapshotOutput, error) {
+	if m.deleteSnapshotErr != nil {
+		return nil, m.deleteSnapshotErr
+	}
+	m.deletedSnapshots = append(m.deletedSnapshots, aws.StringValue(input.SnapshotName))
+	return nil, nil
+}
+
+func (m *mockElasticacheClientForCloneTests) DeleteReplicationGroup(input *elasticache.DeleteReplicationGroupInput) (*elasticache.DeleteReplicationGroupOutput, error) {
+	m.deletedReplicationGroups = append(m.deletedReplicationGroups, aws.StringValue(input.ReplicationGroupId))
+	return nil, nil
+}
+
 func TestPrepareCreateReplicationGroupInput(t *testing.T) {
 	testCases := map[string]struct {
 		redisInstance  *RedisInstance
@@ -101,6 +144,37 @@ func TestPrepareCreateReplicationGroupInput(t *testing.T) {
 				EngineVersion: aws.String("7.0"),
 			},
 		},
+		"sets snapshot name of clones": {
+			redisInstance: &RedisInstance{
+				Description:       "description",
+				ClusterID:         "cluster-1",
+				CacheNodeType:     "node-type",
+				DbSubnetGroup:     "db-group-1",
+				SecGroup:          "sec-group-1",
+				NumCacheClusters:  2,
+				CloneSnapshotName: "cluster-1-clone",
+			},
+			password: "fake-password",
+			expectedParams: &elasticache.CreateReplicationGroupInput{
+				AtRestEncryptionEnabled:     aws.Bool(true),
+				TransitEncryptionEnabled:    aws.Bool(true),
+				AutoMinorVersionUpgrade:     aws.Bool(true),
+				ReplicationGroupDescription: aws.String("description"),
+				AuthToken:                   aws.String("fake-password"),
+				AutomaticFailoverEnabled:    aws.Bool(false),
+				ReplicationGroupId:          aws.String("cluster-1"),
+				CacheNodeType:               aws.String("node-type"),
+				CacheSubnetGroupName:        aws.String("db-group-1"),
+				SecurityGroupIds:            []*string{aws.String("sec-group-1")},
+				Engine:                      aws.String("redis"),
+				NumCacheClusters:            aws.Int64(int64(2)),
+				Port:                        aws.Int64(6379),
+				PreferredMaintenanceWindow:  aws.String(""),
+				SnapshotWindow:              aws.String(""),
+				SnapshotRetentionLimit:      aws.Int64(int64(0)),
+				SnapshotName:                aws.String("cluster-1-clone"),
+			},
+		},
 	}
 	for name, test := range testCases {
 		t.Run(name, func(t *testing.T) {
@@ -114,3 +188,78 @@ func TestPrepareCreateReplicationGroupInput(t *testing.T) {
 		})
 	}
 }
+
+func TestCloneSnapshotCleanup(t *testing.T) {
+	testCases := map[string]struct {
+		client                   *mockElasticacheClientForCloneTests
+		run                      func(*dedicatedRedisAdapter, *RedisInstance) (base.InstanceState, error)
+		expectedState            base.InstanceState
+		expectedDeletedSnapshots []string
+		expectedSnapshotName     string
+	}{
+		"create snapshot fails": {
+			client: &mockElasticacheClientForCloneTests{
+				createSnapshotErr: awserr.New(elasticache.ErrCodeSnapshotQuotaExceededFault, "quota", nil),
+				deleteSnapshotErr: awserr.New(elasticache.ErrCodeSnapshotNotFoundFault, "not found", nil),
+			},
+			run:           (*dedicatedRedisAdapter).snapshotCloneSource,
+			expectedState: base.InstanceNotCreated,
+		},
+		"snapshot failed": {
+			client:                   &mockElasticacheClientForCloneTests{snapshotStatus: "failed"},
+			run:                      (*dedicatedRedisAdapter).checkCloneStatus,
+			expectedState:            base.InstanceNotCreated,
+			expectedDeletedSnapshots: []string{"cluster-clone"},
+		},
+		"restore fails": {
+			client: &mockElasticacheClientForCloneTests{
+				snapshotStatus:            "available",
+				createReplicationGroupErr: awserr.New(elasticache.ErrCodeInsufficientCacheClusterCapacityFault, "capacity", nil),
+			},
+			run:                      (*dedicatedRedisAdapter).checkCloneStatus,
+			expectedState:            base.InstanceNotCreated,
+			expectedDeletedSnapshots: []string{"cluster-clone"},
+		},
+		"deleted while pending": {
+			client:                   &mockElasticacheClientForCloneTests{},
+			run:                      (*dedicatedRedisAdapter).deleteRedis,
+			expectedState:            base.InstanceGone,
+			expectedDeletedSnapshots: []string{"cluster-clone"},
+		},
+		"deleted while the snapshot is being taken": {
+			client: &mockElasticacheClientForCloneTests{
+				deleteSnapshotErr: awserr.New(elasticache.ErrCodeInvalidSnapshotStateFault, "creating", nil),
+			},
+			run:                  (*dedicatedRedisAdapter).deleteRedis,
+			expectedState:        base.InstanceNotGone,
+			expectedSnapshotName: "cluster-clone",
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			adapter := &dedicatedRedisAdapter{
+				logger:      lagertest.NewTestLogger(name),
+				elasticache: test.client,
+			}
+			i := &RedisInstance{
+				ClusterID:         "cluster",
+				ClonePending:      true,
+				CloneSnapshotName: "cluster-clone",
+			}
+			state, _ := test.run(adapter, i)
+			if state != test.expectedState {
+				t.Errorf("expected state %d, got %d", test.expectedState, state)
+			}
+			if diff := deep.Equal(test.client.deletedSnapshots, test.expectedDeletedSnapshots); diff != nil {
+				t.Error(diff)
+			}
+			if i.CloneSnapshotName != test.expectedSnapshotName {
+				t.Errorf("expected snapshot name %q, got %q", test.expectedSnapshotName, i.CloneSnapshotName)
+			}
+			if len(test.client.deletedReplicationGroups) > 0 {
+				t.Errorf("unexpected replication group deletion: %v", test.client.deletedReplicationGroups)
+			}
+		})
+	}
+}
@@ -44,6 +44,14 @@ type RedisInstance struct {
 
 	EngineLogsGroupName string `sql:"size(512)"`
 	SlowLogsGroupName   string `sql:"size(512)"`
+
+	// ClonedFrom is the instance that this instance is a clone of, if any.
+	// The clone is created from CloneSnapshotName, a snapshot of the source
+	// that is taken while ClonePending and deleted once the clone is available.
+	ClonedFrom           string `sql:"size(255)"`
+	CloneSnapshotName    string `sql:"size(255)"`
+	ClonePending         bool   `sql:"size(255)"`
+	cloneSourceClusterID string `sql:"-"`
 }
 
 func (i *RedisInstance) setPassword(password, key string) error {
@@ -147,11 +155,23 @@ func (i *RedisInstance) init(
 	return nil
 }
 
+// setCloneSource sets the instance to be created from a new snapshot of the
+// source instance.
+func (i *RedisInstance) setCloneSource(source *RedisInstance) {
+	i.ClonedFrom = source.Uuid
+	i.CloneSnapshotName = i.ClusterID + "-clone"
+	i.ClonePending = true
+	i.cloneSourceClusterID = source.ClusterID
+}
+
 func (i *RedisInstance) setTags(
 	plan catalog.RedisPlan,
 	tags map[string]string,
 ) error {
 	i.Tags = plan.Tags
+	if i.Tags == nil {
+		i.Tags = make(map[string]string)
+	}
 
 	for k, v := range tags {
 		i.Tags[k] = v